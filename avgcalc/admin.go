@@ -0,0 +1,104 @@
+package avgcalc
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// effectiveConfig is the response of GET /admin/config: the settings in
+// force right now, including changes made at runtime.
+type effectiveConfig struct {
+	Listen           string `json:"listen"`
+	GRPCListen       string `json:"grpcListen,omitempty"`
+	NumberServiceURL string `json:"numberServiceURL"`
+	TimeoutMs        int64  `json:"timeoutMs"`
+	Retries          int    `json:"retries"`
+	RetryBackoffMs   int64  `json:"retryBackoffMs"`
+	DeadlineMs       int64  `json:"deadlineMs"`
+	WindowSize       int    `json:"windowSize"`
+	WindowSizeMax    int    `json:"windowSizeMax"`
+	WindowMode       string `json:"windowMode"`
+	WindowDuration   string `json:"windowDuration,omitempty"`
+	PerClientWindows bool   `json:"perClientWindows"`
+	ClientWindowTTL  string `json:"clientWindowTTL,omitempty"`
+	HistorySize      int    `json:"windowHistorySize"`
+}
+
+func (s *calculator) getConfig(c *gin.Context) {
+	cfg := s.cfg
+	resp := effectiveConfig{
+		Listen:           cfg.Listen,
+		GRPCListen:       cfg.GRPCListen,
+		NumberServiceURL: s.baseURL,
+		TimeoutMs:        s.timeout().Milliseconds(),
+		Retries:          s.client.Retries,
+		RetryBackoffMs:   s.client.Backoff.Milliseconds(),
+		DeadlineMs:       cfg.Deadline.Milliseconds(),
+		WindowSize:       s.windows.Size(),
+		WindowSizeMax:    cfg.WindowSizeMax,
+		PerClientWindows: cfg.PerClientWindows,
+		HistorySize:      cfg.HistorySize,
+	}
+	if cfg.PerClientWindows {
+		resp.ClientWindowTTL = cfg.ClientWindowTTL.String()
+	}
+	resp.WindowMode = "count"
+	if cfg.WindowDuration > 0 {
+		resp.WindowMode = "time"
+		resp.WindowDuration = cfg.WindowDuration.String()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// windowResize is the body of PUT /admin/config/window.
+type windowResize struct {
+	Size *int `json:"size"`
+}
+
+// windowResized is the response of PUT /admin/config/window. Numbers is
+// only sent for the shared window.
+type windowResized struct {
+	WindowSize   int       `json:"windowSize"`
+	PreviousSize int       `json:"previousSize"`
+	Numbers      []float64 `json:"numbers,omitempty"`
+}
+
+// putWindow resizes the windows to {"size": N}. The new size lasts until
+// the next restart. The shared window's numbers are returned; client
+// windows are private to their clients.
+func (s *calculator) putWindow(c *gin.Context) {
+	if s.cfg.WindowDuration > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The window is time based and has no size to change"})
+		return
+	}
+
+	var body windowResize
+	if err := c.ShouldBindJSON(&body); err != nil || body.Size == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": `Body must be {"size": N}`})
+		return
+	}
+	if *body.Size < 1 || *body.Size > s.cfg.WindowSizeMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Size must be between 1 and %d", s.cfg.WindowSizeMax)})
+		return
+	}
+
+	prev := s.windows.Size()
+	if err := s.windows.Resize(*body.Size); err != nil {
+		s.abortStoreUnavailable(c, err)
+		return
+	}
+	s.logRequest(c.Request.Context(), slog.LevelInfo, "Window resized", slog.Int("from", prev), slog.Int("to", *body.Size))
+	resp := windowResized{WindowSize: *body.Size, PreviousSize: prev}
+	if !s.windows.perClient {
+		numbers, err := s.windows.shared.GetCurrentState()
+		if err != nil {
+			s.abortStoreUnavailable(c, err)
+			return
+		}
+		resp.Numbers = numbers
+	}
+	c.JSON(http.StatusOK, resp)
+}
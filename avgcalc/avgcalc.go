@@ -0,0 +1,682 @@
+// Package avgcalc is the average calculator: it keeps a sliding window of
+// unique numbers fetched from the test server and reports their average.
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+	"github.com/Escanor244/713522IT013/internal/buildinfo"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+	"github.com/Escanor244/713522IT013/internal/statuspage"
+	"github.com/Escanor244/713522IT013/internal/upstream"
+)
+
+const (
+	WindowSize       = 10
+	MaxWindowSize    = 10000
+	WindowDuration   = 60 * time.Second
+	ClientWindowTTL  = 15 * time.Minute
+	APITimeoutMs     = 500
+	APIRetries       = 2
+	APIRetryBackoff  = 50 * time.Millisecond
+	APIDeadline      = 500 * time.Millisecond
+	NumberServiceURL = "http://20.244.56.144/test"
+)
+
+// statusClientClosedRequest is logged for a request the client gave up on
+// before it was answered, after nginx.
+const statusClientClosedRequest = 499
+
+var tracer = otel.Tracer("github.com/Escanor244/713522IT013/avgcalc")
+
+type NumberResponse struct {
+	Numbers []float64 `json:"numbers"`
+}
+
+// APIResponse is the response of the /numbers endpoints. The statistics
+// describe windowCurrState and are all 0 when it is empty.
+type APIResponse struct {
+	WindowPrevState []float64 `json:"windowPrevState"`
+	WindowCurrState []float64 `json:"windowCurrState"`
+	Numbers         []float64 `json:"numbers"`
+	Average         Fixed2    `json:"avg"`
+	TrimmedAverage  Fixed2    `json:"trimmedAvg"`
+	Median          float64   `json:"median"`
+	Min             float64   `json:"min"`
+	Max             float64   `json:"max"`
+	StdDev          float64   `json:"stddev"`
+
+	// WindowCount is len(windowCurrState) and IsFull whether it has reached
+	// WindowSize, past which adding a number evicts the oldest.
+	WindowCount int  `json:"windowCount"`
+	WindowSize  int  `json:"windowSize"`
+	IsFull      bool `json:"isFull"`
+
+	// Accepted are the numbers that entered the window. The other
+	// DuplicatesDiscarded were already in it.
+	Accepted            []float64 `json:"accepted"`
+	DuplicatesDiscarded int       `json:"duplicatesDiscarded"`
+
+	// RejectedOutliers are the numbers the outlier filter kept out of the
+	// window, only sent when there were some.
+	RejectedOutliers []float64 `json:"rejectedOutliers,omitempty"`
+
+	// EMAAverage is the exponential moving average, only sent with
+	// avgMode=ema.
+	EMAAverage *float64 `json:"emaAvg,omitempty"`
+
+	// WeightedAverage weighs the newer numbers of windowCurrState more,
+	// only sent with avgMode=weighted.
+	WeightedAverage *float64 `json:"weightedAvg,omitempty"`
+
+	// Failures has the error for each number type that couldn't be
+	// fetched when several were asked for and some arrived.
+	Failures map[string]string `json:"failures,omitempty"`
+
+	// Attempts is how many calls each number type took, only sent when
+	// one of them had to be retried.
+	Attempts map[string]int `json:"attempts,omitempty"`
+
+	// UpstreamUnavailable is set when the number service wasn't called
+	// because its circuit is open. The window is then returned unchanged
+	// and numbers is null.
+	UpstreamUnavailable bool `json:"upstreamUnavailable,omitempty"`
+
+	// TimedOut is set when the number service didn't answer any type in
+	// time. The window is then returned unchanged and numbers is empty;
+	// numbers arriving late are dropped.
+	TimedOut bool `json:"timedOut,omitempty"`
+
+	// Source is "local" when some numbers were made up by a fallback
+	// generator because the number service failed.
+	Source string `json:"source,omitempty"`
+
+	// CacheHit is set when every number type was served from the cache
+	// without calling the number service.
+	CacheHit bool `json:"cacheHit"`
+
+	// Stale is set when a number type couldn't be fetched and its last
+	// numbers were used instead, StaleAgeSeconds old for the oldest.
+	Stale           bool `json:"stale,omitempty"`
+	StaleAgeSeconds *int `json:"staleAgeSeconds,omitempty"`
+
+	// TimeoutMs is the timeout each call to the number service had, only
+	// sent when the request asked for one. TimeoutClamped is set when it
+	// was out of bounds and TimeoutWarning when it couldn't be read, and
+	// the default was used.
+	TimeoutMs      *int64 `json:"timeoutMs,omitempty"`
+	TimeoutClamped bool   `json:"timeoutClamped,omitempty"`
+	TimeoutWarning string `json:"timeoutWarning,omitempty"`
+
+	// Meta is how the number service answered, only sent by
+	// GET /numbers/:numberid.
+	Meta *ResponseMeta `json:"meta,omitempty"`
+}
+
+// ResponseMeta tells the time spent waiting for the number service from
+// the rest. UpstreamLatencyMs is that of the slowest type, UpstreamStatus
+// the worst status it answered, omitted if it didn't answer, and Attempts
+// the calls made for all types. Types has the same for each type when
+// several were asked for. Types served from the cache count for nothing.
+type ResponseMeta struct {
+	RequestID         string  `json:"requestId,omitempty"`
+	UpstreamLatencyMs float64 `json:"upstreamLatencyMs"`
+	UpstreamStatus    int     `json:"upstreamStatus,omitempty"`
+	Attempts          int     `json:"attempts"`
+
+	Types map[string]UpstreamMeta `json:"types,omitempty"`
+}
+
+// UpstreamMeta is how the number service answered for one number type.
+type UpstreamMeta struct {
+	UpstreamLatencyMs float64 `json:"upstreamLatencyMs"`
+	UpstreamStatus    int     `json:"upstreamStatus,omitempty"`
+	Attempts          int     `json:"attempts"`
+	Cached            bool    `json:"cached,omitempty"`
+}
+
+// addNumbers adds numbers of types to store and describes the result, with
+// the average asked for by avg.
+func (s *calculator) addNumbers(store WindowStore, types []string, numbers []float64, avg averaging) (APIResponse, error) {
+	if !avg.ema {
+		added, err := store.AddNumbers(numbers, types...)
+		if err != nil {
+			return APIResponse{}, err
+		}
+		resp := newAPIResponse(added, numbers)
+		if avg.weighted {
+			weighted := weightedAverage(added.Current, s.cfg.WeightedAvgWeights)
+			resp.WeightedAverage = &weighted
+		}
+		return resp, nil
+	}
+	added, err := store.AddNumbersEMA(numbers, avg.alpha, types...)
+	if err != nil {
+		return APIResponse{}, err
+	}
+	resp := newAPIResponse(added, numbers)
+	resp.EMAAverage = &added.EMA
+	return resp, nil
+}
+
+// newAPIResponse describes adding numbers to a window.
+func newAPIResponse(added Added, numbers []float64) APIResponse {
+	return APIResponse{
+		WindowPrevState:     added.Prev,
+		WindowCurrState:     added.Current,
+		Numbers:             numbers,
+		Average:             Fixed2(added.Stats.Average),
+		TrimmedAverage:      Fixed2(added.Stats.TrimmedMean),
+		Median:              added.Stats.Median,
+		Min:                 added.Stats.Min,
+		Max:                 added.Stats.Max,
+		StdDev:              added.Stats.StdDev,
+		WindowCount:         len(added.Current),
+		WindowSize:          added.Size,
+		IsFull:              len(added.Current) >= added.Size,
+		Accepted:            added.Accepted,
+		DuplicatesDiscarded: added.Skipped,
+		RejectedOutliers:    added.Rejected,
+	}
+}
+
+// degradedResponse describes the window without adding to it, for when
+// the number service isn't being called or didn't answer in time.
+func (s *calculator) degradedResponse(authToken string) (APIResponse, error) {
+	store := s.windows.shared
+	if s.windows.perClient {
+		store = s.windows.existing(authToken)
+	}
+	current, stats, err := store.Stats()
+	if err != nil {
+		return APIResponse{}, err
+	}
+	resp := newAPIResponse(Added{
+		Prev:     current,
+		Current:  current,
+		Stats:    stats,
+		Size:     store.Size(),
+		Accepted: []float64{},
+	}, nil)
+	return resp, nil
+}
+
+// windowReset is the response of DELETE /window.
+type windowReset struct {
+	Discarded WindowResponse `json:"discarded"`
+}
+
+// WindowResponse is the response of GET /window. LastUpdated is null until
+// the first successful fetch.
+type WindowResponse struct {
+	WindowCurrState []float64  `json:"windowCurrState"`
+	Average         Fixed2     `json:"avg"`
+	Count           int        `json:"count"`
+	LastUpdated     *time.Time `json:"lastUpdated"`
+
+	// WindowVersion counts the changes to the window, only sent by
+	// GET /window.
+	WindowVersion *uint64 `json:"windowVersion,omitempty"`
+}
+
+// serviceURL is the URL of path, such as "primes", on the number service
+// at base, whatever slashes either has and keeping base's query.
+func serviceURL(base, path string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return strings.TrimRight(base, "/") + "/" + path
+	}
+	return u.JoinPath(path).String()
+}
+
+// newNumbersTransport returns the pooled transport the number service is
+// called through. Every request calls it, up to once per number type, so
+// enough idle connections are kept for a burst not to redial, and dialing
+// and the TLS handshake are cut short so they can't use up the timeout
+// alone.
+func newNumbersTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   250 * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = 250 * time.Millisecond
+	transport.MaxIdleConns = 64
+	transport.MaxIdleConnsPerHost = 32
+	transport.IdleConnTimeout = 90 * time.Second
+	return transport
+}
+
+// upstreamCall is how the number service answered a fetch: after how long,
+// with what status, 0 if it didn't answer, and in how many calls.
+type upstreamCall struct {
+	latency  time.Duration
+	status   int
+	attempts int
+}
+
+// fetchNumbers fetches the numbers of numberType, returning how the number
+// service answered. The fetch, retries included, is bounded by the
+// configured deadline, or the call timeout if a request asked for a longer
+// one, so a struggling number service can't hold a request past its
+// latency budget. Each call gets what is left of it at most, and retries
+// that wouldn't finish in time aren't started.
+func (s *calculator) fetchNumbers(ctx context.Context, numberType string, authToken string) ([]float64, upstreamCall, error) {
+	ctx, span := tracer.Start(ctx, "fetchNumbers", trace.WithAttributes(attribute.String("number.type", numberType)))
+	defer span.End()
+	if !s.breaker.allow(numberType) {
+		span.SetAttributes(attribute.Bool("circuit.open", true))
+		return nil, upstreamCall{}, errCircuitOpen
+	}
+	ctx, cancel := context.WithTimeout(ctx, max(s.cfg.Deadline, callTimeout(ctx)))
+	defer cancel()
+
+	start := time.Now()
+	numbers, attempts, err := s.fetcher.Fetch(ctx, numberType, authToken)
+	latency := time.Since(start)
+	span.SetAttributes(attribute.Int("fetch.attempts", attempts))
+
+	// Successful fetches are only worth a line when debugging, or when
+	// they took retries.
+	level, msg := slog.LevelDebug, "Fetched numbers"
+	upstreamStatus := http.StatusOK
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		level, msg, upstreamStatus = slog.LevelWarn, "Failed to fetch numbers", 0
+		var statusErr *upstream.StatusError
+		if errors.As(err, &statusErr) {
+			upstreamStatus = statusErr.Code
+		}
+	} else if attempts > 1 {
+		level = slog.LevelInfo
+	}
+	attrs := []slog.Attr{
+		slog.String("numberType", numberType),
+		slog.Int("attempts", attempts),
+		slog.Float64("upstreamLatencyMs", milliseconds(latency)),
+	}
+	if upstreamStatus != 0 {
+		attrs = append(attrs, slog.Int("upstreamStatus", upstreamStatus))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	s.logRequest(ctx, level, msg, attrs...)
+	s.breaker.record(numberType, err)
+	if !errors.Is(err, context.Canceled) {
+		s.lastFetch.record(numberType, err)
+		s.ready.record(err)
+		s.counters.fetched(numberType, err)
+	}
+	return numbers, upstreamCall{latency: latency, status: upstreamStatus, attempts: attempts}, err
+}
+
+// milliseconds is d in fractional milliseconds, to the microsecond.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// fetchOutcome is how the latest call to the number service went, for the
+// status page.
+type fetchOutcome struct {
+	mu         sync.Mutex
+	at         time.Time
+	numberType string
+	status     int
+}
+
+func (f *fetchOutcome) record(numberType string, err error) {
+	status := http.StatusOK
+	if err != nil {
+		status = apperr.HTTPStatus(err)
+	}
+	f.mu.Lock()
+	f.at, f.numberType, f.status = time.Now(), numberType, status
+	f.mu.Unlock()
+}
+
+// parseNumbers reads a POST /numbers body, {"numbers": [1, 2, 3]}.
+func parseNumbers(body io.Reader) ([]float64, error) {
+	var req struct {
+		Numbers []json.RawMessage `json:"numbers"`
+	}
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return nil, fmt.Errorf(`Body must be {"numbers": [...]}: %v`, err)
+	}
+	if len(req.Numbers) == 0 {
+		return nil, errors.New("At least one number is required")
+	}
+
+	numbers := make([]float64, len(req.Numbers))
+	for i, raw := range req.Numbers {
+		if err := json.Unmarshal(raw, &numbers[i]); err != nil {
+			return nil, fmt.Errorf("numbers[%d] must be a number, got %s", i, raw)
+		}
+	}
+	return numbers, nil
+}
+
+// bearerToken returns the request's bearer token, answering 401 when there
+// isn't one.
+func bearerToken(c *gin.Context) (string, bool) {
+	token, err := authorization(c)
+	if err != nil {
+		c.JSON(err.status, gin.H{"error": err.message})
+		return "", false
+	}
+	return token, true
+}
+
+// authorization returns the request's bearer token.
+func authorization(c *gin.Context) (string, *requestError) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return "", &requestError{http.StatusUnauthorized, CodeMissingAuthorization, "Missing authorization header"}
+	}
+
+	if len(authHeader) <= 7 || authHeader[:7] != "Bearer " {
+		return "", &requestError{http.StatusUnauthorized, CodeMalformedAuthorization, "Invalid authorization header format. Use 'Bearer <token>'"}
+	}
+
+	return authHeader[7:], nil
+}
+
+// Run serves the average calculator until ctx is cancelled.
+func Run(ctx context.Context, cfg Config) error {
+	if cfg.MockUpstream != nil {
+		url, err := cfg.MockUpstream.start(ctx, serviceLogger(cfg))
+		if err != nil {
+			return fmt.Errorf("failed to start the mock number service: %w", err)
+		}
+		cfg.NumberServiceURL = url
+	}
+	s := newCalculator(cfg)
+	if cfg.MockUpstream != nil {
+		s.logger.Warn("Calling the mock number service instead of the real one", "url", cfg.NumberServiceURL)
+	}
+	if cfg.Redis != nil {
+		if err := cfg.Redis.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("failed to reach Redis: %w", err)
+		}
+		defer cfg.Redis.Close()
+	}
+	state := &windowState{path: cfg.StateFile, maxAge: cfg.StateMaxAge, interval: cfg.StateSaveInterval, logger: s.logger}
+	state.load(s.windows)
+	go state.run(ctx, s.windows)
+	go s.windows.runEviction(ctx)
+	go s.credentials.run(ctx)
+	router := s.router(ctx)
+
+	buildinfo.Log("avg")
+	ln, err := cfg.Server.Listen(cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", cfg.Listen, err)
+	}
+	grpcDone := make(chan struct{})
+	if cfg.GRPCListen != "" {
+		grpcLn, err := net.Listen("tcp", cfg.GRPCListen)
+		if err != nil {
+			ln.Close()
+			return fmt.Errorf("failed to listen on %s: %w", cfg.GRPCListen, err)
+		}
+		go func() {
+			defer close(grpcDone)
+			if err := s.serveGRPC(ctx, grpcLn); err != nil {
+				s.logger.Error("gRPC server stopped", "error", err)
+			}
+		}()
+	} else {
+		close(grpcDone)
+	}
+	if cfg.RedirectListen != "" {
+		_, port, _ := net.SplitHostPort(cfg.Listen)
+		go func() {
+			if err := cfg.Server.Redirect(ctx, "avg", cfg.RedirectListen, port); err != nil {
+				s.logger.Error("HTTPS redirect stopped", "error", err)
+			}
+		}()
+	}
+	err = cfg.Server.Serve(ctx, "avg", cfg.Server.Server(router), ln)
+	// The server doesn't track WebSockets, so they are waited for here,
+	// to see their clients told of the shutdown.
+	s.openWS.Wait()
+	<-grpcDone
+	state.save(s.windows)
+	return err
+}
+
+// router routes the calculator's API to the handlers. It registers the
+// metrics with cfg.Metrics, so it is called once per registry. Streams end
+// when ctx is cancelled.
+func (s *calculator) router(ctx context.Context) *gin.Engine {
+	cfg, windows, numberTypes := s.cfg, s.windows, s.numberTypes
+	router := gin.New()
+	router.Use(otelgin.Middleware("avg"))
+	router.Use(cfg.HTTP.Chain("avg")...)
+	router.GET("/version", buildinfo.Handler("avg"))
+	router.GET("/healthz", getHealth)
+	router.GET("/readyz", s.getReady)
+	router.GET("/numbertypes", getNumberTypes(numberTypes))
+	router.GET("/stats", s.serveStats(false))
+	router.DELETE("/stats", middleware.AdminAuth(cfg.AdminToken), s.serveStats(true))
+	spec := openAPIDocument(operations)
+	router.GET("/openapi.json", func(c *gin.Context) { c.JSON(http.StatusOK, spec) })
+	router.GET("/docs", cfg.HTTP.Security.ContentPolicy(docsPolicy), getDocs)
+	registry := cfg.Metrics
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+	registerMetrics(registry, windows)
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+	router.GET("/", cfg.HTTP.Security.ContentPolicy(middleware.DashboardPolicy),
+		statuspage.Handler("avg", cfg.Listen, s.vitals))
+
+	admin := router.Group("/admin", middleware.AdminAuth(cfg.AdminToken))
+	admin.GET("/config", s.getConfig)
+	admin.PUT("/config/window", s.putWindow)
+	admin.GET("/breakers", s.getBreakers)
+
+	// GET /numbers/p,f,e fetches several types concurrently and adds
+	// whatever arrived in one update.
+	router.GET("/numbers/:numberid", s.countRequests, s.validateToken, func(c *gin.Context) {
+		types, valid := numberTypes.parse(c.Param("numberid"))
+		if valid {
+			middleware.LogField(c, "numberType", strings.Join(types, ","))
+		}
+
+		// In managed mode the number service gets our own token, and the
+		// client's, if it sends one, only picks its window.
+		authToken, authErr := authorization(c)
+		if authErr != nil && !(s.credentials.enabled && authErr.code == CodeMissingAuthorization) {
+			abortWithError(c, authErr.status, ErrorBody{Code: authErr.code, Message: authErr.message})
+			return
+		}
+		fetchToken := authToken
+		if s.credentials.enabled {
+			fetchToken = ""
+		}
+		if !valid {
+			abortWithError(c, http.StatusBadRequest, numberTypes.invalid())
+			return
+		}
+		avg, err := avgMode(c)
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, ErrorBody{Code: CodeInvalidAvgMode, Message: err.Error()})
+			return
+		}
+		override, timed := s.requestTimeout(c)
+		if override.warning != "" {
+			s.logRequest(c.Request.Context(), slog.LevelWarn, "Ignored the timeout asked for", slog.String("warning", override.warning))
+		}
+		var timeout time.Duration
+		if timed && override.warning == "" {
+			timeout = override.timeout
+			middleware.LogField(c, "timeoutMs", timeout.Milliseconds())
+		}
+
+		result := s.getNumbers(c.Request.Context(), numbersRequest{
+			types:      types,
+			authToken:  authToken,
+			fetchToken: fetchToken,
+			avg:        avg,
+			timeout:    timeout,
+			route:      c.FullPath(),
+		})
+		if result.canceled {
+			c.AbortWithStatus(statusClientClosedRequest)
+			return
+		}
+		middleware.LogField(c, "upstreamAttempts", result.attempts)
+		middleware.LogField(c, "cacheHit", result.cached)
+		var timeoutMs *int64
+		if timed {
+			ms := override.timeout.Milliseconds()
+			timeoutMs = &ms
+		}
+		if result.status != http.StatusOK {
+			body := result.err
+			body.TimeoutMs, body.TimeoutClamped, body.TimeoutWarning = timeoutMs, override.clamped, override.warning
+			body.Meta = result.meta
+			abortWithError(c, result.status, body)
+			return
+		}
+		c.Set(duplicatesKey, result.resp.DuplicatesDiscarded)
+		middleware.LogField(c, "windowCount", result.resp.WindowCount)
+		middleware.LogField(c, "windowSize", result.resp.WindowSize)
+		resp := result.resp
+		resp.TimeoutMs, resp.TimeoutClamped, resp.TimeoutWarning = timeoutMs, override.clamped, override.warning
+		resp.Meta = result.meta
+		c.JSON(http.StatusOK, resp)
+	})
+
+	// GET /numbers/p/stream pushes the window each time it changes.
+	router.GET("/numbers/:numberid/stream", s.getStream(ctx))
+
+	// GET /ws is the same over a WebSocket, for the number types the client
+	// subscribes to.
+	router.GET("/ws", s.getWS(ctx))
+
+	// POST /numbers adds numbers from the body as if they had been fetched,
+	// for seeding the window without the number service.
+	router.POST("/numbers", s.validateToken, func(c *gin.Context) {
+		authToken, ok := bearerToken(c)
+		if !ok {
+			return
+		}
+
+		avg, ok := parseAvgMode(c)
+		if !ok {
+			return
+		}
+		numbers, err := parseNumbers(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		resp, err := s.addNumbers(windows.get(authToken), nil, numbers, avg)
+		if err != nil {
+			s.abortStoreUnavailable(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	// GET /window reads the window without calling the number service, so
+	// it can be polled freely. Client windows need the client's token.
+	router.GET("/window", s.validateToken, func(c *gin.Context) {
+		format, ok := exportFormat(c)
+		if !ok {
+			return
+		}
+		store := windows.shared
+		if windows.perClient {
+			authToken, ok := bearerToken(c)
+			if !ok {
+				return
+			}
+			store = windows.existing(authToken)
+		}
+
+		snapshot, err := store.Snapshot()
+		if err != nil {
+			s.abortStoreUnavailable(c, err)
+			return
+		}
+		resp := WindowResponse{
+			WindowCurrState: snapshot.WindowCurrState,
+			Average:         snapshot.Average,
+			Count:           snapshot.Count,
+			LastUpdated:     snapshot.LastUpdated,
+			WindowVersion:   &snapshot.Seq,
+		}
+		if notModified(c, windowETag(snapshot, store.Size(), cfg.WindowDuration > 0, format)) {
+			return
+		}
+		if format != "json" {
+			history, err := store.History(cfg.HistorySize, "")
+			if err != nil {
+				s.abortStoreUnavailable(c, err)
+				return
+			}
+			exportWindow(c, format, resp.WindowCurrState, addedTimes(history))
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	// GET /window/history shows how the window got where it is.
+	router.GET("/window/history", s.validateToken, s.getWindowHistory)
+
+	// DELETE /window empties the window, the caller's own with per-client
+	// windows, and its history, and returns what was discarded.
+	router.DELETE("/window", s.validateToken, func(c *gin.Context) {
+		authToken, ok := bearerToken(c)
+		if !ok {
+			return
+		}
+		if c.Query("type") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The window is shared by all number types and can only be reset as a whole"})
+			return
+		}
+
+		store := windows.shared
+		if windows.perClient {
+			store = windows.existing(authToken)
+		}
+		discarded, err := store.Reset()
+		if err != nil {
+			s.abortStoreUnavailable(c, err)
+			return
+		}
+		s.logRequest(c.Request.Context(), slog.LevelInfo, "Window reset", slog.Int("discarded", discarded.Count))
+		c.JSON(http.StatusOK, windowReset{Discarded: discarded})
+	})
+
+	for _, route := range undocumentedRoutes(router.Routes(), operations) {
+		s.logger.Warn("Route missing from the OpenAPI document", "route", route)
+	}
+	return router
+}
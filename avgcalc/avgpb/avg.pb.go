@@ -0,0 +1,673 @@
+// The average calculator over gRPC: the same windows as the HTTP API, with
+// the same numbers fetched for them.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v5.27.1
+// source: avg.proto
+
+package avgpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type NumberRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// number_id is one or more of p, f, e and r, comma-separated.
+	NumberId string `protobuf:"bytes,1,opt,name=number_id,json=numberId,proto3" json:"number_id,omitempty"`
+	// avg_mode is "mean", the default, "ema" for the exponential moving
+	// average with alpha, in (0, 1], on top of the plain one, or "weighted"
+	// for the weighted average.
+	AvgMode string  `protobuf:"bytes,2,opt,name=avg_mode,json=avgMode,proto3" json:"avg_mode,omitempty"`
+	Alpha   float64 `protobuf:"fixed64,3,opt,name=alpha,proto3" json:"alpha,omitempty"`
+}
+
+func (x *NumberRequest) Reset() {
+	*x = NumberRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_avg_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NumberRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NumberRequest) ProtoMessage() {}
+
+func (x *NumberRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_avg_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NumberRequest.ProtoReflect.Descriptor instead.
+func (*NumberRequest) Descriptor() ([]byte, []int) {
+	return file_avg_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *NumberRequest) GetNumberId() string {
+	if x != nil {
+		return x.NumberId
+	}
+	return ""
+}
+
+func (x *NumberRequest) GetAvgMode() string {
+	if x != nil {
+		return x.AvgMode
+	}
+	return ""
+}
+
+func (x *NumberRequest) GetAlpha() float64 {
+	if x != nil {
+		return x.Alpha
+	}
+	return 0
+}
+
+// NumberWindowResponse mirrors the JSON of GET /numbers/{number_id}.
+type NumberWindowResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	WindowPrevState     []float64         `protobuf:"fixed64,1,rep,packed,name=window_prev_state,json=windowPrevState,proto3" json:"window_prev_state,omitempty"`
+	WindowCurrState     []float64         `protobuf:"fixed64,2,rep,packed,name=window_curr_state,json=windowCurrState,proto3" json:"window_curr_state,omitempty"`
+	Numbers             []float64         `protobuf:"fixed64,3,rep,packed,name=numbers,proto3" json:"numbers,omitempty"`
+	Avg                 float64           `protobuf:"fixed64,4,opt,name=avg,proto3" json:"avg,omitempty"`
+	Median              float64           `protobuf:"fixed64,5,opt,name=median,proto3" json:"median,omitempty"`
+	Min                 float64           `protobuf:"fixed64,6,opt,name=min,proto3" json:"min,omitempty"`
+	Max                 float64           `protobuf:"fixed64,7,opt,name=max,proto3" json:"max,omitempty"`
+	Stddev              float64           `protobuf:"fixed64,8,opt,name=stddev,proto3" json:"stddev,omitempty"`
+	WindowCount         int32             `protobuf:"varint,9,opt,name=window_count,json=windowCount,proto3" json:"window_count,omitempty"`
+	WindowSize          int32             `protobuf:"varint,10,opt,name=window_size,json=windowSize,proto3" json:"window_size,omitempty"`
+	IsFull              bool              `protobuf:"varint,11,opt,name=is_full,json=isFull,proto3" json:"is_full,omitempty"`
+	Accepted            []float64         `protobuf:"fixed64,12,rep,packed,name=accepted,proto3" json:"accepted,omitempty"`
+	DuplicatesDiscarded int32             `protobuf:"varint,13,opt,name=duplicates_discarded,json=duplicatesDiscarded,proto3" json:"duplicates_discarded,omitempty"`
+	EmaAvg              *float64          `protobuf:"fixed64,14,opt,name=ema_avg,json=emaAvg,proto3,oneof" json:"ema_avg,omitempty"`
+	Failures            map[string]string `protobuf:"bytes,15,rep,name=failures,proto3" json:"failures,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Attempts            map[string]int32  `protobuf:"bytes,16,rep,name=attempts,proto3" json:"attempts,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	UpstreamUnavailable bool              `protobuf:"varint,17,opt,name=upstream_unavailable,json=upstreamUnavailable,proto3" json:"upstream_unavailable,omitempty"`
+	Source              string            `protobuf:"bytes,18,opt,name=source,proto3" json:"source,omitempty"`
+	CacheHit            bool              `protobuf:"varint,19,opt,name=cache_hit,json=cacheHit,proto3" json:"cache_hit,omitempty"`
+	Stale               bool              `protobuf:"varint,20,opt,name=stale,proto3" json:"stale,omitempty"`
+	StaleAgeSeconds     *int32            `protobuf:"varint,21,opt,name=stale_age_seconds,json=staleAgeSeconds,proto3,oneof" json:"stale_age_seconds,omitempty"`
+	TimedOut            bool              `protobuf:"varint,22,opt,name=timed_out,json=timedOut,proto3" json:"timed_out,omitempty"`
+	WeightedAvg         *float64          `protobuf:"fixed64,23,opt,name=weighted_avg,json=weightedAvg,proto3,oneof" json:"weighted_avg,omitempty"`
+	TrimmedAvg          float64           `protobuf:"fixed64,24,opt,name=trimmed_avg,json=trimmedAvg,proto3" json:"trimmed_avg,omitempty"`
+}
+
+func (x *NumberWindowResponse) Reset() {
+	*x = NumberWindowResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_avg_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NumberWindowResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NumberWindowResponse) ProtoMessage() {}
+
+func (x *NumberWindowResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_avg_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NumberWindowResponse.ProtoReflect.Descriptor instead.
+func (*NumberWindowResponse) Descriptor() ([]byte, []int) {
+	return file_avg_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *NumberWindowResponse) GetWindowPrevState() []float64 {
+	if x != nil {
+		return x.WindowPrevState
+	}
+	return nil
+}
+
+func (x *NumberWindowResponse) GetWindowCurrState() []float64 {
+	if x != nil {
+		return x.WindowCurrState
+	}
+	return nil
+}
+
+func (x *NumberWindowResponse) GetNumbers() []float64 {
+	if x != nil {
+		return x.Numbers
+	}
+	return nil
+}
+
+func (x *NumberWindowResponse) GetAvg() float64 {
+	if x != nil {
+		return x.Avg
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetMedian() float64 {
+	if x != nil {
+		return x.Median
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetStddev() float64 {
+	if x != nil {
+		return x.Stddev
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetWindowCount() int32 {
+	if x != nil {
+		return x.WindowCount
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetWindowSize() int32 {
+	if x != nil {
+		return x.WindowSize
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetIsFull() bool {
+	if x != nil {
+		return x.IsFull
+	}
+	return false
+}
+
+func (x *NumberWindowResponse) GetAccepted() []float64 {
+	if x != nil {
+		return x.Accepted
+	}
+	return nil
+}
+
+func (x *NumberWindowResponse) GetDuplicatesDiscarded() int32 {
+	if x != nil {
+		return x.DuplicatesDiscarded
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetEmaAvg() float64 {
+	if x != nil && x.EmaAvg != nil {
+		return *x.EmaAvg
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetFailures() map[string]string {
+	if x != nil {
+		return x.Failures
+	}
+	return nil
+}
+
+func (x *NumberWindowResponse) GetAttempts() map[string]int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return nil
+}
+
+func (x *NumberWindowResponse) GetUpstreamUnavailable() bool {
+	if x != nil {
+		return x.UpstreamUnavailable
+	}
+	return false
+}
+
+func (x *NumberWindowResponse) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *NumberWindowResponse) GetCacheHit() bool {
+	if x != nil {
+		return x.CacheHit
+	}
+	return false
+}
+
+func (x *NumberWindowResponse) GetStale() bool {
+	if x != nil {
+		return x.Stale
+	}
+	return false
+}
+
+func (x *NumberWindowResponse) GetStaleAgeSeconds() int32 {
+	if x != nil && x.StaleAgeSeconds != nil {
+		return *x.StaleAgeSeconds
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetTimedOut() bool {
+	if x != nil {
+		return x.TimedOut
+	}
+	return false
+}
+
+func (x *NumberWindowResponse) GetWeightedAvg() float64 {
+	if x != nil && x.WeightedAvg != nil {
+		return *x.WeightedAvg
+	}
+	return 0
+}
+
+func (x *NumberWindowResponse) GetTrimmedAvg() float64 {
+	if x != nil {
+		return x.TrimmedAvg
+	}
+	return 0
+}
+
+type WatchWindowRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// number_id only has to be valid: there is one window for every type.
+	NumberId string `protobuf:"bytes,1,opt,name=number_id,json=numberId,proto3" json:"number_id,omitempty"`
+}
+
+func (x *WatchWindowRequest) Reset() {
+	*x = WatchWindowRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_avg_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchWindowRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchWindowRequest) ProtoMessage() {}
+
+func (x *WatchWindowRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_avg_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchWindowRequest.ProtoReflect.Descriptor instead.
+func (*WatchWindowRequest) Descriptor() ([]byte, []int) {
+	return file_avg_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WatchWindowRequest) GetNumberId() string {
+	if x != nil {
+		return x.NumberId
+	}
+	return ""
+}
+
+type WindowEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seq             uint64                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	WindowCurrState []float64              `protobuf:"fixed64,2,rep,packed,name=window_curr_state,json=windowCurrState,proto3" json:"window_curr_state,omitempty"`
+	Avg             float64                `protobuf:"fixed64,3,opt,name=avg,proto3" json:"avg,omitempty"`
+	Count           int32                  `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+	LastUpdated     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
+}
+
+func (x *WindowEvent) Reset() {
+	*x = WindowEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_avg_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WindowEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WindowEvent) ProtoMessage() {}
+
+func (x *WindowEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_avg_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WindowEvent.ProtoReflect.Descriptor instead.
+func (*WindowEvent) Descriptor() ([]byte, []int) {
+	return file_avg_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WindowEvent) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *WindowEvent) GetWindowCurrState() []float64 {
+	if x != nil {
+		return x.WindowCurrState
+	}
+	return nil
+}
+
+func (x *WindowEvent) GetAvg() float64 {
+	if x != nil {
+		return x.Avg
+	}
+	return 0
+}
+
+func (x *WindowEvent) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *WindowEvent) GetLastUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return nil
+}
+
+var File_avg_proto protoreflect.FileDescriptor
+
+var file_avg_proto_rawDesc = []byte{
+	0x0a, 0x09, 0x61, 0x76, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0a, 0x61, 0x76, 0x67,
+	0x63, 0x61, 0x6c, 0x63, 0x2e, 0x76, 0x31, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x5d, 0x0a, 0x0d, 0x4e, 0x75, 0x6d, 0x62,
+	0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x6e, 0x75, 0x6d,
+	0x62, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6e, 0x75,
+	0x6d, 0x62, 0x65, 0x72, 0x49, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x61, 0x76, 0x67, 0x5f, 0x6d, 0x6f,
+	0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x76, 0x67, 0x4d, 0x6f, 0x64,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x05, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x22, 0x92, 0x08, 0x0a, 0x14, 0x4e, 0x75, 0x6d, 0x62,
+	0x65, 0x72, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x2a, 0x0a, 0x11, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x70, 0x72, 0x65, 0x76, 0x5f,
+	0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x03, 0x28, 0x01, 0x52, 0x0f, 0x77, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x50, 0x72, 0x65, 0x76, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x2a, 0x0a, 0x11,
+	0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x63, 0x75, 0x72, 0x72, 0x5f, 0x73, 0x74, 0x61, 0x74,
+	0x65, 0x18, 0x02, 0x20, 0x03, 0x28, 0x01, 0x52, 0x0f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x43,
+	0x75, 0x72, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6e, 0x75, 0x6d, 0x62,
+	0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x01, 0x52, 0x07, 0x6e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x73, 0x12, 0x10, 0x0a, 0x03, 0x61, 0x76, 0x67, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x03, 0x61, 0x76, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x6e, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x6e, 0x12, 0x10, 0x0a, 0x03,
+	0x6d, 0x69, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x01, 0x52, 0x03, 0x6d, 0x69, 0x6e, 0x12, 0x10,
+	0x0a, 0x03, 0x6d, 0x61, 0x78, 0x18, 0x07, 0x20, 0x01, 0x28, 0x01, 0x52, 0x03, 0x6d, 0x61, 0x78,
+	0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x64, 0x64, 0x65, 0x76, 0x18, 0x08, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x06, 0x73, 0x74, 0x64, 0x64, 0x65, 0x76, 0x12, 0x21, 0x0a, 0x0c, 0x77, 0x69, 0x6e, 0x64,
+	0x6f, 0x77, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b,
+	0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x77,
+	0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x0a, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x17, 0x0a, 0x07,
+	0x69, 0x73, 0x5f, 0x66, 0x75, 0x6c, 0x6c, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x69,
+	0x73, 0x46, 0x75, 0x6c, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x65,
+	0x64, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x01, 0x52, 0x08, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x65,
+	0x64, 0x12, 0x31, 0x0a, 0x14, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x5f,
+	0x64, 0x69, 0x73, 0x63, 0x61, 0x72, 0x64, 0x65, 0x64, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x13, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x44, 0x69, 0x73, 0x63, 0x61,
+	0x72, 0x64, 0x65, 0x64, 0x12, 0x1c, 0x0a, 0x07, 0x65, 0x6d, 0x61, 0x5f, 0x61, 0x76, 0x67, 0x18,
+	0x0e, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x06, 0x65, 0x6d, 0x61, 0x41, 0x76, 0x67, 0x88,
+	0x01, 0x01, 0x12, 0x4a, 0x0a, 0x08, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x73, 0x18, 0x0f,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x2e, 0x2e, 0x61, 0x76, 0x67, 0x63, 0x61, 0x6c, 0x63, 0x2e, 0x76,
+	0x31, 0x2e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x2e, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x73, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x73, 0x12, 0x4a,
+	0x0a, 0x08, 0x61, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x73, 0x18, 0x10, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x2e, 0x2e, 0x61, 0x76, 0x67, 0x63, 0x61, 0x6c, 0x63, 0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x75,
+	0x6d, 0x62, 0x65, 0x72, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x2e, 0x41, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x08, 0x61, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x73, 0x12, 0x31, 0x0a, 0x14, 0x75, 0x70,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x75, 0x6e, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62,
+	0x6c, 0x65, 0x18, 0x11, 0x20, 0x01, 0x28, 0x08, 0x52, 0x13, 0x75, 0x70, 0x73, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x55, 0x6e, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x12, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x61, 0x63, 0x68, 0x65, 0x5f, 0x68,
+	0x69, 0x74, 0x18, 0x13, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x63, 0x61, 0x63, 0x68, 0x65, 0x48,
+	0x69, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x6c, 0x65, 0x18, 0x14, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x05, 0x73, 0x74, 0x61, 0x6c, 0x65, 0x12, 0x2f, 0x0a, 0x11, 0x73, 0x74, 0x61, 0x6c,
+	0x65, 0x5f, 0x61, 0x67, 0x65, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x15, 0x20,
+	0x01, 0x28, 0x05, 0x48, 0x01, 0x52, 0x0f, 0x73, 0x74, 0x61, 0x6c, 0x65, 0x41, 0x67, 0x65, 0x53,
+	0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x09, 0x74, 0x69, 0x6d,
+	0x65, 0x64, 0x5f, 0x6f, 0x75, 0x74, 0x18, 0x16, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x74, 0x69,
+	0x6d, 0x65, 0x64, 0x4f, 0x75, 0x74, 0x12, 0x26, 0x0a, 0x0c, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74,
+	0x65, 0x64, 0x5f, 0x61, 0x76, 0x67, 0x18, 0x17, 0x20, 0x01, 0x28, 0x01, 0x48, 0x02, 0x52, 0x0b,
+	0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x65, 0x64, 0x41, 0x76, 0x67, 0x88, 0x01, 0x01, 0x12, 0x1f,
+	0x0a, 0x0b, 0x74, 0x72, 0x69, 0x6d, 0x6d, 0x65, 0x64, 0x5f, 0x61, 0x76, 0x67, 0x18, 0x18, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x0a, 0x74, 0x72, 0x69, 0x6d, 0x6d, 0x65, 0x64, 0x41, 0x76, 0x67, 0x1a,
+	0x3b, 0x0a, 0x0d, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3b, 0x0a, 0x0d,
+	0x41, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a,
+	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x65, 0x6d,
+	0x61, 0x5f, 0x61, 0x76, 0x67, 0x42, 0x14, 0x0a, 0x12, 0x5f, 0x73, 0x74, 0x61, 0x6c, 0x65, 0x5f,
+	0x61, 0x67, 0x65, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x42, 0x0f, 0x0a, 0x0d, 0x5f,
+	0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x76, 0x67, 0x22, 0x31, 0x0a, 0x12,
+	0x57, 0x61, 0x74, 0x63, 0x68, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x49, 0x64, 0x22,
+	0xb2, 0x01, 0x0a, 0x0b, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12,
+	0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65,
+	0x71, 0x12, 0x2a, 0x0a, 0x11, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x63, 0x75, 0x72, 0x72,
+	0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x03, 0x28, 0x01, 0x52, 0x0f, 0x77, 0x69,
+	0x6e, 0x64, 0x6f, 0x77, 0x43, 0x75, 0x72, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x10, 0x0a,
+	0x03, 0x61, 0x76, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x03, 0x61, 0x76, 0x67, 0x12,
+	0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05,
+	0x63, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x3d, 0x0a, 0x0c, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x75, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x64, 0x32, 0xa8, 0x01, 0x0a, 0x11, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65,
+	0x43, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x49, 0x0a, 0x0a, 0x47, 0x65,
+	0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x19, 0x2e, 0x61, 0x76, 0x67, 0x63, 0x61,
+	0x6c, 0x63, 0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x61, 0x76, 0x67, 0x63, 0x61, 0x6c, 0x63, 0x2e, 0x76, 0x31,
+	0x2e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x48, 0x0a, 0x0b, 0x57, 0x61, 0x74, 0x63, 0x68, 0x57, 0x69,
+	0x6e, 0x64, 0x6f, 0x77, 0x12, 0x1e, 0x2e, 0x61, 0x76, 0x67, 0x63, 0x61, 0x6c, 0x63, 0x2e, 0x76,
+	0x31, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x61, 0x76, 0x67, 0x63, 0x61, 0x6c, 0x63, 0x2e, 0x76,
+	0x31, 0x2e, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x30, 0x01, 0x42,
+	0x31, 0x5a, 0x2f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x45, 0x73,
+	0x63, 0x61, 0x6e, 0x6f, 0x72, 0x32, 0x34, 0x34, 0x2f, 0x37, 0x31, 0x33, 0x35, 0x32, 0x32, 0x49,
+	0x54, 0x30, 0x31, 0x33, 0x2f, 0x61, 0x76, 0x67, 0x63, 0x61, 0x6c, 0x63, 0x2f, 0x61, 0x76, 0x67,
+	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_avg_proto_rawDescOnce sync.Once
+	file_avg_proto_rawDescData = file_avg_proto_rawDesc
+)
+
+func file_avg_proto_rawDescGZIP() []byte {
+	file_avg_proto_rawDescOnce.Do(func() {
+		file_avg_proto_rawDescData = protoimpl.X.CompressGZIP(file_avg_proto_rawDescData)
+	})
+	return file_avg_proto_rawDescData
+}
+
+var file_avg_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_avg_proto_goTypes = []any{
+	(*NumberRequest)(nil),         // 0: avgcalc.v1.NumberRequest
+	(*NumberWindowResponse)(nil),  // 1: avgcalc.v1.NumberWindowResponse
+	(*WatchWindowRequest)(nil),    // 2: avgcalc.v1.WatchWindowRequest
+	(*WindowEvent)(nil),           // 3: avgcalc.v1.WindowEvent
+	nil,                           // 4: avgcalc.v1.NumberWindowResponse.FailuresEntry
+	nil,                           // 5: avgcalc.v1.NumberWindowResponse.AttemptsEntry
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_avg_proto_depIdxs = []int32{
+	4, // 0: avgcalc.v1.NumberWindowResponse.failures:type_name -> avgcalc.v1.NumberWindowResponse.FailuresEntry
+	5, // 1: avgcalc.v1.NumberWindowResponse.attempts:type_name -> avgcalc.v1.NumberWindowResponse.AttemptsEntry
+	6, // 2: avgcalc.v1.WindowEvent.last_updated:type_name -> google.protobuf.Timestamp
+	0, // 3: avgcalc.v1.AverageCalculator.GetNumbers:input_type -> avgcalc.v1.NumberRequest
+	2, // 4: avgcalc.v1.AverageCalculator.WatchWindow:input_type -> avgcalc.v1.WatchWindowRequest
+	1, // 5: avgcalc.v1.AverageCalculator.GetNumbers:output_type -> avgcalc.v1.NumberWindowResponse
+	3, // 6: avgcalc.v1.AverageCalculator.WatchWindow:output_type -> avgcalc.v1.WindowEvent
+	5, // [5:7] is the sub-list for method output_type
+	3, // [3:5] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_avg_proto_init() }
+func file_avg_proto_init() {
+	if File_avg_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_avg_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*NumberRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_avg_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*NumberWindowResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_avg_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*WatchWindowRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_avg_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*WindowEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_avg_proto_msgTypes[1].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_avg_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_avg_proto_goTypes,
+		DependencyIndexes: file_avg_proto_depIdxs,
+		MessageInfos:      file_avg_proto_msgTypes,
+	}.Build()
+	File_avg_proto = out.File
+	file_avg_proto_rawDesc = nil
+	file_avg_proto_goTypes = nil
+	file_avg_proto_depIdxs = nil
+}
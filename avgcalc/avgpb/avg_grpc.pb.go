@@ -0,0 +1,191 @@
+// The average calculator over gRPC: the same windows as the HTTP API, with
+// the same numbers fetched for them.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v5.27.1
+// source: avg.proto
+
+package avgpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	AverageCalculator_GetNumbers_FullMethodName  = "/avgcalc.v1.AverageCalculator/GetNumbers"
+	AverageCalculator_WatchWindow_FullMethodName = "/avgcalc.v1.AverageCalculator/WatchWindow"
+)
+
+// AverageCalculatorClient is the client API for AverageCalculator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Calls authenticate with an "authorization" metadata entry of the form
+// "Bearer <token>", as the Authorization header does over HTTP.
+type AverageCalculatorClient interface {
+	// GetNumbers is GET /numbers/{number_id}.
+	GetNumbers(ctx context.Context, in *NumberRequest, opts ...grpc.CallOption) (*NumberWindowResponse, error)
+	// WatchWindow is GET /numbers/{number_id}/stream: the window as of the
+	// call, then each time it changes.
+	WatchWindow(ctx context.Context, in *WatchWindowRequest, opts ...grpc.CallOption) (AverageCalculator_WatchWindowClient, error)
+}
+
+type averageCalculatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAverageCalculatorClient(cc grpc.ClientConnInterface) AverageCalculatorClient {
+	return &averageCalculatorClient{cc}
+}
+
+func (c *averageCalculatorClient) GetNumbers(ctx context.Context, in *NumberRequest, opts ...grpc.CallOption) (*NumberWindowResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NumberWindowResponse)
+	err := c.cc.Invoke(ctx, AverageCalculator_GetNumbers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *averageCalculatorClient) WatchWindow(ctx context.Context, in *WatchWindowRequest, opts ...grpc.CallOption) (AverageCalculator_WatchWindowClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AverageCalculator_ServiceDesc.Streams[0], AverageCalculator_WatchWindow_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &averageCalculatorWatchWindowClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AverageCalculator_WatchWindowClient interface {
+	Recv() (*WindowEvent, error)
+	grpc.ClientStream
+}
+
+type averageCalculatorWatchWindowClient struct {
+	grpc.ClientStream
+}
+
+func (x *averageCalculatorWatchWindowClient) Recv() (*WindowEvent, error) {
+	m := new(WindowEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AverageCalculatorServer is the server API for AverageCalculator service.
+// All implementations must embed UnimplementedAverageCalculatorServer
+// for forward compatibility
+//
+// Calls authenticate with an "authorization" metadata entry of the form
+// "Bearer <token>", as the Authorization header does over HTTP.
+type AverageCalculatorServer interface {
+	// GetNumbers is GET /numbers/{number_id}.
+	GetNumbers(context.Context, *NumberRequest) (*NumberWindowResponse, error)
+	// WatchWindow is GET /numbers/{number_id}/stream: the window as of the
+	// call, then each time it changes.
+	WatchWindow(*WatchWindowRequest, AverageCalculator_WatchWindowServer) error
+	mustEmbedUnimplementedAverageCalculatorServer()
+}
+
+// UnimplementedAverageCalculatorServer must be embedded to have forward compatible implementations.
+type UnimplementedAverageCalculatorServer struct {
+}
+
+func (UnimplementedAverageCalculatorServer) GetNumbers(context.Context, *NumberRequest) (*NumberWindowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNumbers not implemented")
+}
+func (UnimplementedAverageCalculatorServer) WatchWindow(*WatchWindowRequest, AverageCalculator_WatchWindowServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchWindow not implemented")
+}
+func (UnimplementedAverageCalculatorServer) mustEmbedUnimplementedAverageCalculatorServer() {}
+
+// UnsafeAverageCalculatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AverageCalculatorServer will
+// result in compilation errors.
+type UnsafeAverageCalculatorServer interface {
+	mustEmbedUnimplementedAverageCalculatorServer()
+}
+
+func RegisterAverageCalculatorServer(s grpc.ServiceRegistrar, srv AverageCalculatorServer) {
+	s.RegisterService(&AverageCalculator_ServiceDesc, srv)
+}
+
+func _AverageCalculator_GetNumbers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NumberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AverageCalculatorServer).GetNumbers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AverageCalculator_GetNumbers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AverageCalculatorServer).GetNumbers(ctx, req.(*NumberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AverageCalculator_WatchWindow_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchWindowRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AverageCalculatorServer).WatchWindow(m, &averageCalculatorWatchWindowServer{ServerStream: stream})
+}
+
+type AverageCalculator_WatchWindowServer interface {
+	Send(*WindowEvent) error
+	grpc.ServerStream
+}
+
+type averageCalculatorWatchWindowServer struct {
+	grpc.ServerStream
+}
+
+func (x *averageCalculatorWatchWindowServer) Send(m *WindowEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// AverageCalculator_ServiceDesc is the grpc.ServiceDesc for AverageCalculator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AverageCalculator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "avgcalc.v1.AverageCalculator",
+	HandlerType: (*AverageCalculatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetNumbers",
+			Handler:    _AverageCalculator_GetNumbers_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchWindow",
+			Handler:       _AverageCalculator_WatchWindow_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "avg.proto",
+}
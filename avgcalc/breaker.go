@@ -0,0 +1,160 @@
+package avgcalc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+)
+
+const (
+	BreakerThreshold = 5
+	BreakerCooldown  = 30 * time.Second
+)
+
+// errCircuitOpen is returned instead of calling the number service while
+// a number type's circuit is open.
+var errCircuitOpen = apperr.Wrap(apperr.ErrUpstreamUnreachable, "number service is failing, not calling it until the circuit closes")
+
+// breaker is a circuit breaker per number type. After threshold
+// consecutive failures a type's circuit opens and its fetches fail at once
+// for cooldown. Then one fetch is let through as a probe: success closes
+// the circuit, failure opens it for another cooldown. A threshold of 0
+// turns it off.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	logger    *slog.Logger
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// breakerState is a circuit as GET /admin/breakers shows it.
+type breakerState struct {
+	NumberType string     `json:"numberType"`
+	State      string     `json:"state"`
+	Failures   int        `json:"consecutiveFailures"`
+	OpenedAt   *time.Time `json:"openedAt,omitempty"`
+	RetryAt    *time.Time `json:"retryAt,omitempty"`
+}
+
+// allow reports whether numberType may call the number service now,
+// turning an open circuit whose cooldown is over into a probe.
+func (b *breaker) allow(numberType string) bool {
+	if b.threshold == 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuits[numberType]
+	if c == nil || c.failures < b.threshold {
+		return true
+	}
+	if c.probing || time.Since(c.openedAt) < b.cooldown {
+		return false
+	}
+	c.probing = true
+	return true
+}
+
+// record counts the outcome of a fetch that allow let through. Only the
+// number service failing counts: it answering 4xx means it is up.
+func (b *breaker) record(numberType string, err error) {
+	if b.threshold == 0 {
+		return
+	}
+	failed := err != nil && apperr.HTTPStatus(err) >= http.StatusBadGateway
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+	c := b.circuits[numberType]
+	if c == nil {
+		c = &circuit{}
+		b.circuits[numberType] = c
+	}
+
+	if errors.Is(err, context.Canceled) {
+		// The client gave up, which says nothing about the service.
+		c.probing = false
+		return
+	}
+	wasOpen := c.failures >= b.threshold
+	if !failed {
+		if wasOpen {
+			b.logger.Info("Circuit closed", "numberType", numberType)
+		}
+		*c = circuit{}
+		return
+	}
+	c.failures++
+	c.probing = false
+	if c.failures >= b.threshold {
+		c.openedAt = time.Now()
+		if !wasOpen {
+			b.logger.Warn("Circuit opened", "numberType", numberType, "failures", c.failures, "cooldown", b.cooldown.String())
+		}
+	}
+}
+
+// states lists the circuits that have seen a fetch, by number type.
+func (b *breaker) states() []breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	states := []breakerState{}
+	for numberType, c := range b.circuits {
+		s := breakerState{NumberType: numberType, State: "closed", Failures: c.failures}
+		if c.failures >= b.threshold {
+			s.State = "open"
+			if c.probing || time.Since(c.openedAt) >= b.cooldown {
+				s.State = "half-open"
+			}
+			openedAt, retryAt := c.openedAt, c.openedAt.Add(b.cooldown)
+			s.OpenedAt, s.RetryAt = &openedAt, &retryAt
+		}
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].NumberType < states[j].NumberType })
+	return states
+}
+
+// breakerReport is the response of GET /admin/breakers.
+type breakerReport struct {
+	Threshold int            `json:"threshold"`
+	Cooldown  string         `json:"cooldown"`
+	Circuits  []breakerState `json:"circuits"`
+}
+
+func (s *calculator) getBreakers(c *gin.Context) {
+	c.JSON(http.StatusOK, breakerReport{
+		Threshold: s.breaker.threshold,
+		Cooldown:  s.breaker.cooldown.String(),
+		Circuits:  s.breaker.states(),
+	})
+}
+
+// circuitOpen reports whether every error in errs is errCircuitOpen.
+func circuitOpen(errs []error) bool {
+	for _, err := range errs {
+		if !errors.Is(err, errCircuitOpen) {
+			return false
+		}
+	}
+	return len(errs) > 0
+}
@@ -0,0 +1,93 @@
+package avgcalc
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+)
+
+const (
+	// CacheTTL is how long the numbers fetched are served again without
+	// calling the number service.
+	CacheTTL = time.Second
+
+	// MaxStale is how old the last numbers fetched may be for them to
+	// stand in for a failed fetch.
+	MaxStale = 60 * time.Second
+)
+
+// fetchCache remembers the last numbers fetched for each number type and
+// token. They are served again for ttl, sparing the number service calls
+// it charges for, and for up to maxAge in place of a failed fetch. A ttl
+// or maxAge of 0 turns that use of it off.
+type fetchCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxAge  time.Duration
+	entries map[string]cachedFetch
+
+	hits, misses atomic.Int64
+}
+
+type cachedFetch struct {
+	numbers []float64
+	at      time.Time
+}
+
+// put remembers numbers as just fetched, dropping entries too old to be
+// served again so tokens that stopped calling don't pile up.
+func (fc *fetchCache) put(numberType, authToken string, numbers []float64) {
+	keep := max(fc.ttl, fc.maxAge)
+	if keep == 0 {
+		return
+	}
+	now := time.Now()
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.entries == nil {
+		fc.entries = make(map[string]cachedFetch)
+	}
+	for key, entry := range fc.entries {
+		if now.Sub(entry.at) > keep {
+			delete(fc.entries, key)
+		}
+	}
+	fc.entries[numberType+"\x00"+authToken] = cachedFetch{numbers: numbers, at: now}
+}
+
+// fresh returns the numbers fetched for numberType and authToken within
+// the ttl, counting the hit or miss.
+func (fc *fetchCache) fresh(numberType, authToken string) ([]float64, bool) {
+	if fc.ttl == 0 {
+		return nil, false
+	}
+	fc.mu.Lock()
+	entry, ok := fc.entries[numberType+"\x00"+authToken]
+	fc.mu.Unlock()
+	if !ok || time.Since(entry.at) >= fc.ttl {
+		fc.misses.Add(1)
+		return nil, false
+	}
+	fc.hits.Add(1)
+	return entry.numbers, true
+}
+
+// stale returns the numbers to serve in place of a fetch that failed with
+// err and how old they are. Only the number service failing qualifies; a
+// 4xx is the client's to see.
+func (fc *fetchCache) stale(numberType, authToken string, err error) ([]float64, time.Duration, bool) {
+	if fc.maxAge == 0 || apperr.HTTPStatus(err) < http.StatusBadGateway {
+		return nil, 0, false
+	}
+	fc.mu.Lock()
+	entry, ok := fc.entries[numberType+"\x00"+authToken]
+	fc.mu.Unlock()
+	age := time.Since(entry.at)
+	if !ok || age > fc.maxAge {
+		return nil, 0, false
+	}
+	return entry.numbers, age, true
+}
@@ -0,0 +1,141 @@
+package avgcalc
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/errreport"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+	"github.com/Escanor244/713522IT013/internal/upstream"
+)
+
+// calculator is the average calculator running with a Config: its windows
+// and everything the handlers share, from the number service's client to
+// the counters behind GET /stats. Nothing of it is package state, so each
+// calculator, in Run or in a test, runs on its own settings.
+type calculator struct {
+	cfg         Config
+	logger      *slog.Logger
+	windows     *windows
+	numberTypes *numberTypeRegistry
+
+	// client calls the number service at baseURL; fetcher gets the numbers
+	// through it unless Config.Fetcher replaces it.
+	client  *upstream.Client
+	baseURL string
+	fetcher NumberFetcher
+
+	cache       *fetchCache
+	fallback    *generators
+	breaker     *breaker
+	ready       *readiness
+	credentials *credentials
+	tokens      *tokenValidator
+	fetches     fetchGroup
+	lastFetch   fetchOutcome
+	counters    serviceCounters
+
+	// streams counts the streams and WebSockets open, against maxStreams,
+	// and openWS the WebSockets being served, which Run waits for on
+	// shutdown.
+	streams    atomic.Int64
+	maxStreams int64
+	openWS     sync.WaitGroup
+}
+
+// newCalculator builds the calculator cfg describes, with empty windows.
+func newCalculator(cfg Config) *calculator {
+	if cfg.live == nil {
+		cfg.live = newLiveConfig(cfg.Timeout)
+	}
+	if cfg.HTTP.Reporter == nil {
+		cfg.HTTP.Reporter = errreport.Nop{}
+	}
+	logger := serviceLogger(cfg)
+	transport := cfg.Transport
+	if transport == nil {
+		transport = newNumbersTransport()
+	}
+	s := &calculator{
+		cfg:         cfg,
+		logger:      logger,
+		numberTypes: newNumberTypeRegistry(cfg.NumberTypes),
+		client:      newNumbersClient(cfg, transport),
+		baseURL:     cfg.NumberServiceURL,
+		cache:       &fetchCache{ttl: cfg.CacheTTL, maxAge: cfg.MaxStale},
+		fallback:    &generators{enabled: cfg.FallbackGenerators, randMin: cfg.FallbackRandomMin, randMax: cfg.FallbackRandomMax},
+		breaker:     &breaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown, logger: logger},
+		maxStreams:  int64(cfg.StreamMaxSubscribers),
+	}
+	s.ready = &readiness{
+		probe:            cfg.ReadyProbe,
+		probeInterval:    cfg.ReadyProbeInterval,
+		failingThreshold: cfg.ReadyFailingThreshold,
+		client:           s.client.HTTP,
+		url:              serviceURL(s.baseURL, "even"),
+		logger:           logger,
+	}
+	s.credentials = newCredentials(cfg.Credentials, s.client.HTTP, s.baseURL, logger)
+	s.tokens = &tokenValidator{
+		enabled: cfg.TokenValidation,
+		url:     cfg.TokenValidatorURL,
+		ttl:     cfg.TokenCacheTTL,
+		size:    cfg.TokenCacheSize,
+		client:  s.client.HTTP,
+	}
+	if s.tokens.url == "" {
+		s.tokens.url = serviceURL(s.baseURL, "auth")
+	}
+	s.fetcher = cfg.Fetcher
+	if s.fetcher == nil {
+		s.fetcher = HTTPFetcher{BaseURL: s.baseURL, Client: s.client, credentials: s.credentials}
+	}
+	s.windows = newWindows(cfg, windowOptionsFor(cfg, logger))
+	return s
+}
+
+// serviceLogger is the calculator's structured logger, cfg.HTTP.Logger
+// if set.
+func serviceLogger(cfg Config) *slog.Logger {
+	logger := slog.Default()
+	if cfg.HTTP.Logger != nil {
+		logger = cfg.HTTP.Logger
+	}
+	return logger.With("service", "avg")
+}
+
+// timeout is the timeout of each call to the number service, as last
+// configured or reloaded.
+func (s *calculator) timeout() time.Duration {
+	return time.Duration(s.cfg.live.timeout.Load())
+}
+
+// logRequest logs a line about the request ctx belongs to, tagged with its
+// ID so it can be found next to the access log line and the number
+// service's logs.
+func (s *calculator) logRequest(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if id := middleware.RequestIDFrom(ctx); id != "" {
+		attrs = append(attrs, slog.String("requestId", id))
+	}
+	s.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// newNumbersClient returns the client the number service is called with.
+// Network errors, timeouts and 5xx are retried with jittered backoff; a
+// 4xx, including 429, is the client's to deal with and never retried.
+// Calls are bounded through their context rather than http.Client.Timeout,
+// so the one client serves every timeout the service is configured with.
+func newNumbersClient(cfg Config, transport http.RoundTripper) *upstream.Client {
+	return &upstream.Client{
+		HTTP:      &http.Client{Transport: transport},
+		Observe:   observeUpstream,
+		Retries:   cfg.Retries,
+		Backoff:   cfg.RetryBackoff,
+		Jitter:    true,
+		Retryable: func(status int) bool { return status >= 500 },
+	}
+}
@@ -0,0 +1,676 @@
+package avgcalc
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/internal/httpserver"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+// Config is the average calculator's configuration. Run reads nothing
+// else, so calculators with different configurations can run side by
+// side.
+type Config struct {
+	// Listen is the address to listen on, :port or unix:///path.
+	Listen string
+
+	// WindowSize is how many numbers the sliding window holds at startup.
+	// The admin API can change it up to WindowSizeMax.
+	WindowSize    int
+	WindowSizeMax int
+
+	// WindowDuration makes the window time based when set: it holds the
+	// numbers seen in the last WindowDuration, up to WindowSizeMax of them,
+	// and WindowSize doesn't apply.
+	WindowDuration time.Duration
+
+	// PerClientWindows keeps a window per bearer token instead of one for
+	// everybody, each dropped after ClientWindowTTL without requests.
+	PerClientWindows bool
+	ClientWindowTTL  time.Duration
+
+	// HistorySize is how many snapshots each window's history keeps, 0 for
+	// none.
+	HistorySize int
+
+	// WeightedAvgWeights weighs the numbers for avgMode=weighted, newest
+	// first. Nil means linear weights: of N numbers, the newest weighs N
+	// and the oldest 1.
+	WeightedAvgWeights []float64
+
+	// OutlierFilter keeps numbers further than OutlierZScore standard
+	// deviations from the window's mean out of it, once it holds
+	// OutlierMinSamples numbers.
+	OutlierFilter     bool
+	OutlierZScore     float64
+	OutlierMinSamples int
+
+	// TrimFraction is the share of numbers dropped from each end for the
+	// trimmed mean.
+	TrimFraction float64
+
+	// StateFile, if set, is where the windows are saved every
+	// StateSaveInterval they change and on shutdown, to be restored on
+	// startup unless older than StateMaxAge.
+	StateFile         string
+	StateMaxAge       time.Duration
+	StateSaveInterval time.Duration
+
+	// Redis, if set, keeps the windows in Redis under keys starting with
+	// RedisPrefix instead of in memory, for replicas to share them.
+	Redis       *redis.Client
+	RedisPrefix string
+
+	// GRPCListen, if set, is the address to serve the gRPC API on, beside
+	// the HTTP one.
+	GRPCListen string
+
+	// AdminToken guards the admin API, which is disabled without one. It is
+	// ADMIN_TOKEN, shared with the social media analytics service.
+	AdminToken string
+
+	// NumberServiceURL is the number service's base URL, and NumberTypes
+	// the types that can be asked of it.
+	NumberServiceURL string
+	NumberTypes      []NumberType
+
+	// Timeout bounds each call to the number service; a timeout asked for
+	// by a request is clamped to TimeoutMin and TimeoutMax. Deadline
+	// bounds a fetch with its retries, unless the call timeout is longer:
+	// up to Retries more calls, the first after RetryBackoff, each cut
+	// short by what is left of it.
+	Timeout      time.Duration
+	TimeoutMin   time.Duration
+	TimeoutMax   time.Duration
+	Deadline     time.Duration
+	Retries      int
+	RetryBackoff time.Duration
+
+	// CacheTTL is how long fetched numbers are served again without calling
+	// the number service, and MaxStale how old they may be to stand in for
+	// a failed fetch. 0 turns either off.
+	CacheTTL time.Duration
+	MaxStale time.Duration
+
+	// FallbackGenerators makes numbers up locally when the number service
+	// fails, the random ones from FallbackRandomMin to FallbackRandomMax.
+	FallbackGenerators bool
+	FallbackRandomMin  int
+	FallbackRandomMax  int
+
+	// BreakerThreshold consecutive failures open a number type's circuit
+	// for BreakerCooldown. 0 turns the breaker off.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// ReadyProbe has GET /readyz probe the number service, at most every
+	// ReadyProbeInterval. The calculator is not ready once the number
+	// service has been failing for ReadyFailingThreshold.
+	ReadyProbe            bool
+	ReadyProbeInterval    time.Duration
+	ReadyFailingThreshold time.Duration
+
+	// Credentials, if set, has the calculator call the number service with
+	// a token of its own instead of the client's.
+	Credentials *ManagedCredentials
+
+	// TokenValidation checks bearer tokens with TokenValidatorURL, the
+	// number service's auth endpoint if empty, before they are used.
+	// Accepted ones are cached for TokenCacheTTL, up to TokenCacheSize.
+	TokenValidation   bool
+	TokenValidatorURL string
+	TokenCacheTTL     time.Duration
+	TokenCacheSize    int
+
+	// StreamMaxSubscribers caps the streams and WebSockets open at once.
+	StreamMaxSubscribers int
+
+	// HTTP configures the middleware shared with the other service.
+	HTTP middleware.Config
+
+	// Server configures the HTTP server and its listener.
+	Server httpserver.Options
+
+	// RedirectListen, if set, is a plain HTTP address redirecting to the
+	// service over HTTPS, with TLS configured.
+	RedirectListen string
+
+	// Transport, if set, carries the calls to the number service in place
+	// of the pooled transport.
+	Transport http.RoundTripper
+
+	// MockUpstream, if set, is served on a loopback port and called in
+	// place of the number service.
+	MockUpstream *MockUpstream
+
+	// Fetcher, if set, gets the numbers in place of the number service.
+	// The cache, circuit breaker and deadline still apply.
+	Fetcher NumberFetcher
+
+	// Metrics, if set, is the registry /metrics serves, in place of one of
+	// its own.
+	Metrics *prometheus.Registry
+
+	// live is what Reload changes while the calculator runs.
+	live *liveConfig
+}
+
+// ManagedCredentials identify the calculator to the number service's auth
+// endpoint, AuthURL or the service's /auth if empty, for a token of its
+// own. The token is replaced RefreshMargin before it expires.
+type ManagedCredentials struct {
+	AuthURL       string
+	RefreshMargin time.Duration
+
+	CompanyName  string
+	ClientID     string
+	ClientSecret string
+	OwnerName    string
+	OwnerEmail   string
+	RollNo       string
+}
+
+// liveConfig holds the settings that can change at runtime, shared by the
+// copies of the Config they were loaded with.
+type liveConfig struct {
+	timeout atomic.Int64
+	loaded  atomic.Pointer[config.Source]
+}
+
+// DefaultConfig is the configuration with every setting at its default,
+// which LoadConfig starts from.
+func DefaultConfig() Config {
+	return Config{
+		Listen:                ":9877",
+		WindowSize:            WindowSize,
+		WindowSizeMax:         MaxWindowSize,
+		ClientWindowTTL:       ClientWindowTTL,
+		HistorySize:           WindowHistorySize,
+		OutlierZScore:         OutlierZScore,
+		OutlierMinSamples:     OutlierMinSamples,
+		TrimFraction:          TrimFraction,
+		StateMaxAge:           WindowStateMaxAge,
+		StateSaveInterval:     WindowStateSaveInterval,
+		RedisPrefix:           RedisKeyPrefix,
+		NumberServiceURL:      NumberServiceURL,
+		NumberTypes:           DefaultNumberTypes,
+		Timeout:               APITimeoutMs * time.Millisecond,
+		TimeoutMin:            APITimeoutMin,
+		TimeoutMax:            APITimeoutMax,
+		Deadline:              APIDeadline,
+		Retries:               APIRetries,
+		RetryBackoff:          APIRetryBackoff,
+		CacheTTL:              CacheTTL,
+		MaxStale:              MaxStale,
+		FallbackRandomMin:     FallbackRandomMin,
+		FallbackRandomMax:     FallbackRandomMax,
+		BreakerThreshold:      BreakerThreshold,
+		BreakerCooldown:       BreakerCooldown,
+		ReadyProbeInterval:    ReadyProbeInterval,
+		ReadyFailingThreshold: ReadyFailingThreshold,
+		TokenCacheTTL:         TokenCacheTTL,
+		TokenCacheSize:        TokenCacheSize,
+		StreamMaxSubscribers:  StreamMaxSubscribers,
+	}
+}
+
+// LoadConfig reads the configuration from src and logs the result.
+func LoadConfig(src *config.Source) (Config, error) {
+	cfg := DefaultConfig()
+	cfg.StateFile = src.Get("WINDOW_STATE_FILE")
+	cfg.AdminToken = src.Get("ADMIN_TOKEN")
+	if raw := src.Get("PORT"); raw != "" {
+		if n, err := strconv.Atoi(raw); err != nil || n < 1 || n > 65535 {
+			return Config{}, fmt.Errorf("%s must be a port number, got %q", src.Name("PORT"), raw)
+		}
+		cfg.Listen = ":" + raw
+	}
+	if raw := src.Get("LISTEN"); raw != "" {
+		if _, _, err := httpserver.ParseAddr(raw); err != nil {
+			return Config{}, fmt.Errorf("%s must be host:port or unix:///path, got %q", src.Name("LISTEN"), raw)
+		}
+		cfg.Listen = raw
+	}
+
+	if raw := src.Get("GRPC_PORT"); raw != "" {
+		if n, err := strconv.Atoi(raw); err != nil || n < 1 || n > 65535 {
+			return Config{}, fmt.Errorf("%s must be a port number, got %q", src.Name("GRPC_PORT"), raw)
+		}
+		cfg.GRPCListen = ":" + raw
+	}
+
+	if raw := src.Get("NUMBER_SERVICE_URL"); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Fragment != "" {
+			return Config{}, fmt.Errorf("%s must be an absolute http(s) URL, got %q", src.Name("NUMBER_SERVICE_URL"), raw)
+		}
+		cfg.NumberServiceURL = raw
+	}
+	types, err := loadNumberTypes(src)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.NumberTypes = types
+
+	if raw := src.Get("WINDOW_SIZE"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return Config{}, fmt.Errorf("%s must be a positive integer, got %q", src.Name("WINDOW_SIZE"), raw)
+		}
+		cfg.WindowSize = n
+	}
+	if raw := src.Get("WINDOW_SIZE_MAX"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return Config{}, fmt.Errorf("%s must be a positive integer, got %q", src.Name("WINDOW_SIZE_MAX"), raw)
+		}
+		cfg.WindowSizeMax = n
+	}
+	if cfg.WindowSize > cfg.WindowSizeMax {
+		return Config{}, fmt.Errorf("%s must be at most %s (%d), got %d",
+			src.Name("WINDOW_SIZE"), src.Name("WINDOW_SIZE_MAX"), cfg.WindowSizeMax, cfg.WindowSize)
+	}
+	switch mode := src.Get("WINDOW_MODE"); mode {
+	case "", "count":
+	case "time":
+		cfg.WindowDuration = WindowDuration
+		if raw := src.Get("WINDOW_DURATION"); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 {
+				return Config{}, fmt.Errorf("%s must be a positive duration, got %q", src.Name("WINDOW_DURATION"), raw)
+			}
+			cfg.WindowDuration = d
+		}
+	default:
+		return Config{}, fmt.Errorf("%s must be count or time, got %q", src.Name("WINDOW_MODE"), mode)
+	}
+	if raw := src.Get("PER_CLIENT_WINDOWS"); raw != "" {
+		perClient, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s must be a boolean, got %q", src.Name("PER_CLIENT_WINDOWS"), raw)
+		}
+		cfg.PerClientWindows = perClient
+	}
+	if raw := src.Get("CLIENT_WINDOW_TTL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("%s must be a positive duration, got %q", src.Name("CLIENT_WINDOW_TTL"), raw)
+		}
+		cfg.ClientWindowTTL = d
+	}
+	if raw := src.Get("WINDOW_HISTORY_SIZE"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative integer, got %q", src.Name("WINDOW_HISTORY_SIZE"), raw)
+		}
+		cfg.HistorySize = n
+	}
+	if raw := src.Get("WEIGHTED_AVG_WEIGHTS"); raw != "" {
+		var weights []float64
+		positive := false
+		for _, field := range strings.Split(raw, ",") {
+			w, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil || !(w >= 0) || math.IsInf(w, 1) {
+				return Config{}, fmt.Errorf("%s must be comma-separated non-negative numbers, got %q", src.Name("WEIGHTED_AVG_WEIGHTS"), raw)
+			}
+			weights = append(weights, w)
+			positive = positive || w > 0
+		}
+		if !positive {
+			return Config{}, fmt.Errorf("%s must have a positive weight, got %q", src.Name("WEIGHTED_AVG_WEIGHTS"), raw)
+		}
+		cfg.WeightedAvgWeights = weights
+	}
+	switch filter := src.Get("OUTLIER_FILTER"); filter {
+	case "", "off":
+	case "zscore":
+		cfg.OutlierFilter = true
+	default:
+		return Config{}, fmt.Errorf("%s must be off or zscore, got %q", src.Name("OUTLIER_FILTER"), filter)
+	}
+	if raw := src.Get("OUTLIER_ZSCORE_THRESHOLD"); raw != "" {
+		z, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(z > 0) || math.IsInf(z, 1) {
+			return Config{}, fmt.Errorf("%s must be a positive number, got %q", src.Name("OUTLIER_ZSCORE_THRESHOLD"), raw)
+		}
+		cfg.OutlierZScore = z
+	}
+	if raw := src.Get("OUTLIER_MIN_SAMPLES"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 2 {
+			return Config{}, fmt.Errorf("%s must be an integer of at least 2, got %q", src.Name("OUTLIER_MIN_SAMPLES"), raw)
+		}
+		cfg.OutlierMinSamples = n
+	}
+	if raw := src.Get("TRIM_FRACTION"); raw != "" {
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(f >= 0 && f < 0.5) {
+			return Config{}, fmt.Errorf("%s must be a number in [0, 0.5), got %q", src.Name("TRIM_FRACTION"), raw)
+		}
+		cfg.TrimFraction = f
+	}
+	if raw := src.Get("WINDOW_STATE_MAX_AGE"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative duration, got %q", src.Name("WINDOW_STATE_MAX_AGE"), raw)
+		}
+		cfg.StateMaxAge = d
+	}
+	if raw := src.Get("WINDOW_STATE_SAVE_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("%s must be a positive duration, got %q", src.Name("WINDOW_STATE_SAVE_INTERVAL"), raw)
+		}
+		cfg.StateSaveInterval = d
+	}
+	switch backend := src.Get("STORE_BACKEND"); backend {
+	case "", "memory":
+	case "redis":
+		raw := src.Get("REDIS_URL")
+		if raw == "" {
+			return Config{}, fmt.Errorf("%s must be set when %s is redis", src.Name("REDIS_URL"), src.Name("STORE_BACKEND"))
+		}
+		opts, err := redis.ParseURL(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s must be a redis:// or rediss:// URL: %v", src.Name("REDIS_URL"), err)
+		}
+		opts.DialTimeout, opts.ReadTimeout, opts.WriteTimeout = RedisTimeout, RedisTimeout, RedisTimeout
+		if cfg.StateFile != "" {
+			return Config{}, fmt.Errorf("%s can't be used when %s is redis", src.Name("WINDOW_STATE_FILE"), src.Name("STORE_BACKEND"))
+		}
+		cfg.Redis = redis.NewClient(opts)
+		if prefix := src.Get("REDIS_KEY_PREFIX"); prefix != "" {
+			cfg.RedisPrefix = prefix
+		}
+	default:
+		return Config{}, fmt.Errorf("%s must be memory or redis, got %q", src.Name("STORE_BACKEND"), backend)
+	}
+
+	if cfg.Timeout, err = loadTimeout(src); err != nil {
+		return Config{}, err
+	}
+	if raw := src.Get("NUMBER_SERVICE_RETRIES"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative integer, got %q", src.Name("NUMBER_SERVICE_RETRIES"), raw)
+		}
+		cfg.Retries = n
+	}
+	if raw := src.Get("NUMBER_SERVICE_RETRY_BACKOFF"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative duration, got %q", src.Name("NUMBER_SERVICE_RETRY_BACKOFF"), raw)
+		}
+		cfg.RetryBackoff = d
+	}
+	if raw := src.Get("NUMBER_SERVICE_DEADLINE"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("%s must be a positive duration, got %q", src.Name("NUMBER_SERVICE_DEADLINE"), raw)
+		}
+		cfg.Deadline = d
+	}
+	for env, bound := range map[string]*time.Duration{"NUMBER_SERVICE_TIMEOUT_MIN": &cfg.TimeoutMin, "NUMBER_SERVICE_TIMEOUT_MAX": &cfg.TimeoutMax} {
+		if raw := src.Get(env); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 {
+				return Config{}, fmt.Errorf("%s must be a positive duration, got %q", src.Name(env), raw)
+			}
+			*bound = d
+		}
+	}
+	if cfg.TimeoutMin > cfg.TimeoutMax {
+		return Config{}, fmt.Errorf("%s must be at most %s (%v), got %v",
+			src.Name("NUMBER_SERVICE_TIMEOUT_MIN"), src.Name("NUMBER_SERVICE_TIMEOUT_MAX"), cfg.TimeoutMax, cfg.TimeoutMin)
+	}
+	if raw := src.Get("NUMBER_SERVICE_CACHE_TTL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative duration, got %q", src.Name("NUMBER_SERVICE_CACHE_TTL"), raw)
+		}
+		cfg.CacheTTL = d
+	}
+	if raw := src.Get("NUMBER_SERVICE_MAX_STALE"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative duration, got %q", src.Name("NUMBER_SERVICE_MAX_STALE"), raw)
+		}
+		cfg.MaxStale = d
+	}
+	if raw := src.Get("MOCK_UPSTREAM"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s must be a boolean, got %q", src.Name("MOCK_UPSTREAM"), raw)
+		}
+		if enabled {
+			if src.Get("NUMBER_SERVICE_URL") != "" {
+				return Config{}, fmt.Errorf("%s can't be used with %s", src.Name("NUMBER_SERVICE_URL"), src.Name("MOCK_UPSTREAM"))
+			}
+			cfg.MockUpstream = &MockUpstream{Token: src.Get("MOCK_UPSTREAM_TOKEN")}
+		}
+	}
+	if raw := src.Get("MOCK_UPSTREAM_LATENCY"); raw != "" && cfg.MockUpstream != nil {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative duration, got %q", src.Name("MOCK_UPSTREAM_LATENCY"), raw)
+		}
+		cfg.MockUpstream.Latency = d
+	}
+	if raw := src.Get("MOCK_UPSTREAM_FAILURE_RATE"); raw != "" && cfg.MockUpstream != nil {
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(rate >= 0 && rate <= 1) {
+			return Config{}, fmt.Errorf("%s must be a number from 0 to 1, got %q", src.Name("MOCK_UPSTREAM_FAILURE_RATE"), raw)
+		}
+		cfg.MockUpstream.FailureRate = rate
+	}
+	if raw := src.Get("FALLBACK_GENERATORS"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s must be a boolean, got %q", src.Name("FALLBACK_GENERATORS"), raw)
+		}
+		cfg.FallbackGenerators = enabled
+	}
+	for env, bound := range map[string]*int{"FALLBACK_RANDOM_MIN": &cfg.FallbackRandomMin, "FALLBACK_RANDOM_MAX": &cfg.FallbackRandomMax} {
+		if raw := src.Get(env); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return Config{}, fmt.Errorf("%s must be an integer, got %q", src.Name(env), raw)
+			}
+			*bound = n
+		}
+	}
+	if cfg.FallbackRandomMin > cfg.FallbackRandomMax {
+		return Config{}, fmt.Errorf("%s must be at most %s (%d), got %d",
+			src.Name("FALLBACK_RANDOM_MIN"), src.Name("FALLBACK_RANDOM_MAX"), cfg.FallbackRandomMax, cfg.FallbackRandomMin)
+	}
+	if raw := src.Get("NUMBER_SERVICE_BREAKER_THRESHOLD"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative integer, got %q", src.Name("NUMBER_SERVICE_BREAKER_THRESHOLD"), raw)
+		}
+		cfg.BreakerThreshold = n
+	}
+	if raw := src.Get("NUMBER_SERVICE_BREAKER_COOLDOWN"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("%s must be a positive duration, got %q", src.Name("NUMBER_SERVICE_BREAKER_COOLDOWN"), raw)
+		}
+		cfg.BreakerCooldown = d
+	}
+	if raw := src.Get("READY_PROBE_UPSTREAM"); raw != "" {
+		probe, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s must be a boolean, got %q", src.Name("READY_PROBE_UPSTREAM"), raw)
+		}
+		cfg.ReadyProbe = probe
+	}
+	if raw := src.Get("READY_PROBE_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("%s must be a positive duration, got %q", src.Name("READY_PROBE_INTERVAL"), raw)
+		}
+		cfg.ReadyProbeInterval = d
+	}
+	if raw := src.Get("READY_FAILING_THRESHOLD"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative duration, got %q", src.Name("READY_FAILING_THRESHOLD"), raw)
+		}
+		cfg.ReadyFailingThreshold = d
+	}
+	switch mode := src.Get("NUMBER_SERVICE_AUTH"); mode {
+	case "", "passthrough":
+	case "managed":
+		cfg.Credentials = &ManagedCredentials{
+			RefreshMargin: CredentialsRefreshMargin,
+			CompanyName:   src.Get("NUMBER_SERVICE_COMPANY_NAME"),
+			ClientID:      src.Get("NUMBER_SERVICE_CLIENT_ID"),
+			ClientSecret:  src.Get("NUMBER_SERVICE_CLIENT_SECRET"),
+			OwnerName:     src.Get("NUMBER_SERVICE_OWNER_NAME"),
+			OwnerEmail:    src.Get("NUMBER_SERVICE_OWNER_EMAIL"),
+			RollNo:        src.Get("NUMBER_SERVICE_ROLL_NO"),
+		}
+		for _, env := range []string{"NUMBER_SERVICE_CLIENT_ID", "NUMBER_SERVICE_CLIENT_SECRET"} {
+			if src.Get(env) == "" {
+				return Config{}, fmt.Errorf("%s must be set when %s is managed", src.Name(env), src.Name("NUMBER_SERVICE_AUTH"))
+			}
+		}
+	default:
+		return Config{}, fmt.Errorf("%s must be passthrough or managed, got %q", src.Name("NUMBER_SERVICE_AUTH"), mode)
+	}
+	if raw := src.Get("NUMBER_SERVICE_AUTH_URL"); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Config{}, fmt.Errorf("%s must be an http(s) URL, got %q", src.Name("NUMBER_SERVICE_AUTH_URL"), raw)
+		}
+		if cfg.Credentials != nil {
+			cfg.Credentials.AuthURL = raw
+		}
+	}
+	if raw := src.Get("NUMBER_SERVICE_TOKEN_REFRESH_MARGIN"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative duration, got %q", src.Name("NUMBER_SERVICE_TOKEN_REFRESH_MARGIN"), raw)
+		}
+		if cfg.Credentials != nil {
+			cfg.Credentials.RefreshMargin = d
+		}
+	}
+	if raw := src.Get("STREAM_MAX_SUBSCRIBERS"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative integer, got %q", src.Name("STREAM_MAX_SUBSCRIBERS"), raw)
+		}
+		cfg.StreamMaxSubscribers = n
+	}
+	if raw := src.Get("TOKEN_VALIDATION"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s must be a boolean, got %q", src.Name("TOKEN_VALIDATION"), raw)
+		}
+		cfg.TokenValidation = enabled
+	}
+	if raw := src.Get("TOKEN_VALIDATOR_URL"); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Config{}, fmt.Errorf("%s must be an http(s) URL, got %q", src.Name("TOKEN_VALIDATOR_URL"), raw)
+		}
+		cfg.TokenValidatorURL = raw
+	}
+	if raw := src.Get("TOKEN_CACHE_TTL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("%s must be a non-negative duration, got %q", src.Name("TOKEN_CACHE_TTL"), raw)
+		}
+		cfg.TokenCacheTTL = d
+	}
+	if raw := src.Get("TOKEN_CACHE_SIZE"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return Config{}, fmt.Errorf("%s must be a positive integer, got %q", src.Name("TOKEN_CACHE_SIZE"), raw)
+		}
+		cfg.TokenCacheSize = n
+	}
+
+	if cfg.HTTP, err = middleware.Load(src); err != nil {
+		return Config{}, err
+	}
+	if cfg.Server, err = httpserver.LoadOptions(src); err != nil {
+		return Config{}, err
+	}
+
+	if raw := src.Get("TLS_REDIRECT_LISTEN"); raw != "" {
+		if cfg.Server.TLS == nil {
+			return Config{}, fmt.Errorf("%s needs %s and %s", src.Name("TLS_REDIRECT_LISTEN"), src.Name("TLS_CERT_FILE"), src.Name("TLS_KEY_FILE"))
+		}
+		if _, _, err := net.SplitHostPort(raw); err != nil {
+			return Config{}, fmt.Errorf("%s must be host:port, got %q", src.Name("TLS_REDIRECT_LISTEN"), raw)
+		}
+		if network, _, _ := httpserver.ParseAddr(cfg.Listen); network != "tcp" {
+			return Config{}, fmt.Errorf("%s needs the service to listen on host:port, not %q", src.Name("TLS_REDIRECT_LISTEN"), cfg.Listen)
+		}
+		cfg.RedirectListen = raw
+	}
+
+	cfg.live = newLiveConfig(cfg.Timeout)
+	cfg.live.loaded.Store(src)
+	src.LogEffective("avg")
+	return cfg, nil
+}
+
+func newLiveConfig(timeout time.Duration) *liveConfig {
+	live := &liveConfig{}
+	live.timeout.Store(int64(timeout))
+	return live
+}
+
+// Reload applies the settings from src that are safe to change at runtime
+// to the calculator running with cfg, which is only the number service
+// timeout. Changes to the others are logged and ignored until the next
+// restart.
+func (cfg Config) Reload(src *config.Source) error {
+	if cfg.live == nil {
+		return fmt.Errorf("the configuration wasn't loaded with LoadConfig")
+	}
+	timeout, err := loadTimeout(src)
+	if err != nil {
+		return err
+	}
+
+	if loaded := cfg.live.loaded.Load(); loaded != nil {
+		for _, key := range src.Changed(loaded, "avg") {
+			if key != "NUMBER_SERVICE_TIMEOUT" {
+				log.Printf("[avg] WARNING: %s changed but only takes effect after a restart", key)
+			}
+		}
+	}
+
+	cfg.live.timeout.Store(int64(timeout))
+	log.Printf("[avg] Configuration reloaded")
+	return nil
+}
+
+func loadTimeout(src *config.Source) (time.Duration, error) {
+	raw := src.Get("NUMBER_SERVICE_TIMEOUT")
+	if raw == "" {
+		return APITimeoutMs * time.Millisecond, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration, got %q", src.Name("NUMBER_SERVICE_TIMEOUT"), raw)
+	}
+	return d, nil
+}
@@ -0,0 +1,83 @@
+package avgcalc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("avg:\n  windowSize: 20\n  windowHistorySize: 30\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		file     bool
+		env      string
+		flag     string
+		wantSize int
+	}{
+		{name: "default", wantSize: WindowSize},
+		{name: "file over default", file: true, wantSize: 20},
+		{name: "env over file", file: true, env: "40", wantSize: 40},
+		{name: "flag over env", file: true, env: "40", flag: "50", wantSize: 50},
+		{name: "flag without file", env: "40", flag: "50", wantSize: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WINDOW_SIZE", tt.env)
+			t.Setenv("WINDOW_HISTORY_SIZE", "")
+			src := config.FromEnv()
+			if tt.file {
+				var err error
+				if src, err = config.Load(path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.flag != "" {
+				if err := src.Set("WINDOW_SIZE", tt.flag); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := LoadConfig(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.WindowSize != tt.wantSize {
+				t.Errorf("WindowSize = %d, want %d", cfg.WindowSize, tt.wantSize)
+			}
+			wantHistory := WindowHistorySize
+			if tt.file {
+				wantHistory = 30
+			}
+			if cfg.HistorySize != wantHistory {
+				t.Errorf("HistorySize = %d, want %d", cfg.HistorySize, wantHistory)
+			}
+		})
+	}
+}
+
+func TestLoadConfigLeavesOtherConfigsAlone(t *testing.T) {
+	t.Setenv("NUMBER_SERVICE_TIMEOUT", "")
+	first, err := LoadConfig(config.FromEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NUMBER_SERVICE_TIMEOUT", "2s")
+	second, err := LoadConfig(config.FromEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := newCalculator(first).timeout(); got != first.Timeout {
+		t.Errorf("first calculator's timeout = %v, want %v", got, first.Timeout)
+	}
+	if got := newCalculator(second).timeout(); got.String() != "2s" {
+		t.Errorf("second calculator's timeout = %v, want 2s", got)
+	}
+}
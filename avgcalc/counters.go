@@ -0,0 +1,148 @@
+package avgcalc
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/statuspage"
+)
+
+// typeCounters count what happened to one number type.
+type typeCounters struct {
+	requests    atomic.Int64
+	failures    atomic.Int64
+	lastSuccess atomic.Int64 // Unix nanoseconds, 0 for never
+}
+
+// serviceCounters are the counts behind GET /stats, by number type. The
+// lock only guards the map; the counts themselves are atomics.
+type serviceCounters struct {
+	mu    sync.Mutex
+	types map[string]*typeCounters
+	since atomic.Int64 // Unix nanoseconds of the last reset, 0 for startup
+}
+
+// of returns the counters of numberType, creating them if needed.
+func (s *serviceCounters) of(numberType string) *typeCounters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.types == nil {
+		s.types = make(map[string]*typeCounters)
+	}
+	t, ok := s.types[numberType]
+	if !ok {
+		t = &typeCounters{}
+		s.types[numberType] = t
+	}
+	return t
+}
+
+// request counts a request to /numbers/:numberid asking for numberType.
+func (s *serviceCounters) request(numberType string) {
+	s.of(numberType).requests.Add(1)
+}
+
+// fetched counts a fetch of numberType from the number service: a failure
+// for err, the time of the last success otherwise.
+func (s *serviceCounters) fetched(numberType string, err error) {
+	t := s.of(numberType)
+	if err != nil {
+		t.failures.Add(1)
+		return
+	}
+	t.lastSuccess.Store(time.Now().UnixNano())
+}
+
+// typeStats is one number type in GET /stats.
+type typeStats struct {
+	Requests         int64      `json:"requests"`
+	UpstreamFailures int64      `json:"upstreamFailures"`
+	LastSuccessAt    *time.Time `json:"lastSuccessAt"`
+}
+
+// windowFill is the window in GET /stats: the shared window's count, or
+// with per-client windows, how many clients have one.
+type windowFill struct {
+	Count   *int  `json:"count,omitempty"`
+	Clients *int  `json:"clients,omitempty"`
+	Size    int   `json:"size"`
+	IsFull  *bool `json:"isFull,omitempty"`
+}
+
+// serviceStats is the response of GET /stats and DELETE /stats. The
+// counts are since CountingSince, startup or the last reset.
+type serviceStats struct {
+	UptimeSeconds int64                `json:"uptimeSeconds"`
+	CountingSince time.Time            `json:"countingSince"`
+	Window        windowFill           `json:"window"`
+	NumberTypes   map[string]typeStats `json:"numberTypes"`
+}
+
+// snapshot reads the counters, with a zero entry for every type in
+// registry not counted yet. With reset, the counts are zeroed as they are
+// read, so none is lost to a request counted in between.
+func (s *serviceCounters) snapshot(registry *numberTypeRegistry, reset bool) (map[string]typeStats, time.Time) {
+	for _, t := range registry.types {
+		s.of(t.Path)
+	}
+	since := time.Now().Add(-statuspage.Uptime())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if at := s.since.Load(); at != 0 {
+		since = time.Unix(0, at)
+	}
+	stats := make(map[string]typeStats, len(s.types))
+	for numberType, t := range s.types {
+		ts := typeStats{}
+		if reset {
+			ts.Requests, ts.UpstreamFailures = t.requests.Swap(0), t.failures.Swap(0)
+		} else {
+			ts.Requests, ts.UpstreamFailures = t.requests.Load(), t.failures.Load()
+		}
+		if at := t.lastSuccess.Load(); at != 0 {
+			last := time.Unix(0, at).UTC()
+			ts.LastSuccessAt = &last
+		}
+		stats[numberType] = ts
+	}
+	if reset {
+		s.since.Store(time.Now().UnixNano())
+	}
+	return stats, since
+}
+
+// serveStats serves GET /stats and, with reset, DELETE /stats, which
+// answers the counts it discarded.
+func (s *calculator) serveStats(reset bool) gin.HandlerFunc {
+	windows := s.windows
+	return func(c *gin.Context) {
+		var fill windowFill
+		fill.Size = windows.Size()
+		if windows.perClient {
+			windows.mu.Lock()
+			clients := len(windows.clients)
+			windows.mu.Unlock()
+			fill.Clients = &clients
+		} else {
+			count, size, err := windows.shared.Occupancy()
+			if err != nil {
+				s.abortStoreUnavailable(c, err)
+				return
+			}
+			full := count >= size
+			fill.Count, fill.Size, fill.IsFull = &count, size, &full
+		}
+
+		types, since := s.counters.snapshot(s.numberTypes, reset)
+		c.JSON(http.StatusOK, serviceStats{
+			UptimeSeconds: int64(statuspage.Uptime().Seconds()),
+			CountingSince: since.UTC(),
+			Window:        fill,
+			NumberTypes:   types,
+		})
+	}
+}
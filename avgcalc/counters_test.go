@@ -0,0 +1,124 @@
+package avgcalc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// getStats sends method /stats to the API at url with the admin token if
+// set and decodes the response.
+func getStats(t *testing.T, method, url, adminToken string) (int, serviceStats) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url+"/stats", nil)
+	if adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var stats serviceStats
+	json.NewDecoder(resp.Body).Decode(&stats)
+	return resp.StatusCode, stats
+}
+
+func TestStatsCounters(t *testing.T) {
+	s := newTestCalculator(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/even") {
+			writeNumbers(w, 2, 4)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}), func(cfg *Config) {
+		cfg.Retries = 0
+		cfg.CacheTTL = 0
+		cfg.AdminToken = "admin"
+	})
+	url := serveAPI(t, s)
+
+	start := time.Now()
+	for _, path := range []string{"/numbers/e", "/numbers/e", "/numbers/p", "/numbers/p,e", "/numbers/x"} {
+		getStatus(t, url, path)
+	}
+
+	status, stats := getStats(t, http.MethodGet, url, "")
+	if status != http.StatusOK {
+		t.Fatalf("GET /stats: status %d", status)
+	}
+	even, primes, fibo := stats.NumberTypes["even"], stats.NumberTypes["primes"], stats.NumberTypes["fibo"]
+	if even.Requests != 3 || even.UpstreamFailures != 0 || even.LastSuccessAt == nil || even.LastSuccessAt.Before(start.Add(-time.Second)) {
+		t.Errorf("even = %+v, want 3 requests and a recent success", even)
+	}
+	if primes.Requests != 2 || primes.UpstreamFailures != 2 || primes.LastSuccessAt != nil {
+		t.Errorf("primes = %+v, want 2 requests, both failing", primes)
+	}
+	if _, ok := stats.NumberTypes["fibo"]; !ok || fibo.Requests != 0 {
+		t.Errorf("fibo = %+v, %v, want listed without requests", fibo, ok)
+	}
+	if invalid := stats.NumberTypes["invalid"]; invalid.Requests != 1 || invalid.UpstreamFailures != 0 {
+		t.Errorf("invalid = %+v, want the unknown number ID counted once", invalid)
+	}
+	if w := stats.Window; w.Count == nil || *w.Count != 2 || w.Size != WindowSize || w.IsFull == nil || *w.IsFull {
+		t.Errorf("window = %+v, want 2 of %d numbers", w, WindowSize)
+	}
+
+	// Resetting needs the admin token and answers the counts discarded.
+	if status, _ := getStats(t, http.MethodDelete, url, ""); status != http.StatusUnauthorized {
+		t.Errorf("DELETE /stats without the admin token: status %d, want 401", status)
+	}
+	status, discarded := getStats(t, http.MethodDelete, url, "admin")
+	if status != http.StatusOK || discarded.NumberTypes["even"].Requests != 3 || discarded.NumberTypes["primes"].UpstreamFailures != 2 {
+		t.Errorf("DELETE /stats: status %d, %+v, want the counts before the reset", status, discarded.NumberTypes)
+	}
+
+	_, stats = getStats(t, http.MethodGet, url, "")
+	even, primes = stats.NumberTypes["even"], stats.NumberTypes["primes"]
+	if even.Requests != 0 || primes.Requests != 0 || primes.UpstreamFailures != 0 {
+		t.Errorf("after the reset: even %+v, primes %+v, want no requests or failures", even, primes)
+	}
+	if even.LastSuccessAt == nil {
+		t.Error("reset forgot the last success")
+	}
+	if !stats.CountingSince.After(discarded.CountingSince) {
+		t.Errorf("countingSince %s, want after the reset", stats.CountingSince)
+	}
+
+	getStatus(t, url, "/numbers/p")
+	if _, stats = getStats(t, http.MethodGet, url, ""); stats.NumberTypes["primes"].UpstreamFailures != 1 {
+		t.Errorf("primes after the reset = %+v, want counting again", stats.NumberTypes["primes"])
+	}
+}
+
+func TestStatsCountersConcurrent(t *testing.T) {
+	var counters serviceCounters
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counters.request("even")
+				counters.fetched("even", nil)
+			}
+		}()
+	}
+
+	// Resets while counting lose nothing: every request is in exactly one
+	// snapshot.
+	registry := newNumberTypeRegistry(DefaultNumberTypes)
+	var total int64
+	for i := 0; i < 20; i++ {
+		stats, _ := counters.snapshot(registry, true)
+		total += stats["even"].Requests
+	}
+	wg.Wait()
+	stats, _ := counters.snapshot(registry, true)
+	if total += stats["even"].Requests; total != 8000 {
+		t.Errorf("%d requests counted across resets, want 8000", total)
+	}
+}
@@ -0,0 +1,234 @@
+package avgcalc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+)
+
+const (
+	// CredentialsRefreshMargin is how long before the number service token
+	// expires it is replaced, so requests never go out with a token about
+	// to lapse. Short-lived tokens are replaced halfway through instead.
+	CredentialsRefreshMargin = 30 * time.Second
+
+	// CredentialsTokenTTL is taken as the lifetime of a token the auth
+	// endpoint gives none for.
+	CredentialsTokenTTL = 5 * time.Minute
+
+	CredentialsTimeout = 2 * time.Second
+
+	// CredentialsRetry is how long a failed background refresh waits
+	// before trying again.
+	CredentialsRetry = 5 * time.Second
+)
+
+// credentials holds the calculator's own token for the number service,
+// with NUMBER_SERVICE_AUTH=managed. It is obtained from the auth endpoint
+// with the configured client ID and secret, replaced in the background
+// before it expires, and on demand when the number service refuses it.
+// Refreshes are shared: however many requests find the token expired, the
+// auth endpoint is called once.
+type credentials struct {
+	enabled bool
+	url     string
+	request authRequest
+	margin  time.Duration
+	client  *http.Client
+	logger  *slog.Logger
+
+	mu        sync.Mutex
+	token     string
+	expires   time.Time
+	refreshAt time.Time
+	refresh   *tokenRefresh
+}
+
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// authRequest is the body the test server's auth endpoint expects.
+type authRequest struct {
+	CompanyName  string `json:"companyName,omitempty"`
+	ClientID     string `json:"clientID"`
+	ClientSecret string `json:"clientSecret"`
+	OwnerName    string `json:"ownerName,omitempty"`
+	OwnerEmail   string `json:"ownerEmail,omitempty"`
+	RollNo       string `json:"rollNo,omitempty"`
+}
+
+type authResponse struct {
+	TokenType   string `json:"token_type"`
+	AccessToken string `json:"access_token"`
+	// ExpiresIn is seconds from now, or, as the test server sends it, the
+	// Unix time the token expires at.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// newCredentials returns the credentials mc describes, calling the auth
+// endpoint with client. They are disabled if mc is nil.
+func newCredentials(mc *ManagedCredentials, client *http.Client, baseURL string, logger *slog.Logger) *credentials {
+	c := &credentials{client: client, logger: logger}
+	if mc == nil {
+		return c
+	}
+	c.enabled, c.url, c.margin = true, mc.AuthURL, mc.RefreshMargin
+	if c.url == "" {
+		c.url = serviceURL(baseURL, "auth")
+	}
+	c.request = authRequest{
+		CompanyName:  mc.CompanyName,
+		ClientID:     mc.ClientID,
+		ClientSecret: mc.ClientSecret,
+		OwnerName:    mc.OwnerName,
+		OwnerEmail:   mc.OwnerEmail,
+		RollNo:       mc.RollNo,
+	}
+	return c
+}
+
+// get returns a token for the number service, obtaining a new one when
+// there is none or it is due to be replaced. A token that is due but not
+// yet expired is still returned if replacing it fails.
+func (c *credentials) get(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	now := time.Now()
+	if c.token != "" && now.Before(c.refreshAt) {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+	r := c.refresh
+	if r == nil {
+		r = &tokenRefresh{done: make(chan struct{})}
+		c.refresh = r
+		go c.obtain(ctx, r)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if r.err != nil {
+		c.mu.Lock()
+		token, expires := c.token, c.expires
+		c.mu.Unlock()
+		if token != "" && time.Now().Before(expires) {
+			return token, nil
+		}
+		return "", r.err
+	}
+	return r.token, nil
+}
+
+// obtain calls the auth endpoint for r, which every caller of get waits
+// on. It is not cancelled with the request that started it, since the
+// others still want the token.
+func (c *credentials) obtain(ctx context.Context, r *tokenRefresh) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), CredentialsTimeout)
+	defer cancel()
+	token, lifetime, err := c.requestToken(ctx)
+
+	c.mu.Lock()
+	if err == nil {
+		now := time.Now()
+		c.token, c.expires = token, now.Add(lifetime)
+		c.refreshAt = c.expires.Add(-min(c.margin, lifetime/2))
+	}
+	r.token, r.err = token, err
+	c.refresh = nil
+	c.mu.Unlock()
+	close(r.done)
+
+	if err != nil {
+		c.logger.Warn("Failed to obtain number service token", "error", err)
+		return
+	}
+	c.logger.Info("Obtained number service token", "expiresIn", lifetime.Round(time.Second).String())
+}
+
+func (c *credentials) requestToken(ctx context.Context) (string, time.Duration, error) {
+	body, err := json.Marshal(c.request)
+	if err != nil {
+		return "", 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", 0, apperr.Wrap(apperr.ErrUpstreamUnreachable, "failed to obtain a number service token: "+err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", 0, apperr.Wrap(apperr.ErrUpstreamUnreachable, "number service auth endpoint answered "+resp.Status)
+	}
+
+	var auth authResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&auth); err != nil {
+		return "", 0, apperr.Wrap(apperr.ErrUpstreamUnreachable, fmt.Sprintf("failed to decode the number service token: %v", err))
+	}
+	if auth.AccessToken == "" {
+		return "", 0, apperr.Wrap(apperr.ErrUpstreamUnreachable, "number service auth endpoint sent no token")
+	}
+	lifetime := CredentialsTokenTTL
+	switch {
+	case auth.ExpiresIn > 1e9:
+		lifetime = time.Until(time.Unix(auth.ExpiresIn, 0))
+	case auth.ExpiresIn > 0:
+		lifetime = time.Duration(auth.ExpiresIn) * time.Second
+	}
+	if lifetime <= 0 {
+		return "", 0, apperr.Wrap(apperr.ErrUpstreamUnreachable, "number service auth endpoint sent an expired token")
+	}
+	return auth.AccessToken, lifetime, nil
+}
+
+// expire drops token after the number service refused it, so the next get
+// obtains a new one. Requests that were refused with a token already
+// replaced leave the new one alone.
+func (c *credentials) expire(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+		c.logger.Info("Number service refused our token, obtaining a new one")
+	}
+}
+
+// run obtains the first token and replaces each one before it expires,
+// until ctx is cancelled, so requests seldom wait for the auth endpoint.
+func (c *credentials) run(ctx context.Context) {
+	if !c.enabled {
+		return
+	}
+	for {
+		wait := CredentialsRetry
+		if _, err := c.get(ctx); err == nil {
+			c.mu.Lock()
+			wait = max(time.Until(c.refreshAt), time.Second)
+			c.mu.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
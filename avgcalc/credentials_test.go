@@ -0,0 +1,177 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAuth is a number service with an auth endpoint, issuing tokens that
+// last lifetime seconds and answering only requests with one it issued
+// and hasn't revoked.
+type fakeAuth struct {
+	lifetime int64
+	delay    time.Duration
+
+	mu     sync.Mutex
+	issued int
+	valid  map[string]bool
+	used   []string
+}
+
+func newFakeAuth(lifetime int64) *fakeAuth {
+	return &fakeAuth{lifetime: lifetime, valid: map[string]bool{}}
+}
+
+func (a *fakeAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/auth" {
+		var req authRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ClientID != "id" || req.ClientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		time.Sleep(a.delay)
+		a.mu.Lock()
+		a.issued++
+		token := fmt.Sprintf("token-%d", a.issued)
+		a.valid[token] = true
+		a.mu.Unlock()
+		json.NewEncoder(w).Encode(authResponse{TokenType: "Bearer", AccessToken: token, ExpiresIn: a.lifetime})
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	a.mu.Lock()
+	a.used = append(a.used, token)
+	valid := a.valid[token]
+	a.mu.Unlock()
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeNumbers(w, 2, 4)
+}
+
+// revoke refuses every token issued so far.
+func (a *fakeAuth) revoke() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.valid = map[string]bool{}
+}
+
+// state returns how many tokens were issued and the tokens the number
+// endpoints were called with.
+func (a *fakeAuth) state() (int, []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.issued, append([]string(nil), a.used...)
+}
+
+// newManagedCalculator returns a calculator in managed mode with a as its
+// number service.
+func newManagedCalculator(t *testing.T, a *fakeAuth) *calculator {
+	return newTestCalculator(t, a, func(cfg *Config) {
+		cfg.Credentials = &ManagedCredentials{ClientID: "id", ClientSecret: "secret", RefreshMargin: time.Minute}
+		cfg.CacheTTL = 0
+	})
+}
+
+func TestManagedCredentialsWithoutAuthorization(t *testing.T) {
+	a := newFakeAuth(300)
+	url := serveAPI(t, newManagedCalculator(t, a))
+
+	resp, err := http.Get(url + "/numbers/e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d without an Authorization header, want 200", resp.StatusCode)
+	}
+	if issued, used := a.state(); issued != 1 || len(used) != 1 || used[0] != "token-1" {
+		t.Errorf("%d tokens issued, number service called with %v, want token-1 once", issued, used)
+	}
+}
+
+func TestManagedCredentialsRefreshBeforeExpiry(t *testing.T) {
+	// A two-second token is replaced halfway through, as the margin is
+	// longer than that.
+	a := newFakeAuth(2)
+	s := newManagedCalculator(t, a)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.credentials.run(ctx)
+
+	deadline := time.Now().Add(1800 * time.Millisecond)
+	for {
+		if issued, _ := a.state(); issued >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("token not replaced before it expired")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if status, _ := getStatus(t, serveAPI(t, s), "/numbers/e"); status != http.StatusOK {
+		t.Fatalf("status %d after the refresh", status)
+	}
+	if _, used := a.state(); len(used) != 1 || used[0] != "token-2" {
+		t.Errorf("number service called with %v, want the replacement token-2 alone", used)
+	}
+}
+
+func TestManagedCredentialsRefreshOnUnauthorized(t *testing.T) {
+	a := newFakeAuth(300)
+	url := serveAPI(t, newManagedCalculator(t, a))
+
+	if status, _ := getStatus(t, url, "/numbers/e"); status != http.StatusOK {
+		t.Fatalf("first request: status %d", status)
+	}
+
+	// The token is revoked long before it expires; the next request is
+	// refused with it, gets a new one and tries again.
+	a.revoke()
+	if status, _ := getStatus(t, url, "/numbers/e"); status != http.StatusOK {
+		t.Fatalf("request after revocation: status %d", status)
+	}
+	issued, used := a.state()
+	if want := []string{"token-1", "token-1", "token-2"}; issued != 2 || strings.Join(used, " ") != strings.Join(want, " ") {
+		t.Errorf("%d tokens issued, number service called with %v, want 2 and %v", issued, used, want)
+	}
+}
+
+func TestManagedCredentialsSharedRefresh(t *testing.T) {
+	a := newFakeAuth(300)
+	a.delay = 50 * time.Millisecond
+	s := newManagedCalculator(t, a)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 20)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := s.credentials.get(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			tokens[i] = token
+		}(i)
+	}
+	wg.Wait()
+
+	if issued, _ := a.state(); issued != 1 {
+		t.Errorf("auth endpoint called %d times by concurrent requests, want once", issued)
+	}
+	for _, token := range tokens {
+		if token != "token-1" {
+			t.Errorf("got token %q, want the shared token-1", token)
+		}
+	}
+}
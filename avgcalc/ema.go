@@ -0,0 +1,121 @@
+package avgcalc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxEMAs bounds how many alphas a window tracks at once. Past it the least
+// recently requested one is dropped and starts over if asked for again.
+const maxEMAs = 16
+
+// ema is an exponential moving average over the numbers that entered a
+// window, in the order they did.
+type ema struct {
+	value    float64
+	seeded   bool
+	lastUsed time.Time
+}
+
+// add folds n into the average; the first number seeds it.
+func (e *ema) add(alpha float64, n float64) {
+	if !e.seeded {
+		e.value, e.seeded = n, true
+		return
+	}
+	e.value = alpha*n + (1-alpha)*e.value
+}
+
+// AddNumbersEMA is AddNumbers that also reports the moving average for
+// alpha. An alpha not asked for before starts from the numbers already in
+// the window, oldest first.
+func (ns *NumberStore) AddNumbersEMA(newNumbers []float64, alpha float64, types ...string) (Added, error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	now := time.Now()
+	ns.expire(now)
+	e := ns.trackEMA(alpha)
+	added := ns.add(types, newNumbers, now)
+	added.EMA = e.value
+	return added, nil
+}
+
+// trackEMA returns the moving average for alpha, creating it if needed.
+// The caller must hold the write lock and have expired old numbers.
+func (ns *NumberStore) trackEMA(alpha float64) *ema {
+	if ns.emas == nil {
+		ns.emas = make(map[float64]*ema)
+	}
+	e, ok := ns.emas[alpha]
+	if !ok {
+		if len(ns.emas) >= maxEMAs {
+			ns.dropOldestEMA()
+		}
+		e = &ema{}
+		for i := 0; i < ns.count; i++ {
+			e.add(alpha, ns.buf[ns.at(i)])
+		}
+		ns.emas[alpha] = e
+	}
+	e.lastUsed = time.Now()
+	return e
+}
+
+func (ns *NumberStore) dropOldestEMA() {
+	var oldest float64
+	var oldestUsed time.Time
+	for alpha, e := range ns.emas {
+		if oldestUsed.IsZero() || e.lastUsed.Before(oldestUsed) {
+			oldest, oldestUsed = alpha, e.lastUsed
+		}
+	}
+	delete(ns.emas, oldest)
+}
+
+// averaging is the average a request asked for on top of the plain one:
+// the moving average for alpha with ema, or the weighted average.
+type averaging struct {
+	ema      bool
+	alpha    float64
+	weighted bool
+}
+
+// parseAvgMode reads ?avgMode= and ?alpha=. It answers 400 to bad values.
+func parseAvgMode(c *gin.Context) (averaging, bool) {
+	avg, err := avgMode(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return averaging{}, false
+	}
+	return avg, true
+}
+
+// avgMode reads avgMode and alpha from the query.
+func avgMode(c *gin.Context) (averaging, error) {
+	return parseMode(c.Query("avgMode"), c.Query("alpha"))
+}
+
+// parseMode reads an averaging mode, mean, ema or weighted, and for ema
+// its alpha.
+func parseMode(mode, rawAlpha string) (averaging, error) {
+	switch mode {
+	case "", "mean":
+		return averaging{}, nil
+	case "weighted":
+		return averaging{weighted: true}, nil
+	case "ema":
+	default:
+		return averaging{}, fmt.Errorf("avgMode must be mean, ema or weighted, got %q", mode)
+	}
+
+	alpha, err := strconv.ParseFloat(rawAlpha, 64)
+	if err != nil || !(alpha > 0 && alpha <= 1) {
+		return averaging{}, fmt.Errorf("alpha must be a number in (0, 1], got %q", rawAlpha)
+	}
+	return averaging{ema: true, alpha: alpha}, nil
+}
@@ -0,0 +1,62 @@
+package avgcalc
+
+import "testing"
+
+func TestEMA(t *testing.T) {
+	ns := newNumberStore(2, 0, windowOptions{})
+	addEMA := func(alpha float64, numbers ...float64) float64 {
+		t.Helper()
+		added, err := ns.AddNumbersEMA(numbers, alpha)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return added.EMA
+	}
+
+	// Seeded with 10, then 0.5*20 + 0.5*10 = 15 and 0.5*40 + 0.5*15 = 27.5.
+	if got := addEMA(0.5, 10, 20, 40); got != 27.5 {
+		t.Errorf("EMA = %v, want 27.5", got)
+	}
+	// 40 is already in the window and doesn't count again. 10 was evicted
+	// from it but still counts.
+	if got := addEMA(0.5, 40); got != 27.5 {
+		t.Errorf("EMA after a duplicate = %v, want 27.5", got)
+	}
+	// A new alpha starts from the window, 20 then 40: 0.25*40 + 0.75*20 =
+	// 25, then 0.25*5 + 0.75*25 = 20 and the others go on from 27.5 with
+	// 0.5*5 + 0.5*27.5 = 16.25.
+	if got := addEMA(0.25, 5); got != 20 {
+		t.Errorf("EMA of a new alpha = %v, want 20", got)
+	}
+	if got := addEMA(0.5); got != 16.25 {
+		t.Errorf("EMA of the first alpha = %v, want 16.25", got)
+	}
+	if got := addEMA(1, 7); got != 7 {
+		t.Errorf("EMA with alpha 1 = %v, want the last number, 7", got)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		mode, alpha string
+		want        averaging
+		wantErr     bool
+	}{
+		{mode: "", want: averaging{}},
+		{mode: "mean", alpha: "0.3", want: averaging{}},
+		{mode: "weighted", want: averaging{weighted: true}},
+		{mode: "ema", alpha: "0.3", want: averaging{ema: true, alpha: 0.3}},
+		{mode: "ema", alpha: "1", want: averaging{ema: true, alpha: 1}},
+		{mode: "ema", alpha: "0", wantErr: true},
+		{mode: "ema", alpha: "1.5", wantErr: true},
+		{mode: "ema", alpha: "NaN", wantErr: true},
+		{mode: "ema", wantErr: true},
+		{mode: "median", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseMode(tt.mode, tt.alpha)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseMode(%q, %q) = %+v, %v, want %+v, error %v", tt.mode, tt.alpha, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package avgcalc
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+// Error codes in the error envelope of GET /numbers/:numberid. Clients
+// match on them, so they never change once released.
+const (
+	CodeMissingAuthorization    = "MISSING_AUTHORIZATION"
+	CodeMalformedAuthorization  = "MALFORMED_AUTHORIZATION"
+	CodeInvalidToken            = "INVALID_TOKEN"
+	CodeInvalidNumberID         = "INVALID_NUMBER_ID"
+	CodeInvalidAvgMode          = "INVALID_AVG_MODE"
+	CodeUpstreamUnauthorized    = "UPSTREAM_UNAUTHORIZED"
+	CodeUpstreamRejected        = "UPSTREAM_REJECTED"
+	CodeUpstreamTimeout         = "UPSTREAM_TIMEOUT"
+	CodeUpstreamUnavailable     = "UPSTREAM_UNAVAILABLE"
+	CodeUpstreamInvalidResponse = "UPSTREAM_INVALID_RESPONSE"
+	CodeAllNumberTypesFailed    = "ALL_NUMBER_TYPES_FAILED"
+	CodeStoreUnavailable        = "STORE_UNAVAILABLE"
+	CodeInternal                = "INTERNAL_ERROR"
+)
+
+// requestError is a request refused before any work was done.
+type requestError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *requestError) Error() string { return e.message }
+
+// ErrorEnvelope is the error body of GET /numbers/:numberid, and of any
+// route refusing a token the validator refused.
+type ErrorEnvelope struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody is the envelope's "error" object. The window fields are only
+// sent when the request got as far as the window.
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId,omitempty"`
+
+	// ValidIDs lists the registered number IDs, for INVALID_NUMBER_ID.
+	ValidIDs []string `json:"validIds,omitempty"`
+
+	Failures    map[string]string `json:"failures,omitempty"`
+	Attempts    map[string]int    `json:"attempts,omitempty"`
+	WindowCount *int              `json:"windowCount,omitempty"`
+	WindowSize  *int              `json:"windowSize,omitempty"`
+	IsFull      *bool             `json:"isFull,omitempty"`
+
+	// The timeout fields are those of APIResponse.
+	TimeoutMs      *int64 `json:"timeoutMs,omitempty"`
+	TimeoutClamped bool   `json:"timeoutClamped,omitempty"`
+	TimeoutWarning string `json:"timeoutWarning,omitempty"`
+
+	// Meta is that of APIResponse, with what is known of the calls made.
+	Meta *ResponseMeta `json:"meta,omitempty"`
+}
+
+// abortWithError answers {"error": body} with status, stamped with the
+// request ID.
+func abortWithError(c *gin.Context, status int, body ErrorBody) {
+	body.RequestID = middleware.RequestID(c)
+	c.AbortWithStatusJSON(status, ErrorEnvelope{Error: body})
+}
+
+// abortStoreUnavailable answers 503 for a window that couldn't be read or
+// changed, which only happens to a window in Redis.
+func (s *calculator) abortStoreUnavailable(c *gin.Context, err error) {
+	s.logRequest(c.Request.Context(), slog.LevelError, "Window store failed", slog.Any("error", err))
+	abortWithError(c, http.StatusServiceUnavailable, ErrorBody{Code: CodeStoreUnavailable, Message: err.Error()})
+}
+
+// upstreamCode is the error code for a failed fetch.
+func upstreamCode(err error) string {
+	switch {
+	case errors.Is(err, apperr.ErrUnauthorized):
+		return CodeUpstreamUnauthorized
+	case errors.Is(err, apperr.ErrBadRequest):
+		return CodeUpstreamRejected
+	case errors.Is(err, apperr.ErrUpstreamTimeout):
+		return CodeUpstreamTimeout
+	case errors.Is(err, apperr.ErrDecode):
+		return CodeUpstreamInvalidResponse
+	case apperr.HTTPStatus(err) == http.StatusBadGateway:
+		return CodeUpstreamUnavailable
+	default:
+		return CodeInternal
+	}
+}
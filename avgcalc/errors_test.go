@@ -0,0 +1,72 @@
+package avgcalc
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// getStatus gets path from the API at url with the token and returns the
+// status and error envelope.
+func getStatus(t *testing.T, url, path string) (int, ErrorEnvelope) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url+path, nil)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var envelope ErrorEnvelope
+	json.NewDecoder(resp.Body).Decode(&envelope)
+	return resp.StatusCode, envelope
+}
+
+func TestNumbersErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream http.HandlerFunc
+		status   int
+		code     string
+	}{
+		{
+			name:     "token refused upstream",
+			upstream: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusUnauthorized) },
+			status:   http.StatusUnauthorized,
+			code:     CodeUpstreamUnauthorized,
+		},
+		{
+			name:     "request refused upstream",
+			upstream: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			status:   http.StatusBadRequest,
+			code:     CodeUpstreamRejected,
+		},
+		{
+			name:     "upstream failing",
+			upstream: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+			status:   http.StatusBadGateway,
+			code:     CodeUpstreamUnavailable,
+		},
+		{
+			name:     "undecodable body",
+			upstream: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("not json")) },
+			status:   http.StatusBadGateway,
+			code:     CodeUpstreamInvalidResponse,
+		},
+		{
+			name:     "no numbers",
+			upstream: func(w http.ResponseWriter, r *http.Request) { writeNumbers(w) },
+			status:   http.StatusBadGateway,
+			code:     CodeUpstreamInvalidResponse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestCalculator(t, tt.upstream, func(cfg *Config) { cfg.Retries = 0 })
+			status, envelope := getStatus(t, serveAPI(t, s), "/numbers/e")
+			if status != tt.status || envelope.Error.Code != tt.code {
+				t.Errorf("status, code = %d, %s, want %d, %s", status, envelope.Error.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package avgcalc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// windowETag is the entity tag of a window at snapshot sent in format,
+// size being how many numbers the window holds and timed whether it is
+// time based. It is derived from the window's version rather than the
+// response, so lastUpdated moving on alone doesn't change it. Numbers
+// expiring from a timed window don't count as a change to the version,
+// but they only ever shrink it, so its count tells them apart.
+func windowETag(snapshot WindowEvent, size int, timed bool, format string) string {
+	mode := "count"
+	if timed {
+		mode = "time"
+	}
+	key := fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%d", format, mode, size, snapshot.Seq, snapshot.Count)
+	sum := sha256.Sum256([]byte(key))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified sets etag on the response and answers 304 without a body
+// when c's If-None-Match already has it.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	c.Writer.Header().Add("Vary", "Accept")
+	for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			c.AbortWithStatus(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package avgcalc
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getWindow reads GET /window at url, sending ifNoneMatch if set, and
+// returns the status and ETag.
+func getWindow(t *testing.T, url, ifNoneMatch string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url+"/window", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("ETag")
+}
+
+// sendWindow sends method to url's path with the token and body if set,
+// expecting 200.
+func sendWindow(t *testing.T, method, url, body string) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s %s: status %d", method, url, resp.StatusCode)
+	}
+}
+
+func TestWindowETag(t *testing.T) {
+	s := newTestCalculator(t, http.NotFoundHandler(), nil)
+	url := serveAPI(t, s)
+
+	status, empty := getWindow(t, url, "")
+	if status != http.StatusOK || empty == "" {
+		t.Fatalf("GET /window: status %d, ETag %q", status, empty)
+	}
+	if _, again := getWindow(t, url, ""); again != empty {
+		t.Errorf("unchanged window: ETag %s, then %s", empty, again)
+	}
+	if status, _ := getWindow(t, url, empty); status != http.StatusNotModified {
+		t.Errorf("If-None-Match with the current ETag: status %d, want 304", status)
+	}
+
+	sendWindow(t, http.MethodPost, url+"/numbers", `{"numbers": [1, 2]}`)
+	_, added := getWindow(t, url, "")
+	if added == empty {
+		t.Error("ETag unchanged after numbers were added")
+	}
+	if status, _ := getWindow(t, url, empty); status != http.StatusOK {
+		t.Errorf("If-None-Match with the old ETag: status %d, want 200", status)
+	}
+
+	// Only lastUpdated moves on when every number is already in the window.
+	sendWindow(t, http.MethodPost, url+"/numbers", `{"numbers": [2]}`)
+	if _, dup := getWindow(t, url, ""); dup != added {
+		t.Errorf("ETag changed from %s to %s with only duplicates added", added, dup)
+	}
+
+	sendWindow(t, http.MethodDelete, url+"/window", "")
+	_, reset := getWindow(t, url, "")
+	if reset == added || reset == empty {
+		t.Errorf("ETag after reset = %s, want one new, not %s or %s", reset, added, empty)
+	}
+}
+
+func TestWindowETagOfTimedWindow(t *testing.T) {
+	ns := newNumberStore(10, 50*time.Millisecond, windowOptions{})
+	mustAdd(t, ns, 1, 2)
+	before, err := ns.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(80 * time.Millisecond)
+	after, err := ns.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Seq != before.Seq || after.Count != 0 {
+		t.Fatalf("expired window: seq %d, count %d, want seq %d, count 0", after.Seq, after.Count, before.Seq)
+	}
+	if windowETag(before, 10, true, "json") == windowETag(after, 10, true, "json") {
+		t.Error("ETag unchanged after the numbers expired")
+	}
+
+	tag := windowETag(after, 10, true, "json")
+	for name, other := range map[string]string{
+		"size":   windowETag(after, 20, true, "json"),
+		"mode":   windowETag(after, 10, false, "json"),
+		"format": windowETag(after, 10, true, "csv"),
+	} {
+		if other == tag {
+			t.Errorf("ETag unchanged by the window's %s", name)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package avgcalc
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Export formats of GET /window and GET /window/history besides JSON.
+const (
+	MIMECSV    = "text/csv"
+	MIMENDJSON = "application/x-ndjson"
+)
+
+// exportFormat reads the format c asks for, json, csv or ndjson: ?format=
+// if given, else the Accept header, else JSON. It answers 400 to an
+// unknown ?format=.
+func exportFormat(c *gin.Context) (string, bool) {
+	switch format := c.Query("format"); format {
+	case "json", "csv", "ndjson":
+		return format, true
+	case "":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or ndjson, got " + strconv.Quote(format)})
+		return "", false
+	}
+	switch c.NegotiateFormat(gin.MIMEJSON, MIMECSV, MIMENDJSON) {
+	case MIMECSV:
+		return "csv", true
+	case MIMENDJSON:
+		return "ndjson", true
+	default:
+		return "json", true
+	}
+}
+
+// startExport answers 200 with a download of name in format, csv or
+// ndjson, for the body to be written to c.Writer.
+func startExport(c *gin.Context, format, name string) {
+	contentType := MIMECSV + "; charset=utf-8"
+	if format == "ndjson" {
+		contentType = MIMENDJSON
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+name+"."+format+`"`)
+	c.Status(http.StatusOK)
+}
+
+// windowRow is one number of the window in NDJSON. Position 1 is the
+// oldest. AddedAt is only known for numbers added within the history.
+type windowRow struct {
+	Value    float64    `json:"value"`
+	Position int        `json:"position"`
+	AddedAt  *time.Time `json:"addedAt,omitempty"`
+}
+
+// addedTimes maps the numbers added in history, newest first, to when
+// they last were.
+func addedTimes(history []WindowSnapshot) map[float64]time.Time {
+	times := make(map[float64]time.Time)
+	for i := len(history) - 1; i >= 0; i-- {
+		for _, n := range history[i].Added {
+			times[n] = history[i].Time
+		}
+	}
+	return times
+}
+
+// exportWindow writes numbers, oldest first, in format as they are
+// encoded rather than all at once.
+func exportWindow(c *gin.Context, format string, numbers []float64, addedAt map[float64]time.Time) {
+	startExport(c, format, "window")
+	if format == "ndjson" {
+		writeNDJSON(c.Writer, len(numbers), func(i int) any {
+			row := windowRow{Value: numbers[i], Position: i + 1}
+			if at, ok := addedAt[numbers[i]]; ok {
+				row.AddedAt = &at
+			}
+			return row
+		})
+		return
+	}
+	writeCSV(c.Writer, []string{"value", "position", "addedAt"}, len(numbers), func(i int) []string {
+		at := ""
+		if t, ok := addedAt[numbers[i]]; ok {
+			at = t.UTC().Format(time.RFC3339Nano)
+		}
+		return []string{formatNumber(numbers[i]), strconv.Itoa(i + 1), at}
+	})
+}
+
+// exportHistory writes history in format. In CSV, the lists are
+// space-separated within their cell.
+func exportHistory(c *gin.Context, format string, history []WindowSnapshot) {
+	startExport(c, format, "window-history")
+	if format == "ndjson" {
+		writeNDJSON(c.Writer, len(history), func(i int) any { return history[i] })
+		return
+	}
+	header := []string{"seq", "time", "types", "added", "evicted", "duplicatesDiscarded", "avg", "count"}
+	writeCSV(c.Writer, header, len(history), func(i int) []string {
+		s := history[i]
+		return []string{
+			strconv.FormatUint(s.Seq, 10),
+			s.Time.UTC().Format(time.RFC3339Nano),
+			strings.Join(s.Types, " "),
+			formatNumberList(s.Added),
+			formatNumberList(s.Evicted),
+			strconv.Itoa(s.DuplicatesDiscarded),
+			strconv.FormatFloat(s.Average.Rounded(), 'f', 2, 64),
+			strconv.Itoa(s.Count),
+		}
+	})
+}
+
+// writeCSV writes header and n rows to w, stopping at the first error,
+// which can only be the client going away.
+func writeCSV(w io.Writer, header []string, n int, row func(int) []string) {
+	cw := csv.NewWriter(w)
+	if cw.Write(header) != nil {
+		return
+	}
+	for i := 0; i < n; i++ {
+		if cw.Write(row(i)) != nil {
+			return
+		}
+	}
+	cw.Flush()
+}
+
+// writeNDJSON writes n values to w, one JSON object per line.
+func writeNDJSON(w io.Writer, n int, value func(int) any) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for i := 0; i < n; i++ {
+		if enc.Encode(value(i)) != nil {
+			return
+		}
+	}
+	bw.Flush()
+}
+
+// formatNumber writes n in full, without a fractional part for integers.
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func formatNumberList(numbers []float64) string {
+	parts := make([]string, len(numbers))
+	for i, n := range numbers {
+		parts[i] = formatNumber(n)
+	}
+	return strings.Join(parts, " ")
+}
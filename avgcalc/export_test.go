@@ -0,0 +1,64 @@
+package avgcalc
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWriteCSVEscaping(t *testing.T) {
+	rows := [][]string{
+		{"plain", "1"},
+		{"with, comma", "2"},
+		{`with "quotes"`, "3"},
+		{"with\nnewline", "4"},
+		{" leading space", ""},
+	}
+	var b strings.Builder
+	writeCSV(&b, []string{"name", "n"}, len(rows), func(i int) []string { return rows[i] })
+
+	want := "name,n\n" +
+		"plain,1\n" +
+		"\"with, comma\",2\n" +
+		"\"with \"\"quotes\"\"\",3\n" +
+		"\"with\nnewline\",4\n" +
+		"\" leading space\",\n"
+	if b.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestExportHistoryCSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 500, time.FixedZone("CEST", 2*60*60))
+	exportHistory(c, "csv", []WindowSnapshot{
+		{
+			Seq:                 7,
+			Time:                at,
+			Types:               []string{"even", `odd,"sq"`},
+			Added:               []float64{0.1, -2.5, 1e21},
+			Evicted:             []float64{},
+			DuplicatesDiscarded: 1,
+			Average:             Fixed2(1.0 / 3),
+			Count:               3,
+		},
+	})
+
+	want := "seq,time,types,added,evicted,duplicatesDiscarded,avg,count\n" +
+		"7,2024-05-01T10:00:00.0000005Z,\"even odd,\"\"sq\"\"\",0.1 -2.5 1000000000000000000000,,1,0.33,3\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", got, want)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="window-history.csv"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+package avgcalc
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+)
+
+const (
+	// FallbackBatch is how many numbers a local generator makes at a time.
+	FallbackBatch = 10
+
+	FallbackRandomMin = 1
+	FallbackRandomMax = 100
+)
+
+// fallbackLimit is where the primes, Fibonacci numbers and even numbers
+// start over. Past 2^53 not every integer is a float64 any more.
+const fallbackLimit = 1 << 53
+
+// primeLimit is where the primes start over instead, keeping the sieve
+// small.
+const primeLimit = 1_000_000
+
+// generators stand in for the number service when it fails, with
+// FALLBACK_GENERATORS set. Each type carries on from the largest number it
+// has seen, fetched or generated, so successive batches are new numbers.
+type generators struct {
+	enabled          bool
+	randMin, randMax int
+
+	mu      sync.Mutex
+	largest map[string]float64
+}
+
+// observe notes numbers of numberType fetched from the number service.
+func (g *generators) observe(numberType string, numbers []float64) {
+	if !g.enabled {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.largest == nil {
+		g.largest = make(map[string]float64)
+	}
+	for _, n := range numbers {
+		g.largest[numberType] = max(g.largest[numberType], n)
+	}
+}
+
+// generate makes the next batch of numberType in place of a fetch that
+// failed with err. Like stale numbers, it only stands in for the number
+// service failing.
+func (g *generators) generate(numberType string, err error) ([]float64, bool) {
+	if !g.enabled || apperr.HTTPStatus(err) < http.StatusBadGateway {
+		return nil, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.largest == nil {
+		g.largest = make(map[string]float64)
+	}
+
+	var batch []float64
+	switch numberType {
+	case "primes":
+		batch = primesAfter(g.largest[numberType], FallbackBatch)
+	case "fibo":
+		batch = fibonacciAfter(g.largest[numberType], FallbackBatch)
+	case "even":
+		batch = evensAfter(g.largest[numberType], FallbackBatch)
+	case "rand":
+		batch = make([]float64, FallbackBatch)
+		for i := range batch {
+			batch[i] = float64(g.randMin + rand.Intn(g.randMax-g.randMin+1))
+		}
+		return batch, true
+	default:
+		return nil, false
+	}
+	g.largest[numberType] = batch[len(batch)-1]
+	return batch, true
+}
+
+// primesAfter returns the n primes after last, sieving ever further until
+// there are enough.
+func primesAfter(last float64, n int) []float64 {
+	if last >= primeLimit {
+		last = 0
+	}
+	from := int(last) + 1
+	for limit := max(2*from, 64); ; limit *= 2 {
+		composite := make([]bool, limit+1)
+		var primes []float64
+		for i := 2; i <= limit; i++ {
+			if composite[i] {
+				continue
+			}
+			if i >= from {
+				primes = append(primes, float64(i))
+				if len(primes) == n {
+					return primes
+				}
+			}
+			for j := i * i; j <= limit; j += i {
+				composite[j] = true
+			}
+		}
+	}
+}
+
+// fibonacciAfter returns the n Fibonacci numbers after last. The sequence
+// starts 1, 2 since the window keeps only one 1.
+func fibonacciAfter(last float64, n int) []float64 {
+	a, b := 1.0, 2.0
+	var fibs []float64
+	for len(fibs) < n {
+		if a > fallbackLimit {
+			a, b, last = 1, 2, 0
+		}
+		if a > last {
+			fibs = append(fibs, a)
+		}
+		a, b = b, a+b
+	}
+	return fibs
+}
+
+// evensAfter returns the n even numbers after last.
+func evensAfter(last float64, n int) []float64 {
+	next := math.Floor(last/2)*2 + 2
+	if next < 2 || next+2*float64(n) > fallbackLimit {
+		next = 2
+	}
+	evens := make([]float64, n)
+	for i := range evens {
+		evens[i] = next + 2*float64(i)
+	}
+	return evens
+}
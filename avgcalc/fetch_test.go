@@ -0,0 +1,101 @@
+package avgcalc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+)
+
+func TestFetchRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		answer       func(w http.ResponseWriter)
+		wantErr      error
+		wantAttempts int32
+	}{
+		{
+			name: "network error",
+			answer: func(w http.ResponseWriter) {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+			},
+			wantErr:      apperr.ErrUpstreamUnreachable,
+			wantAttempts: 3,
+		},
+		{
+			name:         "timeout",
+			answer:       func(w http.ResponseWriter) { time.Sleep(100 * time.Millisecond) },
+			wantErr:      apperr.ErrUpstreamTimeout,
+			wantAttempts: 3,
+		},
+		{
+			name:         "5xx",
+			answer:       func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			wantErr:      apperr.ErrUpstreamStatus,
+			wantAttempts: 3,
+		},
+		{
+			name:         "4xx",
+			answer:       func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+			wantErr:      apperr.ErrUpstreamStatus,
+			wantAttempts: 1,
+		},
+		{
+			name:         "429",
+			answer:       func(w http.ResponseWriter) { w.WriteHeader(http.StatusTooManyRequests) },
+			wantErr:      apperr.ErrUpstreamStatus,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			s := newTestCalculator(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				tt.answer(w)
+			}), func(cfg *Config) {
+				cfg.Timeout = 20 * time.Millisecond
+			})
+
+			_, call, err := s.fetchNumbers(withServiceTimeout(context.Background(), s.timeout()), "even", "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantAttempts || call.attempts != int(tt.wantAttempts) {
+				t.Errorf("upstream called %d times, %d attempts reported, want %d", got, call.attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestFetchStaysWithinDeadline(t *testing.T) {
+	var calls atomic.Int32
+	s := newTestCalculator(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}), func(cfg *Config) {
+		cfg.Timeout = 200 * time.Millisecond
+		cfg.Retries = 5
+	})
+
+	start := time.Now()
+	_, _, err := s.fetchNumbers(withServiceTimeout(context.Background(), s.timeout()), "even", "")
+	elapsed := time.Since(start)
+	if !errors.Is(err, apperr.ErrUpstreamTimeout) {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+	if elapsed > APIDeadline+100*time.Millisecond {
+		t.Errorf("fetch took %v, past the %v deadline", elapsed, APIDeadline)
+	}
+	if n := calls.Load(); n < 2 || n > 3 {
+		t.Errorf("upstream called %d times, want 2 or 3 within the deadline", n)
+	}
+}
@@ -0,0 +1,105 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+	"github.com/Escanor244/713522IT013/internal/upstream"
+)
+
+// NumberFetcher gets the numbers of a type, passing on the client's token,
+// and says how many calls that took. Errors wrap the apperr sentinels,
+// which decide the status and error code the client gets.
+type NumberFetcher interface {
+	Fetch(ctx context.Context, numberType, token string) (numbers []float64, attempts int, err error)
+}
+
+// HTTPFetcher calls the number service at BaseURL with Client, which
+// decides the retries. In managed mode it sends the calculator's own token
+// in place of the client's.
+type HTTPFetcher struct {
+	BaseURL string
+	Client  *upstream.Client
+
+	credentials *credentials
+}
+
+// Fetch calls the number service with token, or with our own in managed
+// mode. A refusal of our own token gets a new one and one more try, in
+// case it was revoked before it expired.
+func (f HTTPFetcher) Fetch(ctx context.Context, numberType, token string) ([]float64, int, error) {
+	if f.credentials == nil || !f.credentials.enabled {
+		return f.request(ctx, numberType, token)
+	}
+	token, err := f.credentials.get(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	numbers, attempts, err := f.request(ctx, numberType, token)
+	if !errors.Is(err, apperr.ErrUnauthorized) {
+		return numbers, attempts, err
+	}
+	f.credentials.expire(token)
+	if token, err = f.credentials.get(ctx); err != nil {
+		return nil, attempts, err
+	}
+	numbers, more, err := f.request(ctx, numberType, token)
+	return numbers, attempts + more, err
+}
+
+func (f HTTPFetcher) request(ctx context.Context, numberType, authToken string) ([]float64, int, error) {
+	var result NumberResponse
+	var parseErr error
+	var attempts int
+	header := http.Header{
+		"Content-Type": {"application/json"},
+		"Accept":       {"application/json"},
+	}
+	// A fetch shared by several requests carries the first one's ID.
+	if id := middleware.RequestIDFrom(ctx); id != "" {
+		header.Set(middleware.RequestIDHeader, id)
+	}
+	err := f.Client.Get(ctx, upstream.Request{
+		Endpoint: numberType,
+		URL:      serviceURL(f.BaseURL, numberType),
+		Token:    authToken,
+		Timeout:  callTimeout(ctx),
+		Attempts: &attempts,
+		Header:   header,
+	}, func(body io.Reader) error {
+		parseErr = json.NewDecoder(body).Decode(&result)
+		return parseErr
+	})
+
+	// The client's token is passed through, so the upstream refusing it,
+	// or refusing the request otherwise, is the client's problem rather
+	// than ours. Being rate limited is ours.
+	var statusErr *upstream.StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		msg := fmt.Sprintf("server responded with status %d: %s", statusErr.Code, statusErr.Body)
+		switch code := statusErr.Code; {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			err = fmt.Errorf("%w: %w", apperr.ErrUnauthorized, err)
+		case code >= 400 && code < 500 && code != http.StatusTooManyRequests:
+			err = fmt.Errorf("%w: %w", apperr.ErrBadRequest, err)
+		}
+		return nil, attempts, apperr.Wrap(err, msg)
+	case parseErr != nil:
+		return nil, attempts, apperr.Wrap(err, fmt.Sprintf("failed to parse response: %v", parseErr))
+	case err != nil:
+		return nil, attempts, fmt.Errorf("failed to execute request: %w", err)
+	}
+
+	if result.Numbers == nil || len(result.Numbers) == 0 {
+		return nil, attempts, apperr.Wrap(apperr.ErrDecode, "no numbers received from server")
+	}
+
+	return result.Numbers, attempts, nil
+}
@@ -0,0 +1,84 @@
+package avgcalc
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fetchGroup lets concurrent requests for the same numbers share one call
+// to the number service. Calls are keyed by number type and token, since
+// the token decides what the service answers, and by the timeout asked
+// for.
+type fetchGroup struct {
+	mu    sync.Mutex
+	calls map[string]*fetchCall
+}
+
+// fetchFunc fetches the numbers of numberType with authToken.
+type fetchFunc func(ctx context.Context, numberType, authToken string) ([]float64, upstreamCall, error)
+
+type fetchCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	numbers  []float64
+	upstream upstreamCall
+	err      error
+}
+
+// fetch joins concurrent calls for the same number type and token. The
+// numbers are shared by every caller and must not be modified.
+//
+// The call is shared, so it is not cancelled along with ctx: a caller
+// whose ctx ends stops waiting for it, and the call is cancelled once
+// nobody is waiting any more.
+func (g *fetchGroup) fetch(ctx context.Context, numberType, authToken string, fetch fetchFunc) ([]float64, upstreamCall, error) {
+	key := numberType + "\x00" + authToken + "\x00" + callTimeout(ctx).String()
+
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*fetchCall)
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &fetchCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			defer cancel()
+			call.numbers, call.upstream, call.err = fetch(callCtx, numberType, authToken)
+			g.mu.Lock()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	if ok {
+		trace.SpanFromContext(ctx).AddEvent("joined fetch of " + numberType + " numbers in flight")
+	}
+
+	select {
+	case <-call.done:
+		return call.numbers, call.upstream, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Later callers start afresh rather than join a cancelled call.
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			call.cancel()
+		}
+		g.mu.Unlock()
+		return nil, upstreamCall{}, ctx.Err()
+	}
+}
@@ -0,0 +1,159 @@
+package avgcalc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingFetch counts its calls and holds each until release is closed,
+// or its ctx ends.
+type blockingFetch struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (f *blockingFetch) fetch(ctx context.Context, numberType, authToken string) ([]float64, upstreamCall, error) {
+	f.calls.Add(1)
+	select {
+	case <-f.release:
+		return []float64{2, 4}, upstreamCall{attempts: 1}, nil
+	case <-ctx.Done():
+		return nil, upstreamCall{}, ctx.Err()
+	}
+}
+
+// waitForWaiters waits until n callers wait on g's calls.
+func waitForWaiters(t *testing.T, g *fetchGroup, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		g.mu.Lock()
+		waiters := 0
+		for _, call := range g.calls {
+			waiters += call.waiters
+		}
+		g.mu.Unlock()
+		if waiters == n {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d callers", n)
+}
+
+func TestFetchGroupSharesCalls(t *testing.T) {
+	var g fetchGroup
+	f := &blockingFetch{release: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	results := make([][]float64, 6)
+	for i := range results {
+		// Two tokens, so two calls of three callers each.
+		token := []string{"a", "b"}[i%2]
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			numbers, _, err := g.fetch(context.Background(), "even", token, f.fetch)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = numbers
+		}(i)
+	}
+	waitForWaiters(t, &g, len(results))
+	close(f.release)
+	wg.Wait()
+
+	if n := f.calls.Load(); n != 2 {
+		t.Errorf("upstream called %d times, want once per token", n)
+	}
+	for _, numbers := range results {
+		assertNumbers(t, "caller's numbers", numbers, 2, 4)
+	}
+
+	// Once done, the next caller calls again.
+	if _, _, err := g.fetch(context.Background(), "even", "a", f.fetch); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.calls.Load(); n != 3 {
+		t.Errorf("upstream called %d times after the shared calls ended, want 3", n)
+	}
+}
+
+func TestFetchGroupCancellation(t *testing.T) {
+	var g fetchGroup
+	f := &blockingFetch{release: make(chan struct{})}
+
+	// A caller giving up leaves the call to those still waiting.
+	ctx, cancel := context.WithCancel(context.Background())
+	gaveUp := make(chan error)
+	go func() {
+		_, _, err := g.fetch(ctx, "even", "", f.fetch)
+		gaveUp <- err
+	}()
+	stayed := make(chan error)
+	go func() {
+		_, _, err := g.fetch(context.Background(), "even", "", f.fetch)
+		stayed <- err
+	}()
+	waitForWaiters(t, &g, 2)
+	cancel()
+	if err := <-gaveUp; !errors.Is(err, context.Canceled) {
+		t.Errorf("caller that gave up: err = %v, want context.Canceled", err)
+	}
+	close(f.release)
+	if err := <-stayed; err != nil {
+		t.Errorf("caller that stayed: err = %v", err)
+	}
+
+	// Once every caller gives up, the call is cancelled and the next one
+	// starts afresh.
+	f = &blockingFetch{release: make(chan struct{})}
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		_, _, err := g.fetch(ctx, "even", "", f.fetch)
+		gaveUp <- err
+	}()
+	waitForWaiters(t, &g, 1)
+	cancel()
+	<-gaveUp
+	close(f.release)
+	if _, _, err := g.fetch(context.Background(), "even", "", f.fetch); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.calls.Load(); n != 2 {
+		t.Errorf("upstream called %d times, want the cancelled call and a new one", n)
+	}
+}
+
+func TestConcurrentRequestsShareAFetch(t *testing.T) {
+	var calls atomic.Int32
+	s := newTestCalculator(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		writeNumbers(w, 2, 4)
+	}), func(cfg *Config) {
+		cfg.CacheTTL = 0
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := s.getNumbers(context.Background(), numbersRequest{types: []string{"even"}})
+			if result.status != http.StatusOK {
+				t.Errorf("status %d, %+v", result.status, result.err)
+				return
+			}
+			assertNumbers(t, "numbers", result.resp.Numbers, 2, 4)
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream called %d times for 10 concurrent requests, want 1", n)
+	}
+}
@@ -0,0 +1,274 @@
+package avgcalc
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Escanor244/713522IT013/avgcalc/avgpb"
+)
+
+var (
+	grpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_requests_total",
+		Help: "gRPC calls by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_request_duration_seconds",
+		Help:    "Duration of gRPC calls by method; for streams, how long they were open.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"method"})
+)
+
+// grpcServer serves the calculator over gRPC, on the windows the HTTP
+// API uses and with the same fetches. Errors carry the HTTP API's error
+// code in the "error-code" trailer.
+type grpcServer struct {
+	avgpb.UnimplementedAverageCalculatorServer
+
+	ctx  context.Context
+	calc *calculator
+}
+
+// serveGRPC serves gRPC on ln until ctx is cancelled, then stops taking
+// calls and waits for those under way. Streams end with ctx, as they do
+// over HTTP.
+func (s *calculator) serveGRPC(ctx context.Context, ln net.Listener) error {
+	srv := s.grpcServer(ctx)
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+	s.logger.Info("Serving gRPC", "addr", ln.Addr().String())
+	return srv.Serve(ln)
+}
+
+// grpcServer returns the gRPC server of the calculator, its streams ending
+// with ctx.
+func (s *calculator) grpcServer(ctx context.Context) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.observeUnary),
+		grpc.ChainStreamInterceptor(s.observeStream))
+	avgpb.RegisterAverageCalculatorServer(srv, &grpcServer{ctx: ctx, calc: s})
+	return srv
+}
+
+// GetNumbers is GET /numbers/:numberid. Token validation, when on, applies
+// as it does there.
+func (s *grpcServer) GetNumbers(ctx context.Context, req *avgpb.NumberRequest) (*avgpb.NumberWindowResponse, error) {
+	types, valid := s.calc.numberTypes.parse(req.NumberId)
+
+	authToken, authErr := grpcAuthorization(ctx)
+	if authErr != nil && !(s.calc.credentials.enabled && authErr.code == CodeMissingAuthorization) {
+		return nil, grpcError(ctx, authErr.status, ErrorBody{Code: authErr.code, Message: authErr.message})
+	}
+	if authErr == nil && s.calc.tokenRefused(ctx, authToken) {
+		return nil, grpcError(ctx, http.StatusUnauthorized, ErrorBody{Code: CodeInvalidToken, Message: "The bearer token was refused"})
+	}
+	fetchToken := authToken
+	if s.calc.credentials.enabled {
+		fetchToken = ""
+	}
+	if !valid {
+		return nil, grpcError(ctx, http.StatusBadRequest, s.calc.numberTypes.invalid())
+	}
+	avg, err := parseMode(req.AvgMode, strconv.FormatFloat(req.Alpha, 'g', -1, 64))
+	if err != nil {
+		return nil, grpcError(ctx, http.StatusBadRequest, ErrorBody{Code: CodeInvalidAvgMode, Message: err.Error()})
+	}
+
+	result := s.calc.getNumbers(ctx, numbersRequest{
+		types:      types,
+		authToken:  authToken,
+		fetchToken: fetchToken,
+		avg:        avg,
+		route:      avgpb.AverageCalculator_GetNumbers_FullMethodName,
+	})
+	if result.canceled {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if result.status != http.StatusOK {
+		return nil, grpcError(ctx, result.status, result.err)
+	}
+	duplicatesDiscarded.Observe(float64(result.resp.DuplicatesDiscarded))
+	return numberWindowResponse(result.resp), nil
+}
+
+// WatchWindow is GET /numbers/:numberid/stream, and counts against the
+// same limit of streams open.
+func (s *grpcServer) WatchWindow(req *avgpb.WatchWindowRequest, stream avgpb.AverageCalculator_WatchWindowServer) error {
+	ctx := stream.Context()
+	if _, valid := s.calc.numberTypes.parse(req.NumberId); !valid {
+		return grpcError(ctx, http.StatusBadRequest, s.calc.numberTypes.invalid())
+	}
+	store := s.calc.windows.shared
+	if s.calc.windows.perClient {
+		authToken, authErr := grpcAuthorization(ctx)
+		if authErr != nil {
+			return grpcError(ctx, authErr.status, ErrorBody{Code: authErr.code, Message: authErr.message})
+		}
+		store = s.calc.windows.get(authToken)
+	}
+
+	if s.calc.streams.Add(1) > s.calc.maxStreams {
+		s.calc.streams.Add(-1)
+		return status.Error(codes.ResourceExhausted, "Too many streams open, try again later")
+	}
+	defer s.calc.streams.Add(-1)
+
+	sub, err := store.Subscribe()
+	if err != nil {
+		return grpcError(ctx, http.StatusServiceUnavailable, ErrorBody{Code: CodeStoreUnavailable, Message: err.Error()})
+	}
+	defer store.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.ctx.Done():
+			return status.Error(codes.Unavailable, "The service is shutting down")
+		case e := <-sub.Events():
+			if err := stream.Send(windowEventMessage(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// grpcAuthorization reads the bearer token from the "authorization"
+// metadata, as authorization does from the header.
+func grpcAuthorization(ctx context.Context) (string, *requestError) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || values[0] == "" {
+		return "", &requestError{http.StatusUnauthorized, CodeMissingAuthorization, "Missing authorization metadata"}
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || token == "" {
+		return "", &requestError{http.StatusUnauthorized, CodeMalformedAuthorization, "Invalid authorization metadata format. Use 'Bearer <token>'"}
+	}
+	return token, nil
+}
+
+// grpcError is body as a gRPC status, with the code closest to httpStatus.
+func grpcError(ctx context.Context, httpStatus int, body ErrorBody) error {
+	_ = grpc.SetTrailer(ctx, metadata.Pairs("error-code", body.Code))
+	code := codes.Internal
+	switch httpStatus {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, body.Message)
+}
+
+func numberWindowResponse(resp APIResponse) *avgpb.NumberWindowResponse {
+	msg := &avgpb.NumberWindowResponse{
+		WindowPrevState:     resp.WindowPrevState,
+		WindowCurrState:     resp.WindowCurrState,
+		Numbers:             resp.Numbers,
+		Avg:                 resp.Average.Rounded(),
+		TrimmedAvg:          resp.TrimmedAverage.Rounded(),
+		Median:              resp.Median,
+		Min:                 resp.Min,
+		Max:                 resp.Max,
+		Stddev:              resp.StdDev,
+		WindowCount:         int32(resp.WindowCount),
+		WindowSize:          int32(resp.WindowSize),
+		IsFull:              resp.IsFull,
+		Accepted:            resp.Accepted,
+		DuplicatesDiscarded: int32(resp.DuplicatesDiscarded),
+		EmaAvg:              resp.EMAAverage,
+		WeightedAvg:         resp.WeightedAverage,
+		Failures:            resp.Failures,
+		UpstreamUnavailable: resp.UpstreamUnavailable,
+		TimedOut:            resp.TimedOut,
+		Source:              resp.Source,
+		CacheHit:            resp.CacheHit,
+		Stale:               resp.Stale,
+	}
+	if len(resp.Attempts) > 0 {
+		msg.Attempts = make(map[string]int32, len(resp.Attempts))
+		for numberType, n := range resp.Attempts {
+			msg.Attempts[numberType] = int32(n)
+		}
+	}
+	if resp.StaleAgeSeconds != nil {
+		seconds := int32(*resp.StaleAgeSeconds)
+		msg.StaleAgeSeconds = &seconds
+	}
+	return msg
+}
+
+func windowEventMessage(e WindowEvent) *avgpb.WindowEvent {
+	msg := &avgpb.WindowEvent{
+		Seq:             e.Seq,
+		WindowCurrState: e.WindowCurrState,
+		Avg:             e.Average.Rounded(),
+		Count:           int32(e.Count),
+	}
+	if e.LastUpdated != nil {
+		msg.LastUpdated = timestamppb.New(*e.LastUpdated)
+	}
+	return msg
+}
+
+// observeUnary logs and counts each unary call once it is answered.
+func (s *calculator) observeUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	s.observeCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+// observeStream logs and counts each stream once it ends.
+func (s *calculator) observeStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	s.observeCall(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+// observeCall logs a call as the access log does a request, server errors
+// at error level.
+func (s *calculator) observeCall(ctx context.Context, method string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	code := status.Code(err)
+	grpcRequests.WithLabelValues(method, code.String()).Inc()
+	grpcDuration.WithLabelValues(method).Observe(elapsed.Seconds())
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DeadlineExceeded:
+		level = slog.LevelError
+	}
+	s.logger.LogAttrs(ctx, level, "gRPC call",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Float64("latencyMs", math.Round(float64(elapsed.Microseconds()))/1000))
+}
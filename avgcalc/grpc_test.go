@@ -0,0 +1,144 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/Escanor244/713522IT013/avgcalc/avgpb"
+)
+
+// scriptedNumbers answers each number type's calls with the next batch of
+// its script, the last one once the script runs out.
+type scriptedNumbers struct {
+	mu     sync.Mutex
+	script map[string][][]float64
+	calls  map[string]int
+}
+
+func newScriptedNumbers(script map[string][][]float64) *scriptedNumbers {
+	return &scriptedNumbers{script: script, calls: make(map[string]int)}
+}
+
+func (u *scriptedNumbers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	u.mu.Lock()
+	batches, ok := u.script[path]
+	n := u.calls[path]
+	u.calls[path]++
+	u.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeNumbers(w, batches[min(n, len(batches)-1)]...)
+}
+
+// dialGRPC serves s's gRPC API over an in-memory connection until the test
+// ends, returning a client of it.
+func dialGRPC(t *testing.T, s *calculator) avgpb.AverageCalculatorClient {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	ln := bufconn.Listen(1 << 20)
+	srv := s.grpcServer(ctx)
+	go srv.Serve(ln)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		cancel()
+		srv.Stop()
+	})
+	return avgpb.NewAverageCalculatorClient(conn)
+}
+
+func TestGRPCAndHTTPGiveTheSameWindows(t *testing.T) {
+	script := map[string][][]float64{
+		"even":   {{2, 4, 6}, {4, 8, 10}, {12, 14}},
+		"primes": {{3, 5, 7}},
+	}
+	configure := func(cfg *Config) {
+		cfg.WindowSize = 5
+		cfg.CacheTTL = 0
+	}
+	grpcCalc := newTestCalculator(t, newScriptedNumbers(script), configure)
+	httpCalc := newTestCalculator(t, newScriptedNumbers(script), configure)
+	client := dialGRPC(t, grpcCalc)
+	url := serveAPI(t, httpCalc)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token")
+
+	calls := []*avgpb.NumberRequest{
+		{NumberId: "e"},
+		{NumberId: "e", AvgMode: "ema", Alpha: 0.5},
+		{NumberId: "p"},
+		{NumberId: "e", AvgMode: "weighted"},
+		{NumberId: "p"},
+	}
+	for i, call := range calls {
+		got, err := client.GetNumbers(ctx, call)
+		if err != nil {
+			t.Fatalf("call %d over gRPC: %v", i, err)
+		}
+
+		query := "?avgMode=" + call.AvgMode
+		if call.Alpha != 0 {
+			query += "&alpha=" + strconv.FormatFloat(call.Alpha, 'g', -1, 64)
+		}
+		req, _ := http.NewRequest(http.MethodGet, url+"/numbers/"+call.NumberId+query, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		httpResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want APIResponse
+		err = json.NewDecoder(httpResp.Body).Decode(&want)
+		httpResp.Body.Close()
+		if err != nil || httpResp.StatusCode != http.StatusOK {
+			t.Fatalf("call %d over HTTP: status %d, %v", i, httpResp.StatusCode, err)
+		}
+
+		name := "call " + strconv.Itoa(i) + " "
+		assertNumbers(t, name+"windowPrevState", got.WindowPrevState, want.WindowPrevState...)
+		assertNumbers(t, name+"windowCurrState", got.WindowCurrState, want.WindowCurrState...)
+		assertNumbers(t, name+"numbers", got.Numbers, want.Numbers...)
+		assertNumbers(t, name+"accepted", got.Accepted, want.Accepted...)
+		if got.Avg != want.Average.Rounded() || got.TrimmedAvg != want.TrimmedAverage.Rounded() {
+			t.Errorf("%savg, trimmedAvg = %v, %v over gRPC, %v, %v over HTTP", name, got.Avg, got.TrimmedAvg, want.Average, want.TrimmedAverage)
+		}
+		if int(got.DuplicatesDiscarded) != want.DuplicatesDiscarded || int(got.WindowCount) != want.WindowCount ||
+			int(got.WindowSize) != want.WindowSize || got.IsFull != want.IsFull {
+			t.Errorf("%sduplicates, count, size, full = %d, %d, %d, %v over gRPC, %d, %d, %d, %v over HTTP", name,
+				got.DuplicatesDiscarded, got.WindowCount, got.WindowSize, got.IsFull,
+				want.DuplicatesDiscarded, want.WindowCount, want.WindowSize, want.IsFull)
+		}
+		if (got.EmaAvg == nil) != (want.EMAAverage == nil) || got.EmaAvg != nil && *got.EmaAvg != *want.EMAAverage {
+			t.Errorf("%semaAvg = %v over gRPC, %v over HTTP", name, got.EmaAvg, want.EMAAverage)
+		}
+		if (got.WeightedAvg == nil) != (want.WeightedAverage == nil) || got.WeightedAvg != nil && *got.WeightedAvg != *want.WeightedAverage {
+			t.Errorf("%sweightedAvg = %v over gRPC, %v over HTTP", name, got.WeightedAvg, want.WeightedAverage)
+		}
+	}
+
+	// Both ended on the window the script makes.
+	for name, s := range map[string]*calculator{"gRPC": grpcCalc, "HTTP": httpCalc} {
+		state, err := s.windows.shared.GetCurrentState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertNumbers(t, "final window over "+name, state, 3, 5, 7, 12, 14)
+	}
+}
@@ -0,0 +1,160 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+// fakeFetcher answers every fetch with numbers and err, recording the
+// number types and tokens it was asked for.
+type fakeFetcher struct {
+	numbers []float64
+	err     error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, numberType, token string) ([]float64, int, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, numberType+" "+token)
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, 1, f.err
+	}
+	return f.numbers, 1, nil
+}
+
+// newHandler returns the HTTP API of a calculator fetching with f, which
+// never touches the network.
+func newHandler(t *testing.T, f NumberFetcher) http.Handler {
+	t.Helper()
+	cfg := DefaultConfig()
+	var err error
+	if cfg.HTTP, err = middleware.Load(config.FromEnv()); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Fetcher = f
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return newCalculator(cfg).router(ctx)
+}
+
+// serve answers a GET of path with the Authorization header set to auth,
+// if any, and decodes the body into v.
+func serve(t *testing.T, h http.Handler, path, auth string, v any) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("GET %s: %v: %s", path, err, w.Body)
+	}
+	return w.Code
+}
+
+func TestNumbersHandlerSuccess(t *testing.T) {
+	f := &fakeFetcher{numbers: []float64{2, 4, 6}}
+	h := newHandler(t, f)
+
+	var resp APIResponse
+	if status := serve(t, h, "/numbers/e", "Bearer client-token", &resp); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	assertNumbers(t, "numbers", resp.Numbers, 2, 4, 6)
+	assertNumbers(t, "windowCurrState", resp.WindowCurrState, 2, 4, 6)
+	if resp.Average != 4 {
+		t.Errorf("avg = %v, want 4", resp.Average)
+	}
+	if len(f.calls) != 1 || f.calls[0] != "even client-token" {
+		t.Errorf("fetches = %q, want the even numbers with the client's token", f.calls)
+	}
+}
+
+func TestNumbersHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		auth      string
+		fetchErr  error
+		status    int
+		code      string
+		wantFetch bool
+	}{
+		{
+			name:      "upstream unreachable",
+			path:      "/numbers/e",
+			auth:      "Bearer token",
+			fetchErr:  fmt.Errorf("failed to execute request: %w", apperr.ErrUpstreamUnreachable),
+			status:    http.StatusBadGateway,
+			code:      CodeUpstreamUnavailable,
+			wantFetch: true,
+		},
+		{
+			name:      "upstream undecodable",
+			path:      "/numbers/e",
+			auth:      "Bearer token",
+			fetchErr:  apperr.Wrap(apperr.ErrDecode, "no numbers received from server"),
+			status:    http.StatusBadGateway,
+			code:      CodeUpstreamInvalidResponse,
+			wantFetch: true,
+		},
+		{
+			name:      "token refused upstream",
+			path:      "/numbers/e",
+			auth:      "Bearer token",
+			fetchErr:  fmt.Errorf("%w: status 401", apperr.ErrUnauthorized),
+			status:    http.StatusUnauthorized,
+			code:      CodeUpstreamUnauthorized,
+			wantFetch: true,
+		},
+		{
+			name:   "invalid ID",
+			path:   "/numbers/x",
+			auth:   "Bearer token",
+			status: http.StatusBadRequest,
+			code:   CodeInvalidNumberID,
+		},
+		{
+			name:   "no token",
+			path:   "/numbers/e",
+			status: http.StatusUnauthorized,
+			code:   CodeMissingAuthorization,
+		},
+		{
+			name:   "malformed token",
+			path:   "/numbers/e",
+			auth:   "Basic dXNlcjpwYXNz",
+			status: http.StatusUnauthorized,
+			code:   CodeMalformedAuthorization,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeFetcher{numbers: []float64{2}, err: tt.fetchErr}
+			var envelope ErrorEnvelope
+			status := serve(t, newHandler(t, f), tt.path, tt.auth, &envelope)
+			if status != tt.status || envelope.Error.Code != tt.code {
+				t.Errorf("status, code = %d, %s, want %d, %s", status, envelope.Error.Code, tt.status, tt.code)
+			}
+			if fetched := len(f.calls) > 0; fetched != tt.wantFetch {
+				t.Errorf("fetched = %v, want %v", fetched, tt.wantFetch)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package avgcalc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+)
+
+const (
+	ReadyFailingThreshold = 60 * time.Second
+	ReadyProbeInterval    = 10 * time.Second
+	ReadyProbeTimeout     = 250 * time.Millisecond
+)
+
+// readiness tracks whether the number service is usable, from the fetches
+// made for clients and, with READY_PROBE_UPSTREAM set, from probes made by
+// GET /readyz. The service is reported not ready once the number service
+// has failed continuously for longer than failingThreshold.
+type readiness struct {
+	probe            bool
+	probeInterval    time.Duration
+	failingThreshold time.Duration
+
+	// client probes the number service at url.
+	client *http.Client
+	url    string
+	logger *slog.Logger
+
+	mu           sync.Mutex
+	failingSince time.Time
+	checkedAt    time.Time
+	probedAt     time.Time
+	probeStatus  int
+	probeErr     error
+}
+
+// record notes the outcome of a call to the number service. As with the
+// breaker, only the service failing counts as down.
+func (r *readiness) record(err error) {
+	failed := err != nil && apperr.HTTPStatus(err) >= http.StatusBadGateway
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkedAt = now
+	switch {
+	case !failed:
+		r.failingSince = time.Time{}
+	case r.failingSince.IsZero():
+		r.failingSince = now
+	}
+}
+
+// probeIfDue probes the number service unless it was probed less than
+// probeInterval ago, so however often the orchestrator asks, the service
+// sees at most one probe per interval. Callers arriving during a probe
+// use the previous result.
+func (r *readiness) probeIfDue(ctx context.Context) {
+	if !r.probe {
+		return
+	}
+	r.mu.Lock()
+	if time.Since(r.probedAt) < r.probeInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.probedAt = time.Now()
+	r.mu.Unlock()
+
+	status, err := r.probeNumberService(ctx)
+	r.mu.Lock()
+	r.probeStatus, r.probeErr = status, err
+	r.mu.Unlock()
+	if err != nil {
+		r.logger.Warn("Number service probe failed", "error", err)
+	}
+	r.record(err)
+}
+
+// probeNumberService sends the number service a GET without a token, which
+// it refuses without doing any work. Any answer below 500, a 401 included,
+// shows it is up. HEAD would be cheaper still, but not every server
+// implements it.
+func (r *readiness) probeNumberService(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ReadyProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 0, apperr.Wrap(apperr.ErrUpstreamTimeout, "number service probe timed out")
+		}
+		return 0, apperr.Wrap(apperr.ErrUpstreamUnreachable, err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return resp.StatusCode, apperr.Wrap(apperr.ErrUpstreamUnreachable, "number service probe got "+resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// numberServiceCheck is the number service's entry in GET /readyz.
+type numberServiceCheck struct {
+	Status            string     `json:"status"`
+	FailingSince      *time.Time `json:"failingSince,omitempty"`
+	FailingForSeconds *float64   `json:"failingForSeconds,omitempty"`
+	LastCheckedAt     *time.Time `json:"lastCheckedAt,omitempty"`
+	Probe             *probeInfo `json:"probe,omitempty"`
+}
+
+type probeInfo struct {
+	At     time.Time `json:"at"`
+	Status int       `json:"status,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// check reports the number service's state and whether it has been
+// failing for too long to count as ready.
+func (r *readiness) check() (numberServiceCheck, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	check := numberServiceCheck{Status: "unknown"}
+	if !r.checkedAt.IsZero() {
+		checkedAt := r.checkedAt
+		check.Status, check.LastCheckedAt = "up", &checkedAt
+	}
+	ok := true
+	if !r.failingSince.IsZero() {
+		since, failingFor := r.failingSince, time.Since(r.failingSince)
+		seconds := failingFor.Seconds()
+		check.Status, check.FailingSince, check.FailingForSeconds = "failing", &since, &seconds
+		ok = failingFor <= r.failingThreshold
+	}
+	if r.probe && !r.probedAt.IsZero() {
+		check.Probe = &probeInfo{At: r.probedAt, Status: r.probeStatus}
+		if r.probeErr != nil {
+			check.Probe.Error = r.probeErr.Error()
+		}
+	}
+	return check, ok
+}
+
+// healthResponse is the response of GET /healthz.
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+// readyResponse is the response of GET /readyz, "ready" or "not ready".
+type readyResponse struct {
+	Status string `json:"status"`
+	Checks struct {
+		NumberService numberServiceCheck `json:"numberService"`
+	} `json:"checks"`
+	FailingThresholdSeconds float64 `json:"failingThresholdSeconds"`
+}
+
+// getHealth answers as long as the process can serve requests.
+func getHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// getReady reports whether the calculator can do useful work, with 503
+// once the number service has been failing for longer than
+// READY_FAILING_THRESHOLD.
+func (s *calculator) getReady(c *gin.Context) {
+	s.ready.probeIfDue(c.Request.Context())
+	check, ok := s.ready.check()
+
+	status, body := http.StatusOK, readyResponse{Status: "ready"}
+	if !ok {
+		status, body.Status = http.StatusServiceUnavailable, "not ready"
+	}
+	body.Checks.NumberService = check
+	body.FailingThresholdSeconds = s.ready.failingThreshold.Seconds()
+	c.JSON(status, body)
+}
@@ -0,0 +1,141 @@
+package avgcalc
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WindowHistorySize is how many snapshots each window keeps, unless
+// WINDOW_HISTORY_SIZE says otherwise.
+const WindowHistorySize = 100
+
+// WindowSnapshot is what one batch of numbers did to a window. It holds
+// what changed, not the window itself, so recording it costs no copy of
+// the window.
+type WindowSnapshot struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+
+	// Types are the number types the batch came from, none for numbers
+	// posted to /numbers.
+	Types []string `json:"types"`
+
+	// Added are the numbers that entered the window and Evicted the ones
+	// that left it as they did, to make room or having expired;
+	// DuplicatesDiscarded were already in it.
+	Added               []float64 `json:"added"`
+	Evicted             []float64 `json:"evicted"`
+	DuplicatesDiscarded int       `json:"duplicatesDiscarded"`
+
+	Average Fixed2 `json:"avg"`
+	Count   int    `json:"count"`
+}
+
+// windowHistory is a ring of a window's latest snapshots. It grows to its
+// size as snapshots come in, so idle windows cost nothing. It is guarded
+// by the window's lock.
+type windowHistory struct {
+	size      int
+	snapshots []WindowSnapshot
+	next      int
+}
+
+func (h *windowHistory) record(s WindowSnapshot) {
+	if h.size == 0 {
+		return
+	}
+	if len(h.snapshots) < h.size {
+		h.snapshots = append(h.snapshots, s)
+		return
+	}
+	h.snapshots[h.next] = s
+	h.next = (h.next + 1) % h.size
+}
+
+// newest returns up to limit snapshots, newest first, those with
+// numberType only if it is set.
+func (h *windowHistory) newest(limit int, numberType string) []WindowSnapshot {
+	out := make([]WindowSnapshot, 0, min(limit, len(h.snapshots)))
+	for i := 0; i < len(h.snapshots) && len(out) < limit; i++ {
+		s := h.snapshots[(h.next-1-i+len(h.snapshots))%len(h.snapshots)]
+		if numberType == "" || slices.Contains(s.Types, numberType) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (h *windowHistory) clear() {
+	h.snapshots, h.next = nil, 0
+}
+
+// History returns up to limit of the window's snapshots, newest first,
+// only those with numbers of numberType if it is set.
+func (ns *NumberStore) History(limit int, numberType string) ([]WindowSnapshot, error) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	return ns.history.newest(limit, numberType), nil
+}
+
+// windowHistoryResponse is the response of GET /window/history.
+type windowHistoryResponse struct {
+	History []WindowSnapshot `json:"history"`
+}
+
+// getWindowHistory serves GET /window/history, the window's latest
+// snapshots, newest first. type=p keeps the ones with primes.
+func (s *calculator) getWindowHistory(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+	store := s.windows.shared
+	if s.windows.perClient {
+		authToken, ok := bearerToken(c)
+		if !ok {
+			return
+		}
+		if store = s.windows.peek(authToken); store == nil {
+			respondHistory(c, format, []WindowSnapshot{})
+			return
+		}
+	}
+
+	limit := 20
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer, got " + strconv.Quote(raw)})
+			return
+		}
+		limit = n
+	}
+	var numberType string
+	if id := c.Query("type"); id != "" {
+		t, valid := s.numberTypes.byID[id]
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": s.numberTypes.invalid().Message})
+			return
+		}
+		numberType = t.Path
+	}
+	history, err := store.History(limit, numberType)
+	if err != nil {
+		s.abortStoreUnavailable(c, err)
+		return
+	}
+	respondHistory(c, format, history)
+}
+
+// respondHistory answers history in format.
+func respondHistory(c *gin.Context, format string, history []WindowSnapshot) {
+	if format != "json" {
+		exportHistory(c, format, history)
+		return
+	}
+	c.JSON(http.StatusOK, windowHistoryResponse{History: history})
+}
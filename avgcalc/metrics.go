@@ -0,0 +1,112 @@
+package avgcalc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+var (
+	numberRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "numbers_requests_total",
+		Help: "Requests to /numbers/:numberid by number type and status code.",
+	}, []string{"number_type", "status"})
+
+	fetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "numbers_fetch_duration_seconds",
+		Help:    "Duration of number fetches, retries included, by number type and outcome.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"number_type", "outcome"})
+
+	duplicatesDiscarded = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "numbers_duplicates_discarded",
+		Help:    "Numbers per request discarded as already in the window.",
+		Buckets: []float64{0, 1, 2, 5, 10, 20, 50, 100},
+	})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_request_duration_seconds",
+		Help:    "Latency of calls to the number service by number type and status code.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"endpoint", "status"})
+
+	upstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_request_errors_total",
+		Help: "Failed calls to the number service by number type and status code.",
+	}, []string{"endpoint", "status"})
+)
+
+// duplicatesKey carries the duplicates a request discarded from the
+// handler to countRequests.
+const duplicatesKey = "avgcalc.duplicates"
+
+// registerMetrics registers the calculator's metrics with registry,
+// including the fill of windows, read when scraped.
+func registerMetrics(registry *prometheus.Registry, windows *windows) {
+	registry.MustRegister(
+		numberRequests,
+		fetchDuration,
+		duplicatesDiscarded,
+		upstreamDuration,
+		upstreamErrors,
+		grpcRequests,
+		grpcDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "window_fill_ratio",
+			Help: "How full the window is, from 0 to 1; with per-client windows, the mean over them.",
+		}, windows.fill),
+	)
+	registry.MustRegister(middleware.Collectors()...)
+}
+
+func observeUpstream(endpoint, status string, elapsed time.Duration, failed bool) {
+	upstreamDuration.WithLabelValues(endpoint, status).Observe(elapsed.Seconds())
+	if failed {
+		upstreamErrors.WithLabelValues(endpoint, status).Inc()
+	}
+}
+
+// timedFetch is fetchNumbers, timed under its outcome: success, the error
+// code in lower case, circuit_open or canceled.
+func (s *calculator) timedFetch(ctx context.Context, numberType, authToken string) ([]float64, upstreamCall, error) {
+	start := time.Now()
+	numbers, call, err := s.fetchNumbers(ctx, numberType, authToken)
+
+	outcome := "success"
+	switch {
+	case errors.Is(err, errCircuitOpen):
+		outcome = "circuit_open"
+	case errors.Is(err, context.Canceled):
+		outcome = "canceled"
+	case err != nil:
+		outcome = strings.ToLower(upstreamCode(err))
+	}
+	fetchDuration.WithLabelValues(numberType, outcome).Observe(time.Since(start).Seconds())
+	return numbers, call, err
+}
+
+// countRequests counts the requests to /numbers/:numberid once they are
+// answered, once for each number type asked for.
+func (s *calculator) countRequests(c *gin.Context) {
+	c.Next()
+
+	status := strconv.Itoa(c.Writer.Status())
+	types, valid := s.numberTypes.parse(c.Param("numberid"))
+	if !valid {
+		types = []string{"invalid"}
+	}
+	for _, numberType := range types {
+		numberRequests.WithLabelValues(numberType, status).Inc()
+		s.counters.request(numberType)
+	}
+	if n, ok := c.Get(duplicatesKey); ok {
+		duplicatesDiscarded.Observe(float64(n.(int)))
+	}
+}
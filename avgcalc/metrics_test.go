@@ -0,0 +1,86 @@
+package avgcalc
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrape reads /metrics from the API at url into its series, each keyed
+// by its name and labels as exposed.
+func scrape(t *testing.T, url string) map[string]float64 {
+	t.Helper()
+	resp, err := http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	series := map[string]float64{}
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		line := lines.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("unparseable sample %q", line)
+		}
+		series[line[:i]] = v
+	}
+	return series
+}
+
+func TestMetricsAfterRequests(t *testing.T) {
+	s := newTestCalculator(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/even") {
+			writeNumbers(w, 2, 4, 4)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}), func(cfg *Config) {
+		cfg.Metrics = prometheus.NewRegistry()
+		cfg.Retries = 0
+	})
+	url := serveAPI(t, s)
+
+	// The collectors are shared by every calculator in the test binary,
+	// so the series are compared with a scrape from before the requests.
+	before := scrape(t, url)
+	if status, _ := getStatus(t, url, "/numbers/e"); status != http.StatusOK {
+		t.Fatalf("GET /numbers/e: status %d", status)
+	}
+	if status, _ := getStatus(t, url, "/numbers/p"); status != http.StatusBadGateway {
+		t.Fatalf("GET /numbers/p: status %d, want 502", status)
+	}
+	after := scrape(t, url)
+
+	for _, name := range []string{
+		`numbers_requests_total{number_type="even",status="200"}`,
+		`numbers_requests_total{number_type="primes",status="502"}`,
+		`numbers_fetch_duration_seconds_count{number_type="even",outcome="success"}`,
+		`numbers_duplicates_discarded_count`,
+		`upstream_request_duration_seconds_count{endpoint="even",status="200"}`,
+		`upstream_request_errors_total{endpoint="primes",status="503"}`,
+	} {
+		v, ok := after[name]
+		if !ok {
+			t.Errorf("%s missing from /metrics", name)
+			continue
+		}
+		if v != before[name]+1 {
+			t.Errorf("%s went from %v to %v, want one more", name, before[name], v)
+		}
+	}
+	if v := after[`numbers_duplicates_discarded_sum`] - before[`numbers_duplicates_discarded_sum`]; v != 1 {
+		t.Errorf("%v duplicates discarded, want the second 4", v)
+	}
+	if fill, ok := after["window_fill_ratio"]; !ok || fill <= 0 || fill > 1 {
+		t.Errorf("window_fill_ratio = %v, %v, want the window partly filled", fill, ok)
+	}
+}
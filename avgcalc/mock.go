@@ -0,0 +1,125 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockBatch is how many numbers the mock number service answers with.
+const MockBatch = 10
+
+// MockUpstream is a stand-in for the number service, for working without
+// access to the real one. GET /test/primes, fibo, even and rand answer
+// the next batch of each sequence, carrying on from the last call, and
+// rand a fixed pseudo-random sequence, so a run can be repeated. Calls
+// need a bearer token, Token if set. Each one takes Latency, and a
+// FailureRate share of them, picked pseudo-randomly too, fail with 503.
+type MockUpstream struct {
+	Latency     time.Duration
+	FailureRate float64
+	Token       string
+
+	mu   sync.Mutex
+	last map[string]float64
+	rand *rand.Rand
+}
+
+func (m *MockUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	numberType, ok := strings.CutPrefix(r.URL.Path, "/test/")
+	if !ok || r.Method != http.MethodGet {
+		mockError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" || (m.Token != "" && token != m.Token) {
+		mockError(w, http.StatusUnauthorized, "Invalid authorization token")
+		return
+	}
+
+	if m.Latency > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(m.Latency):
+		}
+	}
+
+	numbers, ok := m.next(numberType)
+	if !ok {
+		mockError(w, http.StatusNotFound, "Unknown number type")
+		return
+	}
+	if numbers == nil {
+		mockError(w, http.StatusServiceUnavailable, "Injected failure")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(NumberResponse{Numbers: numbers})
+}
+
+// next is the batch of numberType to answer, nil for a failure, and
+// false for a type there is no such sequence of.
+func (m *MockUpstream) next(numberType string) ([]float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rand == nil {
+		m.rand = rand.New(rand.NewSource(1))
+		m.last = make(map[string]float64)
+	}
+
+	var batch []float64
+	switch numberType {
+	case "primes":
+		batch = primesAfter(m.last[numberType], MockBatch)
+	case "fibo":
+		batch = fibonacciAfter(m.last[numberType], MockBatch)
+	case "even":
+		batch = evensAfter(m.last[numberType], MockBatch)
+	case "rand":
+		batch = make([]float64, MockBatch)
+		for i := range batch {
+			batch[i] = float64(FallbackRandomMin + m.rand.Intn(FallbackRandomMax-FallbackRandomMin+1))
+		}
+	default:
+		return nil, false
+	}
+	if m.rand.Float64() < m.FailureRate {
+		return nil, true
+	}
+	m.last[numberType] = batch[len(batch)-1]
+	return batch, true
+}
+
+func mockError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// start serves m on a loopback port until ctx is cancelled, returning the
+// URL to use as the number service's.
+func (m *MockUpstream) start(ctx context.Context, logger *slog.Logger) (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	srv := &http.Server{Handler: m, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Mock number service stopped", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	return "http://" + ln.Addr().String() + "/test", nil
+}
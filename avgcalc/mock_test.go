@@ -0,0 +1,129 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// runWithMock runs the whole service against the mock number service,
+// with settings given as name/value pairs, until the test ends. It serves
+// on a Unix socket and returns a client dialling it.
+func runWithMock(t *testing.T, settings ...string) *http.Client {
+	t.Helper()
+	t.Setenv("NUMBER_SERVICE_URL", "")
+	socket := filepath.Join(t.TempDir(), "avg.sock")
+	src := config.FromEnv()
+	settings = append([]string{"MOCK_UPSTREAM", "true", "LISTEN", "unix://" + socket}, settings...)
+	for i := 0; i < len(settings); i += 2 {
+		if err := src.Set(settings[i], settings[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := LoadConfig(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	t.Cleanup(client.CloseIdleConnections)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if resp, err := client.Get("http://avg/healthz"); err == nil {
+			resp.Body.Close()
+			return client
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("service never started")
+		}
+	}
+}
+
+// getNumbers gets /numbers/:numberid from the service with token.
+func getNumbers(t *testing.T, client *http.Client, numberID, token string) (int, APIResponse, ErrorEnvelope) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, "http://avg/numbers/"+numberID, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&body)
+	var apiResp APIResponse
+	var envelope ErrorEnvelope
+	json.Unmarshal(body, &apiResp)
+	json.Unmarshal(body, &envelope)
+	return resp.StatusCode, apiResp, envelope
+}
+
+func TestMockUpstreamSequences(t *testing.T) {
+	client := runWithMock(t, "MOCK_UPSTREAM_TOKEN", "secret", "NUMBER_SERVICE_CACHE_TTL", "0s")
+
+	status, resp, _ := getNumbers(t, client, "e", "secret")
+	if status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	assertNumbers(t, "first batch", resp.Numbers, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20)
+
+	// The next call carries on from the last.
+	_, resp, _ = getNumbers(t, client, "e", "secret")
+	assertNumbers(t, "second batch", resp.Numbers, 22, 24, 26, 28, 30, 32, 34, 36, 38, 40)
+	assertNumbers(t, "window", resp.WindowCurrState, 22, 24, 26, 28, 30, 32, 34, 36, 38, 40)
+
+	status, _, envelope := getNumbers(t, client, "e", "wrong")
+	if status != http.StatusUnauthorized || envelope.Error.Code != CodeUpstreamUnauthorized {
+		t.Errorf("wrong token: status %d, code %q, want 401 %s", status, envelope.Error.Code, CodeUpstreamUnauthorized)
+	}
+}
+
+func TestMockUpstreamTimeout(t *testing.T) {
+	client := runWithMock(t,
+		"MOCK_UPSTREAM_LATENCY", "300ms",
+		"NUMBER_SERVICE_TIMEOUT", "50ms",
+		"NUMBER_SERVICE_RETRIES", "0")
+
+	start := time.Now()
+	status, resp, _ := getNumbers(t, client, "p", "token")
+	if status != http.StatusOK || !resp.TimedOut || len(resp.Numbers) != 0 {
+		t.Errorf("status %d, timedOut %v, numbers %v, want 200 timed out without numbers", status, resp.TimedOut, resp.Numbers)
+	}
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Errorf("answered after %s, want before the mock's latency", elapsed)
+	}
+}
+
+func TestMockUpstreamFailures(t *testing.T) {
+	client := runWithMock(t,
+		"MOCK_UPSTREAM_FAILURE_RATE", "1",
+		"NUMBER_SERVICE_RETRIES", "1")
+
+	status, _, envelope := getNumbers(t, client, "f", "token")
+	if status != http.StatusBadGateway || envelope.Error.Code != CodeUpstreamUnavailable {
+		t.Errorf("status %d, code %q, want 502 %s", status, envelope.Error.Code, CodeUpstreamUnavailable)
+	}
+}
@@ -0,0 +1,271 @@
+package avgcalc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+// numbersRequest asks for the numbers of types to be fetched and added to
+// a window, over HTTP or gRPC.
+type numbersRequest struct {
+	types []string
+
+	// authToken picks the client's window and fetchToken is sent to the
+	// number service; they differ with managed credentials.
+	authToken  string
+	fetchToken string
+
+	avg averaging
+
+	// timeout, if set, replaces NUMBER_SERVICE_TIMEOUT and the types' own
+	// timeouts for the fetches.
+	timeout time.Duration
+
+	// route names the request in error reports.
+	route string
+}
+
+// numbersResult is how a numbersRequest went: resp, or with status other
+// than 200, the error. Attempts and Cached are by number type.
+type numbersResult struct {
+	resp     APIResponse
+	status   int
+	err      ErrorBody
+	attempts map[string]int
+	cached   map[string]bool
+
+	// meta is how the number service answered, whether or not status is
+	// 200.
+	meta *ResponseMeta
+
+	// canceled is set when ctx ended while fetching and nothing was added.
+	canceled bool
+}
+
+// getNumbers fetches the numbers of req.types concurrently and adds
+// whatever arrived in one update. Requests for the same type with the same
+// token in flight at once share one fetch, and each adds its result to the
+// window itself; the repeats are discarded as duplicates.
+func (s *calculator) getNumbers(ctx context.Context, req numbersRequest) numbersResult {
+	types, fetchToken := req.types, req.fetchToken
+	windows := s.windows
+	timeout := s.timeout()
+	if req.timeout > 0 {
+		timeout = req.timeout
+	}
+	fetchCtx := withServiceTimeout(ctx, timeout)
+	results := make([][]float64, len(types))
+	calls := make([]upstreamCall, len(types))
+	errs := make([]error, len(types))
+	cached := map[string]bool{}
+	var wg sync.WaitGroup
+	for i, numberType := range types {
+		if numbers, ok := s.cache.fresh(numberType, fetchToken); ok {
+			results[i] = numbers
+			cached[numberType] = true
+			continue
+		}
+		cached[numberType] = false
+		wg.Add(1)
+		go func(i int, numberType string) {
+			defer wg.Done()
+			typeCtx := fetchCtx
+			if d := s.numberTypes.timeout(numberType); d > 0 && req.timeout == 0 {
+				typeCtx = withServiceTimeout(ctx, d)
+			}
+			results[i], calls[i], errs[i] = s.fetches.fetch(typeCtx, numberType, fetchToken, s.timedFetch)
+		}(i, numberType)
+	}
+	wg.Wait()
+
+	result := numbersResult{status: http.StatusOK, cached: cached, attempts: map[string]int{}, meta: upstreamMeta(ctx, types, calls, cached)}
+	if ctx.Err() != nil {
+		// The client is gone and the fetches were abandoned with it.
+		s.logRequest(ctx, slog.LevelInfo, "Client went away while fetching numbers",
+			slog.String("numberType", strings.Join(types, ",")))
+		result.status, result.canceled = statusClientClosedRequest, true
+		return result
+	}
+
+	retried := false
+	for i, call := range calls {
+		result.attempts[types[i]] = call.attempts
+		retried = retried || call.attempts > 1
+	}
+
+	var numbers []float64
+	var arrived []string
+	failures := map[string]string{}
+	var firstErr error
+	var staleAge time.Duration
+	stale, local := false, false
+	for i, err := range errs {
+		if err == nil {
+			if !cached[types[i]] {
+				s.cache.put(types[i], fetchToken, results[i])
+				s.fallback.observe(types[i], results[i])
+			}
+			numbers = append(numbers, results[i]...)
+			arrived = append(arrived, types[i])
+			continue
+		}
+		if apperr.HTTPStatus(err) >= http.StatusInternalServerError && !errors.Is(err, errCircuitOpen) {
+			s.cfg.HTTP.Reporter.Report(ctx, err, map[string]string{
+				"service":    "avg",
+				"route":      req.route,
+				"numberType": types[i],
+			})
+		}
+		// A timeout leaves the window unchanged: neither stale nor local
+		// numbers stand in for a service that is only slow.
+		timeout := errors.Is(err, apperr.ErrUpstreamTimeout)
+		if previous, age, ok := s.cache.stale(types[i], fetchToken, err); ok && !timeout {
+			s.logRequest(ctx, slog.LevelWarn, "Serving stale numbers",
+				slog.String("numberType", types[i]), slog.Int64("ageMs", age.Milliseconds()), slog.Any("error", err))
+			numbers = append(numbers, previous...)
+			arrived = append(arrived, types[i])
+			staleAge = max(staleAge, age)
+			stale = true
+			continue
+		}
+		if generated, ok := s.fallback.generate(types[i], err); ok && !timeout {
+			s.logRequest(ctx, slog.LevelWarn, "Generated numbers locally",
+				slog.String("numberType", types[i]), slog.Any("error", err))
+			numbers = append(numbers, generated...)
+			arrived = append(arrived, types[i])
+			local = true
+			continue
+		}
+		failures[types[i]] = err.Error()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if numbers == nil && circuitOpen(errs) {
+		resp, err := s.degradedResponse(req.authToken)
+		if err != nil {
+			return s.storeUnavailable(ctx, result, err)
+		}
+		resp.UpstreamUnavailable = true
+		result.resp = resp
+		return result
+	}
+	if numbers == nil && timedOut(errs) {
+		s.logRequest(ctx, slog.LevelWarn, "Number service timed out, serving the window unchanged",
+			slog.String("numberType", strings.Join(types, ",")))
+		resp, err := s.degradedResponse(req.authToken)
+		if err != nil {
+			return s.storeUnavailable(ctx, result, err)
+		}
+		resp.Numbers, resp.TimedOut = []float64{}, true
+		if retried {
+			resp.Attempts = result.attempts
+		}
+		result.resp = resp
+		return result
+	}
+	if numbers == nil {
+		body := ErrorBody{Code: upstreamCode(firstErr), Message: firstErr.Error()}
+		if len(types) > 1 {
+			body.Code, body.Message = CodeAllNumberTypesFailed, "Every number type failed"
+			body.Failures = failures
+		}
+		store := windows.shared
+		if windows.perClient {
+			store = windows.peek(req.authToken)
+		}
+		if store != nil {
+			if count, size, err := store.Occupancy(); err == nil {
+				full := count >= size
+				body.WindowCount, body.WindowSize, body.IsFull = &count, &size, &full
+			}
+		}
+		if retried {
+			body.Attempts = result.attempts
+		}
+		result.status, result.err = apperr.HTTPStatus(firstErr), body
+		return result
+	}
+
+	_, span := tracer.Start(ctx, "NumberStore.AddNumbers")
+	resp, err := s.addNumbers(windows.get(req.authToken), arrived, numbers, req.avg)
+	span.End()
+	if err != nil {
+		return s.storeUnavailable(ctx, result, err)
+	}
+	s.logRequest(ctx, slog.LevelDebug, "Window updated",
+		slog.Int("windowCount", resp.WindowCount),
+		slog.Int("windowSize", resp.WindowSize),
+		slog.Int("accepted", len(resp.Accepted)),
+		slog.Int("duplicatesDiscarded", resp.DuplicatesDiscarded))
+	if len(failures) > 0 {
+		resp.Failures = failures
+	}
+	if retried {
+		resp.Attempts = result.attempts
+	}
+	if stale {
+		seconds := int(staleAge.Seconds())
+		resp.Stale, resp.StaleAgeSeconds = true, &seconds
+	}
+	if local {
+		resp.Source = "local"
+	}
+	resp.CacheHit = true
+	for _, hit := range cached {
+		resp.CacheHit = resp.CacheHit && hit
+	}
+	result.resp = resp
+	return result
+}
+
+// storeUnavailable fails result for a window that couldn't be read or
+// changed.
+func (s *calculator) storeUnavailable(ctx context.Context, result numbersResult, err error) numbersResult {
+	s.logRequest(ctx, slog.LevelError, "Window store failed", slog.Any("error", err))
+	result.status = http.StatusServiceUnavailable
+	result.err = ErrorBody{Code: CodeStoreUnavailable, Message: err.Error()}
+	return result
+}
+
+// upstreamMeta sums up calls, made for types, for the response.
+func upstreamMeta(ctx context.Context, types []string, calls []upstreamCall, cached map[string]bool) *ResponseMeta {
+	meta := &ResponseMeta{RequestID: middleware.RequestIDFrom(ctx)}
+	byType := make(map[string]UpstreamMeta, len(types))
+	for i, call := range calls {
+		latency := milliseconds(call.latency)
+		meta.UpstreamLatencyMs = max(meta.UpstreamLatencyMs, latency)
+		meta.UpstreamStatus = max(meta.UpstreamStatus, call.status)
+		meta.Attempts += call.attempts
+		byType[types[i]] = UpstreamMeta{
+			UpstreamLatencyMs: latency,
+			UpstreamStatus:    call.status,
+			Attempts:          call.attempts,
+			Cached:            cached[types[i]],
+		}
+	}
+	if len(types) > 1 {
+		meta.Types = byType
+	}
+	return meta
+}
+
+// timedOut reports whether every error in errs is the number service
+// timing out.
+func timedOut(errs []error) bool {
+	for _, err := range errs {
+		if !errors.Is(err, apperr.ErrUpstreamTimeout) {
+			return false
+		}
+	}
+	return len(errs) > 0
+}
@@ -0,0 +1,143 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+// newTestCalculator returns a calculator calling upstream as its number
+// service, with the defaults changed by configure if set.
+func newTestCalculator(t *testing.T, upstream http.Handler, configure func(*Config)) *calculator {
+	t.Helper()
+	srv := httptest.NewServer(upstream)
+	t.Cleanup(srv.Close)
+	cfg := DefaultConfig()
+	var err error
+	if cfg.HTTP, err = middleware.Load(config.FromEnv()); err != nil {
+		t.Fatal(err)
+	}
+	cfg.NumberServiceURL = srv.URL
+	cfg.RetryBackoff = time.Millisecond
+	if configure != nil {
+		configure(&cfg)
+	}
+	return newCalculator(cfg)
+}
+
+// serveAPI serves s's HTTP API until the test ends, returning its URL.
+func serveAPI(t *testing.T, s *calculator) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := httptest.NewServer(s.router(ctx))
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+	})
+	return srv.URL
+}
+
+// writeNumbers answers as the number service does.
+func writeNumbers(w http.ResponseWriter, numbers ...float64) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(NumberResponse{Numbers: numbers})
+}
+
+func TestGetNumbersTimeoutLeavesWindowUnchanged(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	var calls atomic.Int32
+	s := newTestCalculator(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			// Just past the deadline: the answer comes, but too late.
+			time.Sleep(timeout + 30*time.Millisecond)
+			writeNumbers(w, 6, 8)
+			return
+		}
+		writeNumbers(w, 2, 4)
+	}), func(cfg *Config) {
+		cfg.Timeout = timeout
+		cfg.Retries = 0
+		cfg.CacheTTL = 0
+		cfg.MaxStale = time.Minute
+		cfg.FallbackGenerators = true
+	})
+	req := numbersRequest{types: []string{"even"}}
+
+	first := s.getNumbers(context.Background(), req)
+	if first.status != http.StatusOK {
+		t.Fatalf("first request: status %d, %+v", first.status, first.err)
+	}
+	assertNumbers(t, "first window", first.resp.WindowCurrState, 2, 4)
+
+	second := s.getNumbers(context.Background(), req)
+	if second.status != http.StatusOK {
+		t.Fatalf("timed out request: status %d, %+v", second.status, second.err)
+	}
+	resp := second.resp
+	if !resp.TimedOut || resp.Numbers == nil || len(resp.Numbers) != 0 {
+		t.Errorf("TimedOut, Numbers = %v, %v, want true, []", resp.TimedOut, resp.Numbers)
+	}
+	if resp.Stale || resp.Source != "" {
+		t.Errorf("Stale, Source = %v, %q: a timeout must not be served stale or local numbers", resp.Stale, resp.Source)
+	}
+	assertNumbers(t, "windowPrevState", resp.WindowPrevState, 2, 4)
+	assertNumbers(t, "windowCurrState", resp.WindowCurrState, 2, 4)
+
+	// The late answer arrives after the request gave up on it.
+	time.Sleep(100 * time.Millisecond)
+	state, err := s.windows.shared.GetCurrentState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNumbers(t, "window after the late answer", state, 2, 4)
+}
+
+func TestParseNumbersMixed(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    []float64
+		wantErr bool
+	}{
+		{body: `{"numbers": [1, 2.5, -3, 0.1, 1e3, -0]}`, want: []float64{1, 2.5, -3, 0.1, 1000, 0}},
+		{body: `{"numbers": [9007199254740993]}`, want: []float64{9007199254740992}},
+		{body: `{"numbers": [1, "2"]}`, wantErr: true},
+		{body: `{"numbers": []}`, wantErr: true},
+		{body: `[1, 2]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseNumbers(strings.NewReader(tt.body))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseNumbers(%s) error = %v, want error %v", tt.body, err, tt.wantErr)
+			continue
+		}
+		assertNumbers(t, "parseNumbers("+tt.body+")", got, tt.want...)
+	}
+}
+
+func TestFetchMixedNumbers(t *testing.T) {
+	s := newTestCalculator(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"numbers": [2, 4.5, -6, 8.25]}`))
+	}), nil)
+
+	result := s.getNumbers(context.Background(), numbersRequest{types: []string{"even"}})
+	if result.status != http.StatusOK {
+		t.Fatalf("status %d, %+v", result.status, result.err)
+	}
+	assertNumbers(t, "numbers", result.resp.Numbers, 2, 4.5, -6, 8.25)
+	assertNumbers(t, "windowCurrState", result.resp.WindowCurrState, 2, 4.5, -6, 8.25)
+	if result.resp.Min != -6 || result.resp.Max != 8.25 || result.resp.Average.Rounded() != 2.19 {
+		t.Errorf("min, max, avg = %v, %v, %v, want -6, 8.25, 2.19", result.resp.Min, result.resp.Max, result.resp.Average)
+	}
+}
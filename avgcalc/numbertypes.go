@@ -0,0 +1,164 @@
+package avgcalc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// NumberType is a kind of number the calculator can ask the number service
+// for, by ID.
+type NumberType struct {
+	ID string
+
+	// Path is the number service's endpoint, joined onto
+	// NUMBER_SERVICE_URL. It names the type in responses and metrics.
+	Path string
+	Name string
+
+	// Timeout, if set, replaces NUMBER_SERVICE_TIMEOUT for this type. A
+	// timeout asked for by the request still wins.
+	Timeout time.Duration
+}
+
+// DefaultNumberTypes are the types registered without NUMBER_TYPES.
+var DefaultNumberTypes = []NumberType{
+	{ID: "p", Path: "primes", Name: "Primes"},
+	{ID: "f", Path: "fibo", Name: "Fibonacci"},
+	{ID: "e", Path: "even", Name: "Even"},
+	{ID: "r", Path: "rand", Name: "Random"},
+}
+
+// numberTypeRegistry holds the number types the API accepts.
+type numberTypeRegistry struct {
+	types  []NumberType
+	byID   map[string]NumberType
+	byPath map[string]NumberType
+}
+
+func newNumberTypeRegistry(types []NumberType) *numberTypeRegistry {
+	r := &numberTypeRegistry{byID: map[string]NumberType{}, byPath: map[string]NumberType{}}
+	for _, t := range types {
+		r.byID[t.ID] = t
+	}
+	for _, t := range r.byID {
+		r.types = append(r.types, t)
+		r.byPath[t.Path] = t
+	}
+	sort.Slice(r.types, func(i, j int) bool { return r.types[i].ID < r.types[j].ID })
+	return r
+}
+
+// loadNumberTypes reads NUMBER_TYPES, a JSON object mapping IDs to types,
+// e.g. {"s":{"path":"squares","name":"Squares","timeout":"800ms"}}. The
+// types are registered beside the default ones, replacing those with the
+// same ID.
+func loadNumberTypes(src *config.Source) ([]NumberType, error) {
+	raw := src.Get("NUMBER_TYPES")
+	if raw == "" {
+		return DefaultNumberTypes, nil
+	}
+	var entries map[string]config.NumberType
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", src.Name("NUMBER_TYPES"), err)
+	}
+
+	types := append([]NumberType(nil), DefaultNumberTypes...)
+	for id, entry := range entries {
+		if id == "" || strings.ContainsAny(id, ", /") {
+			return nil, fmt.Errorf("%s: number ID %q must be non-empty, without commas, spaces or slashes", src.Name("NUMBER_TYPES"), id)
+		}
+		if entry.Path == "" {
+			return nil, fmt.Errorf("%s: number type %q has no path", src.Name("NUMBER_TYPES"), id)
+		}
+		t := NumberType{ID: id, Path: strings.Trim(entry.Path, "/"), Name: entry.Name}
+		if t.Name == "" {
+			t.Name = t.Path
+		}
+		if entry.Timeout != "" {
+			d, err := time.ParseDuration(entry.Timeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("%s: number type %q timeout must be a positive duration, got %q", src.Name("NUMBER_TYPES"), id, entry.Timeout)
+			}
+			t.Timeout = d
+		}
+		types = append(types, t)
+	}
+
+	r := newNumberTypeRegistry(types)
+	if len(r.byPath) != len(r.types) {
+		return nil, fmt.Errorf("%s: two number types have the same path", src.Name("NUMBER_TYPES"))
+	}
+	return r.types, nil
+}
+
+// parse maps a comma-separated list of number IDs to their paths, dropping
+// repeats. Any unknown ID makes the whole list invalid.
+func (r *numberTypeRegistry) parse(ids string) ([]string, bool) {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(ids, ",") {
+		t, valid := r.byID[id]
+		if !valid {
+			return nil, false
+		}
+		if !seen[id] {
+			seen[id] = true
+			paths = append(paths, t.Path)
+		}
+	}
+	return paths, true
+}
+
+// ids lists the registered IDs in order.
+func (r *numberTypeRegistry) ids() []string {
+	ids := make([]string, len(r.types))
+	for i, t := range r.types {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// invalid is the error for a number ID that isn't registered.
+func (r *numberTypeRegistry) invalid() ErrorBody {
+	return ErrorBody{
+		Code:     CodeInvalidNumberID,
+		Message:  "Invalid number type; valid IDs are " + strings.Join(r.ids(), ", "),
+		ValidIDs: r.ids(),
+	}
+}
+
+// timeout is the timeout of the type at path, or 0 for the default.
+func (r *numberTypeRegistry) timeout(path string) time.Duration {
+	return r.byPath[path].Timeout
+}
+
+// numberTypeInfo is one entry of GET /numbertypes.
+type numberTypeInfo struct {
+	ID        string `json:"id"`
+	Path      string `json:"path"`
+	Name      string `json:"name"`
+	TimeoutMs int64  `json:"timeoutMs,omitempty"`
+}
+
+type numberTypesResponse struct {
+	NumberTypes []numberTypeInfo `json:"numberTypes"`
+}
+
+// getNumberTypes serves GET /numbertypes, the registered number types.
+func getNumberTypes(r *numberTypeRegistry) gin.HandlerFunc {
+	resp := numberTypesResponse{NumberTypes: make([]numberTypeInfo, len(r.types))}
+	for i, t := range r.types {
+		resp.NumberTypes[i] = numberTypeInfo{ID: t.ID, Path: t.Path, Name: t.Name, TimeoutMs: t.Timeout.Milliseconds()}
+	}
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,427 @@
+package avgcalc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/buildinfo"
+)
+
+// messageError is the error body of the other routes.
+type messageError struct {
+	Error string `json:"error"`
+}
+
+// operation is one route in the OpenAPI document. Request and response
+// bodies are Go types, turned into schemas by reflection, so the document
+// follows the types the handlers actually use.
+type operation struct {
+	method, path string
+	summary      string
+	security     string
+	params       []parameter
+	body         reflect.Type
+	responses    []response
+}
+
+type parameter struct {
+	name, in, description string
+	required              bool
+	schema                map[string]any
+}
+
+type response struct {
+	status      int
+	description string
+	contentType string
+	body        reflect.Type
+}
+
+func typeOf[T any]() reflect.Type { return reflect.TypeOf((*T)(nil)).Elem() }
+
+var (
+	numberIDParam = parameter{
+		name: "numberid", in: "path", required: true,
+		description: "Comma-separated number IDs, by default p (primes), f (Fibonacci), e (even) and r (random). GET /numbertypes lists them all.",
+		schema:      map[string]any{"type": "string", "example": "p,f"},
+	}
+	avgModeParams = []parameter{
+		{name: "avgMode", in: "query", description: "ema adds an exponential moving average, with alpha, in emaAvg, and weighted an average weighing newer numbers more in weightedAvg.",
+			schema: map[string]any{"type": "string", "enum": []string{"mean", "ema", "weighted"}}},
+		{name: "alpha", in: "query", description: "Smoothing factor of the moving average, in (0, 1].",
+			schema: map[string]any{"type": "number", "exclusiveMinimum": true, "minimum": 0, "maximum": 1}},
+	}
+	formatParam = parameter{
+		name: "format", in: "query", description: "csv or ndjson downloads the response as text/csv or application/x-ndjson, as does asking for them with Accept.",
+		schema: map[string]any{"type": "string", "enum": []string{"json", "csv", "ndjson"}},
+	}
+	timeoutParams = []parameter{
+		{name: "timeoutMs", in: "query", description: "Timeout of each call to the number service, clamped to the configured bounds.",
+			schema: map[string]any{"type": "integer", "minimum": 1}},
+		{name: TimeoutHeader, in: "header", description: "The same as timeoutMs, which wins if both are given.",
+			schema: map[string]any{"type": "integer", "minimum": 1}},
+	}
+)
+
+// operations lists every route the calculator serves.
+var operations = []operation{
+	{method: "GET", path: "/numbers/{numberid}", summary: "Fetch numbers and add them to the window", security: "bearer",
+		params: append(append([]parameter{numberIDParam}, avgModeParams...), timeoutParams...),
+		responses: []response{
+			{status: 200, description: "The window after adding the numbers, or unchanged if the number service timed out or its circuit is open", body: typeOf[APIResponse]()},
+			{status: 400, description: "Invalid number ID or avgMode, or the number service refused the request", body: typeOf[ErrorEnvelope]()},
+			{status: 401, description: "Missing, malformed or refused token", body: typeOf[ErrorEnvelope]()},
+			{status: 502, description: "The number service failed", body: typeOf[ErrorEnvelope]()},
+			{status: 503, description: "The window is kept in Redis and Redis failed", body: typeOf[ErrorEnvelope]()},
+			{status: 504, description: "The number service timed out for some types and failed for the others", body: typeOf[ErrorEnvelope]()},
+		}},
+	{method: "GET", path: "/numbers/{numberid}/stream", summary: "Stream the window as server-sent events", security: "bearer",
+		params: []parameter{numberIDParam},
+		responses: []response{
+			{status: 200, description: "An event named window, with the window as data, each time it changes", contentType: "text/event-stream", body: typeOf[WindowEvent]()},
+			{status: 400, description: "Invalid number ID", body: typeOf[ErrorEnvelope]()},
+			{status: 401, description: "Missing or malformed token, with per-client windows", body: typeOf[ErrorEnvelope]()},
+			{status: 503, description: "Too many streams open, or the window is kept in Redis and Redis failed", body: typeOf[messageError]()},
+		}},
+	{method: "GET", path: "/ws", summary: "Receive the window over a WebSocket each time its average changes", security: "bearer",
+		responses: []response{
+			{status: 101, description: `Switched to WebSocket; send {"subscribe": ["p","f"]} to receive the window`, body: typeOf[wsMessage]()},
+			{status: 400, description: "Not a WebSocket upgrade", contentType: "text/plain"},
+			{status: 401, description: "Missing or malformed token, with per-client windows", body: typeOf[ErrorEnvelope]()},
+			{status: 503, description: "Too many streams open, or the window is kept in Redis and Redis failed", body: typeOf[messageError]()},
+		}},
+	{method: "POST", path: "/numbers", summary: "Add numbers to the window without the number service", security: "bearer",
+		params: avgModeParams, body: typeOf[NumberResponse](),
+		responses: []response{
+			{status: 200, description: "The window after adding the numbers", body: typeOf[APIResponse]()},
+			{status: 400, description: "Not a list of numbers", body: typeOf[messageError]()},
+			{status: 401, description: "Missing or malformed token", body: typeOf[messageError]()},
+			{status: 503, description: "The window is kept in Redis and Redis failed", body: typeOf[ErrorEnvelope]()},
+		}},
+	{method: "GET", path: "/window", summary: "Read the window", security: "bearer",
+		params: []parameter{formatParam,
+			{name: "If-None-Match", in: "header", description: "The ETag of a window already held, to be answered 304 if it hasn't changed.",
+				schema: map[string]any{"type": "string"}}},
+		responses: []response{
+			{status: 200, description: "The window; with per-client windows, the caller's. In CSV and NDJSON, a row per number, oldest first", body: typeOf[WindowResponse]()},
+			{status: 304, description: "The window is as tagged by If-None-Match", contentType: "text/plain"},
+			{status: 400, description: "Unknown format", body: typeOf[messageError]()},
+			{status: 401, description: "Missing or malformed token, with per-client windows", body: typeOf[messageError]()},
+			{status: 503, description: "The window is kept in Redis and Redis failed", body: typeOf[ErrorEnvelope]()},
+		}},
+	{method: "GET", path: "/window/history", summary: "Read how the window changed, newest first", security: "bearer",
+		params: []parameter{
+			{name: "limit", in: "query", description: "How many snapshots to return, 20 by default.",
+				schema: map[string]any{"type": "integer", "minimum": 1}},
+			{name: "type", in: "query", description: "Only snapshots with numbers of this number ID.",
+				schema: map[string]any{"type": "string", "example": "p"}},
+			formatParam,
+		},
+		responses: []response{
+			{status: 200, description: "The window's latest snapshots; with per-client windows, the caller's", body: typeOf[windowHistoryResponse]()},
+			{status: 400, description: "Invalid limit, type or format", body: typeOf[messageError]()},
+			{status: 401, description: "Missing or malformed token, with per-client windows", body: typeOf[messageError]()},
+			{status: 503, description: "The window is kept in Redis and Redis failed", body: typeOf[ErrorEnvelope]()},
+		}},
+	{method: "DELETE", path: "/window", summary: "Empty the window", security: "bearer",
+		responses: []response{
+			{status: 200, description: "What the window held; its history is cleared too", body: typeOf[windowReset]()},
+			{status: 400, description: "A ?type= was given", body: typeOf[messageError]()},
+			{status: 401, description: "Missing or malformed token", body: typeOf[messageError]()},
+			{status: 503, description: "The window is kept in Redis and Redis failed", body: typeOf[ErrorEnvelope]()},
+		}},
+	{method: "GET", path: "/healthz", summary: "Liveness",
+		responses: []response{{status: 200, description: "The process is serving", body: typeOf[healthResponse]()}}},
+	{method: "GET", path: "/readyz", summary: "Readiness",
+		responses: []response{
+			{status: 200, description: "Ready", body: typeOf[readyResponse]()},
+			{status: 503, description: "The number service has been failing for too long", body: typeOf[readyResponse]()},
+		}},
+	{method: "GET", path: "/numbertypes", summary: "List the number types",
+		responses: []response{{status: 200, description: "The registered number types, by ID", body: typeOf[numberTypesResponse]()}}},
+	{method: "GET", path: "/stats", summary: "Count requests and number service failures by number type",
+		responses: []response{
+			{status: 200, description: "The counts since startup or the last reset, the window's fill and the uptime", body: typeOf[serviceStats]()},
+			{status: 503, description: "The window is kept in Redis and Redis failed", body: typeOf[ErrorEnvelope]()},
+		}},
+	{method: "DELETE", path: "/stats", summary: "Reset the counts", security: "admin",
+		responses: []response{
+			{status: 200, description: "The counts discarded", body: typeOf[serviceStats]()},
+			{status: 401, description: "Wrong admin token", body: typeOf[messageError]()},
+			{status: 403, description: "The admin API is disabled", body: typeOf[messageError]()},
+			{status: 503, description: "The window is kept in Redis and Redis failed", body: typeOf[ErrorEnvelope]()},
+		}},
+	{method: "GET", path: "/version", summary: "Build information",
+		responses: []response{{status: 200, description: "The running build", body: typeOf[buildinfo.Info]()}}},
+	{method: "GET", path: "/metrics", summary: "Prometheus metrics",
+		responses: []response{{status: 200, description: "Metrics in the Prometheus text format", contentType: "text/plain"}}},
+	{method: "GET", path: "/", summary: "Status page",
+		responses: []response{{status: 200, description: "A status page for humans", contentType: "text/html"}}},
+	{method: "GET", path: "/openapi.json", summary: "This document",
+		responses: []response{{status: 200, description: "The OpenAPI document"}}},
+	{method: "GET", path: "/docs", summary: "Swagger UI for this document",
+		responses: []response{{status: 200, description: "Swagger UI", contentType: "text/html"}}},
+	{method: "GET", path: "/admin/config", summary: "The settings in force", security: "admin",
+		responses: []response{
+			{status: 200, description: "The settings, including runtime changes", body: typeOf[effectiveConfig]()},
+			{status: 401, description: "Missing or wrong admin token", body: typeOf[messageError]()},
+		}},
+	{method: "PUT", path: "/admin/config/window", summary: "Resize the window until the next restart", security: "admin",
+		body: typeOf[windowResize](),
+		responses: []response{
+			{status: 200, description: "The window was resized", body: typeOf[windowResized]()},
+			{status: 400, description: "Not {\"size\": N} or out of range", body: typeOf[messageError]()},
+			{status: 401, description: "Missing or wrong admin token", body: typeOf[messageError]()},
+			{status: 409, description: "The window is time based", body: typeOf[messageError]()},
+		}},
+	{method: "GET", path: "/admin/breakers", summary: "The number service circuits", security: "admin",
+		responses: []response{
+			{status: 200, description: "Each number type's circuit", body: typeOf[breakerReport]()},
+			{status: 401, description: "Missing or wrong admin token", body: typeOf[messageError]()},
+		}},
+}
+
+// openAPIDocument builds the OpenAPI 3 document for operations.
+func openAPIDocument(ops []operation) map[string]any {
+	schemas := schemaSet{components: map[string]any{}}
+	paths := map[string]any{}
+	for _, op := range ops {
+		item, _ := paths[op.path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[op.path] = item
+		}
+
+		o := map[string]any{"summary": op.summary}
+		if op.security != "" {
+			o["security"] = []any{map[string]any{op.security: []string{}}}
+		}
+		if len(op.params) > 0 {
+			params := make([]any, len(op.params))
+			for i, p := range op.params {
+				params[i] = map[string]any{
+					"name": p.name, "in": p.in, "required": p.required,
+					"description": p.description, "schema": p.schema,
+				}
+			}
+			o["parameters"] = params
+		}
+		if op.body != nil {
+			o["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemas.schema(op.body)}},
+			}
+		}
+		responses := map[string]any{}
+		for _, r := range op.responses {
+			resp := map[string]any{"description": r.description}
+			contentType := r.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			content := map[string]any{}
+			if r.body != nil {
+				content["schema"] = schemas.schema(r.body)
+			}
+			resp["content"] = map[string]any{contentType: content}
+			responses[strconv.Itoa(r.status)] = resp
+		}
+		o["responses"] = responses
+		item[strings.ToLower(op.method)] = o
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Average Calculator",
+			"version": buildinfo.Read("avg").Version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer",
+					"description": "The number service token, passed through to it unless credentials are managed."},
+				"admin": map[string]any{"type": "http", "scheme": "bearer", "description": "ADMIN_TOKEN"},
+			},
+		},
+	}
+}
+
+// schemaSet turns Go types into schemas, named structs into shared
+// components referred to by $ref.
+type schemaSet struct {
+	components map[string]any
+}
+
+var (
+	timeType   = typeOf[time.Time]()
+	fixed2Type = typeOf[Fixed2]()
+)
+
+func (s schemaSet) schema(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case fixed2Type:
+		return map[string]any{"type": "number", "description": "Written with exactly two decimals."}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := s.schema(t.Elem())
+		if _, ok := elem["$ref"]; ok {
+			return map[string]any{"allOf": []any{elem}, "nullable": true}
+		}
+		elem["nullable"] = true
+		return elem
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := componentName(t)
+		if _, ok := s.components[name]; !ok {
+			s.components[name] = nil // Reserved, in case t refers to itself.
+			s.components[name] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// object is the schema of a struct as encoding/json writes it. Fields
+// without omitempty are always written, so they are required.
+func (s schemaSet) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" && f.Anonymous {
+			// encoding/json writes an embedded struct's fields as if they
+			// were the outer struct's; through a pointer, only when set.
+			t := f.Type
+			if t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Struct {
+				embedded := s.object(t)
+				for name, schema := range embedded["properties"].(map[string]any) {
+					properties[name] = schema
+				}
+				if f.Type == t {
+					names, _ := embedded["required"].([]string)
+					required = append(required, names...)
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = s.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	object := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		object["required"] = required
+	}
+	return object
+}
+
+// componentName is t's name, capitalized for the unexported types.
+func componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+// undocumentedRoutes lists the routes router serves that operations
+// leaves out, which would mean the document has fallen behind.
+func undocumentedRoutes(routes gin.RoutesInfo, ops []operation) []string {
+	documented := map[string]bool{}
+	for _, op := range ops {
+		documented[op.method+" "+op.path] = true
+	}
+	var missing []string
+	for _, r := range routes {
+		path := r.Path
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") {
+				segments[i] = "{" + segment[1:] + "}"
+			}
+		}
+		path = strings.Join(segments, "/")
+		if !documented[r.Method+" "+path] {
+			missing = append(missing, r.Method+" "+path)
+		}
+	}
+	return missing
+}
+
+// swaggerInit starts Swagger UI on GET /docs. It is allowed by its hash
+// in docsPolicy, so no other inline script can run.
+const swaggerInit = `window.onload = function () { SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"}); };`
+
+// swaggerUI is where Swagger UI is loaded from. unpkg redirects to the
+// latest 5.x, so the policy allows the whole origin.
+const swaggerUI = "https://unpkg.com/swagger-ui-dist@5"
+
+var docsPolicy = func() string {
+	sum := sha256.Sum256([]byte(swaggerInit))
+	return "default-src 'none'; script-src https://unpkg.com 'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'; " +
+		"style-src https://unpkg.com 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; " +
+		"base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+}()
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Average Calculator API</title>
+<link rel="stylesheet" href="` + swaggerUI + `/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="` + swaggerUI + `/swagger-ui-bundle.js"></script>
+<script>` + swaggerInit + `</script>
+</body>
+</html>
+`
+
+func getDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
@@ -0,0 +1,104 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+// pathParam matches a gin path parameter, written {name} in OpenAPI.
+var pathParam = regexp.MustCompile(`:([^/]+)`)
+
+// refs collects every $ref in v.
+func refs(v any, found []string) []string {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			if ref, ok := elem.(string); ok && k == "$ref" {
+				found = append(found, ref)
+			}
+			found = refs(elem, found)
+		}
+	case []any:
+		for _, elem := range v {
+			found = refs(elem, found)
+		}
+	}
+	return found
+}
+
+func TestOpenAPIListsEveryRoute(t *testing.T) {
+	cfg := DefaultConfig()
+	var err error
+	if cfg.HTTP, err = middleware.Load(config.FromEnv()); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Fetcher = &fakeFetcher{}
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	router := newCalculator(cfg).router(ctx)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	var spec struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("spec doesn't parse: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want a 3.x document", spec.OpenAPI)
+	}
+
+	for _, route := range router.Routes() {
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		op, ok := spec.Paths[path][strings.ToLower(route.Method)]
+		if !ok {
+			t.Errorf("%s %s missing from the spec", route.Method, path)
+			continue
+		}
+		if _, ok := op["responses"]; !ok {
+			t.Errorf("%s %s has no responses", route.Method, path)
+		}
+		for _, m := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+			if !strings.Contains(string(mustJSON(t, op["parameters"])), `"name":"`+m[1]+`"`) {
+				t.Errorf("%s %s doesn't describe its %s parameter", route.Method, path, m[1])
+			}
+		}
+	}
+
+	var body any
+	json.Unmarshal(w.Body.Bytes(), &body)
+	for _, ref := range refs(body, nil) {
+		name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+		if _, defined := spec.Components.Schemas[name]; !ok || !defined {
+			t.Errorf("$ref %s doesn't resolve", ref)
+		}
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
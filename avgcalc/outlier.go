@@ -0,0 +1,38 @@
+package avgcalc
+
+import "math"
+
+const (
+	// OutlierZScore is how many standard deviations from the window's mean
+	// a number may be before the filter rejects it.
+	OutlierZScore = 3.0
+
+	// OutlierMinSamples is how many numbers the window needs before the
+	// filter judges any; until then everything is let in.
+	OutlierMinSamples = 5
+)
+
+// outlierFilter keeps numbers whose z-score against the window exceeds
+// threshold out of it. It is off unless OUTLIER_FILTER=zscore.
+type outlierFilter struct {
+	enabled    bool
+	threshold  float64
+	minSamples int
+}
+
+// judge returns a func reporting whether a number is an outlier against
+// window, which is only read now: the numbers of one batch are judged
+// against the window as it was before any of them, so a run of outliers
+// can't make room for itself.
+func (f outlierFilter) judge(window []float64) func(float64) bool {
+	if !f.enabled || len(window) < f.minSamples {
+		return func(float64) bool { return false }
+	}
+	stats := computeStats(window, 0)
+	return func(n float64) bool {
+		if stats.StdDev == 0 {
+			return n != stats.Average
+		}
+		return math.Abs(n-stats.Average)/stats.StdDev > f.threshold
+	}
+}
@@ -0,0 +1,45 @@
+package avgcalc
+
+import "testing"
+
+func TestOutlierFilter(t *testing.T) {
+	on := outlierFilter{enabled: true, threshold: 3, minSamples: 5}
+	// The mean is 12 and the standard deviation 2, so numbers from 6 to 18
+	// are let in.
+	spread := []float64{10, 14, 10, 14, 10, 14}
+
+	tests := []struct {
+		name   string
+		filter outlierFilter
+		window []float64
+		n      float64
+		want   bool
+	}{
+		{"at the mean", on, spread, 12, false},
+		{"at the threshold above", on, spread, 18, false},
+		{"past the threshold above", on, spread, 18.01, true},
+		{"at the threshold below", on, spread, 6, false},
+		{"past the threshold below", on, spread, 5.99, true},
+		{"tighter threshold", outlierFilter{enabled: true, threshold: 1, minSamples: 5}, spread, 14.5, true},
+		{"too few samples", on, spread[:4], 1000, false},
+		{"no spread, same number", on, []float64{5, 5, 5, 5, 5}, 5, false},
+		{"no spread, other number", on, []float64{5, 5, 5, 5, 5}, 5.1, true},
+		{"off", outlierFilter{}, spread, 1000, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.judge(tt.window)(tt.n); got != tt.want {
+			t.Errorf("%s: %v judged an outlier = %v, want %v", tt.name, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestOutlierFilterJudgesBatchAgainstWindowBefore(t *testing.T) {
+	ns := newNumberStore(20, 0, windowOptions{outliers: outlierFilter{enabled: true, threshold: 3, minSamples: 5}})
+	mustAdd(t, ns, 10, 11, 12, 13, 14)
+
+	// 100 alone would be rejected; arriving with more like it doesn't help.
+	added := mustAdd(t, ns, 100, 101, 102, 12.5)
+	assertNumbers(t, "Rejected", added.Rejected, 100, 101, 102)
+	assertNumbers(t, "Accepted", added.Accepted, 12.5)
+	assertNumbers(t, "Current", added.Current, 10, 11, 12, 13, 14, 12.5)
+}
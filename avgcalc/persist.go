@@ -0,0 +1,223 @@
+package avgcalc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// WindowStateMaxAge is how old a saved window may be and still be
+	// restored; older numbers would only skew the average.
+	WindowStateMaxAge = 10 * time.Minute
+
+	// WindowStateSaveInterval is how often the windows are saved when they
+	// have changed.
+	WindowStateSaveInterval = 5 * time.Second
+)
+
+// savedWindows is the on-disk form of the windows. Client windows are
+// keyed by the hash of their token, as in memory.
+type savedWindows struct {
+	SavedAt time.Time              `json:"savedAt"`
+	Shared  *savedWindow           `json:"shared,omitempty"`
+	Clients map[string]savedWindow `json:"clients,omitempty"`
+}
+
+// savedWindow is one window's numbers, oldest first. Arrived is only kept
+// for a timed window.
+type savedWindow struct {
+	Numbers []float64   `json:"numbers"`
+	Arrived []time.Time `json:"arrived,omitempty"`
+	Updated time.Time   `json:"updated,omitempty"`
+}
+
+// saved copies the window for saving.
+func (ns *NumberStore) saved() savedWindow {
+	var w savedWindow
+	ns.read(func() {
+		w.Numbers = ns.state()
+		if ns.duration > 0 {
+			w.Arrived = make([]time.Time, ns.count)
+			for i := range w.Arrived {
+				w.Arrived[i] = ns.arrived[ns.at(i)]
+			}
+		}
+		w.Updated = ns.updated
+	})
+	return w
+}
+
+// restore adds the numbers of a saved window, keeping the newest that fit.
+// Numbers saved without an arrival time arrived at savedAt, for a timed
+// window; those that have expired since are dropped. Restoring isn't
+// recorded in the history.
+func (ns *NumberStore) restore(w savedWindow, savedAt time.Time) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	for i, num := range w.Numbers {
+		if _, ok := ns.members[num]; ok {
+			continue
+		}
+		if ns.count == ns.size {
+			ns.evictOldest()
+		}
+		arrived := savedAt
+		if i < len(w.Arrived) {
+			arrived = w.Arrived[i]
+		}
+		ns.push(num, arrived)
+	}
+	ns.updated = w.Updated
+	ns.expire(time.Now())
+	ns.expired = nil
+}
+
+// windowState saves the windows to path so they survive a restart. It is
+// off without a path, and only for windows in memory. Saving is best
+// effort: failures are logged and the windows carry on in memory.
+type windowState struct {
+	path     string
+	maxAge   time.Duration
+	interval time.Duration
+	logger   *slog.Logger
+
+	// last is the windows as last written, to skip writing them unchanged.
+	// mu serializes the saves.
+	mu   sync.Mutex
+	last []byte
+}
+
+// load restores the windows saved at path. A missing file is normal; an
+// unreadable, corrupt or stale one is logged and ignored.
+func (s *windowState) load(w *windows) {
+	if s.path == "" {
+		return
+	}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		s.logger.Warn("Failed to read saved windows", "path", s.path, "error", err)
+		return
+	}
+	var saved savedWindows
+	if err := json.Unmarshal(data, &saved); err != nil {
+		s.logger.Warn("Ignoring corrupt saved windows", "path", s.path, "error", err)
+		return
+	}
+	if age := time.Since(saved.SavedAt); s.maxAge > 0 && age > s.maxAge {
+		s.logger.Warn("Ignoring stale saved windows", "path", s.path, "age", age.Round(time.Second).String())
+		return
+	}
+
+	restored := 0
+	if w.perClient {
+		now := time.Now()
+		w.mu.Lock()
+		for key, sw := range saved.Clients {
+			store, ok := w.newStore(key).(*NumberStore)
+			if !ok {
+				break
+			}
+			store.restore(sw, saved.SavedAt)
+			w.clients[key] = &clientWindow{store: store, lastUsed: now}
+			restored++
+		}
+		w.mu.Unlock()
+	} else if shared, ok := w.shared.(*NumberStore); ok && saved.Shared != nil {
+		shared.restore(*saved.Shared, saved.SavedAt)
+		restored++
+	}
+	s.logger.Info("Restored saved windows", "path", s.path, "windows", restored)
+}
+
+// save writes the windows if they changed since they were last written.
+func (s *windowState) save(w *windows) {
+	if s.path == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var saved savedWindows
+	if w.perClient {
+		w.mu.Lock()
+		stores := make(map[string]*NumberStore, len(w.clients))
+		for key, cw := range w.clients {
+			if store, ok := cw.store.(*NumberStore); ok {
+				stores[key] = store
+			}
+		}
+		w.mu.Unlock()
+		saved.Clients = make(map[string]savedWindow, len(stores))
+		for key, store := range stores {
+			saved.Clients[key] = store.saved()
+		}
+	} else if store, ok := w.shared.(*NumberStore); ok {
+		shared := store.saved()
+		saved.Shared = &shared
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		s.logger.Warn("Failed to save windows", "error", err)
+		return
+	}
+	if bytes.Equal(data, s.last) {
+		return
+	}
+	saved.SavedAt = time.Now()
+	stamped, err := json.Marshal(saved)
+	if err == nil {
+		err = writeFileAtomic(s.path, stamped)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to save windows", "path", s.path, "error", err)
+		return
+	}
+	s.last = data
+}
+
+// run saves the windows every interval until ctx is cancelled. Run saves
+// them once more after the last request.
+func (s *windowState) run(ctx context.Context, w *windows) {
+	if s.path == "" {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.save(w)
+		}
+	}
+}
+
+// writeFileAtomic replaces the file at path with data, through a temporary
+// file in the same directory so a crash never leaves it half written.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
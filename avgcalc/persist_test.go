@@ -0,0 +1,137 @@
+package avgcalc
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newWindowState returns the state saved at path, logging to log.
+func newWindowState(path string, log *bytes.Buffer) *windowState {
+	return &windowState{path: path, maxAge: time.Minute, logger: slog.New(slog.NewTextHandler(log, nil))}
+}
+
+// newTestWindows returns empty windows of size, per client if perClient.
+func newTestWindows(size int, duration time.Duration, perClient bool) *windows {
+	cfg := DefaultConfig()
+	cfg.WindowSize, cfg.WindowSizeMax = size, size
+	cfg.WindowDuration = duration
+	cfg.PerClientWindows = perClient
+	return newWindows(cfg, windowOptions{})
+}
+
+func currentState(t *testing.T, ws WindowStore) []float64 {
+	t.Helper()
+	if ws == nil {
+		return nil
+	}
+	state, err := ws.GetCurrentState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestWindowStateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	var log bytes.Buffer
+
+	t.Run("shared", func(t *testing.T) {
+		path := filepath.Join(dir, "shared.json")
+		w := newTestWindows(3, 0, false)
+		mustAdd(t, w.shared, 1, 2, 3, 4)
+		newWindowState(path, &log).save(w)
+
+		restored := newTestWindows(3, 0, false)
+		newWindowState(path, &log).load(restored)
+		assertNumbers(t, "restored window", currentState(t, restored.shared), 2, 3, 4)
+
+		// Restored into a smaller window, the newest numbers are kept.
+		smaller := newTestWindows(2, 0, false)
+		newWindowState(path, &log).load(smaller)
+		assertNumbers(t, "restored smaller window", currentState(t, smaller.shared), 3, 4)
+	})
+
+	t.Run("per client", func(t *testing.T) {
+		path := filepath.Join(dir, "clients.json")
+		w := newTestWindows(5, 0, true)
+		mustAdd(t, w.get("a"), 1)
+		mustAdd(t, w.get("b"), 5, 6)
+		newWindowState(path, &log).save(w)
+
+		restored := newTestWindows(5, 0, true)
+		newWindowState(path, &log).load(restored)
+		assertNumbers(t, "client a", currentState(t, restored.existing("a")), 1)
+		assertNumbers(t, "client b", currentState(t, restored.existing("b")), 5, 6)
+		if restored.peek("c") != nil {
+			t.Error("window restored for a client never seen")
+		}
+	})
+
+	t.Run("timed", func(t *testing.T) {
+		path := filepath.Join(dir, "timed.json")
+		w := newTestWindows(10, 100*time.Millisecond, false)
+		mustAdd(t, w.shared, 1)
+		time.Sleep(60 * time.Millisecond)
+		mustAdd(t, w.shared, 2)
+		newWindowState(path, &log).save(w)
+
+		// Numbers keep their arrival times, so the older one expires
+		// first after the restore.
+		time.Sleep(60 * time.Millisecond)
+		restored := newTestWindows(10, 100*time.Millisecond, false)
+		newWindowState(path, &log).load(restored)
+		assertNumbers(t, "restored timed window", currentState(t, restored.shared), 2)
+	})
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("temporary file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestWindowStateIgnoresBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale, _ := json.Marshal(savedWindows{
+		SavedAt: time.Now().Add(-time.Hour),
+		Shared:  &savedWindow{Numbers: []float64{1, 2}},
+	})
+	tests := []struct {
+		name    string
+		content []byte
+		warning string
+	}{
+		{"corrupt", []byte(`{"savedAt": "yesterday", "shared": {`), "corrupt"},
+		{"wrong shape", []byte(`{"shared": {"numbers": "1,2"}}`), "corrupt"},
+		{"stale", stale, "stale"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name+".json")
+		if err := os.WriteFile(path, tt.content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		var log bytes.Buffer
+		w := newTestWindows(3, 0, false)
+		newWindowState(path, &log).load(w)
+		if state := currentState(t, w.shared); len(state) != 0 {
+			t.Errorf("%s: restored %v", tt.name, state)
+		}
+		if !strings.Contains(log.String(), "level=WARN") || !strings.Contains(log.String(), tt.warning) {
+			t.Errorf("%s: logged %q, want a warning", tt.name, log.String())
+		}
+	}
+
+	// A missing file is the first start, not worth a warning.
+	var log bytes.Buffer
+	newWindowState(filepath.Join(dir, "missing.json"), &log).load(newTestWindows(3, 0, false))
+	if log.Len() != 0 {
+		t.Errorf("missing file logged %q", log.String())
+	}
+}
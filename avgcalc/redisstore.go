@@ -0,0 +1,603 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RedisKeyPrefix = "avgcalc:window"
+	RedisTimeout   = time.Second
+
+	// RedisPollInterval is how often a Redis window with subscribers is
+	// checked for changes made by other replicas.
+	RedisPollInterval = time.Second
+)
+
+// The window's keys, in the order the scripts take them: the numbers,
+// oldest first, and the set of them; their arrival times, in microseconds,
+// for a timed window; the numbers expired since the last batch; when
+// numbers were last added; the change count; the moving averages and when
+// each was last asked for; and the history, newest first.
+var redisKeySuffixes = []string{"nums", "members", "arrived", "expired", "updated", "seq", "ema", "emaused", "history"}
+
+// redisExpire is the scripts' common part: expire(cutoff) drops the
+// numbers that arrived at or before cutoff, "" for a window by count, and
+// returns them.
+const redisExpire = `
+local function expire(cutoff)
+  local gone = {}
+  if cutoff == '' then return gone end
+  cutoff = tonumber(cutoff)
+  while true do
+    local t = redis.call('LINDEX', KEYS[3], 0)
+    if not t or tonumber(t) > cutoff then break end
+    local n = redis.call('LPOP', KEYS[1])
+    redis.call('LPOP', KEYS[3])
+    redis.call('SREM', KEYS[2], n)
+    table.insert(gone, n)
+  end
+  return gone
+end
+`
+
+// redisRead expires old numbers, keeping them for the next batch's
+// history if ARGV[2] is 1, and returns the numbers, when they were last
+// added and the change count.
+var redisRead = redis.NewScript(redisExpire + `
+for _, n in ipairs(expire(ARGV[1])) do
+  if ARGV[2] == '1' then redis.call('RPUSH', KEYS[4], n) end
+end
+return {redis.call('LRANGE', KEYS[1], 0, -1), redis.call('GET', KEYS[5]) or '', tonumber(redis.call('GET', KEYS[6]) or '0')}
+`)
+
+// redisAdd is NumberStore.add. ARGV is the size, the expiry cutoff, now in
+// microseconds, now as the time last updated, the TTL in milliseconds (0
+// for none), the alpha to report the moving average for ("" for none),
+// how many alphas are tracked at most, the outlier filter's threshold (""
+// for none) and minimum samples, how many history entries to keep (0 for
+// none), the batch's types as a JSON array, and then the numbers.
+//
+// Moving averages are kept as strings exact to the bit, "" for one not
+// seeded yet. The outlier filter and the history entry are computed as
+// NumberStore computes them, in the same order, so they come out the same
+// to the bit.
+var redisAdd = redis.NewScript(redisExpire + `
+local function fold(v, a, n)
+  if v == '' then return n end
+  return string.format('%.17g', a * tonumber(n) + (1 - a) * tonumber(v))
+end
+
+local function mean(numbers)
+  if #numbers == 0 then return 0 end
+  local sum = 0
+  for _, n in ipairs(numbers) do sum = sum + tonumber(n) end
+  return sum / #numbers
+end
+
+local function judge(window)
+  if ARGV[8] == '' or #window < tonumber(ARGV[9]) then
+    return function() return false end
+  end
+  local threshold, avg, squares = tonumber(ARGV[8]), mean(window), 0
+  for _, n in ipairs(window) do
+    local d = tonumber(n) - avg
+    squares = squares + d * d
+  end
+  local sd = 0
+  if #window > 0 then sd = math.sqrt(squares / #window) end
+  return function(n)
+    n = tonumber(n)
+    if sd == 0 then return n ~= avg end
+    return math.abs(n - avg) / sd > threshold
+  end
+end
+
+local function list(numbers)
+  return '[' .. table.concat(numbers, ',') .. ']'
+end
+
+local size, timed = tonumber(ARGV[1]), ARGV[2] ~= ''
+local evicted = redis.call('LRANGE', KEYS[4], 0, -1)
+redis.call('DEL', KEYS[4])
+for _, n in ipairs(expire(ARGV[2])) do table.insert(evicted, n) end
+local prev = redis.call('LRANGE', KEYS[1], 0, -1)
+
+local emas = {}
+local flat = redis.call('HGETALL', KEYS[7])
+for i = 1, #flat, 2 do emas[flat[i]] = flat[i + 1] end
+local alpha = ARGV[6]
+if alpha ~= '' then
+  if emas[alpha] == nil then
+    if redis.call('HLEN', KEYS[7]) >= tonumber(ARGV[7]) then
+      local used = redis.call('HGETALL', KEYS[8])
+      local oldest, oldestAt
+      for i = 1, #used, 2 do
+        local at = tonumber(used[i + 1])
+        if not oldestAt or at < oldestAt then oldest, oldestAt = used[i], at end
+      end
+      if oldest then
+        redis.call('HDEL', KEYS[7], oldest)
+        redis.call('HDEL', KEYS[8], oldest)
+        emas[oldest] = nil
+      end
+    end
+    local v, a = '', tonumber(alpha)
+    for _, n in ipairs(prev) do v = fold(v, a, n) end
+    emas[alpha] = v
+  end
+  redis.call('HSET', KEYS[8], alpha, ARGV[3])
+end
+
+local outlier = judge(prev)
+local accepted, rejected, skipped = {}, {}, 0
+for i = 12, #ARGV do
+  local n = ARGV[i]
+  if redis.call('SISMEMBER', KEYS[2], n) == 1 then
+    skipped = skipped + 1
+  elseif outlier(n) then
+    table.insert(rejected, n)
+  else
+    while redis.call('LLEN', KEYS[1]) >= size do
+      local old = redis.call('LPOP', KEYS[1])
+      if timed then redis.call('LPOP', KEYS[3]) end
+      redis.call('SREM', KEYS[2], old)
+      table.insert(evicted, old)
+    end
+    redis.call('RPUSH', KEYS[1], n)
+    if timed then redis.call('RPUSH', KEYS[3], ARGV[3]) end
+    redis.call('SADD', KEYS[2], n)
+    table.insert(accepted, n)
+    for a, v in pairs(emas) do emas[a] = fold(v, tonumber(a), n) end
+  end
+end
+
+for a, v in pairs(emas) do redis.call('HSET', KEYS[7], a, v) end
+redis.call('SET', KEYS[5], ARGV[4])
+local seq = tonumber(redis.call('GET', KEYS[6]) or '0')
+if #accepted > 0 then seq = redis.call('INCR', KEYS[6]) end
+local current = redis.call('LRANGE', KEYS[1], 0, -1)
+if tonumber(ARGV[10]) > 0 then
+  redis.call('LPUSH', KEYS[9], '{"seq":' .. seq .. ',"time":"' .. ARGV[4] .. '","types":' .. ARGV[11] ..
+    ',"added":' .. list(accepted) .. ',"evicted":' .. list(evicted) .. ',"duplicatesDiscarded":' .. skipped ..
+    ',"avg":' .. string.format('%.17g', mean(current)) .. ',"count":' .. #current .. '}')
+  redis.call('LTRIM', KEYS[9], 0, tonumber(ARGV[10]) - 1)
+end
+if ARGV[5] ~= '0' then
+  for _, key in ipairs(KEYS) do redis.call('PEXPIRE', key, ARGV[5]) end
+end
+local ema = false
+if alpha ~= '' then ema = emas[alpha] end
+return {prev, accepted, skipped, evicted, current, seq, ema, rejected}
+`)
+
+// redisReset empties the window, expiring old numbers first, and returns
+// what it held and when numbers were last added.
+var redisReset = redis.NewScript(redisExpire + `
+expire(ARGV[1])
+local current = redis.call('LRANGE', KEYS[1], 0, -1)
+local updated = redis.call('GET', KEYS[5]) or ''
+for i, key in ipairs(KEYS) do
+  if i ~= 6 then redis.call('DEL', key) end
+end
+if #current > 0 then redis.call('INCR', KEYS[6]) end
+return {current, updated}
+`)
+
+// redisResize drops the oldest numbers past ARGV[1], and their arrival
+// times if ARGV[2] is 1.
+var redisResize = redis.NewScript(`
+local trimmed = 0
+while redis.call('LLEN', KEYS[1]) > tonumber(ARGV[1]) do
+  local n = redis.call('LPOP', KEYS[1])
+  if ARGV[2] == '1' then redis.call('LPOP', KEYS[3]) end
+  redis.call('SREM', KEYS[2], n)
+  trimmed = trimmed + 1
+end
+if trimmed > 0 then redis.call('INCR', KEYS[6]) end
+return trimmed
+`)
+
+// RedisStore is a sliding window kept in Redis, so that replicas sharing
+// the server share the window. Each change is one script, run atomically,
+// doing what NumberStore does in memory, history and outlier filter
+// included; numbers are stored in their
+// shortest exact form, so they are equal in Redis exactly when they are
+// in memory.
+//
+// The size is the replica's own, as configured or resized, and applies
+// when it adds numbers. Subscribers are told of the replica's own changes
+// at once and of the others' within RedisPollInterval.
+type RedisStore struct {
+	client   *redis.Client
+	keys     []string
+	duration time.Duration
+
+	// ttl, if set, is how long the window is kept in Redis after numbers
+	// were last added to it.
+	ttl time.Duration
+
+	mu          sync.Mutex
+	size        int
+	subscribers map[*Subscription]struct{}
+	delivered   uint64
+	polling     bool
+
+	opts windowOptions
+}
+
+// NewRedisStore returns the window under the keys starting with prefix,
+// holding up to size numbers or, with a duration, those seen in the last
+// duration up to size of them, with the default settings.
+func NewRedisStore(client *redis.Client, prefix string, duration time.Duration, size int) *RedisStore {
+	return newRedisStore(client, prefix, duration, size, windowOptionsFor(DefaultConfig(), slog.Default()))
+}
+
+func newRedisStore(client *redis.Client, prefix string, duration time.Duration, size int, opts windowOptions) *RedisStore {
+	rs := &RedisStore{client: client, duration: duration, size: size, opts: opts}
+	for _, suffix := range redisKeySuffixes {
+		rs.keys = append(rs.keys, prefix+":"+suffix)
+	}
+	return rs
+}
+
+// redisWindow is a window as read from Redis.
+type redisWindow struct {
+	numbers []float64
+	updated time.Time
+	seq     uint64
+}
+
+func (rs *RedisStore) cutoff(now time.Time) string {
+	if rs.duration <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(now.Add(-rs.duration).UnixMicro(), 10)
+}
+
+func (rs *RedisStore) eval(script *redis.Script, args ...string) (interface{}, error) {
+	argv := make([]interface{}, len(args))
+	for i, arg := range args {
+		argv[i] = arg
+	}
+	reply, err := script.Run(context.Background(), rs.client, rs.keys, argv...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("window store: %w", err)
+	}
+	return reply, nil
+}
+
+func (rs *RedisStore) read() (redisWindow, error) {
+	keep := "0"
+	if rs.opts.historySize > 0 {
+		keep = "1"
+	}
+	reply, err := rs.eval(redisRead, rs.cutoff(time.Now()), keep)
+	if err != nil {
+		return redisWindow{}, err
+	}
+	items, _ := reply.([]interface{})
+	if len(items) != 3 {
+		return redisWindow{}, fmt.Errorf("window store: unexpected reply %v", reply)
+	}
+	var w redisWindow
+	if w.numbers, err = parseRedisNumbers(items[0]); err != nil {
+		return redisWindow{}, err
+	}
+	if w.updated, err = parseRedisTime(items[1]); err != nil {
+		return redisWindow{}, err
+	}
+	seq, _ := items[2].(int64)
+	w.seq = uint64(seq)
+	return w, nil
+}
+
+func (rs *RedisStore) AddNumbers(newNumbers []float64, types ...string) (Added, error) {
+	return rs.add(types, newNumbers, "")
+}
+
+func (rs *RedisStore) AddNumbersEMA(newNumbers []float64, alpha float64, types ...string) (Added, error) {
+	return rs.add(types, newNumbers, strconv.FormatFloat(alpha, 'g', -1, 64))
+}
+
+// add adds newNumbers, judging outliers and recording the history in the
+// same script, so no other replica's change can come in between.
+func (rs *RedisStore) add(types []string, newNumbers []float64, alpha string) (Added, error) {
+	threshold := ""
+	if rs.opts.outliers.enabled {
+		threshold = strconv.FormatFloat(rs.opts.outliers.threshold, 'g', -1, 64)
+	}
+	if types == nil {
+		types = []string{}
+	}
+	typesJSON, err := json.Marshal(types)
+	if err != nil {
+		return Added{}, err
+	}
+
+	now := time.Now()
+	size := rs.Size()
+	args := []string{
+		strconv.Itoa(size),
+		rs.cutoff(now),
+		strconv.FormatInt(now.UnixMicro(), 10),
+		now.Format(time.RFC3339Nano),
+		strconv.FormatInt(rs.ttl.Milliseconds(), 10),
+		alpha,
+		strconv.Itoa(maxEMAs),
+		threshold,
+		strconv.Itoa(rs.opts.outliers.minSamples),
+		strconv.Itoa(rs.opts.historySize),
+		string(typesJSON),
+	}
+	for _, num := range newNumbers {
+		args = append(args, formatRedisNumber(num))
+	}
+	reply, err := rs.eval(redisAdd, args...)
+	if err != nil {
+		return Added{}, err
+	}
+	items, _ := reply.([]interface{})
+	if len(items) != 8 {
+		return Added{}, fmt.Errorf("window store: unexpected reply %v", reply)
+	}
+
+	added := Added{Size: size}
+	if added.Prev, err = parseRedisNumbers(items[0]); err != nil {
+		return Added{}, err
+	}
+	if added.Accepted, err = parseRedisNumbers(items[1]); err != nil {
+		return Added{}, err
+	}
+	if rejected, _ := items[7].([]interface{}); len(rejected) > 0 {
+		if added.Rejected, err = parseRedisNumbers(rejected); err != nil {
+			return Added{}, err
+		}
+	}
+	if added.Current, err = parseRedisNumbers(items[4]); err != nil {
+		return Added{}, err
+	}
+	skipped, _ := items[2].(int64)
+	added.Skipped = int(skipped)
+	added.Stats = computeStats(added.Current, rs.opts.trimFraction)
+	if ema, ok := items[6].(string); ok && ema != "" {
+		if added.EMA, err = strconv.ParseFloat(ema, 64); err != nil {
+			return Added{}, fmt.Errorf("window store: malformed moving average %q", ema)
+		}
+	}
+	seq, _ := items[5].(int64)
+
+	if len(added.Accepted) > 0 {
+		rs.notify(redisWindow{numbers: added.Current, updated: now, seq: uint64(seq)})
+	}
+	return added, nil
+}
+
+func (rs *RedisStore) GetAverage() (float64, error) {
+	w, err := rs.read()
+	return average(w.numbers), err
+}
+
+func (rs *RedisStore) GetCurrentState() ([]float64, error) {
+	w, err := rs.read()
+	return w.numbers, err
+}
+
+func (rs *RedisStore) Len() (int, error) {
+	w, err := rs.read()
+	return len(w.numbers), err
+}
+
+func (rs *RedisStore) Stats() ([]float64, Stats, error) {
+	w, err := rs.read()
+	if err != nil {
+		return nil, Stats{}, err
+	}
+	return w.numbers, computeStats(w.numbers, rs.opts.trimFraction), nil
+}
+
+func (rs *RedisStore) Snapshot() (WindowEvent, error) {
+	w, err := rs.read()
+	if err != nil {
+		return WindowEvent{}, err
+	}
+	return w.event(), nil
+}
+
+func (rs *RedisStore) Occupancy() (count, size int, err error) {
+	w, err := rs.read()
+	return len(w.numbers), rs.Size(), err
+}
+
+func (rs *RedisStore) LastUpdated() (time.Time, error) {
+	w, err := rs.read()
+	return w.updated, err
+}
+
+func (rs *RedisStore) History(limit int, numberType string) ([]WindowSnapshot, error) {
+	items, err := rs.client.LRange(context.Background(), rs.keys[8], 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("window store: %w", err)
+	}
+	h := windowHistory{size: len(items)}
+	for i := len(items) - 1; i >= 0; i-- {
+		var s WindowSnapshot
+		if err := json.Unmarshal([]byte(items[i]), &s); err != nil {
+			return nil, fmt.Errorf("window store: malformed history: %w", err)
+		}
+		h.record(s)
+	}
+	return h.newest(limit, numberType), nil
+}
+
+func (rs *RedisStore) Size() int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.size
+}
+
+func (rs *RedisStore) Resize(size int) error {
+	rs.mu.Lock()
+	rs.size = size
+	rs.mu.Unlock()
+	timed := "0"
+	if rs.duration > 0 {
+		timed = "1"
+	}
+	_, err := rs.eval(redisResize, strconv.Itoa(size), timed)
+	return err
+}
+
+func (rs *RedisStore) Reset() (WindowResponse, error) {
+	reply, err := rs.eval(redisReset, rs.cutoff(time.Now()))
+	if err != nil {
+		return WindowResponse{}, err
+	}
+	items, _ := reply.([]interface{})
+	if len(items) != 2 {
+		return WindowResponse{}, fmt.Errorf("window store: unexpected reply %v", reply)
+	}
+	numbers, err := parseRedisNumbers(items[0])
+	if err != nil {
+		return WindowResponse{}, err
+	}
+	updated, err := parseRedisTime(items[1])
+	if err != nil {
+		return WindowResponse{}, err
+	}
+	discarded := WindowResponse{WindowCurrState: numbers, Average: Fixed2(average(numbers)), Count: len(numbers)}
+	if !updated.IsZero() {
+		discarded.LastUpdated = &updated
+	}
+	return discarded, nil
+}
+
+func (rs *RedisStore) Subscribe() (*Subscription, error) {
+	w, err := rs.read()
+	if err != nil {
+		return nil, err
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	s := &Subscription{events: make(chan WindowEvent, 1)}
+	if rs.subscribers == nil {
+		rs.subscribers = make(map[*Subscription]struct{})
+	}
+	rs.subscribers[s] = struct{}{}
+	s.deliver(w.event())
+	rs.delivered = max(rs.delivered, w.seq)
+	if !rs.polling {
+		rs.polling = true
+		go rs.poll()
+	}
+	return s, nil
+}
+
+func (rs *RedisStore) Unsubscribe(s *Subscription) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.subscribers, s)
+}
+
+func (rs *RedisStore) Subscribers() int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return len(rs.subscribers)
+}
+
+// notify tells the subscribers of w, unless they have seen it already.
+func (rs *RedisStore) notify(w redisWindow) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if w.seq <= rs.delivered {
+		return
+	}
+	rs.delivered = w.seq
+	if len(rs.subscribers) == 0 {
+		return
+	}
+	e := w.event()
+	for s := range rs.subscribers {
+		s.deliver(e)
+	}
+}
+
+// poll watches for other replicas' changes while the window has
+// subscribers. A failed read is retried on the next tick.
+func (rs *RedisStore) poll() {
+	ticker := time.NewTicker(RedisPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		rs.mu.Lock()
+		if len(rs.subscribers) == 0 {
+			rs.polling = false
+			rs.mu.Unlock()
+			return
+		}
+		rs.mu.Unlock()
+
+		w, err := rs.read()
+		if err != nil {
+			rs.opts.logger.Warn("Failed to read the window from Redis", "error", err)
+			continue
+		}
+		rs.notify(w)
+	}
+}
+
+func (w redisWindow) event() WindowEvent {
+	e := WindowEvent{
+		Seq:             w.seq,
+		WindowCurrState: w.numbers,
+		Average:         Fixed2(average(w.numbers)),
+		Count:           len(w.numbers),
+	}
+	if !w.updated.IsZero() {
+		updated := w.updated
+		e.LastUpdated = &updated
+	}
+	return e
+}
+
+// formatRedisNumber writes num in its shortest exact form, so equal
+// numbers are equal strings. -0 is 0, as it is to a map.
+func formatRedisNumber(num float64) string {
+	if num == 0 {
+		num = 0
+	}
+	return strconv.FormatFloat(num, 'g', -1, 64)
+}
+
+func parseRedisNumbers(reply interface{}) ([]float64, error) {
+	items, _ := reply.([]interface{})
+	numbers := make([]float64, len(items))
+	for i, item := range items {
+		raw, _ := item.(string)
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(n) {
+			return nil, fmt.Errorf("window store: malformed number %q", raw)
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+func parseRedisTime(reply interface{}) (time.Time, error) {
+	raw, _ := reply.(string)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("window store: malformed time %q", raw)
+	}
+	return t, nil
+}
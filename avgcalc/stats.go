@@ -0,0 +1,80 @@
+package avgcalc
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Stats summarizes a window. All of them are 0 for an empty window.
+type Stats struct {
+	Average float64
+	Median  float64
+	Min     float64
+	Max     float64
+
+	// StdDev is the population standard deviation.
+	StdDev float64
+
+	// TrimmedMean is the mean without the share of smallest and largest
+	// numbers computeStats is given, rounded down to whole numbers: the
+	// plain mean when the window is too small to drop any.
+	TrimmedMean float64
+}
+
+// TrimFraction is the share of numbers dropped from each end for the
+// trimmed mean, unless TRIM_FRACTION says otherwise.
+const TrimFraction = 0.1
+
+// computeStats summarizes numbers, trimming trimFraction of them from each
+// end for the trimmed mean.
+func computeStats(numbers []float64, trimFraction float64) Stats {
+	if len(numbers) == 0 {
+		return Stats{}
+	}
+
+	sorted := make([]float64, len(numbers))
+	copy(sorted, numbers)
+	sort.Float64s(sorted)
+
+	s := Stats{
+		Average: average(numbers),
+		Min:     sorted[0],
+		Max:     sorted[len(sorted)-1],
+	}
+
+	trim := int(float64(len(sorted)) * trimFraction)
+	s.TrimmedMean = average(sorted[trim : len(sorted)-trim])
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		s.Median = sorted[mid]
+	} else {
+		s.Median = (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	var squares float64
+	for _, n := range numbers {
+		d := n - s.Average
+		squares += d * d
+	}
+	s.StdDev = math.Sqrt(squares / float64(len(numbers)))
+	return s
+}
+
+// Fixed2 is a number written to JSON with exactly two decimals, as the
+// spec asks for averages: 23.4 is sent as 23.40. Halves round up.
+type Fixed2 float64
+
+func (f Fixed2) MarshalJSON() ([]byte, error) {
+	return strconv.AppendFloat(nil, f.Rounded(), 'f', 2, 64), nil
+}
+
+// Rounded is f to two decimals, for encodings that don't fix them.
+func (f Fixed2) Rounded() float64 {
+	rounded := math.Floor(float64(f)*100+0.5) / 100
+	if rounded == 0 {
+		rounded = 0 // no -0.00
+	}
+	return rounded
+}
@@ -0,0 +1,70 @@
+package avgcalc
+
+import "testing"
+
+func TestFixed2(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{1.0 / 3, "0.33"},
+		{2.0 / 3, "0.67"},
+		{-1.0 / 3, "-0.33"},
+		{-2.0 / 3, "-0.67"},
+		{23.4, "23.40"},
+		{0, "0.00"},
+		{-0.001, "0.00"},
+		{0.125, "0.13"},
+		{-0.125, "-0.12"},
+		{-1e9 / 3, "-333333333.33"},
+	}
+	for _, tt := range tests {
+		got, err := Fixed2(tt.in).MarshalJSON()
+		if err != nil || string(got) != tt.want {
+			t.Errorf("Fixed2(%v) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestAverageToTwoDecimals(t *testing.T) {
+	tests := []struct {
+		numbers []float64
+		want    string
+	}{
+		{[]float64{1, 2, 2}, "1.67"},
+		{[]float64{0, 0, 1}, "0.33"},
+		{[]float64{-1, -1, -2}, "-1.33"},
+		{[]float64{-1, 0, 0}, "-0.33"},
+		{[]float64{-5, 5}, "0.00"},
+		{[]float64{-3}, "-3.00"},
+	}
+	for _, tt := range tests {
+		got, _ := Fixed2(computeStats(tt.numbers, 0).Average).MarshalJSON()
+		if string(got) != tt.want {
+			t.Errorf("average of %v = %s, want %s", tt.numbers, got, tt.want)
+		}
+	}
+}
+
+func TestTrimmedMean(t *testing.T) {
+	tests := []struct {
+		name     string
+		numbers  []float64
+		fraction float64
+		want     float64
+	}{
+		{"drops one from each end", []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 0.1, 5.5},
+		{"ignores an outlier", []float64{1000, 2, 3, 4, 5, 6, 7, 8, 9, 1}, 0.1, 5.5},
+		{"too few to drop any", []float64{1, 2, 3, 4, 5, 6, 7, 8, 100}, 0.1, 136.0 / 9},
+		{"rounds the share down", []float64{-40, -30, 1, 2, 3, 4, 70, 80}, 0.2, 50.0 / 6},
+		{"quarter from each end", []float64{-40, -30, 1, 2, 3, 4, 70, 80}, 0.25, 2.5},
+		{"nothing trimmed", []float64{-40, -30, 1, 2, 3, 4, 70, 80}, 0, 90.0 / 8},
+		{"almost half", []float64{1, 2, 3, 50, 99}, 0.49, 3},
+		{"empty", nil, 0.1, 0},
+	}
+	for _, tt := range tests {
+		if got := computeStats(tt.numbers, tt.fraction).TrimmedMean; got != tt.want {
+			t.Errorf("%s: trimmed mean of %v at %v = %v, want %v", tt.name, tt.numbers, tt.fraction, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package avgcalc
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// WindowStore is a sliding window. NumberStore keeps one in memory and
+// RedisStore one in Redis, for replicas to share. Only RedisStore's
+// methods fail, when Redis does.
+type WindowStore interface {
+	AddNumbers(newNumbers []float64, types ...string) (Added, error)
+	AddNumbersEMA(newNumbers []float64, alpha float64, types ...string) (Added, error)
+	GetAverage() (float64, error)
+	GetCurrentState() ([]float64, error)
+	Len() (int, error)
+	Stats() ([]float64, Stats, error)
+	Snapshot() (WindowEvent, error)
+	Occupancy() (count, size int, err error)
+	LastUpdated() (time.Time, error)
+	History(limit int, numberType string) ([]WindowSnapshot, error)
+
+	// Size is how many numbers the window can hold, and Resize changes it.
+	Size() int
+	Resize(size int) error
+
+	// Reset empties the window and its history, returning what it held.
+	Reset() (WindowResponse, error)
+
+	// Subscribe starts delivering the window's changes until Unsubscribe,
+	// and Subscribers counts the subscriptions.
+	Subscribe() (*Subscription, error)
+	Unsubscribe(s *Subscription)
+	Subscribers() int
+}
+
+// NumberStore is the sliding window: the last size unique numbers seen or,
+// for a timed window, the unique numbers seen in the last duration, up to
+// size of them.
+//
+// The numbers live in a ring buffer that grows on demand up to size, with
+// a running sum and a set of its members, so adding a number, evicting one
+// and averaging are O(1) however large the window is. Numbers are equal
+// only if their values are exactly equal.
+type NumberStore struct {
+	size     int
+	duration time.Duration
+
+	// buf holds count numbers starting at head, oldest first. arrived is
+	// parallel to buf and only kept for a timed window.
+	buf     []float64
+	arrived []time.Time
+	head    int
+	count   int
+	sum     float64
+	members map[float64]struct{}
+
+	updated time.Time
+	emas    map[float64]*ema
+	mu      sync.RWMutex
+
+	// seq counts the changes to the window, for its subscribers to spot
+	// the ones they missed.
+	seq         uint64
+	subscribers map[*Subscription]struct{}
+
+	// history records each batch added; expired holds the numbers that
+	// expired since the last one, for the next.
+	history windowHistory
+	expired []float64
+
+	opts windowOptions
+}
+
+// windowOptions are the settings a calculator's windows share.
+type windowOptions struct {
+	historySize  int
+	outliers     outlierFilter
+	trimFraction float64
+	logger       *slog.Logger
+}
+
+// windowOptionsFor reads the windows' settings from cfg.
+func windowOptionsFor(cfg Config, logger *slog.Logger) windowOptions {
+	return windowOptions{
+		historySize: cfg.HistorySize,
+		outliers: outlierFilter{
+			enabled:    cfg.OutlierFilter,
+			threshold:  cfg.OutlierZScore,
+			minSamples: cfg.OutlierMinSamples,
+		},
+		trimFraction: cfg.TrimFraction,
+		logger:       logger,
+	}
+}
+
+// NewNumberStore returns an empty window holding up to size numbers, with
+// the default settings.
+func NewNumberStore(size int) *NumberStore {
+	return newNumberStore(size, 0, windowOptionsFor(DefaultConfig(), slog.Default()))
+}
+
+// NewTimedNumberStore returns an empty window holding the numbers seen in
+// the last duration, capped at size, with the default settings.
+func NewTimedNumberStore(duration time.Duration, size int) *NumberStore {
+	return newNumberStore(size, duration, windowOptionsFor(DefaultConfig(), slog.Default()))
+}
+
+func newNumberStore(size int, duration time.Duration, opts windowOptions) *NumberStore {
+	return &NumberStore{
+		size:     size,
+		duration: duration,
+		members:  make(map[float64]struct{}),
+		history:  windowHistory{size: opts.historySize},
+		opts:     opts,
+	}
+}
+
+// Added is what adding numbers did to a window, all read under the lock
+// the numbers were added under.
+type Added struct {
+	Prev    []float64
+	Current []float64
+	Stats   Stats
+	Size    int
+
+	// Accepted are the numbers that went into the window; the rest were
+	// already in it, or earlier in the same batch.
+	Accepted []float64
+	Skipped  int
+
+	// Rejected are the numbers the outlier filter kept out.
+	Rejected []float64
+
+	// EMA is the moving average asked for with AddNumbersEMA.
+	EMA float64
+}
+
+// AddNumbers adds newNumbers to the window. types are the number types
+// they came from, for the window's history.
+func (ns *NumberStore) AddNumbers(newNumbers []float64, types ...string) (Added, error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	now := time.Now()
+	ns.expire(now)
+	return ns.add(types, newNumbers, now), nil
+}
+
+// add appends the numbers not already in the window, feeding each to the
+// moving averages, and records the batch in the history with the numbers
+// evicted for it and those expired since the last. The caller must hold
+// the write lock and have expired old numbers.
+func (ns *NumberStore) add(types []string, newNumbers []float64, now time.Time) Added {
+	added := Added{Prev: ns.state(), Accepted: []float64{}}
+	evicted := ns.expired
+	ns.expired = nil
+
+	outlier := ns.opts.outliers.judge(added.Prev)
+	for _, num := range newNumbers {
+		if _, ok := ns.members[num]; ok {
+			added.Skipped++
+			continue
+		}
+		if outlier(num) {
+			added.Rejected = append(added.Rejected, num)
+			continue
+		}
+		if ns.count == ns.size {
+			evicted = append(evicted, ns.evictOldest())
+		}
+		ns.push(num, now)
+		added.Accepted = append(added.Accepted, num)
+		for alpha, e := range ns.emas {
+			e.add(alpha, num)
+		}
+	}
+
+	ns.updated = now
+	added.Current = ns.state()
+	added.Stats = computeStats(added.Current, ns.opts.trimFraction)
+	added.Size = ns.size
+	if len(added.Accepted) > 0 {
+		ns.changed()
+	}
+	if types == nil {
+		types = []string{}
+	}
+	if evicted == nil {
+		evicted = []float64{}
+	}
+	ns.history.record(WindowSnapshot{
+		Seq:                 ns.seq,
+		Time:                now,
+		Types:               types,
+		Added:               added.Accepted,
+		Evicted:             evicted,
+		DuplicatesDiscarded: added.Skipped,
+		Average:             Fixed2(added.Stats.Average),
+		Count:               ns.count,
+	})
+	return added
+}
+
+// at returns the i-th oldest number's position in buf.
+func (ns *NumberStore) at(i int) int {
+	return (ns.head + i) % len(ns.buf)
+}
+
+func (ns *NumberStore) push(num float64, now time.Time) {
+	if ns.count == len(ns.buf) {
+		ns.grow(min(max(2*len(ns.buf), 8), ns.size))
+	}
+	i := ns.at(ns.count)
+	ns.buf[i] = num
+	if ns.duration > 0 {
+		ns.arrived[i] = now
+	}
+	ns.count++
+	ns.sum += num
+	ns.members[num] = struct{}{}
+}
+
+// evictOldest drops the oldest number and returns it.
+func (ns *NumberStore) evictOldest() float64 {
+	num := ns.buf[ns.head]
+	ns.head = (ns.head + 1) % len(ns.buf)
+	ns.count--
+	ns.sum -= num
+	if ns.count == 0 {
+		// Don't carry rounding error over into the next numbers.
+		ns.sum = 0
+	}
+	delete(ns.members, num)
+	return num
+}
+
+// grow moves the numbers into a buffer of capacity n, oldest first. It is
+// also how Resize shrinks the window.
+func (ns *NumberStore) grow(n int) {
+	for ns.count > n {
+		ns.evictOldest()
+	}
+	buf := make([]float64, n)
+	var arrived []time.Time
+	if ns.duration > 0 {
+		arrived = make([]time.Time, n)
+	}
+	for i := 0; i < ns.count; i++ {
+		buf[i] = ns.buf[ns.at(i)]
+		if arrived != nil {
+			arrived[i] = ns.arrived[ns.at(i)]
+		}
+	}
+	ns.buf, ns.arrived, ns.head = buf, arrived, 0
+}
+
+// isExpired reports whether the oldest number has expired at now. The
+// caller must hold the lock.
+func (ns *NumberStore) isExpired(now time.Time) bool {
+	return ns.duration > 0 && ns.count > 0 && !ns.arrived[ns.head].After(now.Add(-ns.duration))
+}
+
+// expire evicts the numbers that expired by now. Numbers arrive in time
+// order, so they are always the oldest. The caller must hold the write
+// lock.
+func (ns *NumberStore) expire(now time.Time) {
+	for ns.isExpired(now) {
+		num := ns.evictOldest()
+		if ns.history.size > 0 {
+			ns.expired = append(ns.expired, num)
+		}
+	}
+}
+
+// read runs fn with the window up to date. It holds the read lock unless
+// numbers are due to expire, in which case it takes the write lock to
+// evict them first.
+func (ns *NumberStore) read(fn func()) {
+	now := time.Now()
+	ns.mu.RLock()
+	if !ns.isExpired(now) {
+		defer ns.mu.RUnlock()
+		fn()
+		return
+	}
+	ns.mu.RUnlock()
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.expire(now)
+	fn()
+}
+
+// state copies the numbers, oldest first. The caller must hold the lock.
+func (ns *NumberStore) state() []float64 {
+	current := make([]float64, ns.count)
+	for i := range current {
+		current[i] = ns.buf[ns.at(i)]
+	}
+	return current
+}
+
+// average is the mean from the running sum. The caller must hold the lock.
+func (ns *NumberStore) average() float64 {
+	if ns.count == 0 {
+		return 0
+	}
+	return ns.sum / float64(ns.count)
+}
+
+// Resize changes the window size. Shrinking drops the oldest numbers;
+// growing keeps everything and fills up from later fetches.
+func (ns *NumberStore) Resize(size int) error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.size = size
+	if len(ns.buf) > size {
+		count := ns.count
+		ns.grow(size)
+		if ns.count < count {
+			ns.changed()
+		}
+	}
+	return nil
+}
+
+// Reset empties the window and its history, returning what it held.
+func (ns *NumberStore) Reset() (WindowResponse, error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.expire(time.Now())
+	discarded := WindowResponse{
+		WindowCurrState: ns.state(),
+		Average:         Fixed2(ns.average()),
+		Count:           ns.count,
+	}
+	if !ns.updated.IsZero() {
+		updated := ns.updated
+		discarded.LastUpdated = &updated
+	}
+	ns.buf, ns.arrived = nil, nil
+	ns.head, ns.count, ns.sum = 0, 0, 0
+	ns.members = make(map[float64]struct{})
+	ns.updated = time.Time{}
+	ns.emas = nil
+	ns.history.clear()
+	ns.expired = nil
+	if discarded.Count > 0 {
+		ns.changed()
+	}
+	return discarded, nil
+}
+
+// LastUpdated is when numbers were last added, zero if never.
+func (ns *NumberStore) LastUpdated() (time.Time, error) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	return ns.updated, nil
+}
+
+// Occupancy returns how many numbers the window holds and can hold.
+func (ns *NumberStore) Occupancy() (count, size int, err error) {
+	ns.read(func() { count, size = ns.count, ns.size })
+	return count, size, nil
+}
+
+// Size is how many numbers the window can hold.
+func (ns *NumberStore) Size() int {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	return ns.size
+}
+
+// Duration is how long a number stays in a timed window, 0 for a window
+// by count.
+func (ns *NumberStore) Duration() time.Duration {
+	return ns.duration
+}
+
+func (ns *NumberStore) GetAverage() (float64, error) {
+	var avg float64
+	ns.read(func() { avg = ns.average() })
+	return avg, nil
+}
+
+func average(numbers []float64) float64 {
+	if len(numbers) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, num := range numbers {
+		sum += num
+	}
+	return sum / float64(len(numbers))
+}
+
+// Len is how many numbers the window holds.
+func (ns *NumberStore) Len() (int, error) {
+	var n int
+	ns.read(func() { n = ns.count })
+	return n, nil
+}
+
+// Stats returns the current state with its statistics, both read under one
+// lock so they agree.
+func (ns *NumberStore) Stats() ([]float64, Stats, error) {
+	var current []float64
+	var stats Stats
+	ns.read(func() {
+		current = ns.state()
+		stats = computeStats(current, ns.opts.trimFraction)
+	})
+	return current, stats, nil
+}
+
+// Snapshot is the window now, as its subscribers would see it.
+func (ns *NumberStore) Snapshot() (WindowEvent, error) {
+	var e WindowEvent
+	ns.read(func() { e = ns.event() })
+	return e, nil
+}
+
+func (ns *NumberStore) GetCurrentState() ([]float64, error) {
+	var current []float64
+	ns.read(func() { current = ns.state() })
+	return current, nil
+}
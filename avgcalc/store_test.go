@@ -0,0 +1,78 @@
+package avgcalc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNumberStoreSizes(t *testing.T) {
+	for _, size := range []int{1, 10, 1000} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			ns := newNumberStore(size, 0, windowOptions{})
+
+			// Batches of 7 wrap the ring around at every size, and more
+			// than once.
+			var want []float64
+			for next := 0; next < 3*size+7; {
+				batch := make([]float64, 0, 7)
+				for len(batch) < cap(batch) {
+					batch = append(batch, float64(next))
+					next++
+				}
+				mustAdd(t, ns, batch...)
+				want = append(want, batch...)
+				if len(want) > size {
+					want = want[len(want)-size:]
+				}
+
+				state, err := ns.GetCurrentState()
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertNumbers(t, fmt.Sprintf("window after %d numbers", next), state, want...)
+				if avg, _ := ns.GetAverage(); avg != average(want) {
+					t.Fatalf("average after %d numbers = %v, want %v", next, avg, average(want))
+				}
+			}
+
+			// A number evicted long ago is no longer a duplicate.
+			added := mustAdd(t, ns, 0)
+			if len(added.Accepted) != 1 {
+				t.Errorf("Accepted = %v, want [0]", added.Accepted)
+			}
+			if n, _ := ns.Len(); n != size {
+				t.Errorf("Len() = %d, want %d", n, size)
+			}
+		})
+	}
+}
+
+// BenchmarkNumberStore times a full window at each size. Moving the ring
+// on by a number and averaging shouldn't slow down as the window grows;
+// AddNumbers also copies the window twice and sorts it for its response,
+// so it does.
+func BenchmarkNumberStore(b *testing.B) {
+	for _, size := range []int{10, 1000, 100000} {
+		ns := newNumberStore(size, 0, windowOptions{})
+		for i := 0; i < size; i++ {
+			ns.AddNumbers([]float64{float64(i)})
+		}
+		b.Run(fmt.Sprintf("ring/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ns.evictOldest()
+				ns.push(float64(size+i), time.Time{})
+			}
+		})
+		b.Run(fmt.Sprintf("GetAverage/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ns.GetAverage()
+			}
+		})
+		b.Run(fmt.Sprintf("AddNumbers/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ns.AddNumbers([]float64{float64(2*size + i)})
+			}
+		})
+	}
+}
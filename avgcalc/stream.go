@@ -0,0 +1,113 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+const (
+	// StreamMaxSubscribers caps the streams and WebSockets open at once.
+	// Each holds one of the MAX_IN_FLIGHT slots for as long as it is open.
+	StreamMaxSubscribers = 64
+
+	// StreamKeepAlive is how often an idle stream gets a comment, so
+	// proxies don't time it out.
+	StreamKeepAlive = 15 * time.Second
+
+	// StreamWriteTimeout is how long a write to a stream may take before
+	// the client is given up on.
+	StreamWriteTimeout = 10 * time.Second
+)
+
+// getStream serves GET /numbers/:numberid/stream, pushing the window as a
+// server-sent event each time it changes. There is one window for all
+// number types, so every change is pushed whichever type made it; the ID
+// only has to be valid. Like GET /window it calls nothing upstream and
+// needs a token only with per-client windows.
+//
+// Streams end when ctx is cancelled, as the service shuts down, rather
+// than hold up the drain.
+func (s *calculator) getStream(ctx context.Context) gin.HandlerFunc {
+	windows, numberTypes := s.windows, s.numberTypes
+	return func(c *gin.Context) {
+		types, valid := numberTypes.parse(c.Param("numberid"))
+		if !valid {
+			abortWithError(c, http.StatusBadRequest, numberTypes.invalid())
+			return
+		}
+		middleware.LogField(c, "numberType", strings.Join(types, ","))
+		store := windows.shared
+		if windows.perClient {
+			authToken, authErr := authorization(c)
+			if authErr != nil {
+				abortWithError(c, authErr.status, ErrorBody{Code: authErr.code, Message: authErr.message})
+				return
+			}
+			store = windows.get(authToken)
+		}
+
+		if s.streams.Add(1) > s.maxStreams {
+			s.streams.Add(-1)
+			c.Header("Retry-After", "5")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Too many streams open, try again later"})
+			return
+		}
+		defer s.streams.Add(-1)
+
+		sub, err := store.Subscribe()
+		if err != nil {
+			s.abortStoreUnavailable(c, err)
+			return
+		}
+		defer store.Unsubscribe(sub)
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		// The server's write timeout would end the stream, so each write
+		// gets a deadline of its own instead. Writers that can't take one,
+		// such as the request recorder's, keep the server's.
+		rc := http.NewResponseController(c.Writer)
+		send := func(frame string) bool {
+			_ = rc.SetWriteDeadline(time.Now().Add(StreamWriteTimeout))
+			if _, err := c.Writer.WriteString(frame); err != nil {
+				return false
+			}
+			c.Writer.Flush()
+			return true
+		}
+
+		keepAlive := time.NewTicker(StreamKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-ctx.Done():
+				return
+			case <-keepAlive.C:
+				if !send(": keep-alive\n\n") {
+					return
+				}
+			case e := <-sub.Events():
+				data, err := json.Marshal(e)
+				if err != nil {
+					return
+				}
+				if !send(fmt.Sprintf("id: %d\nevent: window\ndata: %s\n\n", e.Seq, data)) {
+					return
+				}
+			}
+		}
+	}
+}
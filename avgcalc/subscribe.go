@@ -0,0 +1,96 @@
+package avgcalc
+
+import "time"
+
+// WindowEvent is a change to a window as its subscribers see it. Seq
+// counts the window's changes, so a gap means some were coalesced.
+type WindowEvent struct {
+	Seq             uint64     `json:"seq"`
+	WindowCurrState []float64  `json:"windowCurrState"`
+	Average         Fixed2     `json:"avg"`
+	Count           int        `json:"count"`
+	LastUpdated     *time.Time `json:"lastUpdated"`
+}
+
+// Subscription receives a window's changes. It holds only the latest
+// event not yet received, replacing it when another change comes first, so
+// a slow subscriber misses intermediate states but never holds up the
+// requests adding numbers.
+type Subscription struct {
+	events chan WindowEvent
+}
+
+// Events delivers the window's state as of each change, starting with its
+// state when subscribing.
+func (s *Subscription) Events() <-chan WindowEvent { return s.events }
+
+// deliver hands e to the subscriber, dropping the event it hasn't
+// received yet. Only the window's writer calls it, under the write lock.
+func (s *Subscription) deliver(e WindowEvent) {
+	select {
+	case s.events <- e:
+		return
+	default:
+	}
+	select {
+	case <-s.events:
+	default:
+	}
+	s.events <- e
+}
+
+// Subscribe starts delivering the window's changes until Unsubscribe.
+func (ns *NumberStore) Subscribe() (*Subscription, error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.expire(time.Now())
+	s := &Subscription{events: make(chan WindowEvent, 1)}
+	if ns.subscribers == nil {
+		ns.subscribers = make(map[*Subscription]struct{})
+	}
+	ns.subscribers[s] = struct{}{}
+	s.deliver(ns.event())
+	return s, nil
+}
+
+func (ns *NumberStore) Unsubscribe(s *Subscription) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	delete(ns.subscribers, s)
+}
+
+// Subscribers is how many subscriptions the window has.
+func (ns *NumberStore) Subscribers() int {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	return len(ns.subscribers)
+}
+
+// changed counts a change to the window and tells the subscribers. The
+// state is only copied when somebody is listening. The caller must hold
+// the write lock.
+func (ns *NumberStore) changed() {
+	ns.seq++
+	if len(ns.subscribers) == 0 {
+		return
+	}
+	e := ns.event()
+	for s := range ns.subscribers {
+		s.deliver(e)
+	}
+}
+
+// event describes the window now. The caller must hold the lock.
+func (ns *NumberStore) event() WindowEvent {
+	e := WindowEvent{
+		Seq:             ns.seq,
+		WindowCurrState: ns.state(),
+		Average:         Fixed2(ns.average()),
+		Count:           ns.count,
+	}
+	if !ns.updated.IsZero() {
+		updated := ns.updated
+		e.LastUpdated = &updated
+	}
+	return e
+}
@@ -0,0 +1,68 @@
+package avgcalc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// APITimeoutMin and APITimeoutMax bound the timeout a request may ask
+	// for in place of NUMBER_SERVICE_TIMEOUT.
+	APITimeoutMin = 50 * time.Millisecond
+	APITimeoutMax = time.Second
+
+	// TimeoutHeader asks for a timeout, as the timeoutMs parameter does.
+	TimeoutHeader = "X-Timeout-Ms"
+)
+
+// timeoutOverride is the timeout a request asked for and what came of it,
+// for the response.
+type timeoutOverride struct {
+	timeout time.Duration
+	clamped bool
+	warning string
+}
+
+// requestTimeout reads the timeout for each call to the number service
+// that c asks for with timeoutMs, or failing that X-Timeout-Ms, clamped to
+// the bounds. It reports false when c asks for none. A value that isn't a
+// whole number of milliseconds leaves the default in place, with a
+// warning.
+func (s *calculator) requestTimeout(c *gin.Context) (timeoutOverride, bool) {
+	raw, source := c.Query("timeoutMs"), "timeoutMs"
+	if raw == "" {
+		raw, source = c.GetHeader(TimeoutHeader), TimeoutHeader
+	}
+	if raw == "" {
+		return timeoutOverride{}, false
+	}
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || ms <= 0 {
+		return timeoutOverride{
+			timeout: s.timeout(),
+			warning: fmt.Sprintf("%s must be a positive whole number of milliseconds, got %q; the default was used", source, raw),
+		}, true
+	}
+	requested := time.Duration(min(ms, int64(time.Hour/time.Millisecond))) * time.Millisecond
+	timeout := min(max(requested, s.cfg.TimeoutMin), s.cfg.TimeoutMax)
+	return timeoutOverride{timeout: timeout, clamped: timeout != requested}, true
+}
+
+type timeoutKey struct{}
+
+// withServiceTimeout has the calls to the number service made for ctx time
+// out after d instead of NUMBER_SERVICE_TIMEOUT.
+func withServiceTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, d)
+}
+
+// callTimeout is the timeout of each call to the number service for ctx,
+// 0 if getNumbers didn't set one.
+func callTimeout(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(timeoutKey{}).(time.Duration)
+	return d
+}
@@ -0,0 +1,146 @@
+package avgcalc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+const (
+	// TokenCacheTTL is how long a token the validator accepted is taken
+	// as valid without asking again.
+	TokenCacheTTL = time.Minute
+
+	// TokenCacheSize is how many accepted tokens are remembered.
+	TokenCacheSize = 1024
+
+	TokenValidateTimeout = 300 * time.Millisecond
+)
+
+// tokenValidator checks bearer tokens against the validator before they
+// are forwarded to the number service, with TOKEN_VALIDATION set. Only
+// accepted tokens are cached, by hash, so a refused token is asked about
+// again on its next request.
+type tokenValidator struct {
+	enabled bool
+	url     string
+	ttl     time.Duration
+	size    int
+	client  *http.Client
+
+	mu    sync.Mutex
+	valid map[string]time.Time
+}
+
+// cached reports whether the token hashed to key was accepted within the
+// ttl.
+func (v *tokenValidator) cached(key string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	expires, ok := v.valid[key]
+	if ok && time.Now().After(expires) {
+		delete(v.valid, key)
+		return false
+	}
+	return ok
+}
+
+// remember caches the token hashed to key as accepted. A full cache drops
+// its expired entries, and failing that the one expiring soonest.
+func (v *tokenValidator) remember(key string) {
+	now := time.Now()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.valid == nil {
+		v.valid = make(map[string]time.Time)
+	}
+	if _, ok := v.valid[key]; !ok && len(v.valid) >= v.size {
+		var soonest string
+		for k, expires := range v.valid {
+			if now.After(expires) {
+				delete(v.valid, k)
+			} else if soonest == "" || expires.Before(v.valid[soonest]) {
+				soonest = k
+			}
+		}
+		if len(v.valid) >= v.size {
+			delete(v.valid, soonest)
+		}
+	}
+	v.valid[key] = now.Add(v.ttl)
+}
+
+// check asks the validator about token. A 2xx accepts it and a 401 or 403
+// refuses it; anything else, the validator failing included, is returned
+// as an error and decides nothing.
+func (v *tokenValidator) check(ctx context.Context, token string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, TokenValidateTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if id := middleware.RequestIDFrom(ctx); id != "" {
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return false, nil
+	default:
+		return false, fmt.Errorf("token validator answered %s", resp.Status)
+	}
+}
+
+// validateToken refuses requests whose bearer token the validator refuses
+// with 401, before the handler calls the number service with it. Requests
+// without a well-formed token are left for the handler to refuse, and so
+// are tokens the validator couldn't judge: the number service has the
+// last word. In managed mode the client's token never reaches the number
+// service, so there is nothing to validate.
+func (s *calculator) validateToken(c *gin.Context) {
+	token, reqErr := authorization(c)
+	if reqErr != nil || !s.tokenRefused(c.Request.Context(), token) {
+		c.Next()
+		return
+	}
+	abortWithError(c, http.StatusUnauthorized, ErrorBody{
+		Code:    CodeInvalidToken,
+		Message: "The bearer token was refused",
+	})
+}
+
+// tokenRefused is whether the validator refuses token, false when it
+// can't tell or validation is off.
+func (s *calculator) tokenRefused(ctx context.Context, token string) bool {
+	if !s.tokens.enabled || s.credentials.enabled {
+		return false
+	}
+	key := clientKey(token)
+	if s.tokens.cached(key) {
+		return false
+	}
+	valid, err := s.tokens.check(ctx, token)
+	if err != nil {
+		s.logRequest(ctx, slog.LevelWarn, "Failed to validate token", slog.Any("error", err))
+		return false
+	}
+	if valid {
+		s.tokens.remember(key)
+	}
+	return !valid
+}
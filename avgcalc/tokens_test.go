@@ -0,0 +1,158 @@
+package avgcalc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeValidator accepts the token "good", fails on "flaky" and refuses
+// any other, counting the tokens it is asked about.
+type fakeValidator struct {
+	mu    sync.Mutex
+	asked map[string]int
+}
+
+func (v *fakeValidator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	v.mu.Lock()
+	v.asked[token]++
+	v.mu.Unlock()
+	switch token {
+	case "good":
+	case "flaky":
+		w.WriteHeader(http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+}
+
+func (v *fakeValidator) times(token string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.asked[token]
+}
+
+// getWithToken gets path from the API at url with token and returns the
+// status and error envelope.
+func getWithToken(t *testing.T, url, path, token string) (int, ErrorEnvelope) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url+path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var envelope ErrorEnvelope
+	json.NewDecoder(resp.Body).Decode(&envelope)
+	return resp.StatusCode, envelope
+}
+
+func TestValidateToken(t *testing.T) {
+	v := &fakeValidator{asked: map[string]int{}}
+	validator := httptest.NewServer(v)
+	defer validator.Close()
+
+	var fetched atomic.Int32
+	s := newTestCalculator(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched.Add(1)
+		writeNumbers(w, 2, 4)
+	}), func(cfg *Config) {
+		cfg.TokenValidation = true
+		cfg.TokenValidatorURL = validator.URL
+		cfg.TokenCacheTTL = 100 * time.Millisecond
+		cfg.CacheTTL = 0
+	})
+	url := serveAPI(t, s)
+
+	// A refused token is answered at once, without a fetch.
+	status, envelope := getWithToken(t, url, "/numbers/e", "bad")
+	if status != http.StatusUnauthorized || envelope.Error.Code != CodeInvalidToken {
+		t.Errorf("refused token: status %d, code %q, want 401 %s", status, envelope.Error.Code, CodeInvalidToken)
+	}
+	if n := fetched.Load(); n != 0 {
+		t.Errorf("refused token fetched numbers %d times", n)
+	}
+	// Refusals aren't cached.
+	getWithToken(t, url, "/numbers/e", "bad")
+	if n := v.times("bad"); n != 2 {
+		t.Errorf("validator asked about the refused token %d times, want 2", n)
+	}
+
+	// An accepted token is asked about once within the TTL.
+	for i := 0; i < 3; i++ {
+		if status, _ := getWithToken(t, url, "/numbers/e", "good"); status != http.StatusOK {
+			t.Fatalf("accepted token: status %d", status)
+		}
+	}
+	if n := v.times("good"); n != 1 {
+		t.Errorf("validator asked about the accepted token %d times within the TTL, want 1", n)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if status, _ := getWithToken(t, url, "/numbers/e", "good"); status != http.StatusOK {
+		t.Fatalf("accepted token after the TTL: status %d", status)
+	}
+	if n := v.times("good"); n != 2 {
+		t.Errorf("validator asked about the accepted token %d times after the TTL, want 2", n)
+	}
+
+	// A validator that can't tell leaves the token to the number service.
+	if status, _ := getWithToken(t, url, "/numbers/e", "flaky"); status != http.StatusOK {
+		t.Errorf("token the validator failed on: status %d, want 200", status)
+	}
+}
+
+func TestTokenCacheBounded(t *testing.T) {
+	v := &tokenValidator{ttl: time.Minute, size: 3}
+	for i := 0; i < 10; i++ {
+		v.remember(fmt.Sprint(i))
+	}
+	if len(v.valid) != 3 {
+		t.Errorf("%d tokens cached, want the size of 3", len(v.valid))
+	}
+	if !v.cached("9") {
+		t.Error("latest token not cached")
+	}
+	if v.cached("0") {
+		t.Error("first token still cached past the size")
+	}
+
+	// Expired tokens are dropped before live ones.
+	v = &tokenValidator{ttl: time.Millisecond, size: 2}
+	v.remember("old")
+	time.Sleep(5 * time.Millisecond)
+	v.ttl = time.Minute
+	v.remember("a")
+	v.remember("b")
+	if !v.cached("a") || !v.cached("b") || len(v.valid) != 2 {
+		t.Errorf("cache %v, want a and b in place of the expired token", v.valid)
+	}
+}
+
+func TestTokenCacheConcurrent(t *testing.T) {
+	v := &tokenValidator{ttl: time.Minute, size: 16}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprint(g, "-", i%40)
+				if !v.cached(key) {
+					v.remember(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if len(v.valid) > 16 {
+		t.Errorf("%d tokens cached, want at most 16", len(v.valid))
+	}
+}
@@ -0,0 +1,24 @@
+package avgcalc
+
+// weightedAverage averages numbers, oldest first, with weights given newest
+// first, nil for linear weights: of N numbers, the newest weighs N and the
+// oldest 1. Numbers past the end of weights weigh nothing. It is 0 when
+// nothing has any weight.
+func weightedAverage(numbers []float64, weights []float64) float64 {
+	var sum, total float64
+	for i := range numbers {
+		w := float64(i + 1)
+		if weights != nil {
+			w = 0
+			if newest := len(numbers) - 1 - i; newest < len(weights) {
+				w = weights[newest]
+			}
+		}
+		sum += w * numbers[i]
+		total += w
+	}
+	if total == 0 {
+		return 0
+	}
+	return sum / total
+}
@@ -0,0 +1,266 @@
+package avgcalc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/statuspage"
+	"github.com/redis/go-redis/v9"
+)
+
+// windows hands out the sliding window a request works on: one shared by
+// everyone, or with per-client windows one per bearer token. Client windows
+// are keyed by a hash of the token so tokens aren't kept in memory, and are
+// dropped after ttl without use.
+//
+// The windows are kept in memory, or with a Redis client in Redis under
+// keys starting with prefix, where client windows expire after ttl too.
+type windows struct {
+	perClient bool
+	ttl       time.Duration
+	duration  time.Duration
+	redis     *redis.Client
+	prefix    string
+	opts      windowOptions
+
+	mu      sync.Mutex
+	size    int
+	shared  WindowStore
+	clients map[string]*clientWindow
+}
+
+type clientWindow struct {
+	store    WindowStore
+	lastUsed time.Time
+}
+
+func newWindows(cfg Config, opts windowOptions) *windows {
+	w := &windows{
+		perClient: cfg.PerClientWindows,
+		ttl:       cfg.ClientWindowTTL,
+		duration:  cfg.WindowDuration,
+		redis:     cfg.Redis,
+		prefix:    cfg.RedisPrefix,
+		opts:      opts,
+		size:      cfg.WindowSize,
+		clients:   make(map[string]*clientWindow),
+	}
+	if w.duration > 0 {
+		w.size = cfg.WindowSizeMax
+	}
+	w.shared = w.newStore("")
+	return w
+}
+
+// newStore returns the window of the configured kind for the client with
+// key, "" for the shared window. In memory it is always a new, empty one.
+func (w *windows) newStore(key string) WindowStore {
+	if w.redis != nil {
+		if key == "" {
+			return newRedisStore(w.redis, w.prefix+":shared", w.duration, w.size, w.opts)
+		}
+		rs := newRedisStore(w.redis, w.prefix+":client:"+key, w.duration, w.size, w.opts)
+		rs.ttl = w.ttl
+		return rs
+	}
+	return newNumberStore(w.size, w.duration, w.opts)
+}
+
+// get returns the window for the client holding token.
+func (w *windows) get(token string) WindowStore {
+	if !w.perClient {
+		return w.shared
+	}
+
+	key := clientKey(token)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cw, ok := w.clients[key]
+	if !ok {
+		cw = &clientWindow{store: w.newStore(key)}
+		w.clients[key] = cw
+	}
+	cw.lastUsed = time.Now()
+	return cw.store
+}
+
+// peek returns the client's window without creating it or counting as
+// use, nil if it has none. A window in Redis may have been created by
+// another replica, so there is always one.
+func (w *windows) peek(token string) WindowStore {
+	key := clientKey(token)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cw, ok := w.clients[key]; ok {
+		return cw.store
+	}
+	if w.redis != nil {
+		return w.newStore(key)
+	}
+	return nil
+}
+
+// existing returns the client's window, or an empty one if it has none,
+// without creating it.
+func (w *windows) existing(token string) WindowStore {
+	if store := w.peek(token); store != nil {
+		return store
+	}
+	return w.newStore("")
+}
+
+func clientKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Size is how many numbers each window can hold.
+func (w *windows) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+// Resize changes the size of every window, present and future. It goes
+// on past a window it fails to resize, returning the first error.
+func (w *windows) Resize(size int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.size = size
+	err := w.shared.Resize(size)
+	for _, cw := range w.clients {
+		if cwErr := cw.store.Resize(size); err == nil {
+			err = cwErr
+		}
+	}
+	return err
+}
+
+// evictIdle drops the client windows unused since before cutoff, returning
+// how many went. Windows being streamed are in use however long ago their
+// last request was.
+func (w *windows) evictIdle(cutoff time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	evicted := 0
+	for key, cw := range w.clients {
+		if cw.lastUsed.Before(cutoff) && cw.store.Subscribers() == 0 {
+			delete(w.clients, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// runEviction evicts idle client windows until ctx is cancelled.
+func (w *windows) runEviction(ctx context.Context) {
+	if !w.perClient {
+		return
+	}
+	ticker := time.NewTicker(max(w.ttl/4, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.evictIdle(now.Add(-w.ttl))
+		}
+	}
+}
+
+// capacity describes what a window holds.
+func (w *windows) capacity() string {
+	if w.duration > 0 {
+		return fmt.Sprintf("those seen in the last %v", w.duration)
+	}
+	return fmt.Sprintf("the last %d", w.Size())
+}
+
+// fill is how full the shared window is, or the client windows are on
+// average, from 0 to 1. Windows that can't be read are left out.
+func (w *windows) fill() float64 {
+	stores := []WindowStore{w.shared}
+	if w.perClient {
+		w.mu.Lock()
+		stores = make([]WindowStore, 0, len(w.clients))
+		for _, client := range w.clients {
+			stores = append(stores, client.store)
+		}
+		w.mu.Unlock()
+	}
+	var sum float64
+	read := 0
+	for _, store := range stores {
+		count, size, err := store.Occupancy()
+		if err != nil {
+			continue
+		}
+		sum += float64(count) / float64(size)
+		read++
+	}
+	if read == 0 {
+		return 0
+	}
+	return sum / float64(read)
+}
+
+// vitals is the calculator's section of the status page. The last fetch
+// shows the status the client got rather than the error, which may quote
+// the upstream's response. Client windows are only counted.
+func (s *calculator) vitals() []statuspage.Section {
+	w := s.windows
+	s.lastFetch.mu.Lock()
+	at, numberType, status := s.lastFetch.at, s.lastFetch.numberType, s.lastFetch.status
+	s.lastFetch.mu.Unlock()
+
+	var rows []statuspage.Row
+	if w.perClient {
+		w.mu.Lock()
+		clients := len(w.clients)
+		w.mu.Unlock()
+		rows = append(rows,
+			statuspage.Row{Label: "Windows", Value: fmt.Sprintf("%d clients, %s each", clients, w.capacity())},
+			statuspage.Row{Label: "Idle eviction", Value: w.ttl.String()})
+	} else if current, err := w.shared.GetCurrentState(); err != nil {
+		rows = append(rows, statuspage.Row{Label: "Window", Value: "unavailable: " + err.Error()})
+	} else {
+		rows = append(rows,
+			statuspage.Row{Label: "Window", Value: fmt.Sprintf("%d numbers, %s", len(current), w.capacity())},
+			statuspage.Row{Label: "Average", Value: strconv.FormatFloat(average(current), 'f', 2, 64)})
+	}
+	if w.redis != nil {
+		rows = append(rows, statuspage.Row{Label: "Store", Value: "Redis"})
+	}
+	rows = append(rows, statuspage.Row{Label: "Last fetch", Value: statuspage.Ago(at)})
+	if !at.IsZero() {
+		rows = append(rows,
+			statuspage.Row{Label: "Last number type", Value: numberType},
+			statuspage.Row{Label: "Last result", Value: fmt.Sprintf("%d %s", status, http.StatusText(status))})
+	}
+	rows = append(rows, statuspage.Row{Label: "Number service timeout", Value: s.timeout().String()})
+	if s.cache.ttl > 0 {
+		rows = append(rows, statuspage.Row{Label: "Number cache", Value: fmt.Sprintf("%d hits, %d misses, %s TTL",
+			s.cache.hits.Load(), s.cache.misses.Load(), s.cache.ttl)})
+	}
+	sections := []statuspage.Section{{Title: "Average calculator", Rows: rows}}
+
+	var circuits []statuspage.Row
+	for _, c := range s.breaker.states() {
+		value := c.State
+		if c.Failures > 0 {
+			value = fmt.Sprintf("%s, %d consecutive failures", c.State, c.Failures)
+		}
+		circuits = append(circuits, statuspage.Row{Label: c.NumberType, Value: value})
+	}
+	if len(circuits) > 0 {
+		sections = append(sections, statuspage.Section{Title: "Number service circuits", Rows: circuits})
+	}
+	return sections
+}
@@ -0,0 +1,221 @@
+package avgcalc
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestStore returns an empty window of size under test, with opts.
+type newTestStore func(t *testing.T, size int, duration time.Duration, opts windowOptions) WindowStore
+
+func TestNumberStoreConformance(t *testing.T) {
+	testWindowStore(t, func(t *testing.T, size int, duration time.Duration, opts windowOptions) WindowStore {
+		return newNumberStore(size, duration, opts)
+	})
+}
+
+func TestRedisStoreConformance(t *testing.T) {
+	testWindowStore(t, func(t *testing.T, size int, duration time.Duration, opts windowOptions) WindowStore {
+		client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		t.Cleanup(func() { client.Close() })
+		return newRedisStore(client, "test", duration, size, opts)
+	})
+}
+
+// testWindowStore checks that a WindowStore behaves as NumberStore does:
+// whatever keeps the window, the responses are the same.
+func testWindowStore(t *testing.T, newStore newTestStore) {
+	opts := windowOptions{historySize: 10, trimFraction: TrimFraction, logger: slog.Default()}
+
+	t.Run("evicts the oldest and skips duplicates", func(t *testing.T) {
+		ws := newStore(t, 3, 0, opts)
+		mustAdd(t, ws, 1, 2)
+		added := mustAdd(t, ws, 2, 3, 4, 4)
+		assertNumbers(t, "Prev", added.Prev, 1, 2)
+		assertNumbers(t, "Accepted", added.Accepted, 3, 4)
+		assertNumbers(t, "Current", added.Current, 2, 3, 4)
+		if added.Skipped != 2 {
+			t.Errorf("Skipped = %d, want 2", added.Skipped)
+		}
+		if added.Stats.Average != 3 || added.Size != 3 {
+			t.Errorf("Average, Size = %v, %d, want 3, 3", added.Stats.Average, added.Size)
+		}
+		count, size, err := ws.Occupancy()
+		if err != nil || count != 3 || size != 3 {
+			t.Errorf("Occupancy() = %d, %d, %v, want 3, 3, nil", count, size, err)
+		}
+	})
+
+	t.Run("keeps negatives and fractions exact", func(t *testing.T) {
+		ws := newStore(t, 10, 0, opts)
+		mustAdd(t, ws, -1.5, 0.1, 0.2, -0)
+		numbers, stats, err := ws.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertNumbers(t, "Stats", numbers, -1.5, 0.1, 0.2, 0)
+		if want := (-1.5 + 0.1 + 0.2 + 0) / 4; stats.Average != want {
+			t.Errorf("Average = %v, want %v", stats.Average, want)
+		}
+	})
+
+	t.Run("moving average", func(t *testing.T) {
+		ws := newStore(t, 10, 0, opts)
+		mustAdd(t, ws, 2)
+		added, err := ws.AddNumbersEMA([]float64{4, 8}, 0.5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Seeded with 2, then 0.5*4 + 0.5*2 = 3 and 0.5*8 + 0.5*3 = 5.5.
+		if added.EMA != 5.5 {
+			t.Errorf("EMA = %v, want 5.5", added.EMA)
+		}
+	})
+
+	t.Run("records the history", func(t *testing.T) {
+		ws := newStore(t, 2, 0, opts)
+		mustAdd(t, ws, 1, 2)
+		if _, err := ws.AddNumbers([]float64{2, 3}, "even", "primes"); err != nil {
+			t.Fatal(err)
+		}
+		history, err := ws.History(10, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 2 {
+			t.Fatalf("len(History) = %d, want 2", len(history))
+		}
+		got := history[0]
+		got.Time = time.Time{}
+		want := WindowSnapshot{
+			Seq:                 2,
+			Types:               []string{"even", "primes"},
+			Added:               []float64{3},
+			Evicted:             []float64{1},
+			DuplicatesDiscarded: 1,
+			Average:             2.5,
+			Count:               2,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("History()[0] = %+v, want %+v", got, want)
+		}
+		if primes, _ := ws.History(10, "primes"); len(primes) != 1 {
+			t.Errorf("History(primes) has %d snapshots, want 1", len(primes))
+		}
+	})
+
+	t.Run("filters outliers", func(t *testing.T) {
+		filtered := opts
+		filtered.outliers = outlierFilter{enabled: true, threshold: OutlierZScore, minSamples: OutlierMinSamples}
+		ws := newStore(t, 10, 0, filtered)
+		mustAdd(t, ws, 10, 11, 12, 13, 14)
+		added := mustAdd(t, ws, 15, 1000, 12)
+		assertNumbers(t, "Accepted", added.Accepted, 15)
+		assertNumbers(t, "Rejected", added.Rejected, 1000)
+		if added.Skipped != 1 {
+			t.Errorf("Skipped = %d, want 1", added.Skipped)
+		}
+	})
+
+	t.Run("resizes", func(t *testing.T) {
+		ws := newStore(t, 5, 0, opts)
+		mustAdd(t, ws, 1, 2, 3, 4, 5)
+		if err := ws.Resize(2); err != nil {
+			t.Fatal(err)
+		}
+		state, err := ws.GetCurrentState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertNumbers(t, "GetCurrentState", state, 4, 5)
+		if ws.Size() != 2 {
+			t.Errorf("Size() = %d, want 2", ws.Size())
+		}
+	})
+
+	t.Run("resets", func(t *testing.T) {
+		ws := newStore(t, 5, 0, opts)
+		mustAdd(t, ws, 1, 2, 3)
+		discarded, err := ws.Reset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertNumbers(t, "discarded", discarded.WindowCurrState, 1, 2, 3)
+		if discarded.LastUpdated == nil {
+			t.Error("discarded.LastUpdated is nil")
+		}
+		if n, err := ws.Len(); err != nil || n != 0 {
+			t.Errorf("Len() = %d, %v after Reset, want 0", n, err)
+		}
+		if history, _ := ws.History(10, ""); len(history) != 0 {
+			t.Errorf("History() has %d snapshots after Reset, want none", len(history))
+		}
+		snapshot, err := ws.Snapshot()
+		if err != nil || snapshot.Seq != 2 {
+			t.Errorf("Snapshot().Seq = %d, %v after Reset, want 2", snapshot.Seq, err)
+		}
+	})
+
+	t.Run("expires timed numbers", func(t *testing.T) {
+		ws := newStore(t, 10, 50*time.Millisecond, opts)
+		mustAdd(t, ws, 1, 2)
+		time.Sleep(80 * time.Millisecond)
+		added := mustAdd(t, ws, 3)
+		assertNumbers(t, "Prev", added.Prev)
+		assertNumbers(t, "Current", added.Current, 3)
+		history, err := ws.History(1, "")
+		if err != nil || len(history) != 1 {
+			t.Fatalf("History() = %v, %v", history, err)
+		}
+		assertNumbers(t, "Evicted", history[0].Evicted, 1, 2)
+	})
+
+	t.Run("notifies subscribers", func(t *testing.T) {
+		ws := newStore(t, 5, 0, opts)
+		sub, err := ws.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ws.Unsubscribe(sub)
+		if e := <-sub.Events(); e.Count != 0 {
+			t.Errorf("first event has %d numbers, want 0", e.Count)
+		}
+		mustAdd(t, ws, 4, 6)
+		select {
+		case e := <-sub.Events():
+			assertNumbers(t, "event", e.WindowCurrState, 4, 6)
+			if e.Seq != 1 || e.Average != 5 {
+				t.Errorf("event Seq, Average = %d, %v, want 1, 5", e.Seq, e.Average)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no event after adding numbers")
+		}
+		if ws.Subscribers() != 1 {
+			t.Errorf("Subscribers() = %d, want 1", ws.Subscribers())
+		}
+	})
+}
+
+func mustAdd(t *testing.T, ws WindowStore, numbers ...float64) Added {
+	t.Helper()
+	added, err := ws.AddNumbers(numbers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return added
+}
+
+func assertNumbers(t *testing.T, name string, got []float64, want ...float64) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
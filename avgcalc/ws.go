@@ -0,0 +1,253 @@
+package avgcalc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WSPingInterval is how often a WebSocket is pinged. A client that
+	// sends nothing, not even a pong, for WSPingInterval+WSPongTimeout is
+	// disconnected.
+	WSPingInterval = 30 * time.Second
+	WSPongTimeout  = 30 * time.Second
+
+	// WSWriteTimeout is how long a message to a WebSocket may take before
+	// the client is given up on.
+	WSWriteTimeout = 10 * time.Second
+
+	// WSQueueSize is how many messages may wait for a WebSocket client. A
+	// client that falls that far behind is disconnected rather than have
+	// messages pile up for it.
+	WSQueueSize = 16
+
+	// WSMaxMessageBytes caps what a client may send in one message; a
+	// larger one closes the connection.
+	WSMaxMessageBytes = 64 << 10
+)
+
+// wsUpgrader takes over GET /ws connections. Any origin may connect, as
+// with the rest of the API: windows are picked by the bearer token, which
+// a browser doesn't send on its own.
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// wsRequest is what clients send: {"subscribe": ["p", "f"]}. An empty list
+// unsubscribes.
+type wsRequest struct {
+	Subscribe []string `json:"subscribe"`
+}
+
+// wsMessage is what clients receive: an acknowledgement of a subscription,
+// an error, or the window, with type saying which.
+type wsMessage struct {
+	Type       string   `json:"type"`
+	Subscribed []string `json:"subscribed,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	*WindowEvent
+}
+
+// getWS serves GET /ws, pushing the window to the client each time its
+// average changes while the client is subscribed to any number type. There
+// is one window for all number types, as with the stream, so each change
+// is pushed once whichever types the client chose. Subscribing again
+// replaces the subscription and sends the window as it is.
+//
+// Each connection counts against the same limit as the streams, and ends
+// when ctx is cancelled, as the service shuts down.
+func (s *calculator) getWS(ctx context.Context) gin.HandlerFunc {
+	windows, numberTypes := s.windows, s.numberTypes
+	return func(c *gin.Context) {
+		store := windows.shared
+		if windows.perClient {
+			authToken, authErr := authorization(c)
+			if authErr != nil {
+				abortWithError(c, authErr.status, ErrorBody{Code: authErr.code, Message: authErr.message})
+				return
+			}
+			store = windows.get(authToken)
+		}
+
+		if s.streams.Add(1) > s.maxStreams {
+			s.streams.Add(-1)
+			c.Header("Retry-After", "5")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Too many streams open, try again later"})
+			return
+		}
+		defer s.streams.Add(-1)
+
+		sub, err := store.Subscribe()
+		if err != nil {
+			s.abortStoreUnavailable(c, err)
+			return
+		}
+		defer store.Unsubscribe(sub)
+
+		// gin only sends the status with the first write, so this is for
+		// the access log; the handshake is written once the connection is
+		// taken over.
+		c.Status(http.StatusSwitchingProtocols)
+		// Counted before the connection is taken over, while the server
+		// still waits for it, so Run can't have stopped waiting already.
+		s.openWS.Add(1)
+		defer s.openWS.Done()
+		conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			s.logger.Debug("Refused WebSocket upgrade", "error", err)
+			return
+		}
+		conn.SetReadLimit(WSMaxMessageBytes)
+		ws := &wsConn{conn: conn, logger: s.logger, queue: make(chan []byte, WSQueueSize), done: make(chan struct{}), quit: make(chan struct{})}
+		defer close(ws.quit)
+		requests := make(chan wsRequest)
+		go ws.read(requests)
+		go ws.write()
+
+		var (
+			subscribed []string
+			last       *WindowEvent
+			pushed     bool
+			pushedAvg  Fixed2
+		)
+		push := func() {
+			if len(subscribed) == 0 || last == nil || (pushed && last.Average == pushedAvg) {
+				return
+			}
+			pushed, pushedAvg = true, last.Average
+			ws.send(wsMessage{Type: "window", WindowEvent: last})
+		}
+		for {
+			select {
+			case <-ws.done:
+				return
+			case <-ctx.Done():
+				ws.close(websocket.CloseGoingAway, "server shutting down")
+				return
+			case req := <-requests:
+				types, valid := numberTypes.parse(strings.Join(req.Subscribe, ","))
+				if len(req.Subscribe) > 0 && !valid {
+					ws.send(wsMessage{Type: "error", Error: numberTypes.invalid().Message})
+					continue
+				}
+				subscribed = types
+				ws.send(wsMessage{Type: "subscribed", Subscribed: subscribed})
+				pushed = false
+				push()
+			case e := <-sub.Events():
+				last = &e
+				push()
+			}
+		}
+	}
+}
+
+// wsConn is a WebSocket with a goroutine reading it and another writing
+// it. Messages queue for the writer, so a slow client never holds up the
+// handler, only fills its queue.
+type wsConn struct {
+	conn      *websocket.Conn
+	logger    *slog.Logger
+	queue     chan []byte
+	closeOnce sync.Once
+
+	// done is closed once the reader has stopped, the connection having
+	// failed or been closed; quit is closed once the handler has returned.
+	done chan struct{}
+	quit chan struct{}
+}
+
+// send queues m, disconnecting the client if its queue is full.
+func (ws *wsConn) send(m wsMessage) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	select {
+	case ws.queue <- data:
+	default:
+		ws.logger.Info("Disconnecting slow WebSocket client", "queued", len(ws.queue))
+		// Closing the connection ends the reader, which closes done.
+		ws.close(websocket.ClosePolicyViolation, "client too slow")
+	}
+}
+
+// close sends a close frame with code and reason, unless one was sent
+// already, and closes the connection. The frame is a control message, so
+// it goes out even while the writer is busy; a client stuck behind a write
+// doesn't get it, but closing the connection ends the write.
+func (ws *wsConn) close(code int, reason string) {
+	ws.closeOnce.Do(func() {
+		ws.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+		ws.conn.Close()
+	})
+}
+
+// read passes the client's requests to the handler until the connection
+// fails or is closed, then closes done. Anything arriving, pongs included,
+// proves the client is still there.
+func (ws *wsConn) read(requests chan<- wsRequest) {
+	defer close(ws.done)
+	extend := func() { ws.conn.SetReadDeadline(time.Now().Add(WSPingInterval + WSPongTimeout)) }
+	ws.conn.SetPongHandler(func(string) error {
+		extend()
+		return nil
+	})
+	extend()
+	for {
+		_, data, err := ws.conn.ReadMessage()
+		if err != nil {
+			code := websocket.CloseGoingAway
+			if errors.Is(err, websocket.ErrReadLimit) {
+				code = websocket.CloseMessageTooBig
+			}
+			ws.close(code, "")
+			return
+		}
+		extend()
+		var req wsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			ws.close(websocket.ClosePolicyViolation, `expected {"subscribe": [...]}`)
+			return
+		}
+		select {
+		case requests <- req:
+		case <-ws.quit:
+			return
+		}
+	}
+}
+
+// write sends queued messages and pings until a write fails or the reader
+// is done, giving each write WSWriteTimeout.
+func (ws *wsConn) write() {
+	ping := time.NewTicker(WSPingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-ws.done:
+			return
+		case <-ws.quit:
+			return
+		case data := <-ws.queue:
+			ws.conn.SetWriteDeadline(time.Now().Add(WSWriteTimeout))
+			err = ws.conn.WriteMessage(websocket.TextMessage, data)
+		case <-ping.C:
+			err = ws.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WSWriteTimeout))
+		}
+		if err != nil {
+			ws.close(websocket.CloseGoingAway, "")
+			return
+		}
+	}
+}
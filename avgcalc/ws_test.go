@@ -0,0 +1,64 @@
+package avgcalc
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWSPushesPostedNumbers(t *testing.T) {
+	s := newTestCalculator(t, http.NotFoundHandler(), nil)
+	url := serveAPI(t, s)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(wsRequest{Subscribe: []string{"e"}}); err != nil {
+		t.Fatal(err)
+	}
+	var subscribed wsMessage
+	if err := conn.ReadJSON(&subscribed); err != nil {
+		t.Fatal(err)
+	}
+	if subscribed.Type != "subscribed" || len(subscribed.Subscribed) != 1 {
+		t.Fatalf("first message = %+v, want the subscription acknowledged", subscribed)
+	}
+	var initial wsMessage
+	if err := conn.ReadJSON(&initial); err != nil {
+		t.Fatal(err)
+	}
+	if initial.Type != "window" || initial.Count != 0 {
+		t.Fatalf("second message = %+v, want the empty window", initial)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, url+"/numbers", strings.NewReader(`{"numbers": [1, 2, 6]}`))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /numbers: status %d", resp.StatusCode)
+	}
+
+	var pushed wsMessage
+	if err := conn.ReadJSON(&pushed); err != nil {
+		t.Fatal(err)
+	}
+	if pushed.Type != "window" || pushed.WindowEvent == nil {
+		t.Fatalf("pushed %+v, want the window", pushed)
+	}
+	assertNumbers(t, "pushed window", pushed.WindowCurrState, 1, 2, 6)
+	if pushed.Average != 3 || pushed.Count != 3 {
+		t.Errorf("pushed avg, count = %v, %d, want 3, 3", pushed.Average, pushed.Count)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Escanor244/713522IT013/avgcalc"
+)
+
+const clientUsage = "usage: 713522IT013 client --type p[,f,e,r] [--server URL] [--token TOKEN] [--watch 2s]"
+
+// runClient calls GET /numbers/:numberid on a running average calculator
+// and prints the window, again every --watch if set. It returns the exit
+// status: 1 once a call fails, 2 for bad arguments.
+func runClient(args []string) int {
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), clientUsage)
+		fs.PrintDefaults()
+	}
+	numberType := fs.String("type", "", "number IDs, comma-separated: p, f, e, r or any other the server registers")
+	server := fs.String("server", "http://localhost:9877", "the average calculator's base URL")
+	token := fs.String("token", os.Getenv("TOKEN"), "bearer token; defaults to $TOKEN")
+	watch := fs.Duration("watch", 0, "call again at this interval until interrupted")
+	timeout := fs.Duration("timeout", 10*time.Second, "how long each call may take")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *numberType == "" || fs.NArg() > 0 || *watch < 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client := &apiClient{
+		base:    strings.TrimRight(*server, "/"),
+		token:   *token,
+		http:    &http.Client{Timeout: *timeout},
+		numbers: *numberType,
+	}
+	for {
+		resp, err := client.get(ctx)
+		if ctx.Err() != nil {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		printWindow(os.Stdout, resp)
+		if *watch == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-time.After(*watch):
+		}
+		fmt.Println()
+	}
+}
+
+// apiClient calls GET /numbers/:numberid, decoding into the types the
+// server encodes with.
+type apiClient struct {
+	base, token string
+	http        *http.Client
+	numbers     string
+}
+
+// clientError is a response other than 200, with the error code from the
+// envelope when there was one.
+type clientError struct {
+	status int
+	body   avgcalc.ErrorBody
+}
+
+func (e *clientError) Error() string {
+	if e.body.Code == "" {
+		return fmt.Sprintf("%d %s", e.status, http.StatusText(e.status))
+	}
+	return fmt.Sprintf("%d %s: %s: %s", e.status, http.StatusText(e.status), e.body.Code, e.body.Message)
+}
+
+func (c *apiClient) get(ctx context.Context) (*avgcalc.APIResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/numbers/"+url.PathEscape(c.numbers), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var envelope avgcalc.ErrorEnvelope
+		_ = json.Unmarshal(body, &envelope)
+		return nil, &clientError{status: res.StatusCode, body: envelope.Error}
+	}
+	var resp avgcalc.APIResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.New("malformed response: " + err.Error())
+	}
+	return &resp, nil
+}
+
+// printWindow writes resp as a table.
+func printWindow(w io.Writer, resp *avgcalc.APIResponse) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Previous\t%s\n", formatNumbers(resp.WindowPrevState))
+	fmt.Fprintf(tw, "Current\t%s\n", formatNumbers(resp.WindowCurrState))
+	fmt.Fprintf(tw, "Fetched\t%s\n", formatNumbers(resp.Numbers))
+	fmt.Fprintf(tw, "Average\t%.2f\n", resp.Average.Rounded())
+	fmt.Fprintf(tw, "Trimmed\t%.2f\n", resp.TrimmedAverage.Rounded())
+	if resp.EMAAverage != nil {
+		fmt.Fprintf(tw, "EMA\t%.2f\n", *resp.EMAAverage)
+	}
+	fmt.Fprintf(tw, "Window\t%d of %d\n", resp.WindowCount, resp.WindowSize)
+	var notes []string
+	if resp.UpstreamUnavailable {
+		notes = append(notes, "number service unavailable")
+	}
+	if resp.Stale {
+		notes = append(notes, "stale")
+	}
+	if resp.Source != "" {
+		notes = append(notes, "source "+resp.Source)
+	}
+	if resp.CacheHit {
+		notes = append(notes, "cached")
+	}
+	if len(resp.RejectedOutliers) > 0 {
+		notes = append(notes, "outliers rejected: "+formatNumbers(resp.RejectedOutliers))
+	}
+	failed := make([]string, 0, len(resp.Failures))
+	for numberType := range resp.Failures {
+		failed = append(failed, numberType)
+	}
+	sort.Strings(failed)
+	for _, numberType := range failed {
+		notes = append(notes, numberType+" failed: "+resp.Failures[numberType])
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(tw, "Notes\t%s\n", strings.Join(notes, "; "))
+	}
+	tw.Flush()
+}
+
+func formatNumbers(numbers []float64) string {
+	if len(numbers) == 0 {
+		return "-"
+	}
+	parts := make([]string, len(numbers))
+	for i, n := range numbers {
+		parts[i] = strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return strings.Join(parts, ", ")
+}
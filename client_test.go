@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Escanor244/713522IT013/avgcalc"
+)
+
+func TestPrintWindowOrdersFailures(t *testing.T) {
+	resp := &avgcalc.APIResponse{Failures: map[string]string{
+		"rand":   "timed out",
+		"even":   "unavailable",
+		"primes": "refused",
+		"fibo":   "invalid response",
+	}}
+	want := "even failed: unavailable; fibo failed: invalid response; primes failed: refused; rand failed: timed out"
+	for i := 0; i < 10; i++ {
+		var out strings.Builder
+		printWindow(&out, resp)
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("notes in %q, want %q", out.String(), want)
+		}
+	}
+}
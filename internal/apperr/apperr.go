@@ -0,0 +1,97 @@
+// Package apperr defines the errors both services classify failures by.
+// Errors are wrapped with %w as they travel up, so handlers pick a status
+// with errors.Is and errors.As rather than by matching messages.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrUpstreamTimeout is the upstream taking longer than its timeout.
+	ErrUpstreamTimeout = errors.New("upstream timed out")
+
+	// ErrUpstreamUnreachable is a request that never got a response.
+	ErrUpstreamUnreachable = errors.New("upstream unreachable")
+
+	// ErrUpstreamStatus matches every UpstreamStatusError.
+	ErrUpstreamStatus = errors.New("upstream responded with an error status")
+
+	// ErrDecode is an upstream body that could not be decoded.
+	ErrDecode = errors.New("failed to decode upstream response")
+
+	// ErrUnauthorized is a request whose credentials were refused.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrBadRequest is a request refused as malformed, by us or by the
+	// upstream it was passed on to.
+	ErrBadRequest = errors.New("bad request")
+
+	// ErrNotFound is a request for something that doesn't exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrCacheEmpty is a lookup that can't be answered because the cache
+	// has never been filled.
+	ErrCacheEmpty = errors.New("cache is empty")
+)
+
+// UpstreamStatusError is a non-200 upstream response. Body holds the start
+// of the response for error messages.
+type UpstreamStatusError struct {
+	Code int
+	Body string
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream responded with status %d", e.Code)
+}
+
+func (e *UpstreamStatusError) Is(target error) bool {
+	return target == ErrUpstreamStatus
+}
+
+// Wrap returns an error reading msg that unwraps to err, for messages that
+// don't end in err's own text.
+func Wrap(err error, msg string) error {
+	return &wrapped{msg: msg, err: err}
+}
+
+type wrapped struct {
+	msg string
+	err error
+}
+
+func (w *wrapped) Error() string { return w.msg }
+func (w *wrapped) Unwrap() error { return w.err }
+
+// HTTPStatus is the status a handler answers err with:
+//
+//	ErrBadRequest            400
+//	ErrUnauthorized          401
+//	ErrNotFound              404
+//	ErrUpstreamUnreachable   502
+//	ErrUpstreamStatus        502
+//	ErrDecode                502
+//	ErrCacheEmpty            503
+//	ErrUpstreamTimeout       504
+//	anything else            500
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUpstreamTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrCacheEmpty):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrUpstreamUnreachable), errors.Is(err, ErrUpstreamStatus), errors.Is(err, ErrDecode):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
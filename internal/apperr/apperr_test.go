@@ -0,0 +1,79 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestWrappedErrorsKeepTheirStatus(t *testing.T) {
+	tests := []struct {
+		sentinel error
+		status   int
+	}{
+		{ErrBadRequest, http.StatusBadRequest},
+		{ErrUnauthorized, http.StatusUnauthorized},
+		{ErrNotFound, http.StatusNotFound},
+		{ErrUpstreamUnreachable, http.StatusBadGateway},
+		{ErrUpstreamStatus, http.StatusBadGateway},
+		{ErrDecode, http.StatusBadGateway},
+		{ErrCacheEmpty, http.StatusServiceUnavailable},
+		{ErrUpstreamTimeout, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		chains := map[string]error{
+			"bare":     tt.sentinel,
+			"Wrap":     Wrap(tt.sentinel, "loading users"),
+			"%w":       fmt.Errorf("fetching posts: %w", tt.sentinel),
+			"%w twice": fmt.Errorf("refresh: %w", fmt.Errorf("fetching posts: %w", tt.sentinel)),
+			"Wrap, %w": Wrap(fmt.Errorf("fetching posts: %w", tt.sentinel), "refresh failed"),
+			"%w, Wrap": fmt.Errorf("refresh: %w", Wrap(tt.sentinel, "fetching posts failed")),
+			"two %w":   fmt.Errorf("%w: %w", tt.sentinel, errors.New("connection reset")),
+		}
+		for name, err := range chains {
+			if !errors.Is(err, tt.sentinel) {
+				t.Errorf("%v, %s: errors.Is lost the sentinel", tt.sentinel, name)
+			}
+			if got := HTTPStatus(err); got != tt.status {
+				t.Errorf("%v, %s: HTTPStatus = %d, want %d", tt.sentinel, name, got, tt.status)
+			}
+		}
+	}
+}
+
+func TestWrapKeepsItsMessage(t *testing.T) {
+	err := Wrap(ErrNotFound, "User not found")
+	if err.Error() != "User not found" {
+		t.Errorf("Error() = %q, want the message alone", err.Error())
+	}
+	if errors.Unwrap(err) != ErrNotFound {
+		t.Errorf("Unwrap() = %v, want ErrNotFound", errors.Unwrap(err))
+	}
+}
+
+func TestUpstreamStatusError(t *testing.T) {
+	err := fmt.Errorf("fetching users: %w", Wrap(&UpstreamStatusError{Code: 503, Body: "down"}, "upstream failed"))
+
+	var statusErr *UpstreamStatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != 503 || statusErr.Body != "down" {
+		t.Fatalf("errors.As = %+v, want the 503", statusErr)
+	}
+	if !errors.Is(err, ErrUpstreamStatus) {
+		t.Error("errors.Is(err, ErrUpstreamStatus) = false")
+	}
+	if errors.Is(err, ErrUpstreamTimeout) || errors.Is(err, ErrDecode) {
+		t.Error("an upstream status matched another sentinel")
+	}
+	if got := HTTPStatus(err); got != http.StatusBadGateway {
+		t.Errorf("HTTPStatus = %d, want 502", got)
+	}
+}
+
+func TestHTTPStatusOfOtherErrors(t *testing.T) {
+	for _, err := range []error{errors.New("boom"), fmt.Errorf("wrapped: %v", ErrNotFound), nil} {
+		if got := HTTPStatus(err); got != http.StatusInternalServerError {
+			t.Errorf("HTTPStatus(%v) = %d, want 500", err, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Package buildinfo reports which build of a service is running, from the
+// information the Go toolchain stamps into the binary.
+package buildinfo
+
+import (
+	"log"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+const unknown = "unknown"
+
+// Info is the response of GET /version. Fields the binary carries no
+// information for, as under go run, are "unknown".
+type Info struct {
+	Service    string `json:"service"`
+	Version    string `json:"version"`
+	Revision   string `json:"revision"`
+	CommitTime string `json:"commitTime"`
+	Modified   bool   `json:"modified"`
+	GoVersion  string `json:"goVersion"`
+}
+
+// Read returns the build information of the running binary for service.
+func Read(service string) Info {
+	info := Info{
+		Service:    service,
+		Version:    unknown,
+		Revision:   unknown,
+		CommitTime: unknown,
+		GoVersion:  runtime.Version(),
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if v := bi.Main.Version; v != "" {
+		info.Version = v
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.CommitTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// Log logs the build at startup.
+func Log(service string) {
+	info := Read(service)
+	log.Printf("[%s] Build %s, revision %s (%s), modified %t, %s",
+		service, info.Version, info.Revision, info.CommitTime, info.Modified, info.GoVersion)
+}
+
+// Handler serves GET /version.
+func Handler(service string) gin.HandlerFunc {
+	info := Read(service)
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, info)
+	}
+}
@@ -0,0 +1,433 @@
+// Package config resolves the services' settings from the environment and
+// an optional YAML file.
+//
+// Every setting is identified by its environment variable and parsed by the
+// service that owns it. A value set on the command line wins, then the
+// environment, then the file, and the service's own defaults fill in
+// whatever none of them sets.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the schema of the configuration file. Each leaf names the
+// environment variable it stands in for.
+type File struct {
+	HTTP   HTTP   `yaml:"http"`
+	Avg    Avg    `yaml:"avg"`
+	Social Social `yaml:"social"`
+}
+
+// HTTP holds the settings shared by both services' HTTP servers.
+type HTTP struct {
+	Security HTTPSecurity `yaml:"security"`
+	Access   HTTPAccess   `yaml:"access"`
+	Server   HTTPServer   `yaml:"server"`
+
+	Maintenance HTTPMaintenance `yaml:"maintenance"`
+	Shedding    HTTPShedding    `yaml:"shedding"`
+
+	AccessLogSample2xx *float64 `yaml:"accessLogSample2xx" env:"ACCESS_LOG_SAMPLE_2XX"`
+	LogLevel           *string  `yaml:"logLevel" env:"LOG_LEVEL"`
+	RecordDir          *string  `yaml:"recordDir" env:"RECORD_DIR"`
+	ErrorReportDSN     *string  `yaml:"errorReportDSN" env:"ERROR_REPORT_DSN" secret:"true"`
+}
+
+type HTTPShedding struct {
+	MaxInFlight  *int      `yaml:"maxInFlight" env:"MAX_IN_FLIGHT"`
+	MaxQueued    *int      `yaml:"maxQueued" env:"MAX_QUEUED"`
+	QueueTimeout *Duration `yaml:"queueTimeout" env:"QUEUE_TIMEOUT"`
+}
+
+type HTTPMaintenance struct {
+	Enabled    *bool     `yaml:"enabled" env:"MAINTENANCE_ENABLED"`
+	Message    *string   `yaml:"message" env:"MAINTENANCE_MESSAGE"`
+	RetryAfter *Duration `yaml:"retryAfter" env:"MAINTENANCE_RETRY_AFTER"`
+}
+
+type HTTPSecurity struct {
+	DisableHeaders []string  `yaml:"disableHeaders" env:"SECURITY_HEADERS_DISABLE"`
+	ReferrerPolicy *string   `yaml:"referrerPolicy" env:"REFERRER_POLICY"`
+	HSTSMaxAge     *Duration `yaml:"hstsMaxAge" env:"HSTS_MAX_AGE"`
+}
+
+type HTTPServer struct {
+	ReadHeaderTimeout *Duration `yaml:"readHeaderTimeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       *Duration `yaml:"readTimeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout      *Duration `yaml:"writeTimeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       *Duration `yaml:"idleTimeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout   *Duration `yaml:"shutdownTimeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+	MaxHeaderBytes    *int      `yaml:"maxHeaderBytes" env:"HTTP_MAX_HEADER_BYTES"`
+	MaxBodyBytes      *int64    `yaml:"maxBodyBytes" env:"HTTP_MAX_BODY_BYTES"`
+	SocketMode        *string   `yaml:"socketMode" env:"HTTP_SOCKET_MODE"`
+	TLSCertFile       *string   `yaml:"tlsCertFile" env:"TLS_CERT_FILE"`
+	TLSKeyFile        *string   `yaml:"tlsKeyFile" env:"TLS_KEY_FILE"`
+}
+
+type HTTPAccess struct {
+	Allow          []string `yaml:"allow" env:"IP_ALLOWLIST"`
+	Deny           []string `yaml:"deny" env:"IP_DENYLIST"`
+	TrustedProxies []string `yaml:"trustedProxies" env:"TRUSTED_PROXIES"`
+	ExemptHealth   *bool    `yaml:"exemptHealth" env:"IP_FILTER_EXEMPT_HEALTH"`
+}
+
+type Avg struct {
+	Port             *int      `yaml:"port" env:"PORT"`
+	Listen           *string   `yaml:"listen" env:"LISTEN"`
+	GRPCPort         *int      `yaml:"grpcPort" env:"GRPC_PORT"`
+	NumberServiceURL *string   `yaml:"numberServiceURL" env:"NUMBER_SERVICE_URL"`
+	Timeout          *Duration `yaml:"timeout" env:"NUMBER_SERVICE_TIMEOUT"`
+	TimeoutMin       *Duration `yaml:"timeoutMin" env:"NUMBER_SERVICE_TIMEOUT_MIN"`
+	TimeoutMax       *Duration `yaml:"timeoutMax" env:"NUMBER_SERVICE_TIMEOUT_MAX"`
+	Retries          *int      `yaml:"retries" env:"NUMBER_SERVICE_RETRIES"`
+	RetryBackoff     *Duration `yaml:"retryBackoff" env:"NUMBER_SERVICE_RETRY_BACKOFF"`
+	Deadline         *Duration `yaml:"deadline" env:"NUMBER_SERVICE_DEADLINE"`
+	CacheTTL         *Duration `yaml:"cacheTTL" env:"NUMBER_SERVICE_CACHE_TTL"`
+	MaxStale         *Duration `yaml:"maxStale" env:"NUMBER_SERVICE_MAX_STALE"`
+	BreakerThreshold *int      `yaml:"breakerThreshold" env:"NUMBER_SERVICE_BREAKER_THRESHOLD"`
+	MockUpstream     *bool     `yaml:"mockUpstream" env:"MOCK_UPSTREAM"`
+	MockLatency      *Duration `yaml:"mockUpstreamLatency" env:"MOCK_UPSTREAM_LATENCY"`
+	MockFailureRate  *float64  `yaml:"mockUpstreamFailureRate" env:"MOCK_UPSTREAM_FAILURE_RATE"`
+	MockToken        *string   `yaml:"mockUpstreamToken" env:"MOCK_UPSTREAM_TOKEN" secret:"true"`
+	Fallback         *bool     `yaml:"fallbackGenerators" env:"FALLBACK_GENERATORS"`
+	FallbackRandMin  *int      `yaml:"fallbackRandomMin" env:"FALLBACK_RANDOM_MIN"`
+	FallbackRandMax  *int      `yaml:"fallbackRandomMax" env:"FALLBACK_RANDOM_MAX"`
+	BreakerCooldown  *Duration `yaml:"breakerCooldown" env:"NUMBER_SERVICE_BREAKER_COOLDOWN"`
+	ReadyProbe       *bool     `yaml:"readyProbeUpstream" env:"READY_PROBE_UPSTREAM"`
+	ReadyProbeEvery  *Duration `yaml:"readyProbeInterval" env:"READY_PROBE_INTERVAL"`
+	ReadyFailingFor  *Duration `yaml:"readyFailingThreshold" env:"READY_FAILING_THRESHOLD"`
+	Auth             *string   `yaml:"auth" env:"NUMBER_SERVICE_AUTH"`
+	AuthURL          *string   `yaml:"authURL" env:"NUMBER_SERVICE_AUTH_URL"`
+	ClientID         *string   `yaml:"clientID" env:"NUMBER_SERVICE_CLIENT_ID"`
+	ClientSecret     *string   `yaml:"clientSecret" env:"NUMBER_SERVICE_CLIENT_SECRET" secret:"true"`
+	CompanyName      *string   `yaml:"companyName" env:"NUMBER_SERVICE_COMPANY_NAME"`
+	OwnerName        *string   `yaml:"ownerName" env:"NUMBER_SERVICE_OWNER_NAME"`
+	OwnerEmail       *string   `yaml:"ownerEmail" env:"NUMBER_SERVICE_OWNER_EMAIL"`
+	RollNo           *string   `yaml:"rollNo" env:"NUMBER_SERVICE_ROLL_NO"`
+	RefreshMargin    *Duration `yaml:"tokenRefreshMargin" env:"NUMBER_SERVICE_TOKEN_REFRESH_MARGIN"`
+	TokenValidation  *bool     `yaml:"tokenValidation" env:"TOKEN_VALIDATION"`
+	TokenValidator   *string   `yaml:"tokenValidatorURL" env:"TOKEN_VALIDATOR_URL"`
+	TokenCacheTTL    *Duration `yaml:"tokenCacheTTL" env:"TOKEN_CACHE_TTL"`
+	TokenCacheSize   *int      `yaml:"tokenCacheSize" env:"TOKEN_CACHE_SIZE"`
+	WindowSize       *int      `yaml:"windowSize" env:"WINDOW_SIZE"`
+	WindowSizeMax    *int      `yaml:"windowSizeMax" env:"WINDOW_SIZE_MAX"`
+	WindowMode       *string   `yaml:"windowMode" env:"WINDOW_MODE"`
+	WindowDuration   *Duration `yaml:"windowDuration" env:"WINDOW_DURATION"`
+	PerClientWindows *bool     `yaml:"perClientWindows" env:"PER_CLIENT_WINDOWS"`
+	ClientWindowTTL  *Duration `yaml:"clientWindowTTL" env:"CLIENT_WINDOW_TTL"`
+	HistorySize      *int      `yaml:"windowHistorySize" env:"WINDOW_HISTORY_SIZE"`
+	WeightedWeights  []float64 `yaml:"weightedAvgWeights" env:"WEIGHTED_AVG_WEIGHTS"`
+	OutlierFilter    *string   `yaml:"outlierFilter" env:"OUTLIER_FILTER"`
+	OutlierZScore    *float64  `yaml:"outlierZScoreThreshold" env:"OUTLIER_ZSCORE_THRESHOLD"`
+	OutlierSamples   *int      `yaml:"outlierMinSamples" env:"OUTLIER_MIN_SAMPLES"`
+	TrimFraction     *float64  `yaml:"trimFraction" env:"TRIM_FRACTION"`
+	StateFile        *string   `yaml:"windowStateFile" env:"WINDOW_STATE_FILE"`
+	StateMaxAge      *Duration `yaml:"windowStateMaxAge" env:"WINDOW_STATE_MAX_AGE"`
+	StateSaveEvery   *Duration `yaml:"windowStateSaveInterval" env:"WINDOW_STATE_SAVE_INTERVAL"`
+	StoreBackend     *string   `yaml:"storeBackend" env:"STORE_BACKEND"`
+	RedisURL         *string   `yaml:"redisURL" env:"REDIS_URL" secret:"true"`
+	RedisKeyPrefix   *string   `yaml:"redisKeyPrefix" env:"REDIS_KEY_PREFIX"`
+	TLSRedirect      *string   `yaml:"tlsRedirectListen" env:"TLS_REDIRECT_LISTEN"`
+	StreamMax        *int      `yaml:"streamMaxSubscribers" env:"STREAM_MAX_SUBSCRIBERS"`
+
+	NumberTypes map[string]NumberType `yaml:"numberTypes" env:"NUMBER_TYPES"`
+}
+
+type Social struct {
+	Port       *int    `yaml:"port" env:"SOCIAL_PORT"`
+	Listen     *string `yaml:"listen" env:"SOCIAL_LISTEN"`
+	AdminToken *string `yaml:"adminToken" env:"ADMIN_TOKEN" secret:"true"`
+	StateDir   *string `yaml:"stateDir" env:"STATE_DIR"`
+
+	Refresh  SocialRefresh  `yaml:"refresh"`
+	Ranking  SocialRanking  `yaml:"ranking"`
+	Comments SocialComments `yaml:"comments"`
+	Upstream SocialUpstream `yaml:"upstream"`
+
+	PreviewLength *int `yaml:"previewLength" env:"PREVIEW_LENGTH"`
+}
+
+type SocialRefresh struct {
+	Interval    *Duration `yaml:"interval" env:"REFRESH_INTERVAL"`
+	Concurrency *int      `yaml:"concurrency" env:"REFRESH_CONCURRENCY"`
+	BackoffMax  *Duration `yaml:"backoffMax" env:"REFRESH_BACKOFF_MAX"`
+	HistorySize *int      `yaml:"historySize" env:"REFRESH_HISTORY_SIZE"`
+}
+
+type SocialRanking struct {
+	TopUsersLimit           *int      `yaml:"topUsersLimit" env:"TOP_USERS_LIMIT"`
+	LatestPostsLimit        *int      `yaml:"latestPostsLimit" env:"LATEST_POSTS_LIMIT"`
+	WindowMax               *Duration `yaml:"windowMax" env:"RANKING_WINDOW_MAX"`
+	EngagementPostWeight    *float64  `yaml:"engagementPostWeight" env:"ENGAGEMENT_POST_WEIGHT"`
+	EngagementCommentWeight *float64  `yaml:"engagementCommentWeight" env:"ENGAGEMENT_COMMENT_WEIGHT"`
+}
+
+type SocialComments struct {
+	Mode            *string `yaml:"mode" env:"COMMENTS_MODE"`
+	LazyPerUser     *int    `yaml:"lazyPerUser" env:"LAZY_COMMENTS_PER_USER"`
+	QuietPostCycles *int    `yaml:"quietPostCycles" env:"QUIET_POST_CYCLES"`
+}
+
+type SocialUpstream struct {
+	BaseURL      *string                `yaml:"baseURL" env:"UPSTREAM_BASE_URL"`
+	Token        *string                `yaml:"token" env:"UPSTREAM_TOKEN" secret:"true"`
+	Environments map[string]Environment `yaml:"environments" env:"UPSTREAM_ENVS" secret:"true"`
+	DefaultEnv   *string                `yaml:"defaultEnv" env:"UPSTREAM_DEFAULT_ENV"`
+
+	Timeout         *Duration `yaml:"timeout" env:"UPSTREAM_TIMEOUT"`
+	UsersTimeout    *Duration `yaml:"usersTimeout" env:"UPSTREAM_USERS_TIMEOUT"`
+	PostsTimeout    *Duration `yaml:"postsTimeout" env:"UPSTREAM_POSTS_TIMEOUT"`
+	CommentsTimeout *Duration `yaml:"commentsTimeout" env:"UPSTREAM_COMMENTS_TIMEOUT"`
+
+	Retries      *int      `yaml:"retries" env:"UPSTREAM_RETRIES"`
+	RetryBackoff *Duration `yaml:"retryBackoff" env:"UPSTREAM_RETRY_BACKOFF"`
+	MaxBodyBytes *int64    `yaml:"maxBodyBytes" env:"UPSTREAM_MAX_BODY_BYTES"`
+
+	CAFile             *string `yaml:"caFile" env:"UPSTREAM_CA_FILE"`
+	ClientCert         *string `yaml:"clientCert" env:"UPSTREAM_CLIENT_CERT"`
+	ClientKey          *string `yaml:"clientKey" env:"UPSTREAM_CLIENT_KEY"`
+	InsecureSkipVerify *bool   `yaml:"insecureSkipVerify" env:"UPSTREAM_INSECURE_SKIP_VERIFY"`
+}
+
+// Environment is one entry of social.upstream.environments.
+type Environment struct {
+	BaseURL string `yaml:"baseURL" json:"baseURL"`
+	Token   string `yaml:"token" json:"token"`
+}
+
+// NumberType is one entry of avg.numberTypes, keyed by number ID.
+type NumberType struct {
+	Path    string `yaml:"path" json:"path"`
+	Name    string `yaml:"name" json:"name,omitempty"`
+	Timeout string `yaml:"timeout" json:"timeout,omitempty"`
+}
+
+// Duration is a time.Duration written as a Go duration string, e.g. "30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(n *yaml.Node) error {
+	parsed, err := time.ParseDuration(n.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", n.Line, n.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// setting is one schema leaf.
+type setting struct {
+	env     string
+	key     string
+	section string
+	secret  bool
+}
+
+// fileValue is a setting's value as given in the file.
+type fileValue struct {
+	value string
+	key   string
+}
+
+// Source answers setting lookups for the services.
+type Source struct {
+	path  string
+	file  map[string]fileValue
+	flags map[string]string
+}
+
+// FromEnv returns a Source backed by the environment alone.
+func FromEnv() *Source {
+	return &Source{file: map[string]fileValue{}}
+}
+
+// Load reads the configuration file at path. Unknown keys are rejected so a
+// typo doesn't silently fall back to a default.
+func Load(path string) (*Source, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config file %s: %v", path, err)
+	}
+
+	src := &Source{path: path, file: map[string]fileValue{}}
+	walk(reflect.ValueOf(f), "", func(s setting, v reflect.Value) {
+		if value, ok := format(v); ok {
+			src.file[s.env] = fileValue{value: value, key: s.key}
+		}
+	})
+	return src, nil
+}
+
+// Set sets the setting named by env from the command line, over the
+// environment and the file. It fails for a name no setting has.
+func (s *Source) Set(env, value string) error {
+	known := false
+	walk(reflect.ValueOf(File{}), "", func(st setting, _ reflect.Value) {
+		known = known || st.env == env
+	})
+	if !known {
+		return fmt.Errorf("unknown setting %q", env)
+	}
+	if s.flags == nil {
+		s.flags = map[string]string{}
+	}
+	s.flags[env] = value
+	return nil
+}
+
+// Get returns the value of the setting named by env: from the command line
+// if set there, then the environment, then the file. Empty means unset.
+func (s *Source) Get(env string) string {
+	if value := s.flags[env]; value != "" {
+		return value
+	}
+	if value := os.Getenv(env); value != "" {
+		return value
+	}
+	return s.file[env].value
+}
+
+// Name identifies the setting named by env for error messages: the file key
+// when its value came from the file, the variable otherwise.
+func (s *Source) Name(env string) string {
+	if s.flags[env] != "" {
+		return fmt.Sprintf("%s (--set)", env)
+	}
+	if os.Getenv(env) == "" {
+		if fv, ok := s.file[env]; ok {
+			return fmt.Sprintf("%s (%s in %s)", env, fv.key, s.path)
+		}
+	}
+	return env
+}
+
+// Reload reads the configuration file again, returning a new Source with
+// the same command line settings.
+func (s *Source) Reload() (*Source, error) {
+	next := FromEnv()
+	if s.path != "" {
+		var err error
+		if next, err = Load(s.path); err != nil {
+			return nil, err
+		}
+	}
+	next.flags = s.flags
+	return next, nil
+}
+
+// Changed lists the settings of section whose value differs between old
+// and s.
+func (s *Source) Changed(old *Source, section string) []string {
+	var changed []string
+	walk(reflect.ValueOf(File{}), "", func(st setting, _ reflect.Value) {
+		if st.section == section && s.Get(st.env) != old.Get(st.env) {
+			changed = append(changed, st.env)
+		}
+	})
+	return changed
+}
+
+// LogEffective logs every setting of section that is set, and where it was
+// set, with secrets redacted.
+func (s *Source) LogEffective(section string) {
+	var lines []string
+	walk(reflect.ValueOf(File{}), "", func(st setting, _ reflect.Value) {
+		if st.section != section {
+			return
+		}
+		value := s.Get(st.env)
+		if value == "" {
+			return
+		}
+		if st.secret {
+			value = "<redacted>"
+		}
+		from := "env"
+		switch {
+		case s.flags[st.env] != "":
+			from = "flag"
+		case os.Getenv(st.env) == "":
+			from = s.path
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s (%s)", st.env, value, from))
+	})
+	sort.Strings(lines)
+
+	if len(lines) == 0 {
+		log.Printf("[%s] Effective config: all defaults", section)
+		return
+	}
+	log.Printf("[%s] Effective config: %s", section, strings.Join(lines, ", "))
+}
+
+// walk calls fn for every leaf of the File schema under v.
+func walk(v reflect.Value, prefix string, fn func(setting, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		env := field.Tag.Get("env")
+		if env == "" {
+			walk(v.Field(i), key, fn)
+			continue
+		}
+		fn(setting{
+			env:     env,
+			key:     key,
+			section: strings.SplitN(key, ".", 2)[0],
+			secret:  field.Tag.Get("secret") == "true",
+		}, v.Field(i))
+	}
+}
+
+// format renders a file value the way it would be written in its
+// environment variable, reporting false if it is unset.
+func format(v reflect.Value) (string, bool) {
+	if v.IsNil() {
+		return "", false
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ","), true
+	}
+	if v.Kind() == reflect.Map {
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+
+	switch elem := v.Elem().Interface().(type) {
+	case Duration:
+		return time.Duration(elem).String(), true
+	default:
+		return fmt.Sprint(elem), true
+	}
+}
@@ -0,0 +1,232 @@
+// Package errreport sends errors nobody would otherwise look at, such as
+// handler panics and repeated refresh failures, to an external tracker.
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// Reporter records an error along with tags describing where it happened.
+// Report must not block the caller.
+type Reporter interface {
+	Report(ctx context.Context, err error, tags map[string]string)
+}
+
+// Nop discards every report.
+type Nop struct{}
+
+func (Nop) Report(context.Context, error, map[string]string) {}
+
+// Load returns the Sentry reporter for ERROR_REPORT_DSN, or Nop when it is
+// unset.
+func Load(src *config.Source) (Reporter, error) {
+	raw := src.Get("ERROR_REPORT_DSN")
+	if raw == "" {
+		return Nop{}, nil
+	}
+	s, err := newSentry(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src.Name("ERROR_REPORT_DSN"), err)
+	}
+	go s.run()
+	return s, nil
+}
+
+const (
+	// queueSize bounds the reports waiting to be sent; more are dropped.
+	queueSize = 256
+
+	// flushInterval is how often queued reports are sent. Identical
+	// reports within one interval are sent once with a count.
+	flushInterval = 5 * time.Second
+
+	// maxPerFlush caps the events sent per interval, so a storm of
+	// distinct errors can't turn into a storm of outbound requests.
+	maxPerFlush = 10
+)
+
+// report is one queued Report call.
+type report struct {
+	err  string
+	tags map[string]string
+	at   time.Time
+}
+
+// sentry posts events to a Sentry-compatible store endpoint.
+type sentry struct {
+	endpoint string
+	auth     string
+	http     *http.Client
+
+	queue chan report
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// newSentry parses a DSN of the form https://key@host/project.
+func newSentry(dsn string) (*sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return nil, fmt.Errorf("must be a DSN like https://key@host/project")
+	}
+	project := strings.Trim(u.Path, "/")
+	if project == "" {
+		return nil, fmt.Errorf("must be a DSN like https://key@host/project")
+	}
+
+	return &sentry{
+		endpoint: fmt.Sprintf("%s://%s/api/%s/store/", u.Scheme, u.Host, project),
+		auth:     fmt.Sprintf("Sentry sentry_version=7, sentry_client=713522IT013/1.0, sentry_key=%s", u.User.Username()),
+		http:     &http.Client{Timeout: 5 * time.Second},
+		queue:    make(chan report, queueSize),
+	}, nil
+}
+
+func (s *sentry) Report(_ context.Context, err error, tags map[string]string) {
+	if err == nil {
+		return
+	}
+	copied := make(map[string]string, len(tags))
+	for k, v := range tags {
+		copied[k] = v
+	}
+
+	select {
+	case s.queue <- report{err: err.Error(), tags: copied, at: time.Now()}:
+	default:
+		s.mu.Lock()
+		s.dropped++
+		s.mu.Unlock()
+	}
+}
+
+// run sends the queued reports every flushInterval, forever. A rejected or
+// failed send backs off, honouring Retry-After, and reports made while
+// backing off are dropped.
+func (s *sentry) run() {
+	var pauseUntil time.Time
+	for range time.Tick(flushInterval) {
+		batch := s.drain()
+		if len(batch) == 0 || time.Now().Before(pauseUntil) {
+			continue
+		}
+
+		for i, ev := range batch {
+			if i == maxPerFlush {
+				log.Printf("Error reporter dropped %d distinct errors over its rate limit", len(batch)-i)
+				break
+			}
+			if retryAfter, err := s.send(ev); err != nil {
+				log.Printf("Error reporter failed to send, pausing for %v: %v", retryAfter, err)
+				pauseUntil = time.Now().Add(retryAfter)
+				break
+			}
+		}
+	}
+}
+
+// event is a group of identical reports drained in one flush.
+type event struct {
+	report
+	count int
+}
+
+// drain empties the queue, merging reports with the same error and tags.
+func (s *sentry) drain() []*event {
+	groups := map[string]*event{}
+	var order []*event
+	for {
+		select {
+		case r := <-s.queue:
+			key := r.err + "\x00" + fingerprint(r.tags)
+			if ev, ok := groups[key]; ok {
+				ev.count++
+				continue
+			}
+			ev := &event{report: r, count: 1}
+			groups[key] = ev
+			order = append(order, ev)
+		default:
+			s.mu.Lock()
+			if s.dropped > 0 {
+				log.Printf("Error reporter dropped %d reports, its queue was full", s.dropped)
+				s.dropped = 0
+			}
+			s.mu.Unlock()
+			return order
+		}
+	}
+}
+
+func fingerprint(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + "=" + tags[k] + "\x00")
+	}
+	return b.String()
+}
+
+// send posts one event, returning how long to back off if it failed.
+func (s *sentry) send(ev *event) (time.Duration, error) {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"event_id":  hex.EncodeToString(id),
+		"timestamp": ev.at.UTC().Format(time.RFC3339),
+		"level":     "error",
+		"platform":  "go",
+		"message":   ev.err,
+		"tags":      ev.tags,
+		"extra":     map[string]int{"occurrences": ev.count},
+	})
+	if err != nil {
+		return flushInterval, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return flushInterval, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return time.Minute, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		backoff := time.Minute
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			backoff = time.Duration(secs) * time.Second
+		}
+		return backoff, fmt.Errorf("rate limited")
+	}
+	if resp.StatusCode >= 300 {
+		return time.Minute, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return 0, nil
+}
@@ -0,0 +1,122 @@
+// Package httpserver runs the services' HTTP servers with a shared
+// lifecycle.
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Serve runs srv on ln until ctx is cancelled, then shuts it down
+// gracefully, which also removes a Unix socket. It returns nil after a
+// clean shutdown and the serve error otherwise.
+//
+// Shutting down stops accepting connections and gives the requests in
+// flight up to o.ShutdownTimeout to finish. Requests still running after
+// that have their context cancelled, so their calls to the number service
+// and retries stop, and their connections are closed; Serve then returns
+// an error so the process exits non-zero.
+func (o Options) Serve(ctx context.Context, name string, srv *http.Server, ln net.Listener) error {
+	base, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	srv.BaseContext = func(net.Listener) context.Context { return base }
+
+	errc := make(chan error, 1)
+	go func() {
+		if o.TLS != nil {
+			srv.TLSConfig = o.TLS.config()
+			log.Printf("[%s] Listening on %s (TLS)", name, ln.Addr())
+			errc <- srv.ServeTLS(ln, "", "")
+			return
+		}
+		log.Printf("[%s] Listening on %s", name, ln.Addr())
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("[%s] Shutting down, draining in-flight requests for up to %s", name, o.ShutdownTimeout)
+	start := time.Now()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), o.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		log.Printf("[%s] Requests still in flight after %s, cancelling them", name, o.ShutdownTimeout)
+		cancelBase()
+		srv.Close()
+		<-errc
+		return fmt.Errorf("in-flight requests didn't finish within %s", o.ShutdownTimeout)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Printf("[%s] Drained in %s, stopped", name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// unixPrefix marks a listen address as a Unix socket path.
+const unixPrefix = "unix://"
+
+// ParseAddr splits a listen address, either host:port or unix:///path, into
+// its network and address.
+func ParseAddr(addr string) (network, address string, err error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		if path == "" {
+			return "", "", fmt.Errorf("missing socket path in %q", addr)
+		}
+		return "unix", path, nil
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return "", "", err
+	}
+	return "tcp", addr, nil
+}
+
+// Listen listens on addr. A Unix socket is created with o.SocketMode; a
+// socket file left behind by a process that died is replaced, but one some
+// process still accepts on is an error.
+func (o Options) Listen(addr string) (net.Listener, error) {
+	network, address, err := ParseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+
+	if fi, err := os.Lstat(address); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", address)
+		}
+		if conn, err := net.Dial("unix", address); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", address)
+		}
+		if err := os.Remove(address); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(address, o.SocketMode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
@@ -0,0 +1,282 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// serve runs handler with o on addr until stop is called or the test ends
+// and returns the address listened on.
+func serve(t *testing.T, o Options, addr string, handler http.Handler) (listening string, stop func()) {
+	t.Helper()
+	ln, err := o.Listen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- o.Serve(ctx, "test", o.Server(handler), ln) }()
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("Serve: %v", err)
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return ln.Addr().String(), stop
+}
+
+func TestSlowHeadersTimeOut(t *testing.T) {
+	o := Options{ReadHeaderTimeout: 100 * time.Millisecond, ShutdownTimeout: time.Second}
+	addr, _ := serve(t, o, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler reached with incomplete headers")
+	}))
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Send the request line and one header, then stall.
+	if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	conn.SetReadDeadline(start.Add(5 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("connection not closed by the server: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("connection closed after %s, want about the 100ms header timeout", elapsed)
+	}
+}
+
+func TestCompleteHeadersWithinTimeout(t *testing.T) {
+	o := Options{ReadHeaderTimeout: 100 * time.Millisecond, ShutdownTimeout: time.Second}
+	addr, _ := serve(t, o, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status %d, want 204", resp.StatusCode)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	o := Options{ShutdownTimeout: time.Second, SocketMode: 0o600}
+	_, stop := serve(t, o, "unix://"+path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "over the socket")
+	}))
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0o600 {
+		t.Errorf("socket file mode %s, want a socket with 0600", fi.Mode())
+	}
+
+	// The host in the URL is ignored; every connection goes to the socket.
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://avgcalc/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "over the socket" {
+		t.Errorf("status %d: %q", resp.StatusCode, body)
+	}
+	client.CloseIdleConnections()
+
+	stop()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file left after shutdown: %v", err)
+	}
+}
+
+func TestListenUnixSocketInTheWay(t *testing.T) {
+	dir := t.TempDir()
+	o := Options{SocketMode: 0o660}
+
+	// A socket left by a process that died is replaced.
+	stale := filepath.Join(dir, "stale.sock")
+	ln, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if ln, err := o.Listen("unix://" + stale); err != nil {
+		t.Errorf("stale socket: %v", err)
+	} else {
+		ln.Close()
+	}
+
+	// One still accepting connections is not.
+	busy := filepath.Join(dir, "busy.sock")
+	ln, err = net.Listen("unix", busy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if ln, err := o.Listen("unix://" + busy); err == nil {
+		ln.Close()
+		t.Error("listened on a socket in use")
+	}
+
+	// Nor is a file that isn't a socket.
+	file := filepath.Join(dir, "file.sock")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ln, err := o.Listen("unix://" + file); err == nil {
+		ln.Close()
+		t.Error("listened over a regular file")
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("regular file removed: %v", err)
+	}
+}
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		addr, network, address string
+		ok                     bool
+	}{
+		{":9877", "tcp", ":9877", true},
+		{"127.0.0.1:8080", "tcp", "127.0.0.1:8080", true},
+		{"unix:///var/run/avgcalc.sock", "unix", "/var/run/avgcalc.sock", true},
+		{"unix://", "", "", false},
+		{"9877", "", "", false},
+	}
+	for _, tt := range tests {
+		network, address, err := ParseAddr(tt.addr)
+		if (err == nil) != tt.ok || network != tt.network || address != tt.address {
+			t.Errorf("ParseAddr(%q) = %q, %q, %v", tt.addr, network, address, err)
+		}
+	}
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	o := Options{ShutdownTimeout: 5 * time.Second}
+	ln, err := o.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	srv := o.Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		if r.Context().Err() != nil {
+			t.Error("draining request cancelled")
+		}
+		io.WriteString(w, "finished")
+	}))
+	done := make(chan error, 1)
+	go func() { done <- o.Serve(ctx, "test", srv, ln) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	got := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			got <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		got <- result{string(body), err}
+	}()
+
+	<-started
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-got; r.err != nil || r.body != "finished" {
+		t.Errorf("slow request during shutdown: %q, %v", r.body, r.err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Serve after draining: %v", err)
+	}
+	if _, err := http.Get("http://" + ln.Addr().String()); err == nil {
+		t.Error("server still accepting after shutdown")
+	}
+}
+
+func TestShutdownCancelsRequestsPastTimeout(t *testing.T) {
+	o := Options{ShutdownTimeout: 100 * time.Millisecond}
+	ln, err := o.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	srv := o.Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- o.Serve(ctx, "test", srv, ln) }()
+
+	go func() {
+		if resp, err := http.Get("http://" + ln.Addr().String()); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("request outlasting the drain timeout never cancelled")
+	}
+	if err := <-done; err == nil {
+		t.Error("Serve returned nil after requests outlasted the drain timeout")
+	}
+}
@@ -0,0 +1,113 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// Options configures the services' servers. The timeouts and header cap
+// bound how long and how much a client may send, so a slow or oversized
+// request can't hold a connection indefinitely. WriteTimeout must leave
+// room for the slowest handler, a waited-for forced refresh.
+type Options struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	// ShutdownTimeout is how long in-flight requests get to finish once
+	// the server has been asked to stop.
+	ShutdownTimeout time.Duration
+
+	// SocketMode is the permission of Unix sockets created by Listen.
+	SocketMode os.FileMode
+
+	// TLS, if set, is the certificate the servers are served with over
+	// HTTPS instead of plain HTTP.
+	TLS *Certificate
+}
+
+// LoadOptions reads the server options from src:
+//
+//	HTTP_READ_HEADER_TIMEOUT  default 5s
+//	HTTP_READ_TIMEOUT         default 30s
+//	HTTP_WRITE_TIMEOUT        default 2m
+//	HTTP_IDLE_TIMEOUT         default 2m
+//	HTTP_MAX_HEADER_BYTES     default 64 KiB
+//	HTTP_SHUTDOWN_TIMEOUT     default 10s
+//	HTTP_SOCKET_MODE          octal, default 0660
+//	TLS_CERT_FILE, TLS_KEY_FILE  PEM key pair to serve HTTPS with
+func LoadOptions(src *config.Source) (Options, error) {
+	o := Options{
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      2 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    64 << 10,
+		ShutdownTimeout:   10 * time.Second,
+		SocketMode:        0o660,
+	}
+
+	durations := []struct {
+		env string
+		d   *time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", &o.ReadHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", &o.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", &o.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", &o.IdleTimeout},
+		{"HTTP_SHUTDOWN_TIMEOUT", &o.ShutdownTimeout},
+	}
+	for _, s := range durations {
+		raw := src.Get(s.env)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return Options{}, fmt.Errorf("%s must be a positive duration, got %q", src.Name(s.env), raw)
+		}
+		*s.d = d
+	}
+
+	if raw := src.Get("HTTP_MAX_HEADER_BYTES"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return Options{}, fmt.Errorf("%s must be a positive integer, got %q", src.Name("HTTP_MAX_HEADER_BYTES"), raw)
+		}
+		o.MaxHeaderBytes = n
+	}
+
+	if raw := src.Get("HTTP_SOCKET_MODE"); raw != "" {
+		mode, err := strconv.ParseUint(raw, 8, 32)
+		if err != nil || mode > 0o777 {
+			return Options{}, fmt.Errorf("%s must be an octal permission such as 0660, got %q", src.Name("HTTP_SOCKET_MODE"), raw)
+		}
+		o.SocketMode = os.FileMode(mode)
+	}
+
+	cert, err := loadCertificate(src)
+	if err != nil {
+		return Options{}, err
+	}
+	o.TLS = cert
+	return o, nil
+}
+
+// Server returns a server for handler with the options applied.
+func (o Options) Server(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: o.ReadHeaderTimeout,
+		ReadTimeout:       o.ReadTimeout,
+		WriteTimeout:      o.WriteTimeout,
+		IdleTimeout:       o.IdleTimeout,
+		MaxHeaderBytes:    o.MaxHeaderBytes,
+	}
+}
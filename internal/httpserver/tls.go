@@ -0,0 +1,124 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// Certificate is the key pair a server presents, read from TLS_CERT_FILE
+// and TLS_KEY_FILE. It is read again on SIGHUP, so a renewed certificate
+// takes effect without a restart; connections already open keep the old
+// one.
+type Certificate struct {
+	certFile, keyFile string
+	current           atomic.Pointer[tls.Certificate]
+}
+
+// certificates are every Certificate loaded, for ReloadCertificates.
+var (
+	certificatesMu sync.Mutex
+	certificates   []*Certificate
+)
+
+func loadCertificate(src *config.Source) (*Certificate, error) {
+	certFile, keyFile := src.Get("TLS_CERT_FILE"), src.Get("TLS_KEY_FILE")
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("%s and %s must be set together", src.Name("TLS_CERT_FILE"), src.Name("TLS_KEY_FILE"))
+	}
+	c := &Certificate{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, fmt.Errorf("%s and %s: %w", src.Name("TLS_CERT_FILE"), src.Name("TLS_KEY_FILE"), err)
+	}
+	certificatesMu.Lock()
+	certificates = append(certificates, c)
+	certificatesMu.Unlock()
+	return c, nil
+}
+
+func (c *Certificate) reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+	c.current.Store(&cert)
+	return nil
+}
+
+// ReloadCertificates reads every server's certificate again. One that
+// fails to load keeps the certificate it had. It takes the reloaded
+// configuration only to fit in with the services' reloaders; the file
+// paths are the ones the servers started with.
+func ReloadCertificates(*config.Source) error {
+	certificatesMu.Lock()
+	defer certificatesMu.Unlock()
+	var errs []error
+	for _, c := range certificates {
+		if err := c.reload(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.certFile, err))
+			continue
+		}
+		log.Printf("[http] Reloaded TLS certificate %s", c.certFile)
+	}
+	return errors.Join(errs...)
+}
+
+// config is a TLS configuration for modern clients: TLS 1.2 or later and,
+// for 1.2, only forward-secret AEAD cipher suites. TLS 1.3 suites aren't
+// configurable and are all sound.
+func (c *Certificate) config() *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.current.Load(), nil
+		},
+	}
+}
+
+// Redirect serves plain HTTP on addr until ctx is cancelled, redirecting
+// every request to the same URL over HTTPS on httpsPort.
+func (o Options) Redirect(ctx context.Context, name, addr, httpsPort string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		ReadHeaderTimeout: o.ReadHeaderTimeout,
+		IdleTimeout:       o.IdleTimeout,
+		MaxHeaderBytes:    o.MaxHeaderBytes,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if httpsPort != "443" {
+				host = net.JoinHostPort(host, httpsPort)
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	plain := o
+	plain.TLS = nil
+	return plain.Serve(ctx, name+" redirect", srv, ln)
+}
@@ -0,0 +1,151 @@
+package httpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// writeCertificate writes a self-signed key pair for 127.0.0.1 with serial
+// to dir and returns the file paths and the certificate.
+func writeCertificate(t *testing.T, dir string, serial int64) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+// tlsOptions loads the options with the key pair in certFile and keyFile.
+func tlsOptions(t *testing.T, certFile, keyFile string) (Options, error) {
+	t.Helper()
+	src := config.FromEnv()
+	for name, value := range map[string]string{"TLS_CERT_FILE": certFile, "TLS_KEY_FILE": keyFile} {
+		if err := src.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return LoadOptions(src)
+}
+
+// clientTrusting returns a client trusting only certs, with no connections
+// kept between requests.
+func clientTrusting(maxVersion uint16, certs ...*x509.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, MaxVersion: maxVersion},
+		DisableKeepAlives: true,
+	}}
+}
+
+func TestServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, first := writeCertificate(t, dir, 1)
+	o, err := tlsOptions(t, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.ShutdownTimeout = time.Second
+	addr, _ := serve(t, o, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure")
+	}))
+	url := "https://" + addr + "/"
+
+	resp, err := clientTrusting(0, first).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "secure" {
+		t.Errorf("status %d: %q", resp.StatusCode, body)
+	}
+	if resp.TLS == nil || resp.TLS.PeerCertificates[0].SerialNumber.Int64() != 1 {
+		t.Errorf("served without the configured certificate")
+	}
+
+	if _, err := clientTrusting(tls.VersionTLS11, first).Get(url); err == nil {
+		t.Error("TLS 1.1 client accepted")
+	}
+	if resp, err := http.Get("http://" + addr + "/"); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Error("plain HTTP served")
+		}
+	}
+
+	// A renewed certificate is served to new connections once reloaded.
+	_, _, second := writeCertificate(t, dir, 2)
+	if err := o.TLS.reload(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = clientTrusting(0, first, second).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if serial := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 2 {
+		t.Errorf("serial %d served after reloading, want the renewed 2", serial)
+	}
+}
+
+func TestLoadTLSErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCertificate(t, dir, 1)
+	for _, files := range [][2]string{
+		{certFile, ""},
+		{"", keyFile},
+		{certFile, filepath.Join(dir, "missing.pem")},
+		{keyFile, certFile},
+	} {
+		if _, err := tlsOptions(t, files[0], files[1]); err == nil {
+			t.Errorf("TLS_CERT_FILE=%q TLS_KEY_FILE=%q loaded, want an error", files[0], files[1])
+		}
+	}
+}
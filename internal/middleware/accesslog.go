@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math"
+	mathrand "math/rand"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// RequestIDHeader carries the request ID, taken from the client when it
+// sends a sane one and generated otherwise.
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	requestIDKey = "middleware.requestID"
+	logFieldsKey = "middleware.logFields"
+)
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// sensitiveParams are query parameters whose values never reach the log.
+var sensitiveParams = map[string]bool{
+	"token":         true,
+	"access_token":  true,
+	"api_key":       true,
+	"apikey":        true,
+	"key":           true,
+	"authorization": true,
+}
+
+var bearerToken = regexp.MustCompile(`(?i)(bearer\s+)\S+`)
+
+// AccessLog writes one JSON line per request through the logger, at info
+// level, or error for a 5xx.
+type AccessLog struct {
+	sample2xx float64
+	logger    *slog.Logger
+}
+
+// LoadAccessLog reads ACCESS_LOG_SAMPLE_2XX from src: the fraction of
+// successful responses to log, 1 by default. Every other response is
+// always logged.
+func LoadAccessLog(src *config.Source, logger *slog.Logger) (AccessLog, error) {
+	a := AccessLog{sample2xx: 1, logger: logger}
+	if raw := src.Get("ACCESS_LOG_SAMPLE_2XX"); raw != "" {
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil || f < 0 || f > 1 {
+			return AccessLog{}, fmt.Errorf("%s must be a number between 0 and 1, got %q", src.Name("ACCESS_LOG_SAMPLE_2XX"), raw)
+		}
+		a.sample2xx = f
+	}
+	return a, nil
+}
+
+// Handler assigns the request ID and logs the request once it is done.
+// Client addresses are resolved through the filter's trusted proxies.
+func (a AccessLog) Handler(service string, filter IPFilter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDContextKey{}, id))
+		c.Header(RequestIDHeader, id)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < 300 && a.sample2xx < 1 && mathrand.Float64() >= a.sample2xx {
+			return
+		}
+
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		ctx := c.Request.Context()
+		if !a.logger.Enabled(ctx, level) {
+			return
+		}
+
+		// The line is stamped with when the request started.
+		record := slog.NewRecord(start.UTC(), level, "Request", 0)
+		record.AddAttrs(
+			slog.String("service", service),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path))
+		if query := scrubQuery(c.Request.URL.RawQuery); query != "" {
+			record.AddAttrs(slog.String("query", query))
+		}
+		if route := c.FullPath(); route != "" {
+			record.AddAttrs(slog.String("route", route))
+		}
+		record.AddAttrs(
+			slog.Int("status", status),
+			slog.Float64("latencyMs", math.Round(float64(time.Since(start).Microseconds()))/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)))
+		clientIP := ""
+		if addr, ok := filter.clientAddr(c.Request); ok {
+			clientIP = addr.String()
+		}
+		record.AddAttrs(slog.String("clientIp", clientIP), slog.String("requestId", id))
+		if fields, ok := c.Get(logFieldsKey); ok {
+			m := fields.(map[string]interface{})
+			for k, v := range m {
+				if s, ok := v.(string); ok {
+					m[k] = scrubValue(s)
+				}
+			}
+			record.AddAttrs(slog.Any("fields", m))
+		}
+		a.logger.Handler().Handle(ctx, record)
+	}
+}
+
+// LogField adds a field to the request's access log line.
+func LogField(c *gin.Context, key string, value interface{}) {
+	fields, _ := c.Get(logFieldsKey)
+	m, ok := fields.(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+		c.Set(logFieldsKey, m)
+	}
+	m[key] = value
+}
+
+// RequestID returns the request's ID, or "" outside AccessLog.
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+type requestIDContextKey struct{}
+
+// RequestIDFrom returns the ID of the request ctx was derived from, or ""
+// outside AccessLog. It is how calls made for a request are tagged with it.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// newRequestID returns a random (version 4) UUID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// scrubQuery redacts the values of sensitive parameters and bearer tokens
+// in a raw query string, keeping the rest as sent.
+func scrubQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	parts := strings.Split(raw, "&")
+	for i, part := range parts {
+		key, value, found := strings.Cut(part, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if !found {
+			continue
+		}
+		if sensitiveParams[strings.ToLower(name)] {
+			parts[i] = key + "=[REDACTED]"
+			continue
+		}
+		if decoded, err := url.QueryUnescape(value); err == nil && bearerToken.MatchString(decoded) {
+			parts[i] = key + "=" + scrubValue(decoded)
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func scrubValue(s string) string {
+	return bearerToken.ReplaceAllString(s, "${1}[REDACTED]")
+}
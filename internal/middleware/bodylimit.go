@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// defaultMaxBodyBytes is generous for APIs that take at most a small JSON
+// body.
+const defaultMaxBodyBytes = 1 << 20
+
+// BodyLimit caps the size of request bodies.
+type BodyLimit struct {
+	max int64
+}
+
+// LoadBodyLimit reads HTTP_MAX_BODY_BYTES from src, 1 MiB by default.
+func LoadBodyLimit(src *config.Source) (BodyLimit, error) {
+	b := BodyLimit{max: defaultMaxBodyBytes}
+	if raw := src.Get("HTTP_MAX_BODY_BYTES"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 1 {
+			return BodyLimit{}, fmt.Errorf("%s must be a positive integer, got %q", src.Name("HTTP_MAX_BODY_BYTES"), raw)
+		}
+		b.max = n
+	}
+	return b, nil
+}
+
+// Handler answers 413 to a request whose declared length is over the limit
+// and cuts off any other body once it grows past it, so handlers that read
+// the body get an error rather than an unbounded stream.
+func (b BodyLimit) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > b.max {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, b.max)
+		c.Next()
+	}
+}
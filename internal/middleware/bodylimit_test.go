@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// limited posts body to a router capped at max bytes whose handler reads
+// the whole body, answering 400 if that fails.
+func limited(t *testing.T, max string, body io.Reader, length int64) *httptest.ResponseRecorder {
+	t.Helper()
+	src := config.FromEnv()
+	if err := src.Set("HTTP_MAX_BODY_BYTES", max); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBodyLimit(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(b.Handler())
+	r.POST("/", func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			var tooLarge *http.MaxBytesError
+			if !errors.As(err, &tooLarge) {
+				t.Errorf("reading the body: %v, want a MaxBytesError", err)
+			}
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.ContentLength = length
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestBodyLimit(t *testing.T) {
+	if w := limited(t, "16", strings.NewReader(`{"n":1}`), 7); w.Code != http.StatusOK {
+		t.Errorf("small body: status %d, want 200", w.Code)
+	}
+
+	w := limited(t, "16", strings.NewReader(strings.Repeat("x", 17)), 17)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized body: status %d, want 413", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"error"`) {
+		t.Errorf("413 body %s, want the error envelope", w.Body)
+	}
+
+	// A body of unknown length is cut off once it passes the limit.
+	if w := limited(t, "16", strings.NewReader(strings.Repeat("x", 1<<10)), -1); w.Code != http.StatusBadRequest {
+		t.Errorf("oversized body of unknown length: status %d, want the handler's read to fail", w.Code)
+	}
+}
+
+func TestLoadBodyLimitErrors(t *testing.T) {
+	for _, raw := range []string{"0", "-1", "lots"} {
+		src := config.FromEnv()
+		if err := src.Set("HTTP_MAX_BODY_BYTES", raw); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadBodyLimit(src); err == nil {
+			t.Errorf("HTTP_MAX_BODY_BYTES=%s loaded, want an error", raw)
+		}
+	}
+}
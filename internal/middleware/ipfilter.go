@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// healthPaths are the routes IP_FILTER_EXEMPT_HEALTH lets through.
+var healthPaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// IPFilter admits requests by client address.
+type IPFilter struct {
+	allow        []netip.Prefix
+	deny         []netip.Prefix
+	trusted      []netip.Prefix
+	exemptHealth bool
+}
+
+// LoadIPFilter reads the client address rules from src. Each list is a
+// comma-separated set of CIDR ranges or single addresses:
+//
+//	IP_ALLOWLIST             only these clients are served, when set
+//	IP_DENYLIST              these clients are refused, even if allowed
+//	TRUSTED_PROXIES          proxies whose X-Forwarded-For is believed
+//	IP_FILTER_EXEMPT_HEALTH  serves /healthz to everyone when true
+func LoadIPFilter(src *config.Source) (IPFilter, error) {
+	var f IPFilter
+	var err error
+	if f.allow, err = parsePrefixes(src, "IP_ALLOWLIST"); err != nil {
+		return IPFilter{}, err
+	}
+	if f.deny, err = parsePrefixes(src, "IP_DENYLIST"); err != nil {
+		return IPFilter{}, err
+	}
+	if f.trusted, err = parsePrefixes(src, "TRUSTED_PROXIES"); err != nil {
+		return IPFilter{}, err
+	}
+	if raw := src.Get("IP_FILTER_EXEMPT_HEALTH"); raw != "" {
+		if f.exemptHealth, err = strconv.ParseBool(raw); err != nil {
+			return IPFilter{}, fmt.Errorf("%s must be a boolean, got %q", src.Name("IP_FILTER_EXEMPT_HEALTH"), raw)
+		}
+	}
+	return f, nil
+}
+
+func parsePrefixes(src *config.Source, env string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, raw := range strings.Split(src.Get(env), ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "/") {
+			addr, err := netip.ParseAddr(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%s must list CIDR ranges or addresses, got %q", src.Name(env), raw)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must list CIDR ranges or addresses, got %q", src.Name(env), raw)
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Handler refuses clients outside the allowlist or inside the denylist
+// with 403. Deny wins over allow.
+func (f IPFilter) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(f.allow) == 0 && len(f.deny) == 0 {
+			c.Next()
+			return
+		}
+		if f.exemptHealth && healthPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		addr, ok := f.clientAddr(c.Request)
+		if !ok || !f.admits(addr) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (f IPFilter) admits(addr netip.Addr) bool {
+	if contains(f.deny, addr) {
+		return false
+	}
+	return len(f.allow) == 0 || contains(f.allow, addr)
+}
+
+// clientAddr is the address of the client that made r. X-Forwarded-For is
+// only consulted when the peer is a trusted proxy, and then read from the
+// right, skipping further trusted proxies, since anything to the left of
+// the first untrusted hop may have been written by the client itself.
+func (f IPFilter) clientAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+
+	if !contains(f.trusted, addr) {
+		return addr, true
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A garbled entry ends the chain we can vouch for.
+			return addr, true
+		}
+		addr = hop.Unmap()
+		if !contains(f.trusted, addr) {
+			return addr, true
+		}
+	}
+	return addr, true
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// newIPFilter loads a filter from settings, given as name/value pairs.
+func newIPFilter(t *testing.T, settings ...string) IPFilter {
+	t.Helper()
+	src := config.FromEnv()
+	for i := 0; i < len(settings); i += 2 {
+		if err := src.Set(settings[i], settings[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := LoadIPFilter(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// filtered is the status f gives a request for path from remoteAddr with
+// the given X-Forwarded-For.
+func filtered(f IPFilter, remoteAddr, forwardedFor, path string) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(f.Handler())
+	r.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestIPFilter(t *testing.T) {
+	rules := newIPFilter(t,
+		"IP_ALLOWLIST", "10.0.0.0/8, 192.0.2.7, 2001:db8::/32",
+		"IP_DENYLIST", "10.1.0.0/16, 2001:db8:bad::/48",
+		"TRUSTED_PROXIES", "127.0.0.1, ::1")
+
+	tests := []struct {
+		name         string
+		filter       IPFilter
+		remoteAddr   string
+		forwardedFor string
+		path         string
+		status       int
+	}{
+		{"no rules", IPFilter{}, "203.0.113.1:1234", "", "/", http.StatusOK},
+		{"IPv4 in an allowed range", rules, "10.2.3.4:1234", "", "/", http.StatusOK},
+		{"allowed single IPv4", rules, "192.0.2.7:1234", "", "/", http.StatusOK},
+		{"IPv4 next to the single address", rules, "192.0.2.8:1234", "", "/", http.StatusForbidden},
+		{"IPv4 outside the allowlist", rules, "203.0.113.1:1234", "", "/", http.StatusForbidden},
+		{"IPv4 denied within an allowed range", rules, "10.1.2.3:1234", "", "/", http.StatusForbidden},
+		{"IPv6 in an allowed range", rules, "[2001:db8:1::5]:1234", "", "/", http.StatusOK},
+		{"IPv6 denied within an allowed range", rules, "[2001:db8:bad::5]:1234", "", "/", http.StatusForbidden},
+		{"IPv6 outside the allowlist", rules, "[2001:db9::1]:1234", "", "/", http.StatusForbidden},
+		{"IPv4-mapped IPv6", rules, "[::ffff:10.2.3.4]:1234", "", "/", http.StatusOK},
+		{"IPv4-mapped IPv6 denied", rules, "[::ffff:10.1.2.3]:1234", "", "/", http.StatusForbidden},
+		{"unparseable peer", rules, "somewhere", "", "/", http.StatusForbidden},
+
+		{"spoofed header from an untrusted peer", rules, "203.0.113.1:1234", "10.2.3.4", "/", http.StatusForbidden},
+		{"header ignored from an untrusted allowed peer", rules, "10.2.3.4:1234", "203.0.113.1", "/", http.StatusOK},
+		{"client behind a trusted proxy", rules, "127.0.0.1:1234", "10.2.3.4", "/", http.StatusOK},
+		{"denied client behind a trusted proxy", rules, "127.0.0.1:1234", "10.1.2.3", "/", http.StatusForbidden},
+		{"client behind two trusted proxies", rules, "[::1]:1234", "10.2.3.4, 127.0.0.1", "/", http.StatusOK},
+		{"spoofed hop left of the real client", rules, "127.0.0.1:1234", "10.2.3.4, 203.0.113.1", "/", http.StatusForbidden},
+		{"garbled hop ends the chain", rules, "127.0.0.1:1234", "10.2.3.4, junk", "/", http.StatusForbidden},
+
+		{"health not exempt by default", rules, "203.0.113.1:1234", "", "/healthz", http.StatusForbidden},
+		{"denylist alone", newIPFilter(t, "IP_DENYLIST", "203.0.113.0/24"), "198.51.100.1:1234", "", "/", http.StatusOK},
+		{"denylist alone refusing", newIPFilter(t, "IP_DENYLIST", "203.0.113.0/24"), "203.0.113.9:1234", "", "/", http.StatusForbidden},
+		{"exempt health", newIPFilter(t, "IP_ALLOWLIST", "10.0.0.0/8", "IP_FILTER_EXEMPT_HEALTH", "true"), "203.0.113.1:1234", "", "/healthz", http.StatusOK},
+		{"exempt health only", newIPFilter(t, "IP_ALLOWLIST", "10.0.0.0/8", "IP_FILTER_EXEMPT_HEALTH", "true"), "203.0.113.1:1234", "", "/", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := filtered(tt.filter, tt.remoteAddr, tt.forwardedFor, tt.path); got != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.status)
+		}
+	}
+}
+
+func TestLoadIPFilterErrors(t *testing.T) {
+	for _, settings := range [][]string{
+		{"IP_ALLOWLIST", "10.0.0.0/33"},
+		{"IP_DENYLIST", "not-an-address"},
+		{"TRUSTED_PROXIES", "10.0.0.1/8/2"},
+		{"IP_FILTER_EXEMPT_HEALTH", "sometimes"},
+	} {
+		src := config.FromEnv()
+		if err := src.Set(settings[0], settings[1]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadIPFilter(src); err == nil {
+			t.Errorf("%s=%s loaded, want an error", settings[0], settings[1])
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+const defaultMaintenanceMessage = "Service is under maintenance"
+
+// MaintenanceState is whether maintenance mode is on and what clients are
+// told while it is.
+type MaintenanceState struct {
+	Enabled       bool       `json:"enabled"`
+	Message       string     `json:"message,omitempty"`
+	RetryAfterSec int        `json:"retryAfterSec,omitempty"`
+	Since         *time.Time `json:"since,omitempty"`
+}
+
+// Maintenance is a switch that takes a service's data endpoints soft down
+// without stopping the process.
+type Maintenance struct {
+	mu    sync.RWMutex
+	state MaintenanceState
+}
+
+// maintenance is shared by every service in the process, so taking the
+// APIs down for a migration covers both when they run together.
+var maintenance = &Maintenance{}
+
+// loadMaintenance applies the startup maintenance settings from src:
+//
+//	MAINTENANCE_ENABLED      starts in maintenance mode when true
+//	MAINTENANCE_MESSAGE      the error returned to clients
+//	MAINTENANCE_RETRY_AFTER  sent as Retry-After, e.g. 2m
+func loadMaintenance(src *config.Source) (*Maintenance, error) {
+	var state MaintenanceState
+	if raw := src.Get("MAINTENANCE_ENABLED"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a boolean, got %q", src.Name("MAINTENANCE_ENABLED"), raw)
+		}
+		state.Enabled = enabled
+	}
+	state.Message = src.Get("MAINTENANCE_MESSAGE")
+	if raw := src.Get("MAINTENANCE_RETRY_AFTER"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("%s must be a duration of at least 1s, got %q", src.Name("MAINTENANCE_RETRY_AFTER"), raw)
+		}
+		state.RetryAfterSec = int(d.Seconds())
+	}
+
+	if state.Enabled {
+		maintenance.Set(state)
+	}
+	return maintenance, nil
+}
+
+// State returns the current maintenance state.
+func (m *Maintenance) State() MaintenanceState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// Set replaces the maintenance state, stamping when it was enabled.
+func (m *Maintenance) Set(state MaintenanceState) MaintenanceState {
+	if state.Enabled {
+		if state.Since == nil {
+			now := time.Now()
+			state.Since = &now
+		}
+	} else {
+		state = MaintenanceState{}
+	}
+
+	m.mu.Lock()
+	m.state = state
+	m.mu.Unlock()
+	return state
+}
+
+// exemptFromMaintenance reports whether path keeps working in maintenance
+// mode: health, metrics, version and the admin API.
+func exemptFromMaintenance(path string) bool {
+	return healthPaths[path] || path == "/metrics" || path == "/version" ||
+		path == "/admin" || strings.HasPrefix(path, "/admin/")
+}
+
+// Handler answers 503 with the maintenance message to every request but
+// the exempt routes while maintenance mode is on.
+func (m *Maintenance) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := m.State()
+		if !state.Enabled || exemptFromMaintenance(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		message := state.Message
+		if message == "" {
+			message = defaultMaintenanceMessage
+		}
+		if state.RetryAfterSec > 0 {
+			c.Header("Retry-After", strconv.Itoa(state.RetryAfterSec))
+		}
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": message})
+	}
+}
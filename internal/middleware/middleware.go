@@ -0,0 +1,95 @@
+// Package middleware holds the Gin middleware shared by both services.
+// Each middleware is configured from the http section of the settings.
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/internal/errreport"
+)
+
+// Config is the configuration of every shared middleware.
+type Config struct {
+	// Logger writes the access log and the services' structured logs.
+	Logger *slog.Logger
+
+	AccessLog   AccessLog
+	Recorder    Recorder
+	Security    Security
+	IPFilter    IPFilter
+	Maintenance *Maintenance
+	Shedder     Shedder
+	BodyLimit   BodyLimit
+
+	// Reporter receives handler panics, and whatever else the service
+	// chooses to report.
+	Reporter errreport.Reporter
+}
+
+// Load reads the http section of src.
+func Load(src *config.Source) (Config, error) {
+	var cfg Config
+	var err error
+	if cfg.Reporter, err = errreport.Load(src); err != nil {
+		return Config{}, err
+	}
+	if cfg.Logger, err = LoadLogger(src); err != nil {
+		return Config{}, err
+	}
+	if cfg.AccessLog, err = LoadAccessLog(src, cfg.Logger); err != nil {
+		return Config{}, err
+	}
+	if cfg.Recorder, err = LoadRecorder(src); err != nil {
+		return Config{}, err
+	}
+	if cfg.Security, err = LoadSecurity(src); err != nil {
+		return Config{}, err
+	}
+	if cfg.IPFilter, err = LoadIPFilter(src); err != nil {
+		return Config{}, err
+	}
+	if cfg.Maintenance, err = loadMaintenance(src); err != nil {
+		return Config{}, err
+	}
+	if cfg.Shedder, err = LoadShedder(src); err != nil {
+		return Config{}, err
+	}
+	if cfg.BodyLimit, err = LoadBodyLimit(src); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Chain is the middleware every route of service runs, in order. The
+// access log wraps panic recovery so crashed requests are logged as 500s,
+// and security headers come next so rejections carry them too.
+func (c Config) Chain(service string) []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		c.AccessLog.Handler(service, c.IPFilter),
+		c.Recorder.Handler(service),
+		c.recovery(service),
+		c.Security.Headers(),
+		c.IPFilter.Handler(),
+		c.Maintenance.Handler(),
+		c.Shedder.Handler(service),
+		c.BodyLimit.Handler(),
+	}
+}
+
+// recovery turns a handler panic into a 500 and reports it.
+func (c Config) recovery(service string) gin.HandlerFunc {
+	return gin.CustomRecovery(func(ctx *gin.Context, recovered interface{}) {
+		c.Reporter.Report(ctx.Request.Context(), fmt.Errorf("panic: %v", recovered), map[string]string{
+			"service":   service,
+			"route":     ctx.FullPath(),
+			"method":    ctx.Request.Method,
+			"requestId": RequestID(ctx),
+		})
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "requestId": RequestID(ctx)})
+	})
+}
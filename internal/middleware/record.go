@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/internal/recording"
+)
+
+// maxRecordedBody caps how much of each body a recording keeps.
+const maxRecordedBody = 1 << 20
+
+// sensitiveHeaders never reach a recording.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// Recorder saves every exchange to a directory for replay with
+// recordingtest.Replay. It is a debugging aid and off unless RECORD_DIR is set.
+type Recorder struct {
+	dir string
+}
+
+// LoadRecorder reads RECORD_DIR from src, creating the directory.
+func LoadRecorder(src *config.Source) (Recorder, error) {
+	dir := src.Get("RECORD_DIR")
+	if dir == "" {
+		return Recorder{}, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Recorder{}, fmt.Errorf("%s: %v", src.Name("RECORD_DIR"), err)
+	}
+	log.Printf("WARNING: RECORD_DIR is set, every request and response is being written to %s", dir)
+	return Recorder{dir: dir}, nil
+}
+
+// recordingWriter keeps a copy of the response body as it is written.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if room := maxRecordedBody - w.body.Len(); room > 0 {
+		w.body.Write(p[:min(len(p), room)])
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Handler records the request once it is done, with credentials removed
+// from headers and the query.
+func (r Recorder) Handler(service string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r.dir == "" {
+			c.Next()
+			return
+		}
+
+		var reqBody []byte
+		if c.Request.Body != nil {
+			reqBody, _ = io.ReadAll(io.LimitReader(c.Request.Body, maxRecordedBody))
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(reqBody), c.Request.Body), c.Request.Body}
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		start := time.Now()
+		c.Next()
+
+		ex := recording.Exchange{
+			ID:      RequestID(c),
+			Time:    start,
+			Service: service,
+			Request: recording.Request{
+				Method:  c.Request.Method,
+				Path:    c.Request.URL.Path,
+				Query:   scrubQuery(c.Request.URL.RawQuery),
+				Headers: sanitizeHeaders(c.Request.Header),
+				Body:    string(reqBody),
+			},
+			Response: recording.Response{
+				Status:  w.Status(),
+				Headers: sanitizeHeaders(w.Header()),
+				Body:    w.body.String(),
+			},
+		}
+		if ex.ID == "" {
+			ex.ID = newRequestID()
+		}
+		if err := recording.Write(r.dir, ex); err != nil {
+			log.Printf("Failed to record request: %v", err)
+		}
+	}
+}
+
+func sanitizeHeaders(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range sensitiveHeaders {
+		h.Del(name)
+	}
+	return h
+}
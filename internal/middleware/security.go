@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// DashboardPolicy is the Content-Security-Policy for server-rendered HTML:
+// nothing may be loaded or framed, and only the page's own inline styles
+// apply.
+const DashboardPolicy = "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// Security is the set of protective headers sent on every response.
+type Security struct {
+	headers  map[string]string
+	disabled map[string]bool
+}
+
+// LoadSecurity reads the security header settings from src:
+//
+//	SECURITY_HEADERS_DISABLE  comma-separated header names not to send
+//	REFERRER_POLICY           Referrer-Policy value, default no-referrer
+//	HSTS_MAX_AGE              sends Strict-Transport-Security with this max-age;
+//	                          only set it when a proxy terminates TLS in front
+func LoadSecurity(src *config.Source) (Security, error) {
+	s := Security{
+		headers: map[string]string{
+			"X-Content-Type-Options": "nosniff",
+			"X-Frame-Options":        "DENY",
+			"Referrer-Policy":        "no-referrer",
+		},
+		disabled: map[string]bool{},
+	}
+
+	if raw := src.Get("REFERRER_POLICY"); raw != "" {
+		s.headers["Referrer-Policy"] = raw
+	}
+
+	if raw := src.Get("HSTS_MAX_AGE"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return Security{}, fmt.Errorf("%s must be a positive duration, got %q", src.Name("HSTS_MAX_AGE"), raw)
+		}
+		s.headers["Strict-Transport-Security"] = "max-age=" + strconv.Itoa(int(d.Seconds()))
+	}
+
+	for _, name := range strings.Split(src.Get("SECURITY_HEADERS_DISABLE"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		name = http.CanonicalHeaderKey(name)
+		if _, ok := s.headers[name]; !ok && name != "Content-Security-Policy" {
+			return Security{}, fmt.Errorf("%s lists unknown header %q", src.Name("SECURITY_HEADERS_DISABLE"), name)
+		}
+		s.disabled[name] = true
+		delete(s.headers, name)
+	}
+	return s, nil
+}
+
+// Headers sets the security headers before the handler runs, so they are
+// also on error responses, including the 500 written by Gin's recovery
+// middleware after a panic.
+func (s Security) Headers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		for name, value := range s.headers {
+			h.Set(name, value)
+		}
+		c.Next()
+	}
+}
+
+// ContentPolicy sets policy as the route's Content-Security-Policy unless
+// that header is disabled.
+func (s Security) ContentPolicy(policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.disabled["Content-Security-Policy"] {
+			c.Header("Content-Security-Policy", policy)
+		}
+		c.Next()
+	}
+}
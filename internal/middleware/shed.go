@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+var (
+	requestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "Requests being handled, per load shedding limiter.",
+	}, []string{"limiter"})
+
+	requestsQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_requests_queued",
+		Help: "Requests waiting for a free slot, per load shedding limiter.",
+	}, []string{"limiter"})
+
+	requestsShed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_shed_total",
+		Help: "Requests refused with 503 for lack of capacity, per limiter.",
+	}, []string{"limiter"})
+)
+
+// Collectors are the metrics of the shared middleware, for each service to
+// register with its own registry.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{requestsInFlight, requestsQueued, requestsShed}
+}
+
+// Shedder caps the number of requests handled at once. Requests over the
+// cap wait briefly for a slot in a bounded queue and are refused once the
+// queue is full or the wait runs out, so admitted requests keep their
+// latency under a burst instead of everything slowing down together.
+type Shedder struct {
+	maxInFlight  int
+	maxQueued    int
+	queueTimeout time.Duration
+}
+
+// LoadShedder reads the load shedding settings from src:
+//
+//	MAX_IN_FLIGHT  requests handled at once, default 256; 0 disables shedding
+//	MAX_QUEUED     requests waiting for a slot, default MAX_IN_FLIGHT
+//	QUEUE_TIMEOUT  how long a request may wait, default 100ms
+func LoadShedder(src *config.Source) (Shedder, error) {
+	s := Shedder{maxInFlight: 256, maxQueued: -1, queueTimeout: 100 * time.Millisecond}
+
+	if raw := src.Get("MAX_IN_FLIGHT"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return Shedder{}, fmt.Errorf("%s must be a non-negative integer, got %q", src.Name("MAX_IN_FLIGHT"), raw)
+		}
+		s.maxInFlight = n
+	}
+	if raw := src.Get("MAX_QUEUED"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return Shedder{}, fmt.Errorf("%s must be a non-negative integer, got %q", src.Name("MAX_QUEUED"), raw)
+		}
+		s.maxQueued = n
+	}
+	if s.maxQueued < 0 {
+		s.maxQueued = s.maxInFlight
+	}
+	if raw := src.Get("QUEUE_TIMEOUT"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Shedder{}, fmt.Errorf("%s must be a non-negative duration, got %q", src.Name("QUEUE_TIMEOUT"), raw)
+		}
+		s.queueTimeout = d
+	}
+	return s, nil
+}
+
+// Handler returns a limiter with its own slots, labelled name in the
+// metrics. A service uses one for all its routes, and may give a route
+// group a separate one so it can't starve the rest. Health checks and
+// metrics are never limited, so an overload stays observable.
+func (s Shedder) Handler(name string) gin.HandlerFunc {
+	if s.maxInFlight == 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	slots := make(chan struct{}, s.maxInFlight)
+	var queued atomic.Int64
+	inFlight := requestsInFlight.WithLabelValues(name)
+	waiting := requestsQueued.WithLabelValues(name)
+	shed := requestsShed.WithLabelValues(name)
+
+	refuse := func(c *gin.Context) {
+		shed.Inc()
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Server is overloaded, try again shortly"})
+	}
+
+	return func(c *gin.Context) {
+		if healthPaths[c.Request.URL.Path] || c.Request.URL.Path == "/metrics" {
+			c.Next()
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			if queued.Add(1) > int64(s.maxQueued) {
+				queued.Add(-1)
+				refuse(c)
+				return
+			}
+			waiting.Inc()
+			timer := time.NewTimer(s.queueTimeout)
+			var admitted bool
+			select {
+			case slots <- struct{}{}:
+				admitted = true
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+			}
+			timer.Stop()
+			queued.Add(-1)
+			waiting.Dec()
+			if !admitted {
+				refuse(c)
+				return
+			}
+		}
+
+		inFlight.Inc()
+		defer func() {
+			inFlight.Dec()
+			<-slots
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+// Package recording stores HTTP exchanges captured from live traffic, for
+// recordingtest to replay against a handler, so a refactor can be checked
+// against what the services actually answered before it.
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Exchange is one recorded request and the response it got.
+type Exchange struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Service  string    `json:"service"`
+	Request  Request   `json:"request"`
+	Response Response  `json:"response"`
+}
+
+type Request struct {
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Query   string      `json:"query,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Write saves ex in dir under a name that sorts in recording order.
+func Write(dir string, ex Exchange) error {
+	data, err := json.MarshalIndent(ex, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s-%s.json", ex.Time.UTC().Format("20060102T150405.000000000"), ex.Service, ex.ID)
+	return os.WriteFile(filepath.Join(dir, name), data, 0o644)
+}
@@ -0,0 +1,111 @@
+// Package recordingtest replays the exchanges package recording captured
+// against a handler and reports the responses that changed.
+package recordingtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Escanor244/713522IT013/internal/recording"
+)
+
+// Mismatch is a replayed response that differs from its recording.
+type Mismatch struct {
+	File   string
+	Reason string
+}
+
+func (m Mismatch) String() string {
+	return m.File + ": " + m.Reason
+}
+
+// Replay sends every exchange recorded in dir through h, in recording
+// order, and compares the status and body of each response with the
+// recorded one. JSON bodies are compared structurally after removing the
+// object keys named in ignore, at any depth, so volatile fields such as
+// timestamps and latencies don't count. Recordings carry no credentials,
+// so header is added to every request, typically with an Authorization.
+func Replay(h http.Handler, dir string, header http.Header, ignore ...string) ([]Mismatch, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	ignored := make(map[string]bool, len(ignore))
+	for _, key := range ignore {
+		ignored[key] = true
+	}
+
+	var mismatches []Mismatch
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var ex recording.Exchange
+		if err := json.Unmarshal(data, &ex); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		target := ex.Request.Path
+		if ex.Request.Query != "" {
+			target += "?" + ex.Request.Query
+		}
+		req := httptest.NewRequest(ex.Request.Method, target, strings.NewReader(ex.Request.Body))
+		for key, values := range ex.Request.Headers {
+			req.Header[key] = values
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		name := filepath.Base(file)
+		if rec.Code != ex.Response.Status {
+			mismatches = append(mismatches, Mismatch{name, fmt.Sprintf("status %d, recorded %d", rec.Code, ex.Response.Status)})
+			continue
+		}
+		if !sameBody(rec.Body.Bytes(), []byte(ex.Response.Body), ignored) {
+			mismatches = append(mismatches, Mismatch{name, fmt.Sprintf("body %s, recorded %s", rec.Body.String(), ex.Response.Body)})
+		}
+	}
+	return mismatches, nil
+}
+
+// sameBody compares JSON bodies without the ignored keys, and anything else
+// byte for byte.
+func sameBody(got, want []byte, ignored map[string]bool) bool {
+	var g, w interface{}
+	if json.Unmarshal(got, &g) != nil || json.Unmarshal(want, &w) != nil {
+		return bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want))
+	}
+	return reflect.DeepEqual(strip(g, ignored), strip(w, ignored))
+}
+
+func strip(v interface{}, ignored map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ignored[key] {
+				delete(v, key)
+				continue
+			}
+			v[key] = strip(child, ignored)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = strip(child, ignored)
+		}
+	}
+	return v
+}
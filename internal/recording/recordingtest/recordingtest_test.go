@@ -0,0 +1,79 @@
+package recordingtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+// counter is a handler worth recording: its answers depend on the order
+// of the requests, carry a timestamp and need a token.
+func counter(recorder middleware.Recorder, step int) http.Handler {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(recorder.Handler("test"))
+	n := 0
+	router.POST("/count", func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "Bearer secret" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
+			return
+		}
+		n += step
+		c.JSON(http.StatusOK, gin.H{"count": n, "at": time.Now()})
+	})
+	return router
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	src := config.FromEnv()
+	if err := src.Set("RECORD_DIR", dir); err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := middleware.LoadRecorder(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live := counter(recorder, 1)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/count", strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer secret")
+		live.ServeHTTP(httptest.NewRecorder(), req)
+		// Recordings are named by time, so keep them apart.
+		time.Sleep(time.Millisecond)
+	}
+
+	auth := http.Header{"Authorization": {"Bearer secret"}}
+	mismatches, err := Replay(counter(middleware.Recorder{}, 1), dir, auth, "at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("replay against the same handler: %v", mismatches)
+	}
+
+	mismatches, err = Replay(counter(middleware.Recorder{}, 2), dir, auth, "at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 3 {
+		t.Errorf("replay against a changed handler: %d mismatches, want 3: %v", len(mismatches), mismatches)
+	}
+
+	// The token was never recorded, so without one every request fails.
+	mismatches, err = Replay(counter(middleware.Recorder{}, 1), dir, nil, "at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 3 || !strings.Contains(mismatches[0].Reason, "status 401") {
+		t.Errorf("replay without the token: %v, want 3 refusals", mismatches)
+	}
+}
@@ -0,0 +1,88 @@
+// Package statuspage serves the small HTML page at / that tells whoever
+// port-forwarded to a service whether it is up and how it is doing. The
+// page needs no auth, so vitals must never carry tokens or client data.
+package statuspage
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/buildinfo"
+)
+
+// refreshSecs is how often the page reloads itself.
+const refreshSecs = 10
+
+//go:embed templates/status.html
+var templateFS embed.FS
+
+var statusTemplate = template.Must(template.ParseFS(templateFS, "templates/status.html"))
+
+// started is when the process came up, for the uptime shown.
+var started = time.Now()
+
+// Uptime is how long the process has been up.
+func Uptime() time.Duration {
+	return time.Since(started)
+}
+
+// Section is a titled group of vitals.
+type Section struct {
+	Title string
+	Rows  []Row
+}
+
+// Row is one vital, already formatted for display.
+type Row struct {
+	Label string
+	Value string
+}
+
+// view is everything the status template renders.
+type view struct {
+	Service     string
+	Build       buildinfo.Info
+	Uptime      time.Duration
+	Listen      string
+	RefreshSecs int
+	Sections    []Section
+}
+
+// Handler serves the status page for service listening on listen. vitals
+// is called per request for the service's own sections.
+func Handler(service, listen string, vitals func() []Section) gin.HandlerFunc {
+	build := buildinfo.Read(service)
+	return func(c *gin.Context) {
+		v := view{
+			Service:     service,
+			Build:       build,
+			Uptime:      Uptime().Truncate(time.Second),
+			Listen:      listen,
+			RefreshSecs: refreshSecs,
+			Sections:    vitals(),
+		}
+
+		var buf bytes.Buffer
+		if err := statusTemplate.Execute(&buf, v); err != nil {
+			log.Printf("[%s] Error rendering status page: %v", service, err)
+			c.String(http.StatusInternalServerError, "Failed to render status page")
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+	}
+}
+
+// Ago formats how long ago t was, or never for the zero time.
+func Ago(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return time.Since(t).Truncate(time.Second).String() + " ago (" + t.Format("2006-01-02 15:04:05 MST") + ")"
+}
@@ -0,0 +1,137 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/internal/tracing"
+	"github.com/Escanor244/713522IT013/social"
+)
+
+// upstream is a social test server with one user and no posts, remembering
+// the traceparent of every request.
+type upstream struct {
+	mu      sync.Mutex
+	parents []string
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	u.parents = append(u.parents, r.Header.Get("traceparent"))
+	u.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/users" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"users": map[string]string{"1": "Ada"}})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"posts": []interface{}{}})
+}
+
+var (
+	installProvider sync.Once
+	memory          = tracetest.NewInMemoryExporter()
+)
+
+// recordSpans installs a provider exporting to memory and returns its
+// exporter, emptied. Tracers bind to the first global provider, so it is
+// installed only once per test binary.
+func recordSpans() *tracetest.InMemoryExporter {
+	installProvider.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(memory)))
+	})
+	memory.Reset()
+	return memory
+}
+
+func TestHandlerSpanParentsUpstreamSpans(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if _, err := tracing.Setup(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	exporter := recordSpans()
+
+	u := &upstream{}
+	srv := httptest.NewServer(u)
+	defer srv.Close()
+
+	gin.SetMode(gin.TestMode)
+	t.Setenv("UPSTREAM_BASE_URL", srv.URL)
+	cfg, err := social.LoadConfig(config.FromEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := social.Handler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	spans := exporter.GetSpans()
+	byID := map[trace.SpanID]tracetest.SpanStub{}
+	var server *tracetest.SpanStub
+	var clients []tracetest.SpanStub
+	for i, s := range spans {
+		byID[s.SpanContext.SpanID()] = s
+		switch s.SpanKind {
+		case trace.SpanKindServer:
+			server = &spans[i]
+		case trace.SpanKindClient:
+			clients = append(clients, s)
+		}
+	}
+	if server == nil {
+		t.Fatalf("no server span among %d spans", len(spans))
+	}
+	if server.Parent.IsValid() {
+		t.Errorf("server span %q has a parent, want it to be the root", server.Name)
+	}
+
+	// One client span for the users listing and one for Ada's posts, each
+	// descended from the handler's span and propagated to the upstream.
+	if len(clients) != 2 {
+		t.Fatalf("%d client spans, want 2", len(clients))
+	}
+	for _, client := range clients {
+		var chain []string
+		found := false
+		for s, ok := client, true; ok; s, ok = byID[s.Parent.SpanID()] {
+			chain = append(chain, s.Name)
+			if s.SpanContext.SpanID() == server.SpanContext.SpanID() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("client span %q descends from %s, not the server span", client.Name, strings.Join(chain, " <- "))
+		}
+		if client.SpanContext.TraceID() != server.SpanContext.TraceID() {
+			t.Errorf("client span %q is in another trace", client.Name)
+		}
+
+		propagated := false
+		for _, parent := range u.parents {
+			propagated = propagated || strings.Contains(parent, client.SpanContext.SpanID().String())
+		}
+		if !propagated {
+			t.Errorf("client span %q not propagated to the upstream: %v", client.Name, u.parents)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Package upstream is the HTTP client both services use to call the test
+// server. It adds timeouts, bearer auth, retries with backoff, an optional
+// rate limiter, a response size cap and per-attempt instrumentation around
+// a plain *http.Client.
+package upstream
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+)
+
+var tracer = otel.Tracer("github.com/Escanor244/713522IT013/internal/upstream")
+
+// ErrDecompress marks a body that looked gzipped but could not be inflated,
+// as opposed to one that inflated but did not decode.
+var ErrDecompress = errors.New("failed to decompress upstream response")
+
+// ErrTooLarge is returned once a body grows past Client.MaxBodyBytes.
+var ErrTooLarge = errors.New("upstream response too large")
+
+// StatusError is a non-200 response. It matches apperr.ErrUpstreamStatus.
+type StatusError = apperr.UpstreamStatusError
+
+// Limiter throttles outgoing requests. *rate.Limiter from
+// golang.org/x/time/rate satisfies it.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Client is safe for concurrent use once configured.
+type Client struct {
+	HTTP *http.Client
+
+	// Timeout bounds each attempt unless the request sets its own.
+	Timeout time.Duration
+
+	// Retries is how many times a request is retried after a network error,
+	// a 429 or a 5xx, waiting Backoff, then twice that, and so on. A request
+	// is never retried once its body has been handed to the decoder.
+	Retries int
+	Backoff time.Duration
+
+	// Jitter waits a random time between half and all of each backoff, so
+	// clients that failed together don't all retry together.
+	Jitter bool
+
+	// Retryable, if set, decides which non-200 statuses are retried in
+	// place of the default 429 and 5xx.
+	Retryable func(status int) bool
+
+	// Limiter, if set, is waited on before every attempt.
+	Limiter Limiter
+
+	// MaxBodyBytes caps the decoded size of a response body. Zero means no
+	// limit.
+	MaxBodyBytes int64
+
+	// Observe, if set, is called after every attempt with the request's
+	// endpoint label, the status code or "error", the attempt's latency and
+	// whether it failed.
+	Observe func(endpoint, status string, elapsed time.Duration, failed bool)
+}
+
+// Request describes one logical call, which may take several attempts.
+type Request struct {
+	// Endpoint labels the call for Observe.
+	Endpoint string
+	URL      string
+
+	// Token is sent as a bearer credential when set.
+	Token  string
+	Header http.Header
+
+	// Timeout overrides Client.Timeout for this request.
+	Timeout time.Duration
+
+	// Stream makes the timeout limit how long any single read of the body
+	// may stall instead of the whole attempt, for bodies the decoder drains
+	// slowly on purpose.
+	Stream bool
+
+	// Attempts, if set, receives how many attempts the call took.
+	Attempts *int
+}
+
+// GetJSON fetches req and decodes its JSON body into v.
+func (c *Client) GetJSON(ctx context.Context, req Request, v interface{}) error {
+	return c.Get(ctx, req, func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(v)
+	})
+}
+
+// Get fetches req and hands the body to decode. Gzip bodies are inflated
+// even when the server forgot the Content-Encoding header.
+//
+// The call is traced as one client span covering every attempt, and the
+// span's context is propagated to the upstream in the request headers.
+func (c *Client) Get(ctx context.Context, req Request, decode func(io.Reader) error) error {
+	ctx, span := tracer.Start(ctx, "GET "+req.Endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodGet),
+			attribute.String("url.full", req.URL),
+		))
+	defer span.End()
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.attempt(ctx, req, decode)
+		if req.Attempts != nil {
+			*req.Attempts = attempt + 1
+		}
+		wait := backoff
+		if c.Jitter && wait > 0 {
+			wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+		}
+		// Don't start a retry the caller's deadline won't let finish.
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			retry = false
+		}
+		if err == nil || !retry || attempt >= c.Retries || ctx.Err() != nil {
+			if attempt > 0 {
+				span.SetAttributes(attribute.Int("http.request.resend_count", attempt))
+			}
+			var status *StatusError
+			if errors.As(err, &status) {
+				span.SetAttributes(attribute.Int("http.response.status_code", status.Code))
+			}
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt makes one request, reporting whether a failure may be retried.
+// Failures are classified for apperr: running out of time is
+// ErrUpstreamTimeout, no response at all is ErrUpstreamUnreachable and a
+// body that doesn't decode is ErrDecode.
+func (c *Client) attempt(ctx context.Context, req Request, decode func(io.Reader) error) (bool, error) {
+	var timedOut atomic.Bool
+	retry, err := c.try(ctx, req, decode, &timedOut)
+	if err != nil && (timedOut.Load() || errors.Is(err, context.DeadlineExceeded)) {
+		err = fmt.Errorf("%w: %w", apperr.ErrUpstreamTimeout, err)
+	}
+	return retry, err
+}
+
+func (c *Client) try(ctx context.Context, req Request, decode func(io.Reader) error, timedOut *atomic.Bool) (bool, error) {
+	if c.Limiter != nil {
+		if err := c.Limiter.Wait(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = c.Timeout
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var timer *time.Timer
+	if timeout > 0 {
+		timer = time.AfterFunc(timeout, func() {
+			timedOut.Store(true)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	for key, values := range req.Header {
+		httpReq.Header[key] = values
+	}
+	if req.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+req.Token)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	start := time.Now()
+	resp, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		c.observe(req.Endpoint, "error", time.Since(start), true)
+		if ctx.Err() == nil {
+			err = fmt.Errorf("%w: %w", apperr.ErrUpstreamUnreachable, err)
+		}
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	status := strconv.Itoa(resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		c.observe(req.Endpoint, status, time.Since(start), true)
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if c.Retryable != nil {
+			retry = c.Retryable(resp.StatusCode)
+		}
+		return retry, &StatusError{Code: resp.StatusCode, Body: string(snippet)}
+	}
+
+	var raw io.Reader = resp.Body
+	if req.Stream && timer != nil {
+		raw = &idleReader{r: resp.Body, timer: timer, timeout: timeout}
+	}
+
+	body, err := decodedBody(raw)
+	if err != nil {
+		c.observe(req.Endpoint, status, time.Since(start), true)
+		return false, err
+	}
+	if c.MaxBodyBytes > 0 {
+		body = &limitedReader{r: body, left: c.MaxBodyBytes}
+	}
+
+	if err := decode(body); err != nil {
+		c.observe(req.Endpoint, status, time.Since(start), true)
+		if !errors.Is(err, ErrTooLarge) && !errors.Is(err, apperr.ErrDecode) && ctx.Err() == nil {
+			err = fmt.Errorf("%w: %w", apperr.ErrDecode, err)
+		}
+		return false, err
+	}
+
+	c.observe(req.Endpoint, status, time.Since(start), false)
+	return false, nil
+}
+
+func (c *Client) observe(endpoint, status string, elapsed time.Duration, failed bool) {
+	if c.Observe != nil {
+		c.Observe(endpoint, status, elapsed, failed)
+	}
+}
+
+// idleReader pushes back its request's deadline every time data arrives.
+type idleReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (i *idleReader) Read(p []byte) (int, error) {
+	n, err := i.r.Read(p)
+	if n > 0 {
+		i.timer.Reset(i.timeout)
+	}
+	return n, err
+}
+
+// limitedReader fails with ErrTooLarge rather than silently truncating, so
+// an oversized body is never decoded as if it were complete.
+type limitedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// gzipMagic is the two-byte header every gzip stream starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decodedBody returns a reader over the response body's content. The
+// transport already negotiates gzip and inflates properly labelled
+// responses; this catches servers that send gzip without a Content-Encoding
+// header by sniffing the magic bytes.
+func decodedBody(body io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(body)
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil || !bytes.Equal(magic, gzipMagic) {
+		return buffered, nil
+	}
+
+	zr, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecompress, err)
+	}
+	return &gzipErrorReader{zr}, nil
+}
+
+// gzipErrorReader tags read errors from a gzip stream as ErrDecompress so
+// a corrupt stream isn't reported as a decode error.
+type gzipErrorReader struct {
+	r io.Reader
+}
+
+func (g *gzipErrorReader) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %v", ErrDecompress, err)
+	}
+	return n, err
+}
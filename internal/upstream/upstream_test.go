@@ -0,0 +1,269 @@
+package upstream
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+)
+
+// script serves each request the next of statuses, and 200 with body once
+// they run out, remembering when every request arrived.
+type script struct {
+	mu       sync.Mutex
+	statuses []int
+	body     string
+	arrivals []time.Time
+}
+
+func (s *script) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.arrivals = append(s.arrivals, time.Now())
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	s.mu.Unlock()
+
+	w.WriteHeader(status)
+	io.WriteString(w, s.body)
+}
+
+func newClient(retries int) *Client {
+	return &Client{HTTP: &http.Client{}, Retries: retries, Backoff: time.Millisecond}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		retries   int
+		retryable func(int) bool
+		attempts  int
+		status    int
+	}{
+		{"succeeds after retrying", []int{503, 502}, 2, nil, 3, 0},
+		{"runs out of retries", []int{503, 503, 503}, 2, nil, 3, 503},
+		{"retries a 429", []int{429}, 1, nil, 2, 0},
+		{"no retry of a 404", []int{404}, 2, nil, 1, 404},
+		{"no retries configured", []int{500}, 0, nil, 1, 500},
+		{"custom retryable", []int{404}, 1, func(status int) bool { return status == 404 }, 2, 0},
+		{"custom not retryable", []int{503}, 1, func(status int) bool { return false }, 1, 503},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(&script{statuses: tt.statuses, body: `{"n":1}`})
+		c := newClient(tt.retries)
+		c.Retryable = tt.retryable
+
+		var attempts int
+		var v struct{ N int }
+		err := c.GetJSON(context.Background(), Request{URL: srv.URL, Attempts: &attempts}, &v)
+		srv.Close()
+
+		if attempts != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, attempts, tt.attempts)
+		}
+		if tt.status == 0 {
+			if err != nil || v.N != 1 {
+				t.Errorf("%s: got %+v, %v, want the body", tt.name, v, err)
+			}
+			continue
+		}
+		var status *StatusError
+		if !errors.As(err, &status) || status.Code != tt.status || !errors.Is(err, apperr.ErrUpstreamStatus) {
+			t.Errorf("%s: error %v, want status %d", tt.name, err, tt.status)
+		}
+	}
+}
+
+func TestBackoffDoubles(t *testing.T) {
+	s := &script{statuses: []int{503, 503, 503}, body: "{}"}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c := &Client{HTTP: &http.Client{}, Retries: 3, Backoff: 40 * time.Millisecond}
+	if err := c.GetJSON(context.Background(), Request{URL: srv.URL}, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.arrivals) != 4 {
+		t.Fatalf("%d requests, want 4", len(s.arrivals))
+	}
+	for i, want := range []time.Duration{40 * time.Millisecond, 80 * time.Millisecond, 160 * time.Millisecond} {
+		if gap := s.arrivals[i+1].Sub(s.arrivals[i]); gap < want {
+			t.Errorf("wait before retry %d = %v, want at least %v", i+1, gap, want)
+		}
+	}
+}
+
+func TestNoRetryPastDeadline(t *testing.T) {
+	s := &script{statuses: []int{503}, body: "{}"}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// The backoff would outlast the caller's deadline, so the retry is
+	// never started.
+	c := &Client{HTTP: &http.Client{}, Retries: 3, Backoff: time.Second}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var attempts int
+	err := c.GetJSON(ctx, Request{URL: srv.URL, Attempts: &attempts}, &struct{}{})
+	if attempts != 1 || !errors.Is(err, apperr.ErrUpstreamStatus) {
+		t.Errorf("%d attempts, error %v, want one attempt failing with its status", attempts, err)
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	c := newClient(1)
+	c.Timeout = 20 * time.Millisecond
+	var attempts int
+	err := c.GetJSON(context.Background(), Request{URL: srv.URL, Attempts: &attempts}, &struct{}{})
+	if !errors.Is(err, apperr.ErrUpstreamTimeout) || attempts != 2 {
+		t.Errorf("%d attempts, error %v, want two attempts timing out", attempts, err)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	body := `{"content":"` + strings.Repeat("x", 100) + `"}`
+	srv := httptest.NewServer(&script{body: body})
+	defer srv.Close()
+
+	tests := []struct {
+		limit   int64
+		tooBig  bool
+		content int
+	}{
+		{0, false, 100},
+		{int64(len(body)), false, 100},
+		{int64(len(body)) - 1, true, 0},
+		{10, true, 0},
+	}
+	for _, tt := range tests {
+		c := newClient(2)
+		c.MaxBodyBytes = tt.limit
+
+		var attempts int
+		var v struct{ Content string }
+		err := c.GetJSON(context.Background(), Request{URL: srv.URL, Attempts: &attempts}, &v)
+		if tt.tooBig {
+			if !errors.Is(err, ErrTooLarge) || attempts != 1 {
+				t.Errorf("limit %d: %d attempts, error %v, want ErrTooLarge without retrying", tt.limit, attempts, err)
+			}
+			continue
+		}
+		if err != nil || len(v.Content) != tt.content {
+			t.Errorf("limit %d: %d bytes of content, error %v, want %d", tt.limit, len(v.Content), err, tt.content)
+		}
+	}
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	io.WriteString(zw, s)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestGzipBodies(t *testing.T) {
+	good := gzipped(t, `{"n":7}`)
+	tests := []struct {
+		name     string
+		body     []byte
+		labelled bool
+		err      error
+	}{
+		{"labelled gzip", good, true, nil},
+		{"unlabelled gzip", good, false, nil},
+		{"plain", []byte(`{"n":7}`), false, nil},
+		{"bad gzip header", append([]byte{0x1f, 0x8b}, "not gzip at all"...), false, ErrDecompress},
+		{"truncated gzip", good[:12], false, ErrDecompress},
+		{"gzip of bad JSON", gzipped(t, `{"n":`), false, apperr.ErrDecode},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tt.labelled {
+				w.Header().Set("Content-Encoding", "gzip")
+			}
+			w.Write(tt.body)
+		}))
+		var v struct{ N int }
+		err := newClient(0).GetJSON(context.Background(), Request{URL: srv.URL}, &v)
+		srv.Close()
+
+		if tt.err == nil {
+			if err != nil || v.N != 7 {
+				t.Errorf("%s: got %+v, %v, want n 7", tt.name, v, err)
+			}
+		} else if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestAuthAndHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		io.WriteString(w, "{}")
+	}))
+	defer srv.Close()
+	c := newClient(0)
+
+	header := http.Header{"X-Trace-Tag": {"abc"}}
+	if err := c.GetJSON(context.Background(), Request{URL: srv.URL, Token: "secret", Header: header}, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if auth := got.Get("Authorization"); auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the bearer token", auth)
+	}
+	if tag := got.Get("X-Trace-Tag"); tag != "abc" {
+		t.Errorf("X-Trace-Tag = %q, want the request's header", tag)
+	}
+
+	if err := c.GetJSON(context.Background(), Request{URL: srv.URL}, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if auth, ok := got["Authorization"]; ok {
+		t.Errorf("Authorization = %q without a token, want none", auth)
+	}
+}
+
+func TestObserveEveryAttempt(t *testing.T) {
+	srv := httptest.NewServer(&script{statuses: []int{503}, body: "{}"})
+	defer srv.Close()
+
+	var observed []string
+	c := newClient(1)
+	c.Observe = func(endpoint, status string, elapsed time.Duration, failed bool) {
+		observed = append(observed, fmt.Sprintf("%s %s %v", endpoint, status, failed))
+	}
+	if err := c.GetJSON(context.Background(), Request{Endpoint: "users", URL: srv.URL}, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(observed, ", "); got != "users 503 true, users 200 false" {
+		t.Errorf("observed %s, want the 503 then the 200", got)
+	}
+}
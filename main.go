@@ -0,0 +1,220 @@
+// Command 713522IT013 runs the average calculator and the social media
+// analytics service, separately or together in one process.
+//
+// Usage:
+//
+//	713522IT013 [--config file.yaml] [--set NAME=value]... [--mock-upstream] serve avg|social|all
+//	713522IT013 client --type p [--server URL] [--token TOKEN] [--watch 2s]
+//
+// Each setting is an environment variable, which may also be given in the
+// config file (see internal/config for its layout) or with --set; --set
+// wins over the environment, and the environment over the file.
+// The average calculator listens on PORT (default 9877) and the analytics
+// service on SOCIAL_PORT (default 8080), so both can run side by side.
+// LISTEN and SOCIAL_LISTEN take a full address instead, including
+// unix:///path/to.sock for a Unix socket.
+//
+// --mock-upstream has the average calculator call an in-process stand-in
+// for the number service, for development without access to the real one.
+//
+// The client command calls a running average calculator's
+// /numbers/{numberid} and prints the window as a table, again every --watch
+// until interrupted. The token defaults to $TOKEN. It exits with 1 on an
+// error response, printing its error code.
+//
+// Traces are exported over OTLP when OTEL_EXPORTER_OTLP_ENDPOINT is set;
+// see internal/tracing.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Escanor244/713522IT013/avgcalc"
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/internal/httpserver"
+	"github.com/Escanor244/713522IT013/internal/tracing"
+	"github.com/Escanor244/713522IT013/social"
+)
+
+const usage = `usage: 713522IT013 [--config file.yaml] [--set NAME=value]... [--mock-upstream] serve avg|social|all
+       713522IT013 client --type p[,f,e,r] [--server URL] [--token TOKEN] [--watch 2s]`
+
+func main() {
+	flag.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	configPath := flag.String("config", "", "YAML configuration file")
+	mockUpstream := flag.Bool("mock-upstream", false, "call a mock number service in place of the real one, as --set MOCK_UPSTREAM=true")
+	var sets settings
+	flag.Var(&sets, "set", "`NAME=value` of a setting, over the environment and the file; repeatable")
+	flag.Parse()
+	if *mockUpstream {
+		sets = append(sets, "MOCK_UPSTREAM=true")
+	}
+
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "client" {
+		os.Exit(runClient(args[1:]))
+	}
+	if len(args) != 2 || args[0] != "serve" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	src := config.FromEnv()
+	if *configPath != "" {
+		var err error
+		if src, err = config.Load(*configPath); err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+	}
+	for _, set := range sets {
+		name, value, _ := strings.Cut(set, "=")
+		if err := src.Set(name, value); err != nil {
+			log.Fatalf("Invalid --set %s: %v", set, err)
+		}
+	}
+	src.LogEffective("http")
+
+	var services []func(context.Context) error
+	var reloaders []func(*config.Source) error
+	switch args[1] {
+	case "avg":
+		run, reload := serveAvg(src)
+		services = append(services, run)
+		reloaders = append(reloaders, reload)
+	case "social":
+		services = append(services, serveSocial(src))
+		reloaders = append(reloaders, social.Reload)
+	case "all":
+		run, reload := serveAvg(src)
+		services = append(services, run, serveSocial(src))
+		reloaders = append(reloaders, reload, social.Reload)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// One service failing takes the whole process down, after the others
+	// have shut down cleanly.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reloaders = append(reloaders, httpserver.ReloadCertificates)
+	go reloadOnHangup(ctx, src, reloaders)
+
+	shutdownTracing, err := tracing.Setup(ctx, "713522IT013")
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(services))
+	for _, run := range services {
+		wg.Add(1)
+		go func(run func(context.Context) error) {
+			defer wg.Done()
+			if err := run(ctx); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(run)
+	}
+	wg.Wait()
+	close(errs)
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+	flushCancel()
+
+	failed := false
+	for err := range errs {
+		log.Print(err)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// settings collects the --set flags.
+type settings []string
+
+func (s *settings) String() string { return strings.Join(*s, " ") }
+
+func (s *settings) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("want NAME=value, got %q", value)
+	}
+	*s = append(*s, value)
+	return nil
+}
+
+// reloadOnHangup re-reads the configuration on every SIGHUP and hands it to
+// the running services. A file that fails to load or validate is rejected
+// as a whole and the previous settings stay in effect.
+func reloadOnHangup(ctx context.Context, src *config.Source, reloaders []func(*config.Source) error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		log.Printf("Received SIGHUP, reloading configuration")
+		next, err := src.Reload()
+		if err != nil {
+			log.Printf("Configuration reload failed: %v", err)
+			continue
+		}
+		for _, reload := range reloaders {
+			if err := reload(next); err != nil {
+				log.Printf("Configuration reload failed: %v", err)
+			}
+		}
+	}
+}
+
+// serveAvg returns the average calculator configured by src, and what
+// reloads its settings on SIGHUP.
+func serveAvg(src *config.Source) (func(context.Context) error, func(*config.Source) error) {
+	cfg, err := avgcalc.LoadConfig(src)
+	if err != nil {
+		log.Fatalf("Invalid average calculator configuration: %v", err)
+	}
+	return func(ctx context.Context) error {
+		if err := avgcalc.Run(ctx, cfg); err != nil {
+			return fmt.Errorf("average calculator: %w", err)
+		}
+		return nil
+	}, cfg.Reload
+}
+
+func serveSocial(src *config.Source) func(context.Context) error {
+	cfg, err := social.LoadConfig(src)
+	if err != nil {
+		log.Fatalf("Invalid analytics configuration: %v", err)
+	}
+	return func(ctx context.Context) error {
+		if err := social.Run(ctx, cfg); err != nil {
+			return fmt.Errorf("analytics: %w", err)
+		}
+		return nil
+	}
+}
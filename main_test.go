@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+func TestReloadOnHangup(t *testing.T) {
+	t.Setenv("NUMBER_SERVICE_RETRIES", "")
+	t.Setenv("NUMBER_SERVICE_TIMEOUT", "")
+
+	// Catch SIGHUP here too, so one sent before reloadOnHangup is
+	// listening can't kill the test binary.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("avg:\n  retries: 2\n  timeout: 1s\n")
+	src, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Set("NUMBER_SERVICE_TIMEOUT", "3s"); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan *config.Source, 10)
+	after := make(chan *config.Source, 10)
+	reloaders := []func(*config.Source) error{
+		func(next *config.Source) error {
+			reloaded <- next
+			return nil
+		},
+		func(*config.Source) error { return errors.New("rejected") },
+		func(next *config.Source) error {
+			after <- next
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloadOnHangup(ctx, src, reloaders)
+
+	// hangup signals the process until the reloaders have been called.
+	hangup := func() *config.Source {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+			if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case next := <-reloaded:
+				select {
+				case <-after:
+				case <-time.After(time.Second):
+					t.Error("a failing reloader stopped the ones after it")
+				}
+				return next
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		t.Fatal("configuration never reloaded")
+		return nil
+	}
+
+	write("avg:\n  retries: 5\n  timeout: 1s\n")
+	next := hangup()
+	if got := next.Get("NUMBER_SERVICE_RETRIES"); got != "5" {
+		t.Errorf("retries after reload = %q, want the edited 5", got)
+	}
+	if got := next.Get("NUMBER_SERVICE_TIMEOUT"); got != "3s" {
+		t.Errorf("timeout after reload = %q, want the --set 3s", got)
+	}
+
+	// A file that no longer loads is rejected whole and reloads nothing.
+	// Any reload still due to a repeated signal lands first.
+	time.Sleep(100 * time.Millisecond)
+	for len(reloaded) > 0 {
+		<-reloaded
+	}
+	write("avg:\n  retries: 7\n  retires: 8\n")
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	select {
+	case next := <-reloaded:
+		t.Errorf("invalid file reloaded with retries %q", next.Get("NUMBER_SERVICE_RETRIES"))
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	write("avg:\n  retries: 9\n")
+	if got := hangup().Get("NUMBER_SERVICE_RETRIES"); got != "9" {
+		t.Errorf("retries after fixing the file = %q, want 9", got)
+	}
+}
@@ -0,0 +1,187 @@
+package social
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func deleteCachedUser(c *gin.Context) {
+	userID := c.Param("id")
+	refetch := c.Query("refetch") == "true"
+	cache := envCache(c)
+
+	// Hold off full refreshes so one in flight can't commit the evicted
+	// user's old data back over us.
+	cache.refreshMu.Lock()
+	defer cache.refreshMu.Unlock()
+
+	name, removedPosts, ok := cache.evictUser(c.Request.Context(), userID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	log.Printf("Evicted user %s and %d posts from cache", userID, removedPosts)
+
+	if !refetch {
+		c.JSON(http.StatusOK, gin.H{"evicted": userID, "removedPosts": removedPosts, "refetched": false})
+		return
+	}
+
+	postCount, err := cache.refetchUser(c.Request.Context(), userID, name)
+	if err != nil {
+		c.JSON(upstreamStatus(err), gin.H{
+			"error":        "Evicted user but refetch failed: " + err.Error(),
+			"evicted":      userID,
+			"removedPosts": removedPosts,
+			"refetched":    false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"evicted":      userID,
+		"removedPosts": removedPosts,
+		"refetched":    true,
+		"postCount":    postCount,
+	})
+}
+
+// evictUser removes a user, their posts and those posts' comment counts in
+// a single critical section. It returns the user's name so a refetch can
+// restore it.
+func (c *Cache) evictUser(ctx context.Context, userID string) (string, int, bool) {
+	_, span := c.startSpan(ctx, "Cache.evictUser", attribute.String("user.id", userID))
+	defer span.End()
+
+	c.Lock()
+	defer c.Unlock()
+
+	name, ok := c.users[userID]
+	if !ok {
+		return "", 0, false
+	}
+
+	delete(c.users, userID)
+	delete(c.userPostCounts, userID)
+
+	kept := make([]Post, 0, len(c.posts))
+	removed := 0
+	for _, post := range c.posts {
+		if strconv.Itoa(post.UserID) == userID {
+			delete(c.postComments, post.ID)
+			delete(c.quietPosts, post.ID)
+			removed++
+			continue
+		}
+		kept = append(kept, post)
+	}
+	c.posts = kept
+	c.reindex()
+
+	return name, removed, true
+}
+
+// refetchUser fetches a single user's posts and comment counts and merges
+// them into the cache. The caller must hold refreshMu.
+func (c *Cache) refetchUser(ctx context.Context, userID, name string) (_ int, err error) {
+	ctx, span := c.startSpan(ctx, "Cache.refetchUser", attribute.String("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	posts, err := c.fetchUserPosts(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	comments := make(map[int]int)
+	for _, post := range posts {
+		postComments, err := c.fetchPostComments(ctx, post.ID)
+		if err != nil {
+			log.Printf("Error fetching comments for post %d: %v", post.ID, err)
+			continue
+		}
+		comments[post.ID] = len(postComments)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.users[userID] = name
+	c.userPostCounts[userID] = len(posts)
+	c.posts = append(c.posts, posts...)
+	for postID, count := range comments {
+		c.postComments[postID] = count
+	}
+	c.reindex()
+
+	log.Printf("Refetched user %s with %d posts", userID, len(posts))
+	return len(posts), nil
+}
+
+// postRefresh forces a full refresh, bypassing both the update interval and
+// any failure backoff. The refresh runs as a background job whose ID is
+// returned with 202 for polling; wait=true blocks and returns the summary
+// instead. With dryRun=true the fetched data is diffed against the cache
+// and discarded.
+func postRefresh(c *gin.Context) {
+	cache := envCache(c)
+
+	if c.Query("dryRun") == "true" {
+		diff, err := cache.dryRun(c.Request.Context())
+		if err != nil {
+			c.JSON(upstreamStatus(err), gin.H{"error": "Dry run failed: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"dryRun": true, "diff": diff})
+		return
+	}
+
+	job := refreshJobs.create(cache.name)
+	ctx := c.Request.Context()
+	done := make(chan struct{})
+	var refreshErr error
+	go func() {
+		defer close(done)
+		_, refreshErr = cache.refresh(ctx, true, job)
+		job.finish(cache.summary(), refreshErr)
+	}()
+
+	if c.Query("wait") != "true" {
+		c.Header("Location", "/admin/refresh/"+job.id)
+		c.JSON(http.StatusAccepted, job.status())
+		return
+	}
+
+	<-done
+	status := job.status()
+	if status.State == jobFailed {
+		c.JSON(upstreamStatus(refreshErr), gin.H{"error": "Refresh failed: " + status.Error, "job": status})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func getRefreshJob(c *gin.Context) {
+	job, ok := refreshJobs.get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Refresh job not found or expired"})
+		return
+	}
+	c.JSON(http.StatusOK, job.status())
+}
+
+func (c *Cache) summary() *refreshSummary {
+	c.RLock()
+	defer c.RUnlock()
+
+	return &refreshSummary{
+		LastUpdated: c.lastUpdated,
+		DurationMs:  c.lastRefresh.Total.Milliseconds(),
+		Users:       len(c.users),
+		Posts:       len(c.posts),
+	}
+}
@@ -0,0 +1,147 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// evict serves DELETE path on c as the admin route does.
+func evict(c *Cache, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.DELETE("/admin/cache/users/:id", func(ctx *gin.Context) { ctx.Set(envContextKey, c) }, deleteCachedUser)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, path, nil))
+	return w
+}
+
+// newEvictionCache returns a refreshed test cache in which Ada has a second
+// post, 11, with two comments.
+func newEvictionCache(t *testing.T) (*Cache, *fakeUpstream) {
+	t.Helper()
+	c, u := newTestCache(t)
+	u.set(func(u *fakeUpstream) {
+		u.posts["1"] = append(u.posts["1"], Post{ID: 11, UserID: 1, Content: "another"})
+		u.comments[11] = []Comment{{ID: 110, PostID: 11, Content: "a"}, {ID: 111, PostID: 11, Content: "b"}}
+	})
+	if _, err := c.refresh(context.Background(), true, nil); err != nil {
+		t.Fatal(err)
+	}
+	return c, u
+}
+
+type evictResponse struct {
+	RemovedPosts int  `json:"removedPosts"`
+	Refetched    bool `json:"refetched"`
+	PostCount    int  `json:"postCount"`
+}
+
+// assertNoTrace fails unless nothing in c refers to user or their posts.
+func assertNoTrace(t *testing.T, c *Cache, user string, posts ...int) {
+	t.Helper()
+	c.RLock()
+	defer c.RUnlock()
+	if _, ok := c.users[user]; ok {
+		t.Errorf("user %s still cached", user)
+	}
+	if _, ok := c.userPostCounts[user]; ok {
+		t.Errorf("user %s still has a post count", user)
+	}
+	for _, entry := range c.nameIndex {
+		if entry.user.UserID == user {
+			t.Errorf("user %s still searchable", user)
+		}
+	}
+	for _, id := range posts {
+		for _, post := range c.posts {
+			if post.ID == id {
+				t.Errorf("post %d still cached", id)
+			}
+		}
+		if _, ok := c.postComments[id]; ok {
+			t.Errorf("post %d still has a comment count", id)
+		}
+	}
+}
+
+func TestDeleteCachedUser(t *testing.T) {
+	c, _ := newEvictionCache(t)
+
+	w := evict(c, "/admin/cache/users/1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp evictResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.RemovedPosts != 2 || resp.Refetched {
+		t.Errorf("response %s, want two posts removed and no refetch", w.Body)
+	}
+	assertNoTrace(t, c, "1", 10, 11)
+
+	// The other user is untouched.
+	c.RLock()
+	brian, posts, comments := c.users["2"], len(c.posts), c.postComments[20]
+	c.RUnlock()
+	if brian != "Brian" || posts != 1 || comments != 0 {
+		t.Errorf("after eviction: user 2 %q, %d posts, want Brian and post 20 alone", brian, posts)
+	}
+	if w := serveCache(c, "/users/:id", "/users/1", getUser); w.Code != http.StatusNotFound {
+		t.Errorf("GET /users/1: status %d, want 404", w.Code)
+	}
+	if w := serveCache(c, "/posts/:id", "/posts/10", getPost); w.Code != http.StatusNotFound {
+		t.Errorf("GET /posts/10: status %d, want 404", w.Code)
+	}
+
+	if w := evict(c, "/admin/cache/users/1"); w.Code != http.StatusNotFound {
+		t.Errorf("evicting again: status %d, want 404", w.Code)
+	}
+}
+
+func TestDeleteCachedUserRefetch(t *testing.T) {
+	c, u := newEvictionCache(t)
+
+	w := evict(c, "/admin/cache/users/1?refetch=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp evictResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.RemovedPosts != 2 || !resp.Refetched || resp.PostCount != 2 {
+		t.Errorf("response %s, want two posts removed and refetched", w.Body)
+	}
+
+	c.RLock()
+	seen := map[int]int{}
+	for _, post := range c.posts {
+		seen[post.ID]++
+	}
+	name, count := c.users["1"], c.userPostCounts["1"]
+	comments := map[int]int{10: c.postComments[10], 11: c.postComments[11]}
+	c.RUnlock()
+	if name != "Ada" || count != 2 {
+		t.Errorf("user 1 = %q with %d posts, want Ada with 2", name, count)
+	}
+	if seen[10] != 1 || seen[11] != 1 || seen[20] != 1 || len(seen) != 3 {
+		t.Errorf("cached posts %v, want 10, 11 and 20 once each", seen)
+	}
+	if comments[10] != 1 || comments[11] != 2 {
+		t.Errorf("comment counts %v, want 1 on post 10 and 2 on post 11", comments)
+	}
+	var found struct{ Total int }
+	json.Unmarshal(serveCache(c, "/users/search", "/users/search?name=ada", searchUsers).Body.Bytes(), &found)
+	if found.Total != 1 {
+		t.Errorf("search for ada found %d, want the refetched user", found.Total)
+	}
+
+	// A failed refetch leaves the user evicted, without half their data.
+	u.set(func(u *fakeUpstream) { u.failing = true })
+	if w := evict(c, "/admin/cache/users/1?refetch=true"); w.Code == http.StatusOK {
+		t.Errorf("refetch from a failing upstream: status %d: %s", w.Code, w.Body)
+	}
+	assertNoTrace(t, c, "1", 10, 11)
+}
@@ -0,0 +1,17 @@
+package social
+
+import "time"
+
+// clock is the time source of refresh scheduling, so tests can step
+// through the backoff without waiting it out.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+var refreshClock clock = realClock{}
@@ -0,0 +1,347 @@
+package social
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/internal/httpserver"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+// settings is where every setting below is read from. LoadConfig replaces
+// it with one that also consults the configuration file.
+var settings = config.FromEnv()
+
+// tunables are the settings that may change while the service runs. They
+// are only ever replaced as a whole, so a reader sees one consistent set;
+// read them through tuning() rather than keeping a copy.
+type tunables struct {
+	// refreshInterval is how old the cache may get before it is refreshed.
+	refreshInterval time.Duration
+
+	// refreshBackoffMax caps the refresh interval while the upstream is
+	// failing.
+	refreshBackoffMax time.Duration
+
+	// upstreamTimeout bounds every upstream request unless upstreamTimeouts
+	// overrides it for that endpoint class.
+	upstreamTimeout  time.Duration
+	upstreamTimeouts map[string]time.Duration
+
+	// rankingWindowMax is the longest window accepted by /users?window=.
+	rankingWindowMax time.Duration
+
+	// topUsersLimit and latestPostsLimit size the default user ranking and
+	// latest posts view.
+	topUsersLimit    int
+	latestPostsLimit int
+
+	// previewLength is the maximum number of runes of content kept when a
+	// listing is requested with full=false.
+	previewLength int
+
+	// In lazy comments mode, refreshes fetch comments for each user's
+	// lazyCommentsPerUser newest posts plus the current popular set.
+	lazyCommentsPerUser int
+
+	// quietPostCycles is how many scheduled refreshes skip the comments
+	// fetch of a post that last came back with no comments. Zero disables
+	// this.
+	quietPostCycles int
+
+	// Engagement scores are engagementPostWeight per post plus
+	// engagementCommentWeight per comment received.
+	engagementPostWeight    float64
+	engagementCommentWeight float64
+}
+
+// reloadable lists the settings that Reload applies.
+var reloadable = map[string]bool{
+	"REFRESH_INTERVAL":          true,
+	"REFRESH_BACKOFF_MAX":       true,
+	"UPSTREAM_TIMEOUT":          true,
+	"UPSTREAM_USERS_TIMEOUT":    true,
+	"UPSTREAM_POSTS_TIMEOUT":    true,
+	"UPSTREAM_COMMENTS_TIMEOUT": true,
+	"RANKING_WINDOW_MAX":        true,
+	"TOP_USERS_LIMIT":           true,
+	"LATEST_POSTS_LIMIT":        true,
+	"PREVIEW_LENGTH":            true,
+	"LAZY_COMMENTS_PER_USER":    true,
+	"QUIET_POST_CYCLES":         true,
+	"ENGAGEMENT_POST_WEIGHT":    true,
+	"ENGAGEMENT_COMMENT_WEIGHT": true,
+}
+
+var live atomic.Pointer[tunables]
+
+func init() {
+	live.Store(defaultTunables())
+}
+
+func defaultTunables() *tunables {
+	return &tunables{
+		refreshInterval:         30 * time.Second,
+		refreshBackoffMax:       10 * time.Minute,
+		upstreamTimeout:         10 * time.Second,
+		upstreamTimeouts:        map[string]time.Duration{},
+		rankingWindowMax:        30 * 24 * time.Hour,
+		topUsersLimit:           5,
+		latestPostsLimit:        5,
+		previewLength:           100,
+		lazyCommentsPerUser:     3,
+		quietPostCycles:         3,
+		engagementPostWeight:    1,
+		engagementCommentWeight: 1,
+	}
+}
+
+// tuning returns the current tunables.
+func tuning() *tunables {
+	return live.Load()
+}
+
+// refreshConcurrency is how many users a refresh fetches in parallel.
+var refreshConcurrency = 8
+
+// refreshHistorySize is how many refresh records each environment keeps.
+var refreshHistorySize = 100
+
+// Upstream retry policy: a failed fetch is retried upstreamRetries times,
+// waiting upstreamRetryBackoff and doubling. upstreamMaxBodyBytes caps each
+// response body, zero meaning no limit.
+var (
+	upstreamRetries      = 1
+	upstreamRetryBackoff = 250 * time.Millisecond
+	upstreamMaxBodyBytes int64
+)
+
+// lazyComments limits refresh-time comment fetches as described on
+// tunables.lazyCommentsPerUser.
+var lazyComments = false
+
+// Config is the analytics service's startup configuration. Its tuning
+// knobs are applied to package state by LoadConfig rather than carried here.
+type Config struct {
+	// Listen is the address to listen on, :port or unix:///path.
+	Listen string
+
+	// HTTP configures the middleware shared with the other service.
+	HTTP middleware.Config
+
+	// Server configures the HTTP server and its listener.
+	Server httpserver.Options
+}
+
+// LoadConfig reads the configuration from src and logs the result.
+func LoadConfig(src *config.Source) (Config, error) {
+	settings = src
+	if err := loadConfig(); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Config{Listen: ":8080"}
+	if raw := settings.Get("SOCIAL_PORT"); raw != "" {
+		if n, err := strconv.Atoi(raw); err != nil || n < 1 || n > 65535 {
+			return Config{}, fmt.Errorf("%s must be a port number, got %q", settings.Name("SOCIAL_PORT"), raw)
+		}
+		cfg.Listen = ":" + raw
+	}
+	if raw := settings.Get("SOCIAL_LISTEN"); raw != "" {
+		if _, _, err := httpserver.ParseAddr(raw); err != nil {
+			return Config{}, fmt.Errorf("%s must be host:port or unix:///path, got %q", settings.Name("SOCIAL_LISTEN"), raw)
+		}
+		cfg.Listen = raw
+	}
+
+	var err error
+	if cfg.HTTP, err = middleware.Load(settings); err != nil {
+		return Config{}, err
+	}
+	if cfg.Server, err = httpserver.LoadOptions(settings); err != nil {
+		return Config{}, err
+	}
+
+	settings.LogEffective("social")
+	return cfg, nil
+}
+
+// Reload re-reads the settings from src and applies those that are safe to
+// change at runtime. Changes to any other setting are logged and ignored
+// until the next restart.
+func Reload(src *config.Source) error {
+	t, err := loadTunables(src)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range src.Changed(settings, "social") {
+		if !reloadable[key] {
+			log.Printf("[social] WARNING: %s changed but only takes effect after a restart", key)
+		}
+	}
+
+	live.Store(t)
+	log.Printf("[social] Configuration reloaded")
+	return nil
+}
+
+// loadConfig applies the optional tuning settings.
+func loadConfig() error {
+	t, err := loadTunables(settings)
+	if err != nil {
+		return err
+	}
+	live.Store(t)
+
+	if raw := settings.Get("REFRESH_CONCURRENCY"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return fmt.Errorf("%s must be a positive integer, got %q", settings.Name("REFRESH_CONCURRENCY"), raw)
+		}
+		refreshConcurrency = n
+	}
+
+	if raw := settings.Get("REFRESH_HISTORY_SIZE"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return fmt.Errorf("%s must be a positive integer, got %q", settings.Name("REFRESH_HISTORY_SIZE"), raw)
+		}
+		refreshHistorySize = n
+	}
+
+	if dir := settings.Get("STATE_DIR"); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("%s %s is not usable: %v", settings.Name("STATE_DIR"), dir, err)
+		}
+		stateDir = dir
+	}
+
+	if raw := settings.Get("UPSTREAM_RETRIES"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s must be a non-negative integer, got %q", settings.Name("UPSTREAM_RETRIES"), raw)
+		}
+		upstreamRetries = n
+	}
+
+	if raw := settings.Get("UPSTREAM_RETRY_BACKOFF"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return fmt.Errorf("%s must be a non-negative duration, got %q", settings.Name("UPSTREAM_RETRY_BACKOFF"), raw)
+		}
+		upstreamRetryBackoff = d
+	}
+
+	if raw := settings.Get("UPSTREAM_MAX_BODY_BYTES"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s must be a non-negative integer, got %q", settings.Name("UPSTREAM_MAX_BODY_BYTES"), raw)
+		}
+		upstreamMaxBodyBytes = n
+	}
+
+	upstreamClient.Retries = upstreamRetries
+	upstreamClient.Backoff = upstreamRetryBackoff
+	upstreamClient.MaxBodyBytes = upstreamMaxBodyBytes
+
+	switch mode := settings.Get("COMMENTS_MODE"); mode {
+	case "", "eager":
+	case "lazy":
+		lazyComments = true
+	default:
+		return fmt.Errorf("%s must be 'eager' or 'lazy', got %q", settings.Name("COMMENTS_MODE"), mode)
+	}
+
+	return configureUpstreamTLS()
+}
+
+// loadTunables reads the runtime-changeable settings from src on top of the
+// defaults.
+func loadTunables(src *config.Source) (*tunables, error) {
+	t := defaultTunables()
+
+	for key, d := range map[string]*time.Duration{
+		"REFRESH_INTERVAL":    &t.refreshInterval,
+		"REFRESH_BACKOFF_MAX": &t.refreshBackoffMax,
+		"UPSTREAM_TIMEOUT":    &t.upstreamTimeout,
+		"RANKING_WINDOW_MAX":  &t.rankingWindowMax,
+	} {
+		raw := src.Get(key)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("%s must be a positive duration, got %q", src.Name(key), raw)
+		}
+		*d = parsed
+	}
+
+	for endpoint, key := range map[string]string{
+		endpointUsers:    "UPSTREAM_USERS_TIMEOUT",
+		endpointPosts:    "UPSTREAM_POSTS_TIMEOUT",
+		endpointComments: "UPSTREAM_COMMENTS_TIMEOUT",
+	} {
+		raw := src.Get(key)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%s must be a positive duration, got %q", src.Name(key), raw)
+		}
+		t.upstreamTimeouts[endpoint] = d
+	}
+
+	for key, setting := range map[string]struct {
+		n   *int
+		min int
+	}{
+		"TOP_USERS_LIMIT":        {&t.topUsersLimit, 1},
+		"LATEST_POSTS_LIMIT":     {&t.latestPostsLimit, 1},
+		"PREVIEW_LENGTH":         {&t.previewLength, 1},
+		"LAZY_COMMENTS_PER_USER": {&t.lazyCommentsPerUser, 0},
+		"QUIET_POST_CYCLES":      {&t.quietPostCycles, 0},
+	} {
+		raw := src.Get(key)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < setting.min {
+			kind := "a positive"
+			if setting.min == 0 {
+				kind = "a non-negative"
+			}
+			return nil, fmt.Errorf("%s must be %s integer, got %q", src.Name(key), kind, raw)
+		}
+		*setting.n = n
+	}
+
+	for key, weight := range map[string]*float64{
+		"ENGAGEMENT_POST_WEIGHT":    &t.engagementPostWeight,
+		"ENGAGEMENT_COMMENT_WEIGHT": &t.engagementCommentWeight,
+	} {
+		raw := src.Get(key)
+		if raw == "" {
+			continue
+		}
+		w, err := strconv.ParseFloat(raw, 64)
+		if err != nil || w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+			return nil, fmt.Errorf("%s must be a non-negative number, got %q", src.Name(key), raw)
+		}
+		*weight = w
+	}
+	if t.engagementPostWeight == 0 && t.engagementCommentWeight == 0 {
+		return nil, fmt.Errorf("%s and %s cannot both be zero", src.Name("ENGAGEMENT_POST_WEIGHT"), src.Name("ENGAGEMENT_COMMENT_WEIGHT"))
+	}
+
+	return t, nil
+}
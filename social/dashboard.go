@@ -0,0 +1,88 @@
+package social
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed templates/dashboard.html
+var templateFS embed.FS
+
+var dashboardTemplate = template.Must(template.ParseFS(templateFS, "templates/dashboard.html"))
+
+// dashboardView is everything the dashboard template renders.
+type dashboardView struct {
+	Environment  string
+	LastUpdated  time.Time
+	RefreshSecs  int
+	Users        []UserPostCount
+	LatestPosts  []dashboardPost
+	PopularPosts []dashboardPost
+}
+
+// dashboardPost is a post row with its author and comment count resolved.
+// CommentsKnown is false for posts whose comments were skipped in lazy mode.
+type dashboardPost struct {
+	ID            int
+	Author        string
+	Content       string
+	Comments      int
+	CommentsKnown bool
+}
+
+// getDashboard renders the summary as a self-contained HTML page. It reads
+// the same snapshot as /summary and reloads itself once per refresh
+// interval. It is mounted with the JSON API, so it sits behind the same
+// middleware.
+func getDashboard(c *gin.Context) {
+	cache := loadCache(c)
+
+	users := cache.rankUsers(defaultRankOptions())
+	cache.annotateRankChanges(users)
+	popular, _ := cache.popularPosts()
+	limit := tuning().latestPostsLimit
+	if len(popular) > limit {
+		popular = popular[:limit]
+	}
+
+	view := dashboardView{
+		Environment:  cache.name,
+		LastUpdated:  cache.lastUpdated,
+		RefreshSecs:  int(tuning().refreshInterval / time.Second),
+		Users:        users,
+		LatestPosts:  cache.dashboardPosts(cache.latestPosts(limit)),
+		PopularPosts: cache.dashboardPosts(popular),
+	}
+	cache.RUnlock()
+
+	var buf bytes.Buffer
+	if err := dashboardTemplate.Execute(&buf, view); err != nil {
+		log.Printf("[%s] Error rendering dashboard: %v", cache.name, err)
+		c.String(http.StatusInternalServerError, "Failed to render dashboard")
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+func (s *snapshot) dashboardPosts(posts []Post) []dashboardPost {
+	rows := make([]dashboardPost, 0, len(posts))
+	for _, post := range previewPosts(posts) {
+		count, ok := s.postComments[post.ID]
+		rows = append(rows, dashboardPost{
+			ID:            post.ID,
+			Author:        s.users[strconv.Itoa(post.UserID)],
+			Content:       post.Content,
+			Comments:      count,
+			CommentsKnown: ok,
+		})
+	}
+	return rows
+}
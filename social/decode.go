@@ -0,0 +1,162 @@
+package social
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+)
+
+// errSchema marks an upstream payload that matches none of the shapes the
+// test server is known to send. It is an apperr.ErrDecode.
+var errSchema = apperr.Wrap(apperr.ErrDecode, "unrecognized upstream payload")
+
+// decodeEnvelope decodes the value under the first of keys present in the
+// response object into v. Any key after the first is a known variant of
+// the upstream schema, and using one is logged and counted.
+func decodeEnvelope(result map[string]json.RawMessage, v interface{}, keys ...string) error {
+	for i, key := range keys {
+		raw, ok := result[key]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, v); err != nil {
+			return fmt.Errorf("%w: %q: %v", errSchema, key, err)
+		}
+		if i > 0 {
+			schemaFallback(fmt.Sprintf("envelope_%s_as_%s", keys[0], key))
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: missing %q", errSchema, strings.Join(keys, `" or "`))
+}
+
+// decodeUsersStream walks a {"users": {"<id>": "<name>", ...}} object token
+// by token, calling fn for each user without buffering the listing.
+// Unrelated top-level keys are skipped.
+func decodeUsersStream(dec *json.Decoder, fn func(userID, name string)) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	found := false
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key != "users" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		found = true
+		if err := expectDelim(dec, '{'); err != nil {
+			return err
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			userID, _ := tok.(string)
+
+			var name string
+			if err := dec.Decode(&name); err != nil {
+				return fmt.Errorf("%w: user %s: %v", errSchema, userID, err)
+			}
+			fn(userID, name)
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%w: missing \"users\"", errSchema)
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("%w: expected %v, got %v", errSchema, want, tok)
+	}
+	return nil
+}
+
+func schemaFallback(variant string) {
+	log.Printf("Upstream schema drift: decoded %s", variant)
+	schemaFallbacks.WithLabelValues(variant).Inc()
+}
+
+// upstreamPost is a Post as the upstream sends it.
+type upstreamPost Post
+
+// UnmarshalJSON accepts userid as either a number or a numeric string.
+func (p *upstreamPost) UnmarshalJSON(data []byte) error {
+	type plain upstreamPost
+	var raw struct {
+		plain
+		UserID json.RawMessage `json:"userid"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*p = upstreamPost(raw.plain)
+	if len(raw.UserID) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(raw.UserID, &p.UserID); err == nil {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw.UserID, &s); err != nil {
+		return fmt.Errorf("%w: post %d userid %s", errSchema, p.ID, raw.UserID)
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("%w: post %d userid %q", errSchema, p.ID, s)
+	}
+	p.UserID = id
+	schemaFallback("post_userid_as_string")
+	return nil
+}
+
+// UnmarshalJSON accepts the content under "comment" when "content" is
+// missing.
+func (c *Comment) UnmarshalJSON(data []byte) error {
+	type plain Comment
+	var raw struct {
+		plain
+		Content *string `json:"content"`
+		Comment *string `json:"comment"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*c = Comment(raw.plain)
+	switch {
+	case raw.Content != nil:
+		c.Content = *raw.Content
+	case raw.Comment != nil:
+		c.Content = *raw.Comment
+		schemaFallback("comment_content_as_comment")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package social
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// BenchmarkUsersListing50k compares decoding a 50k-user listing into a map,
+// as refreshes did before streaming, with walking it user by user.
+func BenchmarkUsersListing50k(b *testing.B) {
+	listing, err := json.Marshal(map[string]interface{}{"users": newLargeUpstream(50000).users})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var result map[string]json.RawMessage
+			if err := json.Unmarshal(listing, &result); err != nil {
+				b.Fatal(err)
+			}
+			var users map[string]string
+			if err := decodeEnvelope(result, &users, "users"); err != nil {
+				b.Fatal(err)
+			}
+			if len(users) != 50000 {
+				b.Fatalf("decoded %d users", len(users))
+			}
+		}
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			n := 0
+			err := decodeUsersStream(json.NewDecoder(bytes.NewReader(listing)), func(userID, name string) { n++ })
+			if err != nil {
+				b.Fatal(err)
+			}
+			if n != 50000 {
+				b.Fatalf("decoded %d users", n)
+			}
+		}
+	})
+}
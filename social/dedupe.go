@@ -0,0 +1,67 @@
+package social
+
+import (
+	"crypto/sha256"
+	"strings"
+)
+
+// reindex recomputes the data derived from posts and users after any change
+// to them. The caller must hold the write lock.
+func (s *snapshot) reindex() {
+	s.markDuplicates()
+	s.buildNameIndex()
+}
+
+// markDuplicates points every post at the earliest post sharing its
+// normalized content.
+func (s *snapshot) markDuplicates() {
+	earliest := make(map[[sha256.Size]byte]int, len(s.posts))
+	for _, post := range s.posts {
+		h := contentHash(post.Content)
+		if id, ok := earliest[h]; !ok || post.ID < id {
+			earliest[h] = post.ID
+		}
+	}
+
+	for i := range s.posts {
+		post := &s.posts[i]
+		post.DuplicateOf = 0
+		if id := earliest[contentHash(post.Content)]; id != post.ID {
+			post.DuplicateOf = id
+		}
+	}
+}
+
+// contentHash hashes content after lowercasing it and collapsing runs of
+// whitespace, so trivially reformatted reposts collide.
+func contentHash(content string) [sha256.Size]byte {
+	normalized := strings.Join(strings.Fields(strings.ToLower(content)), " ")
+	return sha256.Sum256([]byte(normalized))
+}
+
+// deduped returns a view of the snapshot with duplicate posts folded into
+// their earliest instance, whose comment count becomes the sum across the
+// group. Users and the search index are shared, not copied.
+func (s *snapshot) deduped() *snapshot {
+	view := &snapshot{
+		users:          s.users,
+		userPostCounts: s.userPostCounts,
+		posts:          make([]Post, 0, len(s.posts)),
+		postComments:   make(map[int]int, len(s.postComments)),
+		nameIndex:      s.nameIndex,
+	}
+
+	for _, post := range s.posts {
+		canonical := post.ID
+		if post.DuplicateOf != 0 {
+			canonical = post.DuplicateOf
+		} else {
+			view.posts = append(view.posts, post)
+		}
+		if count, ok := s.postComments[post.ID]; ok {
+			view.postComments[canonical] += count
+		}
+	}
+
+	return view
+}
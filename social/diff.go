@@ -0,0 +1,119 @@
+package social
+
+import (
+	"context"
+	"sort"
+)
+
+// snapshotDiff describes what committing a freshly fetched snapshot would
+// change about the cache.
+type snapshotDiff struct {
+	UsersAdded     []string        `json:"usersAdded"`
+	UsersRemoved   []string        `json:"usersRemoved"`
+	PostsAdded     []int           `json:"postsAdded"`
+	PostsRemoved   []int           `json:"postsRemoved"`
+	CommentChanges []commentChange `json:"commentChanges"`
+	TopUsersBefore []UserPostCount `json:"topUsersBefore"`
+	TopUsersAfter  []UserPostCount `json:"topUsersAfter"`
+	TopUsersChange bool            `json:"topUsersChanged"`
+}
+
+type commentChange struct {
+	PostID int `json:"postId"`
+	Before int `json:"before"`
+	After  int `json:"after"`
+}
+
+// dryRun fetches a snapshot exactly like a real refresh would, holding the
+// refresh lock so it never runs alongside one, and diffs it against the
+// cache without committing it.
+func (c *Cache) dryRun(ctx context.Context) (*snapshotDiff, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	snap, _, err := c.fetchSnapshot(ctx, true, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	return diffSnapshots(&c.snapshot, snap), nil
+}
+
+func diffSnapshots(before, after *snapshot) *snapshotDiff {
+	diff := &snapshotDiff{
+		UsersAdded:     []string{},
+		UsersRemoved:   []string{},
+		PostsAdded:     []int{},
+		PostsRemoved:   []int{},
+		CommentChanges: []commentChange{},
+	}
+
+	for userID := range after.users {
+		if _, ok := before.users[userID]; !ok {
+			diff.UsersAdded = append(diff.UsersAdded, userID)
+		}
+	}
+	for userID := range before.users {
+		if _, ok := after.users[userID]; !ok {
+			diff.UsersRemoved = append(diff.UsersRemoved, userID)
+		}
+	}
+	sort.Slice(diff.UsersAdded, func(i, j int) bool { return lessID(diff.UsersAdded[i], diff.UsersAdded[j]) })
+	sort.Slice(diff.UsersRemoved, func(i, j int) bool { return lessID(diff.UsersRemoved[i], diff.UsersRemoved[j]) })
+
+	beforePosts := postIDs(before.posts)
+	afterPosts := postIDs(after.posts)
+	for postID := range afterPosts {
+		if !beforePosts[postID] {
+			diff.PostsAdded = append(diff.PostsAdded, postID)
+		}
+	}
+	for postID := range beforePosts {
+		if !afterPosts[postID] {
+			diff.PostsRemoved = append(diff.PostsRemoved, postID)
+		}
+	}
+	sort.Ints(diff.PostsAdded)
+	sort.Ints(diff.PostsRemoved)
+
+	for postID := range afterPosts {
+		if !beforePosts[postID] {
+			continue
+		}
+		if b, a := before.postComments[postID], after.postComments[postID]; b != a {
+			diff.CommentChanges = append(diff.CommentChanges, commentChange{PostID: postID, Before: b, After: a})
+		}
+	}
+	sort.Slice(diff.CommentChanges, func(i, j int) bool {
+		return diff.CommentChanges[i].PostID < diff.CommentChanges[j].PostID
+	})
+
+	diff.TopUsersBefore = before.rankUsers(defaultRankOptions())
+	diff.TopUsersAfter = after.rankUsers(defaultRankOptions())
+	diff.TopUsersChange = !sameRanking(diff.TopUsersBefore, diff.TopUsersAfter)
+
+	return diff
+}
+
+func postIDs(posts []Post) map[int]bool {
+	ids := make(map[int]bool, len(posts))
+	for _, post := range posts {
+		ids[post.ID] = true
+	}
+	return ids
+}
+
+func sameRanking(a, b []UserPostCount) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].UserID != b[i].UserID || a[i].Count != b[i].Count {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package social
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDryRunThenCommitRemovesUsers(t *testing.T) {
+	c, u := newTestCache(t)
+	ctx := context.Background()
+	if _, err := c.refresh(ctx, true, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	u.set(func(u *fakeUpstream) {
+		delete(u.users, "2")
+		delete(u.posts, "2")
+		u.users["3"] = "Chen"
+		u.posts["3"] = []Post{{ID: 30, UserID: 3, Content: "third"}}
+		u.comments[10] = append(u.comments[10], Comment{ID: 101, PostID: 10, Content: "agreed"})
+	})
+
+	diff, err := c.dryRun(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &snapshotDiff{
+		UsersAdded:     []string{"3"},
+		UsersRemoved:   []string{"2"},
+		PostsAdded:     []int{30},
+		PostsRemoved:   []int{20},
+		CommentChanges: []commentChange{{PostID: 10, Before: 1, After: 2}},
+	}
+	got := *diff
+	got.TopUsersBefore, got.TopUsersAfter, got.TopUsersChange = nil, nil, false
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("dry run diff = %+v, want %+v", got, want)
+	}
+	c.RLock()
+	_, kept := c.users["2"]
+	c.RUnlock()
+	if !kept {
+		t.Fatal("dry run changed the cache")
+	}
+
+	// Committing the same snapshot must do what the dry run reported.
+	if _, err := c.refresh(ctx, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.RLock()
+	users := c.users
+	c.RUnlock()
+	if wantUsers := map[string]string{"1": "Ada", "3": "Chen"}; !reflect.DeepEqual(users, wantUsers) {
+		t.Errorf("users after commit = %v, want %v", users, wantUsers)
+	}
+
+	diff, err = c.dryRun(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.UsersAdded)+len(diff.UsersRemoved)+len(diff.PostsAdded)+len(diff.PostsRemoved)+len(diff.CommentChanges) != 0 {
+		t.Errorf("dry run after commit = %+v, want no changes", diff)
+	}
+}
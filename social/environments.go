@@ -0,0 +1,108 @@
+package social
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultEnvName = "default"
+	envHeader      = "X-Upstream-Env"
+	envContextKey  = "upstreamEnv"
+)
+
+// upstreamEnv describes one named instance of the social test server.
+type upstreamEnv struct {
+	BaseURL string `json:"baseURL"`
+	Token   string `json:"token"`
+}
+
+// loadEnvironments builds one cache per configured upstream environment.
+//
+// UPSTREAM_ENVS is a JSON object mapping names to environments, e.g.
+// {"prod":{"baseURL":"http://20.244.56.144/test","token":"..."}}. Without it
+// a single "default" environment points at UPSTREAM_BASE_URL, or baseURL,
+// using UPSTREAM_TOKEN.
+// UPSTREAM_DEFAULT_ENV names the environment used when no X-Upstream-Env
+// header is sent; it may be omitted when only one environment exists.
+func loadEnvironments() (map[string]*Cache, string, error) {
+	defaultURL := baseURL
+	if raw := settings.Get("UPSTREAM_BASE_URL"); raw != "" {
+		defaultURL = raw
+	}
+	envs := map[string]upstreamEnv{
+		defaultEnvName: {BaseURL: defaultURL, Token: settings.Get("UPSTREAM_TOKEN")},
+	}
+
+	if raw := settings.Get("UPSTREAM_ENVS"); raw != "" {
+		envs = nil
+		if err := json.Unmarshal([]byte(raw), &envs); err != nil {
+			return nil, "", fmt.Errorf("invalid %s: %v", settings.Name("UPSTREAM_ENVS"), err)
+		}
+		if len(envs) == 0 {
+			return nil, "", fmt.Errorf("%s defines no environments", settings.Name("UPSTREAM_ENVS"))
+		}
+	}
+
+	caches := make(map[string]*Cache, len(envs))
+	for name, env := range envs {
+		if env.BaseURL == "" {
+			return nil, "", fmt.Errorf("upstream environment %q has no baseURL", name)
+		}
+		env.BaseURL = strings.TrimRight(env.BaseURL, "/")
+		caches[name] = newCache(name, env)
+	}
+
+	def := settings.Get("UPSTREAM_DEFAULT_ENV")
+	if def == "" {
+		if len(caches) != 1 {
+			return nil, "", fmt.Errorf("%s is required with multiple environments", settings.Name("UPSTREAM_DEFAULT_ENV"))
+		}
+		for name := range caches {
+			def = name
+		}
+	}
+	if _, ok := caches[def]; !ok {
+		return nil, "", fmt.Errorf("%s %q is not a configured environment", settings.Name("UPSTREAM_DEFAULT_ENV"), def)
+	}
+
+	return caches, def, nil
+}
+
+// selectEnvironment resolves the X-Upstream-Env header to a cache and
+// stores it on the request context for the handlers.
+func selectEnvironment(caches map[string]*Cache, def string) gin.HandlerFunc {
+	names := make([]string, 0, len(caches))
+	for name := range caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return func(c *gin.Context) {
+		name := c.GetHeader(envHeader)
+		if name == "" {
+			name = def
+		}
+
+		cache, ok := caches[name]
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Unknown upstream environment %q. Use one of: %s", name, strings.Join(names, ", ")),
+			})
+			return
+		}
+
+		c.Set(envContextKey, cache)
+		c.Next()
+	}
+}
+
+// envCache returns the cache selected for this request.
+func envCache(c *gin.Context) *Cache {
+	return c.MustGet(envContextKey).(*Cache)
+}
@@ -0,0 +1,183 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/social/socialclient"
+)
+
+func TestLoadEnvironments(t *testing.T) {
+	previous := settings
+	t.Cleanup(func() { settings = previous })
+	settings = config.FromEnv()
+
+	tests := []struct {
+		name    string
+		envs    string
+		def     string
+		names   string
+		wantDef string
+		err     string
+	}{
+		{"single default", "", "", "default", "default", ""},
+		{"one named", `{"prod":{"baseURL":"http://prod/"}}`, "", "prod", "prod", ""},
+		{"two with a default", `{"prod":{"baseURL":"http://prod"},"staging":{"baseURL":"http://staging"}}`, "staging", "prod,staging", "staging", ""},
+		{"two without a default", `{"prod":{"baseURL":"http://prod"},"staging":{"baseURL":"http://staging"}}`, "", "", "", "is required"},
+		{"unknown default", `{"prod":{"baseURL":"http://prod"}}`, "dev", "", "", "not a configured environment"},
+		{"no base URL", `{"prod":{"token":"t"}}`, "", "", "", "has no baseURL"},
+		{"empty", `{}`, "", "", "", "defines no environments"},
+		{"not JSON", `prod=http://prod`, "", "", "", "invalid"},
+	}
+	for _, tt := range tests {
+		t.Setenv("UPSTREAM_ENVS", tt.envs)
+		t.Setenv("UPSTREAM_DEFAULT_ENV", tt.def)
+
+		caches, def, err := loadEnvironments()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want one saying %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var names []string
+		for name := range caches {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if got := strings.Join(names, ","); got != tt.names || def != tt.wantDef {
+			t.Errorf("%s: environments %s, default %s, want %s, default %s", tt.name, got, def, tt.names, tt.wantDef)
+		}
+	}
+
+	t.Setenv("UPSTREAM_ENVS", `{"prod":{"baseURL":"http://prod/api/","token":"secret"}}`)
+	t.Setenv("UPSTREAM_DEFAULT_ENV", "")
+	caches, _, err := loadEnvironments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := caches["prod"].upstream; env.BaseURL != "http://prod/api" || env.Token != "secret" {
+		t.Errorf("prod = %+v, want its base URL without the trailing slash and its token", env)
+	}
+}
+
+// twoEnvironments returns caches "a", over the test upstream, and "b", over
+// one whose only user is Zoe.
+func twoEnvironments(t *testing.T) (map[string]*Cache, *fakeUpstream, *fakeUpstream) {
+	t.Helper()
+	a, ua := newTestCache(t)
+	b, ub := newTestCache(t)
+	ub.set(func(u *fakeUpstream) {
+		u.users = map[string]string{"7": "Zoe"}
+		u.posts = map[string][]Post{"7": {{ID: 70, UserID: 7, Content: "hi"}}}
+		u.comments = map[int][]Comment{}
+	})
+	a.name, b.name = "a", "b"
+	return map[string]*Cache{"a": a, "b": b}, ua, ub
+}
+
+func TestEnvironmentsAreIsolated(t *testing.T) {
+	caches, ua, ub := twoEnvironments(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/users", selectEnvironment(caches, "a"), getTopUsers)
+
+	get := func(env string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		if env != "" {
+			req.Header.Set(envHeader, env)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp socialclient.UsersResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		var names []string
+		for _, u := range resp.Users {
+			names = append(names, u.Name)
+		}
+		return w.Code, strings.Join(names, ",")
+	}
+
+	if status, names := get(""); status != http.StatusOK || names != "Ada,Brian" {
+		t.Errorf("default environment: %d %s, want a's users", status, names)
+	}
+	if ub.listed() != 0 {
+		t.Errorf("b listed %d times serving a, want 0", ub.listed())
+	}
+	if status, names := get("b"); status != http.StatusOK || names != "Zoe" {
+		t.Errorf("environment b: %d %s, want b's user", status, names)
+	}
+	if ua.listed() != 1 {
+		t.Errorf("a listed %d times, want once for its own request", ua.listed())
+	}
+	if status, _ := get("c"); status != http.StatusBadRequest {
+		t.Errorf("unknown environment: status %d, want 400", status)
+	}
+
+	// One environment failing leaves the other's data and schedule alone.
+	ub.set(func(u *fakeUpstream) { u.failing = true })
+	if _, err := caches["b"].refresh(context.Background(), true, nil); err == nil {
+		t.Fatal("refresh of the failing environment succeeded")
+	}
+	if status, names := get(""); status != http.StatusOK || names != "Ada,Brian" {
+		t.Errorf("default environment after b failed: %d %s, want a's users", status, names)
+	}
+	if status, names := get("b"); status != http.StatusOK || names != "Zoe" {
+		t.Errorf("environment b after failing: %d %s, want its last data", status, names)
+	}
+	for name, want := range map[string]int{"a": 0, "b": 1} {
+		c := caches[name]
+		c.RLock()
+		streak := c.failureStreak
+		c.RUnlock()
+		if streak != want {
+			t.Errorf("%s failure streak = %d, want %d", name, streak, want)
+		}
+	}
+}
+
+func TestEnvironmentsRefreshIndependently(t *testing.T) {
+	setTuning(t, func(tun *tunables) {
+		tun.refreshInterval = time.Second
+		tun.refreshBackoffMax = time.Minute
+	})
+	clk := useFakeClock(t, 2)
+	start := clk.Now()
+	caches, ua, ub := twoEnvironments(t)
+	ua.set(func(u *fakeUpstream) { u.failing = true })
+	runRefreshLoop(t, caches["a"])
+	runRefreshLoop(t, caches["b"])
+
+	// b keeps to the normal interval while a backs off, ending its waits
+	// 2, 6 and 14 seconds in.
+	var backoffs []string
+	seconds := 0
+	for clk.since(start) < 15*time.Second {
+		if d := clk.elapse(t); d == time.Second {
+			seconds++
+		} else {
+			backoffs = append(backoffs, fmt.Sprintf("%v at %v", d, clk.since(start)))
+		}
+	}
+	if got := strings.Join(backoffs, ", "); got != "2s at 2s, 4s at 6s, 8s at 14s" {
+		t.Errorf("a waited %s, want 2s at 2s, 4s at 6s, 8s at 14s", got)
+	}
+	clk.settle(t)
+	if seconds != 15 || ub.listed() != 16 {
+		t.Errorf("b waited the normal interval %d times and listed its users %d times in 15s, want 15 and 16", seconds, ub.listed())
+	}
+}
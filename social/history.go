@@ -0,0 +1,105 @@
+package social
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refreshRecord summarizes one refresh attempt.
+type refreshRecord struct {
+	Timestamp  time.Time `json:"timestamp"`
+	DurationMs int64     `json:"durationMs"`
+	Users      int       `json:"users"`
+	Posts      int       `json:"posts"`
+	Comments   int       `json:"comments"`
+	Changes    int       `json:"changes"`
+	Errors     int       `json:"errors"`
+	Error      string    `json:"error,omitempty"`
+
+	CommentsSkipped int `json:"commentsSkipped,omitempty"`
+}
+
+// refreshHistory is a bounded, oldest-first log of refresh records.
+type refreshHistory struct {
+	mu      sync.Mutex
+	records []refreshRecord
+	size    int
+}
+
+func newRefreshHistory(size int) *refreshHistory {
+	return &refreshHistory{size: size}
+}
+
+func (h *refreshHistory) add(r refreshRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, r)
+	if len(h.records) > h.size {
+		h.records = append([]refreshRecord(nil), h.records[len(h.records)-h.size:]...)
+	}
+}
+
+// newest returns up to limit records, newest first.
+func (h *refreshHistory) newest(limit int) []refreshRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make([]refreshRecord, 0, limit)
+	for i := len(h.records) - 1; i >= 0 && len(out) < limit; i-- {
+		out = append(out, h.records[i])
+	}
+	return out
+}
+
+// all returns a copy of every record, oldest first.
+func (h *refreshHistory) all() []refreshRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]refreshRecord(nil), h.records...)
+}
+
+// restore replaces the records, keeping only the newest that fit.
+func (h *refreshHistory) restore(records []refreshRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(records) > h.size {
+		records = records[len(records)-h.size:]
+	}
+	h.records = append([]refreshRecord(nil), records...)
+}
+
+// recordRefresh appends to the history and persists it. It must be called
+// without holding the cache lock.
+func (c *Cache) recordRefresh(r refreshRecord) {
+	c.history.add(r)
+	c.saveState()
+}
+
+func (d *snapshotDiff) changes() int {
+	return len(d.UsersAdded) + len(d.UsersRemoved) +
+		len(d.PostsAdded) + len(d.PostsRemoved) + len(d.CommentChanges)
+}
+
+func (s *snapshot) commentTotal() int {
+	total := 0
+	for _, count := range s.postComments {
+		total += count
+	}
+	return total
+}
+
+func getRefreshHistory(c *gin.Context) {
+	limit, ok := intQuery(c, "limit", 20, 1, refreshHistorySize)
+	if !ok {
+		return
+	}
+
+	cache := envCache(c)
+	c.JSON(http.StatusOK, gin.H{"history": cache.history.newest(limit)})
+}
@@ -0,0 +1,215 @@
+package social
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Lifecycle states of a forced refresh job.
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// refreshJob tracks one forced refresh so callers can poll its progress.
+type refreshJob struct {
+	mu sync.Mutex
+
+	id         string
+	env        string
+	state      string
+	phase      string
+	usersTotal int
+	usersDone  int
+	posts      int
+	comments   int
+	summary    *refreshSummary
+	err        string
+	createdAt  time.Time
+	finishedAt time.Time
+}
+
+// refreshSummary is what a finished refresh reports.
+type refreshSummary struct {
+	LastUpdated time.Time `json:"lastUpdated"`
+	DurationMs  int64     `json:"durationMs"`
+	Users       int       `json:"users"`
+	Posts       int       `json:"posts"`
+}
+
+// refreshJobStatus is the JSON view of a job.
+type refreshJobStatus struct {
+	ID         string          `json:"id"`
+	Env        string          `json:"environment"`
+	State      string          `json:"state"`
+	Phase      string          `json:"phase,omitempty"`
+	Progress   *jobProgress    `json:"progress,omitempty"`
+	Summary    *refreshSummary `json:"summary,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+type jobProgress struct {
+	UsersTotal int `json:"usersTotal"`
+	UsersDone  int `json:"usersDone"`
+	Posts      int `json:"posts"`
+	Comments   int `json:"comments"`
+}
+
+// The progress hooks below accept a nil job so the fetch pipeline can call
+// them unconditionally.
+
+func (j *refreshJob) setPhase(phase string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.state = jobRunning
+	j.phase = phase
+}
+
+// userSeen counts a user read from the users stream. The total grows as the
+// listing is decoded.
+func (j *refreshJob) userSeen() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.usersTotal++
+}
+
+func (j *refreshJob) userDone(posts, comments int) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.usersDone++
+	j.posts += posts
+	j.comments += comments
+}
+
+func (j *refreshJob) finish(summary *refreshSummary, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.phase = ""
+	j.finishedAt = time.Now()
+	if err != nil {
+		j.state = jobFailed
+		j.err = err.Error()
+		return
+	}
+	j.state = jobDone
+	j.summary = summary
+}
+
+func (j *refreshJob) status() refreshJobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	s := refreshJobStatus{
+		ID:        j.id,
+		Env:       j.env,
+		State:     j.state,
+		Phase:     j.phase,
+		Summary:   j.summary,
+		Error:     j.err,
+		CreatedAt: j.createdAt,
+	}
+	if j.state != jobQueued {
+		s.Progress = &jobProgress{
+			UsersTotal: j.usersTotal,
+			UsersDone:  j.usersDone,
+			Posts:      j.posts,
+			Comments:   j.comments,
+		}
+	}
+	if !j.finishedAt.IsZero() {
+		finished := j.finishedAt
+		s.FinishedAt = &finished
+	}
+	return s
+}
+
+// jobRegistry keeps the most recent refresh jobs, dropping the oldest past
+// max and any finished more than ttl ago.
+type jobRegistry struct {
+	mu    sync.Mutex
+	jobs  map[string]*refreshJob
+	order []string
+	max   int
+	ttl   time.Duration
+}
+
+var refreshJobs = newJobRegistry(100, time.Hour)
+
+func newJobRegistry(max int, ttl time.Duration) *jobRegistry {
+	return &jobRegistry{
+		jobs: make(map[string]*refreshJob),
+		max:  max,
+		ttl:  ttl,
+	}
+}
+
+func (r *jobRegistry) create(env string) *refreshJob {
+	job := &refreshJob{
+		id:        newJobID(),
+		env:       env,
+		state:     jobQueued,
+		createdAt: time.Now(),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune()
+	for len(r.order) >= r.max {
+		delete(r.jobs, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.jobs[job.id] = job
+	r.order = append(r.order, job.id)
+	return job
+}
+
+func (r *jobRegistry) get(id string) (*refreshJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune()
+	job, ok := r.jobs[id]
+	return job, ok
+}
+
+// prune drops finished jobs older than the TTL. The caller must hold mu.
+func (r *jobRegistry) prune() {
+	kept := r.order[:0]
+	for _, id := range r.order {
+		job := r.jobs[id]
+		job.mu.Lock()
+		expired := !job.finishedAt.IsZero() && time.Since(job.finishedAt) > r.ttl
+		job.mu.Unlock()
+
+		if expired {
+			delete(r.jobs, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	r.order = kept
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
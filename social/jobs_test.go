@@ -0,0 +1,143 @@
+package social
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jobRouter serves the refresh job routes with c as the environment.
+func jobRouter(c *Cache) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/admin/refresh", func(ctx *gin.Context) { ctx.Set(envContextKey, c) }, postRefresh)
+	r.GET("/admin/refresh/:id", getRefreshJob)
+	return r
+}
+
+func serveJob(t *testing.T, r *gin.Engine, method, path string) (int, refreshJobStatus, *httptest.ResponseRecorder) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	var status refreshJobStatus
+	json.Unmarshal(w.Body.Bytes(), &status)
+	return w.Code, status, w
+}
+
+func TestRefreshJobLifecycle(t *testing.T) {
+	c, u := newTestCache(t)
+	u.set(func(u *fakeUpstream) { u.delay = 30 * time.Millisecond })
+	r := jobRouter(c)
+
+	code, job, w := serveJob(t, r, http.MethodPost, "/admin/refresh")
+	if code != http.StatusAccepted || job.ID == "" || job.Env != "test" {
+		t.Fatalf("status %d: %s, want 202 with a job", code, w.Body)
+	}
+	if job.State != jobQueued && job.State != jobRunning {
+		t.Errorf("new job %s, want queued or running", job.State)
+	}
+	if loc := w.Header().Get("Location"); loc != "/admin/refresh/"+job.ID {
+		t.Errorf("Location = %q", loc)
+	}
+
+	// Poll until the job finishes, seeing it run on the way.
+	phases := map[string]bool{}
+	deadline := time.Now().Add(5 * time.Second)
+	for job.State != jobDone && job.State != jobFailed {
+		if time.Now().After(deadline) {
+			t.Fatalf("job still %s", job.State)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if code, job, w = serveJob(t, r, http.MethodGet, "/admin/refresh/"+job.ID); code != http.StatusOK {
+			t.Fatalf("polling: status %d: %s", code, w.Body)
+		}
+		if job.State == jobRunning {
+			phases[job.Phase] = true
+			if job.Progress == nil {
+				t.Error("running job without progress")
+			}
+		}
+	}
+
+	if len(phases) == 0 {
+		t.Error("never saw the job running")
+	}
+	if job.State != jobDone || job.Error != "" || job.FinishedAt == nil || job.Phase != "" {
+		t.Errorf("finished job = %+v, want done without an error", job)
+	}
+	if job.Summary == nil || job.Summary.Users != 2 || job.Summary.Posts != 2 {
+		t.Errorf("summary = %+v, want 2 users and 2 posts", job.Summary)
+	}
+	if p := job.Progress; p == nil || p.UsersTotal != 2 || p.UsersDone != 2 || p.Posts != 2 || p.Comments != 2 {
+		t.Errorf("progress = %+v, want both users done and the comments of both posts counted", p)
+	}
+}
+
+func TestRefreshJobFailure(t *testing.T) {
+	c, u := newTestCache(t)
+	u.set(func(u *fakeUpstream) { u.failing = true })
+	r := jobRouter(c)
+
+	code, _, w := serveJob(t, r, http.MethodPost, "/admin/refresh?wait=true")
+	var resp struct {
+		Error string
+		Job   refreshJobStatus
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if code == http.StatusOK || resp.Error == "" {
+		t.Errorf("waiting on a failing refresh: status %d: %s", code, w.Body)
+	}
+	if resp.Job.State != jobFailed || resp.Job.Error == "" || resp.Job.Summary != nil {
+		t.Errorf("job = %+v, want failed with its error", resp.Job)
+	}
+
+	// The failed job can still be looked up.
+	if code, job, _ := serveJob(t, r, http.MethodGet, "/admin/refresh/"+resp.Job.ID); code != http.StatusOK || job.State != jobFailed {
+		t.Errorf("failed job: status %d, state %s", code, job.State)
+	}
+}
+
+func TestRefreshJobUnknownAndExpired(t *testing.T) {
+	previous := refreshJobs
+	t.Cleanup(func() { refreshJobs = previous })
+	refreshJobs = newJobRegistry(100, 200*time.Millisecond)
+
+	c, _ := newTestCache(t)
+	r := jobRouter(c)
+
+	if code, _, _ := serveJob(t, r, http.MethodGet, "/admin/refresh/0123456789abcdef"); code != http.StatusNotFound {
+		t.Errorf("unknown job: status %d, want 404", code)
+	}
+
+	_, job, _ := serveJob(t, r, http.MethodPost, "/admin/refresh?wait=true")
+	if code, _, _ := serveJob(t, r, http.MethodGet, "/admin/refresh/"+job.ID); code != http.StatusOK {
+		t.Errorf("finished job within its TTL: status %d, want 200", code)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if code, _, _ := serveJob(t, r, http.MethodGet, "/admin/refresh/"+job.ID); code != http.StatusNotFound {
+		t.Errorf("expired job: status %d, want 404", code)
+	}
+}
+
+func TestJobRegistryBounds(t *testing.T) {
+	r := newJobRegistry(2, time.Millisecond)
+	first, second := r.create("a"), r.create("a")
+	third := r.create("a")
+	if _, ok := r.get(first.id); ok {
+		t.Error("oldest job kept past the limit")
+	}
+
+	// Only finished jobs expire.
+	second.finish(&refreshSummary{}, nil)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := r.get(second.id); ok {
+		t.Error("finished job kept past its TTL")
+	}
+	if _, ok := r.get(third.id); !ok {
+		t.Error("unfinished job expired")
+	}
+}
@@ -0,0 +1,57 @@
+package social
+
+import (
+	"context"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// commentTargets picks which of a user's posts get their comments fetched
+// during a refresh. In eager mode that is all of them; in lazy mode it is
+// the lazyCommentsPerUser newest posts plus any currently popular ones, and
+// the rest are fetched on demand by backfillComments.
+func commentTargets(posts []Post, popular map[int]bool) []Post {
+	perUser := tuning().lazyCommentsPerUser
+	if !lazyComments || len(posts) <= perUser {
+		return posts
+	}
+
+	sorted := make([]Post, len(posts))
+	copy(sorted, posts)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID > sorted[j].ID
+	})
+
+	targets := sorted[:perUser:perUser]
+	for _, post := range sorted[perUser:] {
+		if popular[post.ID] {
+			targets = append(targets, post)
+		}
+	}
+	return targets
+}
+
+// backfillComments fetches the comment count of a post skipped by a lazy
+// refresh and records it in the cache.
+func (c *Cache) backfillComments(ctx context.Context, postID int) (_ int, err error) {
+	ctx, span := c.startSpan(ctx, "Cache.backfillComments", attribute.Int("post.id", postID))
+	defer func() { endSpan(span, err) }()
+
+	comments, err := c.fetchPostComments(ctx, postID)
+	if err != nil {
+		return 0, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	// The post may have disappeared in a refresh while we were fetching.
+	for _, post := range c.posts {
+		if post.ID == postID {
+			c.postComments[postID] = len(comments)
+			break
+		}
+	}
+	return len(comments), nil
+}
@@ -0,0 +1,98 @@
+package social
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+// maintenanceFile holds the maintenance state in stateDir, apart from the
+// environments' files since it covers the whole service.
+const maintenanceFile = "maintenance.json"
+
+// maintenanceRequest is the body of POST /admin/maintenance.
+type maintenanceRequest struct {
+	Enabled       *bool  `json:"enabled"`
+	Message       string `json:"message"`
+	RetryAfterSec int    `json:"retryAfterSec"`
+}
+
+func getMaintenance(m *middleware.Maintenance) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, m.State())
+	}
+}
+
+// postMaintenance turns maintenance mode on or off. The data endpoints
+// answer 503 while it is on; health, metrics, version and admin routes
+// keep working.
+func postMaintenance(m *middleware.Maintenance) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req maintenanceRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Enabled == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Body must be a JSON object with a boolean enabled"})
+			return
+		}
+		if req.RetryAfterSec < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "retryAfterSec must not be negative"})
+			return
+		}
+
+		state := m.Set(middleware.MaintenanceState{
+			Enabled:       *req.Enabled,
+			Message:       req.Message,
+			RetryAfterSec: req.RetryAfterSec,
+		})
+		if state.Enabled {
+			log.Printf("Maintenance mode enabled: %q", state.Message)
+		} else {
+			log.Printf("Maintenance mode disabled")
+		}
+		saveMaintenance(state)
+
+		c.JSON(http.StatusOK, state)
+	}
+}
+
+// saveMaintenance persists the maintenance state when STATE_DIR is set.
+func saveMaintenance(state middleware.MaintenanceState) {
+	if stateDir == "" {
+		return
+	}
+	if err := writeState(maintenanceFile, state); err != nil {
+		log.Printf("Failed to save maintenance state: %v", err)
+	}
+}
+
+// loadMaintenance restores a maintenance mode left on before a restart.
+// Maintenance enabled by configuration stays on regardless.
+func loadMaintenance(m *middleware.Maintenance) {
+	if stateDir == "" {
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(stateDir, maintenanceFile))
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read saved maintenance state: %v", err)
+		return
+	}
+
+	var state middleware.MaintenanceState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Ignoring corrupt saved maintenance state: %v", err)
+		return
+	}
+	if state.Enabled && !m.State().Enabled {
+		state = m.Set(state)
+		log.Printf("Maintenance mode is on since %v: %q", *state.Since, state.Message)
+	}
+}
@@ -0,0 +1,100 @@
+package social
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/Escanor244/713522IT013/internal/middleware"
+)
+
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_request_duration_seconds",
+		Help:    "Latency of upstream requests by endpoint class and status code.",
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"endpoint", "status"})
+
+	upstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_request_errors_total",
+		Help: "Failed upstream requests by endpoint class and status code.",
+	}, []string{"endpoint", "status"})
+
+	refreshPhaseDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cache_refresh_phase_duration_seconds",
+		Help: "Duration of each phase of the last full cache refresh.",
+	}, []string{"phase"})
+
+	refreshDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cache_refresh_duration_seconds",
+		Help: "Duration of the last full cache refresh.",
+	})
+
+	refreshFailureStreak = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cache_refresh_failure_streak",
+		Help: "Consecutive failed refreshes per environment.",
+	}, []string{"env"})
+
+	refreshInterval = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cache_refresh_interval_seconds",
+		Help: "Effective refresh interval per environment after backoff.",
+	}, []string{"env"})
+
+	refreshSkips = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_refresh_paused_skips_total",
+		Help: "Scheduled refreshes skipped while paused, per environment.",
+	}, []string{"env"})
+
+	schemaFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_schema_fallbacks_total",
+		Help: "Upstream payloads decoded through a fallback path, by variant.",
+	}, []string{"variant"})
+
+	cacheResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_responses_total",
+		Help: "Data responses by X-Cache status.",
+	}, []string{"status"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		upstreamDuration,
+		upstreamErrors,
+		refreshPhaseDuration,
+		refreshDuration,
+		refreshFailureStreak,
+		refreshInterval,
+		refreshSkips,
+		cacheResponses,
+		schemaFallbacks,
+	)
+	metricsRegistry.MustRegister(middleware.Collectors()...)
+}
+
+func observeUpstream(endpoint, status string, elapsed time.Duration, failed bool) {
+	upstreamDuration.WithLabelValues(endpoint, status).Observe(elapsed.Seconds())
+	if failed {
+		upstreamErrors.WithLabelValues(endpoint, status).Inc()
+	}
+}
+
+func observeRefresh(t refreshTiming) {
+	refreshDuration.Set(t.Total.Seconds())
+	refreshPhaseDuration.WithLabelValues("users").Set(t.Users.Seconds())
+	refreshPhaseDuration.WithLabelValues("posts").Set(t.Posts.Seconds())
+	refreshPhaseDuration.WithLabelValues("comments").Set(t.Comments.Seconds())
+	refreshPhaseDuration.WithLabelValues("commit").Set(t.Commit.Seconds())
+}
+
+func observeBackoff(env string, streak int, interval time.Duration) {
+	refreshFailureStreak.WithLabelValues(env).Set(float64(streak))
+	refreshInterval.WithLabelValues(env).Set(interval.Seconds())
+}
+
+func metricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+}
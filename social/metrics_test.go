@@ -0,0 +1,100 @@
+package social
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gathered returns the metrics of family name whose labels include labels.
+func gathered(t *testing.T, name string, labels map[string]string) []*dto.Metric {
+	t.Helper()
+	families, err := metricsRegistry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metrics []*dto.Metric
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metric:
+		for _, m := range family.GetMetric() {
+			for _, pair := range m.GetLabel() {
+				if want, ok := labels[pair.GetName()]; ok && want != pair.GetValue() {
+					continue metric
+				}
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// observedUpstream counts the upstream requests of endpoint seen so far.
+func observedUpstream(t *testing.T, endpoint string) uint64 {
+	var n uint64
+	for _, m := range gathered(t, "upstream_request_duration_seconds", map[string]string{"endpoint": endpoint}) {
+		n += m.GetHistogram().GetSampleCount()
+	}
+	return n
+}
+
+func TestUpstreamDurationBuckets(t *testing.T) {
+	// A status of its own keeps the series fresh when the test is rerun.
+	status := fmt.Sprintf("test-buckets-%d", time.Now().UnixNano())
+	for _, d := range []time.Duration{5 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond, 700 * time.Millisecond, 20 * time.Second} {
+		observeUpstream(endpointUsers, status, d, d > time.Second)
+	}
+
+	metrics := gathered(t, "upstream_request_duration_seconds", map[string]string{"endpoint": endpointUsers, "status": status})
+	if len(metrics) != 1 {
+		t.Fatalf("found %d histograms, want 1", len(metrics))
+	}
+	h := metrics[0].GetHistogram()
+	want := map[float64]uint64{0.01: 1, 0.025: 1, 0.05: 3, 0.1: 3, 0.25: 3, 0.5: 3, 1: 4, 2.5: 4, 5: 4, 10: 4}
+	if len(h.GetBucket()) != len(want) {
+		t.Errorf("%d buckets, want %d", len(h.GetBucket()), len(want))
+	}
+	for _, b := range h.GetBucket() {
+		if n, ok := want[b.GetUpperBound()]; !ok || b.GetCumulativeCount() != n {
+			t.Errorf("bucket le=%v counts %d, want %d", b.GetUpperBound(), b.GetCumulativeCount(), n)
+		}
+	}
+	if h.GetSampleCount() != 5 {
+		t.Errorf("count = %d, want 5", h.GetSampleCount())
+	}
+
+	failures := gathered(t, "upstream_request_errors_total", map[string]string{"endpoint": endpointUsers, "status": status})
+	if len(failures) != 1 || failures[0].GetCounter().GetValue() != 1 {
+		t.Errorf("errors = %v, want the one failed request counted", failures)
+	}
+}
+
+func TestRefreshObservesEndpointsAndPhases(t *testing.T) {
+	c, _ := newTestCache(t)
+	before := map[string]uint64{}
+	for _, endpoint := range []string{endpointUsers, endpointPosts, endpointComments} {
+		before[endpoint] = observedUpstream(t, endpoint)
+	}
+
+	if _, err := c.refresh(context.Background(), true, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// One users listing, the posts of two users and the comments of two
+	// posts.
+	for endpoint, want := range map[string]uint64{endpointUsers: 1, endpointPosts: 2, endpointComments: 2} {
+		if got := observedUpstream(t, endpoint) - before[endpoint]; got != want {
+			t.Errorf("%s requests observed = %d, want %d", endpoint, got, want)
+		}
+	}
+	for _, phase := range []string{"users", "posts", "comments", "commit"} {
+		if metrics := gathered(t, "cache_refresh_phase_duration_seconds", map[string]string{"phase": phase}); len(metrics) != 1 {
+			t.Errorf("phase %s: %d gauges, want 1", phase, len(metrics))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package social
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setPaused stops or restarts the environment's scheduled refreshes and
+// persists the change. Forced refreshes from the admin API are unaffected.
+func (c *Cache) setPaused(paused bool) {
+	c.Lock()
+	c.paused = paused
+	if paused {
+		c.pausedAt = time.Now()
+	} else {
+		c.pausedAt = time.Time{}
+	}
+	c.Unlock()
+
+	c.saveState()
+}
+
+func postRefreshPause(c *gin.Context) {
+	setRefreshPaused(c, true)
+}
+
+func postRefreshResume(c *gin.Context) {
+	setRefreshPaused(c, false)
+}
+
+func setRefreshPaused(c *gin.Context, paused bool) {
+	cache := envCache(c)
+	cache.setPaused(paused)
+	if paused {
+		log.Printf("[%s] Background refresh paused", cache.name)
+	} else {
+		log.Printf("[%s] Background refresh resumed", cache.name)
+	}
+
+	cache.RLock()
+	defer cache.RUnlock()
+	c.JSON(http.StatusOK, gin.H{
+		"environment": cache.name,
+		"paused":      cache.paused,
+		"pausedAt":    pausedAt(cache.pausedAt),
+	})
+}
+
+// pausedAt renders an unset pause time as null.
+func pausedAt(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,104 @@
+package social
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateDir, when set via STATE_DIR, is where each environment's
+// operational state (not its cached data) is saved so it survives restarts.
+var stateDir string
+
+// persistedState is the on-disk form of an environment's state.
+type persistedState struct {
+	History  []refreshRecord `json:"history,omitempty"`
+	Paused   bool            `json:"paused,omitempty"`
+	PausedAt time.Time       `json:"pausedAt,omitempty"`
+}
+
+func (c *Cache) statePath() string {
+	return filepath.Join(stateDir, c.name+".json")
+}
+
+// saveState writes the environment's state atomically. Failures are logged
+// rather than returned since persistence is best effort.
+func (c *Cache) saveState() {
+	if stateDir == "" {
+		return
+	}
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.RLock()
+	state := persistedState{
+		History:  c.history.all(),
+		Paused:   c.paused,
+		PausedAt: c.pausedAt,
+	}
+	c.RUnlock()
+
+	if err := writeState(c.name+".json", state); err != nil {
+		log.Printf("[%s] Failed to save state: %v", c.name, err)
+	}
+}
+
+// writeState atomically replaces the file name in stateDir with v encoded
+// as JSON.
+func writeState(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(stateDir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(stateDir, name))
+}
+
+// loadState restores previously saved state. A missing file is normal; an
+// unreadable or corrupt one is logged and ignored.
+func (c *Cache) loadState() {
+	if stateDir == "" {
+		return
+	}
+
+	data, err := os.ReadFile(c.statePath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] Failed to read saved state: %v", c.name, err)
+		return
+	}
+
+	var state persistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("[%s] Ignoring corrupt saved state: %v", c.name, err)
+		return
+	}
+
+	c.history.restore(state.History)
+
+	c.Lock()
+	c.paused = state.Paused
+	c.pausedAt = state.PausedAt
+	c.Unlock()
+	if state.Paused {
+		log.Printf("[%s] Background refresh is paused since %v", c.name, state.PausedAt)
+	}
+}
@@ -0,0 +1,101 @@
+package social
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/social/socialclient"
+)
+
+// serveCache answers a GET of path with handler, as the router does with
+// c as the selected environment.
+func serveCache(c *Cache, route, path string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET(route, func(ctx *gin.Context) { ctx.Set(envContextKey, c) }, handler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+// servedPosts decodes a posts response and returns its post IDs and reason.
+func servedPosts(t *testing.T, w *httptest.ResponseRecorder) ([]int, string) {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp socialclient.PostsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, p := range resp.Posts {
+		ids = append(ids, p.ID)
+	}
+	return ids, resp.Reason
+}
+
+func TestPopularPostsWithoutComments(t *testing.T) {
+	c, u := newTestCache(t)
+	u.set(func(u *fakeUpstream) { u.comments = map[int][]Comment{} })
+
+	w := serveCache(c, "/posts", "/posts?type=popular", getPosts)
+	ids, reason := servedPosts(t, w)
+	if len(ids) != 0 || reason != "no_commented_posts" {
+		t.Errorf("popular posts = %v, reason %q, want none and no_commented_posts", ids, reason)
+	}
+	if !strings.Contains(w.Body.String(), `"posts":[]`) {
+		t.Errorf("body %s, want an empty list of posts rather than null", w.Body)
+	}
+
+	ids, reason = servedPosts(t, serveCache(c, "/posts", "/posts?type=popular&fallback=latest", getPosts))
+	if len(ids) != 2 || ids[0] != 20 || ids[1] != 10 || reason != "no_commented_posts" {
+		t.Errorf("popular posts falling back = %v, reason %q, want [20 10] and no_commented_posts", ids, reason)
+	}
+
+	if w := serveCache(c, "/posts", "/posts?type=popular&fallback=oldest", getPosts); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown fallback: status %d, want 400", w.Code)
+	}
+}
+
+func TestPopularPostsWithComments(t *testing.T) {
+	c, _ := newTestCache(t)
+
+	// With comments the fallback doesn't apply.
+	ids, reason := servedPosts(t, serveCache(c, "/posts", "/posts?type=popular&fallback=latest", getPosts))
+	if len(ids) != 1 || ids[0] != 10 || reason != "" {
+		t.Errorf("popular posts = %v, reason %q, want [10] and no reason", ids, reason)
+	}
+}
+
+func TestLookupStatuses(t *testing.T) {
+	cold, u := newTestCache(t)
+	u.set(func(u *fakeUpstream) { u.failing = true })
+	warm, _ := newTestCache(t)
+
+	tests := []struct {
+		name    string
+		cache   *Cache
+		route   string
+		path    string
+		handler gin.HandlerFunc
+		status  int
+	}{
+		{"post before the first refresh", cold, "/posts/:id", "/posts/10", getPost, http.StatusServiceUnavailable},
+		{"user before the first refresh", cold, "/users/:id", "/users/1", getUser, http.StatusServiceUnavailable},
+		{"unknown post", warm, "/posts/:id", "/posts/99", getPost, http.StatusNotFound},
+		{"unknown user", warm, "/users/:id", "/users/99", getUser, http.StatusNotFound},
+		{"known post", warm, "/posts/:id", "/posts/10", getPost, http.StatusOK},
+		{"known user", warm, "/users/:id", "/users/1", getUser, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := serveCache(tt.cache, tt.route, tt.path, tt.handler); w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+}
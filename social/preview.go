@@ -0,0 +1,41 @@
+package social
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// previewPosts returns copies of posts with their content truncated to
+// the configured preview length in runes.
+func previewPosts(posts []Post) []Post {
+	previews := make([]Post, len(posts))
+	length := tuning().previewLength
+	for i, post := range posts {
+		post.Content, post.ContentTruncated = truncateContent(post.Content, length)
+		previews[i] = post
+	}
+	return previews
+}
+
+// truncateContent shortens s to at most limit runes, backing up to the last
+// word boundary when one exists and appending an ellipsis. It works on runes
+// so multi-byte characters are never split.
+func truncateContent(s string, limit int) (string, bool) {
+	if utf8.RuneCountInString(s) <= limit {
+		return s, false
+	}
+
+	runes := []rune(s)
+	cut := runes[:limit]
+	if !unicode.IsSpace(runes[limit]) {
+		for i := len(cut) - 1; i > 0; i-- {
+			if unicode.IsSpace(cut[i]) {
+				cut = cut[:i]
+				break
+			}
+		}
+	}
+
+	return strings.TrimRightFunc(string(cut), unicode.IsSpace) + "…", true
+}
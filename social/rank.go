@@ -0,0 +1,184 @@
+package social
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics users can be ranked by.
+const (
+	rankByPosts      = "posts"
+	rankByComments   = "comments"
+	rankByEngagement = "engagement"
+)
+
+type rankOptions struct {
+	// by is the metric to sort on: rankByPosts, rankByComments or
+	// rankByEngagement.
+	by string
+	// includeZero ranks users without any posts at the bottom.
+	includeZero bool
+	// includeTies extends the result past the limit to every user tied
+	// with the last one included.
+	includeTies bool
+	// limit is the number of users returned; zero returns everyone.
+	limit int
+}
+
+// defaultRankOptions ranks by post count, limited to the configured top-N.
+func defaultRankOptions() rankOptions {
+	return rankOptions{by: rankByPosts, limit: tuning().topUsersLimit}
+}
+
+func parseRankOptions(c *gin.Context) (rankOptions, error) {
+	opts := defaultRankOptions()
+
+	switch by := c.Query("by"); by {
+	case "":
+	case rankByPosts, rankByComments, rankByEngagement:
+		opts.by = by
+	default:
+		return opts, errors.New("Invalid ranking metric. Use 'posts', 'comments' or 'engagement'")
+	}
+
+	if raw := c.Query("includeZero"); raw != "" {
+		var err error
+		if opts.includeZero, err = strconv.ParseBool(raw); err != nil {
+			return opts, errors.New("Invalid includeZero flag. Use 'true' or 'false'")
+		}
+	}
+
+	switch ties := c.Query("ties"); ties {
+	case "":
+	case "include":
+		opts.includeTies = true
+	default:
+		return opts, errors.New("Invalid ties mode. Use 'include'")
+	}
+
+	return opts, nil
+}
+
+// rankUsers returns the top users by the selected metric, breaking ties by
+// ID. Equal engagement scores tie whatever mix of posts and comments gave
+// them. Tied users share a rank, and ties=include can return more than
+// opts.limit of them.
+func (s *snapshot) rankUsers(opts rankOptions) []UserPostCount {
+	comments := s.userComments()
+
+	var users []UserPostCount
+	for userID, name := range s.users {
+		count := s.userPostCounts[userID]
+		if count == 0 && !opts.includeZero {
+			continue
+		}
+		users = append(users, UserPostCount{
+			UserID:     userID,
+			Name:       name,
+			Count:      count,
+			Comments:   comments[userID],
+			Engagement: engagementScore(count, comments[userID]),
+		})
+	}
+
+	metric := func(u UserPostCount) float64 {
+		switch opts.by {
+		case rankByComments:
+			return float64(u.Comments)
+		case rankByEngagement:
+			return u.Engagement
+		}
+		return float64(u.Count)
+	}
+
+	// Users without posts, there with includeZero, come after everyone
+	// with posts whatever the metric, and never tie with them.
+	tied := func(a, b UserPostCount) bool {
+		return (a.Count == 0) == (b.Count == 0) && metric(a) == metric(b)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if zi, zj := users[i].Count == 0, users[j].Count == 0; zi != zj {
+			return zj
+		}
+		if mi, mj := metric(users[i]), metric(users[j]); mi != mj {
+			return mi > mj
+		}
+		return lessID(users[i].UserID, users[j].UserID)
+	})
+
+	for i := range users {
+		if i > 0 && tied(users[i], users[i-1]) {
+			users[i].Rank = users[i-1].Rank
+		} else {
+			users[i].Rank = i + 1
+		}
+	}
+
+	limit := opts.limit
+	if limit <= 0 {
+		return users
+	}
+	if opts.includeTies {
+		for limit < len(users) && tied(users[limit], users[limit-1]) {
+			limit++
+		}
+	}
+	if len(users) > limit {
+		users = users[:limit]
+	}
+
+	return users
+}
+
+// engagementScore weighs a user's posts against the comments they received.
+func engagementScore(posts, comments int) float64 {
+	t := tuning()
+	return t.engagementPostWeight*float64(posts) + t.engagementCommentWeight*float64(comments)
+}
+
+// currentRanks maps every user ID to their post-count rank, read from the
+// name index which is kept in that order.
+func (s *snapshot) currentRanks() map[string]int {
+	ranks := make(map[string]int, len(s.nameIndex))
+	for _, entry := range s.nameIndex {
+		ranks[entry.user.UserID] = entry.user.Rank
+	}
+	return ranks
+}
+
+// annotateRankChanges fills in PreviousRank and RankChange from the ranking
+// before the last refresh. It is only meaningful for post-count rankings.
+func (s *snapshot) annotateRankChanges(users []UserPostCount) {
+	for i := range users {
+		prev, ok := s.previousRanks[users[i].UserID]
+		if !ok {
+			continue
+		}
+		change := prev - users[i].Rank
+		users[i].PreviousRank = &prev
+		users[i].RankChange = &change
+	}
+}
+
+// userComments totals the comments received across each user's posts.
+func (s *snapshot) userComments() map[string]int {
+	totals := make(map[string]int, len(s.userPostCounts))
+	for _, post := range s.posts {
+		totals[strconv.Itoa(post.UserID)] += s.postComments[post.ID]
+	}
+	return totals
+}
+
+// lessID orders user IDs numerically when both are numbers and lexically
+// otherwise.
+func lessID(a, b string) bool {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return x < y
+	}
+	return a < b
+}
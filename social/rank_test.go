@@ -0,0 +1,48 @@
+package social
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newRankSnapshot has users 1 and 4 without posts, 2 with a post nobody
+// commented on and 3 with a post with two comments.
+func newRankSnapshot() *snapshot {
+	return &snapshot{
+		users:          map[string]string{"1": "Ada", "2": "Brian", "3": "Cleo", "4": "Dev"},
+		userPostCounts: map[string]int{"2": 1, "3": 1},
+		posts:          []Post{{ID: 20, UserID: 2}, {ID: 30, UserID: 3}},
+		postComments:   map[int]int{30: 2},
+	}
+}
+
+// ranking lists users as ID:rank.
+func ranking(users []UserPostCount) string {
+	s := ""
+	for _, u := range users {
+		s += fmt.Sprintf("%s:%d ", u.UserID, u.Rank)
+	}
+	return s
+}
+
+func TestRankUsersIncludeZero(t *testing.T) {
+	tests := []struct {
+		opts rankOptions
+		want string
+	}{
+		{rankOptions{by: rankByPosts}, "2:1 3:1 "},
+		{rankOptions{by: rankByPosts, includeZero: true}, "2:1 3:1 1:3 4:3 "},
+		// Brian's post without comments still puts him above the users
+		// without posts, though all of them have no comments.
+		{rankOptions{by: rankByComments, includeZero: true}, "3:1 2:2 1:3 4:3 "},
+		{rankOptions{by: rankByEngagement, includeZero: true}, "3:1 2:2 1:3 4:3 "},
+		// Users without posts don't tie with the last user with posts.
+		{rankOptions{by: rankByComments, includeZero: true, includeTies: true, limit: 2}, "3:1 2:2 "},
+		{rankOptions{by: rankByComments, includeZero: true, includeTies: true, limit: 3}, "3:1 2:2 1:3 4:3 "},
+	}
+	for _, tt := range tests {
+		if got := ranking(newRankSnapshot().rankUsers(tt.opts)); got != tt.want {
+			t.Errorf("%+v: ranked %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
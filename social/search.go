@@ -0,0 +1,117 @@
+package social
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+	"github.com/Escanor244/713522IT013/social/socialclient"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type indexedUser struct {
+	lowerName string
+	user      UserPostCount
+}
+
+// buildNameIndex rebuilds the search index from the current users. The
+// caller must hold the write lock.
+func (s *snapshot) buildNameIndex() {
+	ranked := s.rankUsers(rankOptions{by: rankByPosts, includeZero: true})
+
+	index := make([]indexedUser, len(ranked))
+	for i, user := range ranked {
+		index[i] = indexedUser{lowerName: strings.ToLower(user.Name), user: user}
+	}
+	s.nameIndex = index
+}
+
+// searchUsers does a case-insensitive substring match over user names,
+// returning matches in ranking order.
+func searchUsers(c *gin.Context) {
+	query := strings.ToLower(strings.TrimSpace(c.Query("name")))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing name query"})
+		return
+	}
+
+	limit, ok := intQuery(c, "limit", defaultSearchLimit, 1, maxSearchLimit)
+	if !ok {
+		return
+	}
+	offset, ok := intQuery(c, "offset", 0, 0, -1)
+	if !ok {
+		return
+	}
+
+	cache := loadCache(c)
+	defer cache.RUnlock()
+
+	matches := []UserPostCount{}
+	total := 0
+	for _, entry := range cache.nameIndex {
+		if !strings.Contains(entry.lowerName, query) {
+			continue
+		}
+		if total >= offset && len(matches) < limit {
+			matches = append(matches, entry.user)
+		}
+		total++
+	}
+
+	c.JSON(http.StatusOK, socialclient.SearchUsersResponse{
+		Users:  matches,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// intQuery parses an optional integer query parameter within [min, max],
+// where a negative max means unbounded. On a bad value it writes a 400 and
+// returns false.
+func intQuery(c *gin.Context, key string, def, min, max int) (int, bool) {
+	raw := c.Query(key)
+	if raw == "" {
+		return def, true
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < min || (max >= 0 && n > max) {
+		msg := "Invalid " + key + ". Use an integer of at least " + strconv.Itoa(min)
+		if max >= 0 {
+			msg += " and at most " + strconv.Itoa(max)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return 0, false
+	}
+	return n, true
+}
+
+// getUser returns one user's entry in the all-time post ranking, including
+// users with no posts.
+func getUser(c *gin.Context) {
+	userID := c.Param("id")
+
+	cache := loadCache(c)
+	defer cache.RUnlock()
+
+	for _, entry := range cache.nameIndex {
+		if entry.user.UserID != userID {
+			continue
+		}
+		users := []UserPostCount{entry.user}
+		cache.annotateRankChanges(users)
+		c.JSON(http.StatusOK, socialclient.UserResponse{User: users[0]})
+		return
+	}
+	err := cache.missing("User")
+	c.JSON(apperr.HTTPStatus(err), gin.H{"error": err.Error()})
+}
@@ -0,0 +1,924 @@
+// Package social is the social media analytics service: it caches users,
+// posts and comment counts from the test server and serves rankings and
+// post listings from that cache.
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/Escanor244/713522IT013/internal/apperr"
+	"github.com/Escanor244/713522IT013/internal/buildinfo"
+	"github.com/Escanor244/713522IT013/internal/errreport"
+	"github.com/Escanor244/713522IT013/internal/middleware"
+	"github.com/Escanor244/713522IT013/internal/statuspage"
+	"github.com/Escanor244/713522IT013/social/socialclient"
+)
+
+// reporter receives refresh failures; Run replaces the default with the
+// configured one.
+var reporter errreport.Reporter = errreport.Nop{}
+
+const (
+	baseURL = "http://20.244.56.144/test"
+
+	// maxPopularPosts caps how many tied posts the popular view returns.
+	maxPopularPosts = 50
+)
+
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Post and UserPostCount are part of the API's wire format, which is shared
+// with the Go client.
+type Post = socialclient.Post
+
+type UserPostCount = socialclient.UserPostCount
+
+type Comment struct {
+	ID      int    `json:"id"`
+	PostID  int    `json:"postid"`
+	Content string `json:"content"`
+}
+
+type Cache struct {
+	sync.RWMutex
+	snapshot
+	name        string
+	upstream    upstreamEnv
+	lastUpdated time.Time
+	lastRefresh refreshTiming
+
+	history *refreshHistory
+	stateMu sync.Mutex
+
+	// failureStreak counts consecutive failed refreshes. While it is
+	// non-zero, unforced refreshes wait until nextAttempt.
+	failureStreak int
+	nextAttempt   time.Time
+
+	// paused stops scheduled and on-demand refreshes until resumed.
+	paused   bool
+	pausedAt time.Time
+
+	// refreshMu serializes refreshes so the upstream fetch can run without
+	// holding the data lock.
+	refreshMu sync.Mutex
+
+	// cycles numbers the refreshes run, guarded by refreshMu. refreshed
+	// counts those that have finished, successfully or not, so a request
+	// can tell whether one ran while it waited for refreshMu.
+	cycles    uint64
+	refreshed atomic.Uint64
+}
+
+// refreshTiming breaks a full refresh down into its phases. Posts and
+// comments are the summed time spent fetching across all users and posts.
+// Errors counts the individual fetches that failed along the way.
+type refreshTiming struct {
+	StartedAt time.Time
+	Total     time.Duration
+	Users     time.Duration
+	Posts     time.Duration
+	Comments  time.Duration
+	Commit    time.Duration
+	Errors    int
+
+	// CommentsSkipped counts comment fetches skipped for quiet posts.
+	CommentsSkipped int
+}
+
+func newCache(name string, upstream upstreamEnv) *Cache {
+	return &Cache{
+		snapshot: snapshot{
+			users:          make(map[string]string),
+			userPostCounts: make(map[string]int),
+			posts:          make([]Post, 0),
+			postComments:   make(map[int]int),
+			quietPosts:     make(map[int]int),
+		},
+		name:     name,
+		upstream: upstream,
+		history:  newRefreshHistory(refreshHistorySize),
+	}
+}
+
+// updateData refreshes the cache if it is older than the update interval.
+// It reports whether the caller had to wait on a refresh, either one it ran
+// itself or one that was already in flight.
+func (c *Cache) updateData(ctx context.Context) bool {
+	waited, _ := c.refresh(ctx, false, nil)
+	return waited
+}
+
+// refresh runs a full refresh, reporting progress to job if one is given.
+// Unless force is set, it does nothing while the data is fresh, while a
+// failing upstream is being backed off or while refreshes are paused.
+//
+// A refresh is shared by everyone waiting on it, so it is not cancelled
+// along with ctx; ctx only parents its spans.
+func (c *Cache) refresh(ctx context.Context, force bool, job *refreshJob) (bool, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	// Whoever held refreshMu may only have been checking the data is
+	// fresh; the caller waited on a refresh only if one finished in the
+	// meantime.
+	seen := c.refreshed.Load()
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	waited := c.refreshed.Load() != seen
+
+	if !force {
+		c.RLock()
+		now := refreshClock.Now()
+		fresh := now.Sub(c.lastUpdated) < tuning().refreshInterval
+		backingOff := c.failureStreak > 0 && now.Before(c.nextAttempt)
+		paused := c.paused
+		c.RUnlock()
+		if fresh || backingOff || paused {
+			return waited, nil
+		}
+	}
+
+	c.cycles++
+	defer c.refreshed.Add(1)
+	cycle := strconv.FormatUint(c.cycles, 10)
+	ctx, span := c.startSpan(ctx, "Cache.refresh",
+		attribute.Bool("refresh.force", force),
+		attribute.String("refresh.cycle", cycle))
+	defer span.End()
+
+	snap, timing, err := c.fetchSnapshot(ctx, force, job)
+	if err != nil {
+		failSpan(span, err)
+		log.Printf("[%s] Error fetching users: %v", c.name, err)
+		streak := c.recordFailure()
+		reporter.Report(ctx, err, map[string]string{
+			"service":       "social",
+			"environment":   c.name,
+			"cycle":         cycle,
+			"failureStreak": strconv.Itoa(streak),
+		})
+		c.recordRefresh(refreshRecord{
+			Timestamp:  timing.StartedAt,
+			DurationMs: time.Since(timing.StartedAt).Milliseconds(),
+			Errors:     1,
+			Error:      err.Error(),
+		})
+		return true, err
+	}
+
+	c.RLock()
+	changes := diffSnapshots(&c.snapshot, snap).changes()
+	c.RUnlock()
+
+	job.setPhase("commit")
+	_, commitSpan := c.startSpan(ctx, "refresh.commit")
+	commitStart := time.Now()
+	c.Lock()
+	if !c.lastUpdated.IsZero() {
+		c.previousRanks = c.currentRanks()
+	}
+	c.users = snap.users
+	c.userPostCounts = snap.userPostCounts
+	c.posts = snap.posts
+	c.postComments = snap.postComments
+	c.quietPosts = snap.quietPosts
+	c.reindex()
+	c.lastUpdated = refreshClock.Now()
+	timing.Commit = time.Since(commitStart)
+	timing.Total = time.Since(timing.StartedAt)
+	c.lastRefresh = timing
+	c.failureStreak = 0
+	record := refreshRecord{
+		Timestamp:  timing.StartedAt,
+		DurationMs: timing.Total.Milliseconds(),
+		Users:      len(c.users),
+		Posts:      len(c.posts),
+		Comments:   c.commentTotal(),
+		Changes:    changes,
+		Errors:     timing.Errors,
+
+		CommentsSkipped: timing.CommentsSkipped,
+	}
+	c.Unlock()
+	commitSpan.End()
+
+	c.recordRefresh(record)
+	observeRefresh(timing)
+	observeBackoff(c.name, 0, tuning().refreshInterval)
+	return true, nil
+}
+
+// snapshot is the data produced by one pass of the fetch pipeline.
+type snapshot struct {
+	users          map[string]string
+	userPostCounts map[string]int
+	posts          []Post
+	postComments   map[int]int
+
+	// quietPosts maps the IDs of posts whose comments last came back empty
+	// to how many more refreshes may skip fetching them. Until then their
+	// comment count is carried forward as zero.
+	quietPosts map[int]int
+
+	// nameIndex is every user in ranking order with a lowercased name,
+	// rebuilt whenever users change so searches don't rescan the map.
+	nameIndex []indexedUser
+
+	// previousRanks maps user IDs to their post-count rank in the snapshot
+	// this one replaced.
+	previousRanks map[string]int
+}
+
+// fetchSnapshot runs the fetch pipeline without touching the cache. Only a
+// failure to list users is fatal; per-user and per-post failures are logged
+// and leave gaps in the snapshot.
+//
+// The users listing is decoded as a stream and each user is handed to a
+// pool of refreshConcurrency workers as soon as it is read, so the working
+// set is bounded by the pool rather than by the number of users.
+//
+// Forced refreshes fetch every comment count, ignoring quiet posts.
+func (c *Cache) fetchSnapshot(ctx context.Context, force bool, job *refreshJob) (*snapshot, refreshTiming, error) {
+	ctx, span := c.startSpan(ctx, "refresh.fetch")
+	defer span.End()
+
+	timing := refreshTiming{StartedAt: time.Now()}
+	job.setPhase("users")
+
+	// Size the new maps from the current snapshot, which is usually close.
+	c.RLock()
+	snap := &snapshot{
+		users:          make(map[string]string, len(c.users)),
+		userPostCounts: make(map[string]int, len(c.userPostCounts)),
+		posts:          make([]Post, 0, len(c.posts)),
+		postComments:   make(map[int]int, len(c.postComments)),
+		quietPosts:     make(map[int]int, len(c.quietPosts)),
+	}
+	var plan commentPlan
+	if lazyComments {
+		current, _ := c.popularPosts()
+		plan.popular = postIDs(current)
+	}
+	if !force {
+		// Only replaced, never modified, while we hold refreshMu.
+		plan.quiet = c.quietPosts
+	}
+	c.RUnlock()
+
+	userIDs := make(chan string, refreshConcurrency)
+	results := make(chan userFetch, refreshConcurrency)
+
+	var workers sync.WaitGroup
+	for i := 0; i < refreshConcurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for userID := range userIDs {
+				results <- c.fetchUser(ctx, userID, plan)
+			}
+		}()
+	}
+
+	// Merge results as they arrive; only this goroutine writes the
+	// per-user parts of snap.
+	merged := make(chan struct{})
+	go func() {
+		defer close(merged)
+		for r := range results {
+			timing.Posts += r.postsTime
+			timing.Comments += r.commentsTime
+			timing.Errors += r.errors
+			timing.CommentsSkipped += r.skipped
+			if r.posts != nil {
+				snap.userPostCounts[r.userID] = len(r.posts)
+				snap.posts = append(snap.posts, r.posts...)
+				for postID, count := range r.comments {
+					snap.postComments[postID] = count
+				}
+				for postID, left := range r.quiet {
+					snap.quietPosts[postID] = left
+				}
+			}
+			job.userDone(len(r.posts), len(r.comments))
+		}
+	}()
+
+	job.setPhase("posts")
+	err := c.streamUsers(ctx, func(userID, name string) {
+		snap.users[userID] = name
+		job.userSeen()
+		userIDs <- userID
+	})
+	timing.Users = time.Since(timing.StartedAt)
+
+	close(userIDs)
+	workers.Wait()
+	close(results)
+	<-merged
+
+	span.SetAttributes(
+		attribute.Int("refresh.users", len(snap.users)),
+		attribute.Int("refresh.errors", timing.Errors),
+	)
+	if err != nil {
+		failSpan(span, err)
+		return nil, timing, err
+	}
+	return snap, timing, nil
+}
+
+// userFetch is one worker's result for a single user. A nil posts slice
+// means the posts fetch failed.
+type userFetch struct {
+	userID       string
+	posts        []Post
+	comments     map[int]int
+	quiet        map[int]int
+	postsTime    time.Duration
+	commentsTime time.Duration
+	errors       int
+	skipped      int
+}
+
+// commentPlan is what a refresh knows up front about which comment counts
+// it can avoid fetching.
+type commentPlan struct {
+	popular map[int]bool
+	quiet   map[int]int
+}
+
+func (c *Cache) fetchUser(ctx context.Context, userID string, plan commentPlan) userFetch {
+	ctx, span := c.startSpan(ctx, "fetchUser", attribute.String("user.id", userID))
+	defer span.End()
+
+	r := userFetch{userID: userID}
+
+	start := time.Now()
+	posts, err := c.fetchUserPosts(ctx, userID)
+	r.postsTime = time.Since(start)
+	if err != nil {
+		log.Printf("[%s] Error fetching posts for user %s: %v", c.name, userID, err)
+		r.errors++
+		return r
+	}
+	if posts == nil {
+		posts = []Post{}
+	}
+	r.posts = posts
+
+	// Fetch comments for each post
+	r.comments = make(map[int]int, len(posts))
+	r.quiet = make(map[int]int)
+	for _, post := range commentTargets(posts, plan.popular) {
+		if left := plan.quiet[post.ID]; left > 0 {
+			r.comments[post.ID] = 0
+			if left > 1 {
+				r.quiet[post.ID] = left - 1
+			}
+			r.skipped++
+			continue
+		}
+
+		start := time.Now()
+		comments, err := c.fetchPostComments(ctx, post.ID)
+		r.commentsTime += time.Since(start)
+		if err != nil {
+			log.Printf("[%s] Error fetching comments for post %d: %v", c.name, post.ID, err)
+			r.errors++
+			continue
+		}
+		r.comments[post.ID] = len(comments)
+		if cycles := tuning().quietPostCycles; len(comments) == 0 && cycles > 0 {
+			r.quiet[post.ID] = cycles
+		}
+	}
+	return r
+}
+
+// recordFailure extends the failure streak and schedules the next attempt,
+// returning the new streak.
+func (c *Cache) recordFailure() int {
+	c.Lock()
+	c.failureStreak++
+	interval := c.effectiveInterval()
+	c.nextAttempt = refreshClock.Now().Add(interval)
+	streak := c.failureStreak
+	c.Unlock()
+
+	log.Printf("[%s] Refresh failed %d times in a row, next attempt in %v", c.name, streak, interval)
+	observeBackoff(c.name, streak, interval)
+	return streak
+}
+
+// effectiveInterval is the refresh interval after backoff: it doubles with
+// every consecutive failure up to refreshBackoffMax. The caller must hold
+// the lock.
+func (c *Cache) effectiveInterval() time.Duration {
+	t := tuning()
+	interval := t.refreshInterval
+	for i := 0; i < c.failureStreak && interval < t.refreshBackoffMax; i++ {
+		interval *= 2
+	}
+	if interval > t.refreshBackoffMax {
+		interval = t.refreshBackoffMax
+	}
+	return interval
+}
+
+// streamUsers decodes the users listing incrementally, calling fn for each
+// user as soon as it has been read.
+func (c *Cache) streamUsers(ctx context.Context, fn func(userID, name string)) (err error) {
+	ctx, span := c.startSpan(ctx, "streamUsers")
+	defer func() { endSpan(span, err) }()
+
+	url := fmt.Sprintf("%s/users", c.upstream.BaseURL)
+	return getStream(ctx, endpointUsers, url, c.upstream.Token, func(body io.Reader) error {
+		return decodeUsersStream(json.NewDecoder(body), fn)
+	})
+}
+
+func (c *Cache) fetchUserPosts(ctx context.Context, userID string) (_ []Post, err error) {
+	ctx, span := c.startSpan(ctx, "fetchUserPosts", attribute.String("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	var result map[string]json.RawMessage
+	if err := getJSON(ctx, endpointPosts, fmt.Sprintf("%s/users/%s/posts", c.upstream.BaseURL, userID), c.upstream.Token, &result); err != nil {
+		return nil, err
+	}
+
+	var decoded []upstreamPost
+	if err := decodeEnvelope(result, &decoded, "posts", "post"); err != nil {
+		return nil, err
+	}
+	if decoded == nil {
+		return nil, nil
+	}
+
+	posts := make([]Post, len(decoded))
+	for i, post := range decoded {
+		posts[i] = Post(post)
+	}
+	return posts, nil
+}
+
+func (c *Cache) fetchPostComments(ctx context.Context, postID int) (_ []Comment, err error) {
+	ctx, span := c.startSpan(ctx, "fetchPostComments", attribute.Int("post.id", postID))
+	defer func() { endSpan(span, err) }()
+
+	var result map[string]json.RawMessage
+	if err := getJSON(ctx, endpointComments, fmt.Sprintf("%s/posts/%d/comments", c.upstream.BaseURL, postID), c.upstream.Token, &result); err != nil {
+		return nil, err
+	}
+
+	var comments []Comment
+	if err := decodeEnvelope(result, &comments, "comments"); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// Values of the X-Cache response header.
+const (
+	cacheHit   = "HIT"
+	cacheStale = "STALE"
+	cacheMiss  = "MISS"
+)
+
+// loadCache brings the request's cache up to date, takes its read lock and
+// tags the response with its X-Cache status. The caller must release the
+// lock with RUnlock.
+func loadCache(c *gin.Context) *Cache {
+	cache := envCache(c)
+	ctx, span := cache.startSpan(c.Request.Context(), "loadCache")
+	defer span.End()
+	waited := cache.updateData(ctx)
+
+	cache.RLock()
+	status := cache.status(waited)
+	span.SetAttributes(attribute.String("cache.status", status))
+	c.Header("X-Cache", status)
+	cacheResponses.WithLabelValues(status).Inc()
+	return cache
+}
+
+// missing is the error for a lookup of kind that found nothing. Before the
+// first successful refresh that says nothing about whether it exists, so
+// the client is told to come back rather than that it isn't there. The
+// caller must hold the read lock.
+func (c *Cache) missing(kind string) error {
+	if c.lastUpdated.IsZero() {
+		return apperr.Wrap(apperr.ErrCacheEmpty, "Cache has not been loaded yet")
+	}
+	return apperr.Wrap(apperr.ErrNotFound, kind+" not found")
+}
+
+// upstreamStatus is the status for a failed call to the test server, which
+// is a bad gateway unless apperr knows better.
+func upstreamStatus(err error) int {
+	if status := apperr.HTTPStatus(err); status != http.StatusInternalServerError {
+		return status
+	}
+	return http.StatusBadGateway
+}
+
+// status classifies the snapshot being served. A snapshot older than the
+// update interval is STALE even if we waited, since that means the refresh
+// failed. The caller must hold the read lock.
+func (c *Cache) status(waited bool) string {
+	switch {
+	case time.Since(c.lastUpdated) >= tuning().refreshInterval:
+		return cacheStale
+	case waited:
+		return cacheMiss
+	default:
+		return cacheHit
+	}
+}
+
+func getTopUsers(c *gin.Context) {
+	opts, err := parseRankOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	window, err := parseRankingWindow(c.Query("window"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cache := loadCache(c)
+	defer cache.RUnlock()
+
+	if window == 0 {
+		users := cache.rankUsers(opts)
+		if opts.by == rankByPosts {
+			cache.annotateRankChanges(users)
+		}
+		c.JSON(http.StatusOK, socialclient.UsersResponse{Users: users})
+		return
+	}
+
+	view, untimed := cache.withinWindow(time.Now().Add(-window))
+	c.JSON(http.StatusOK, socialclient.UsersResponse{
+		Users: view.rankUsers(opts),
+		Meta: &socialclient.WindowMeta{
+			Window:                window.String(),
+			PostsWithoutTimestamp: untimed,
+		},
+	})
+}
+
+func getStats(c *gin.Context) {
+	cache := loadCache(c)
+	defer cache.RUnlock()
+
+	usersWithPosts := 0
+	for _, count := range cache.userPostCounts {
+		if count > 0 {
+			usersWithPosts++
+		}
+	}
+
+	comments := 0
+	for _, count := range cache.postComments {
+		comments += count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":          len(cache.users),
+		"usersWithPosts": usersWithPosts,
+		"posts":          len(cache.posts),
+		"comments":       comments,
+	})
+}
+
+func getPosts(c *gin.Context) {
+	postType := c.Query("type")
+	if postType != "latest" && postType != "popular" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post type. Use 'latest' or 'popular'"})
+		return
+	}
+
+	if fallback := c.Query("fallback"); fallback != "" && fallback != "latest" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fallback. Use 'latest'"})
+		return
+	}
+
+	full := true
+	if raw := c.Query("full"); raw != "" {
+		var err error
+		if full, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid full flag. Use 'true' or 'false'"})
+			return
+		}
+	}
+	dedupe := false
+	if raw := c.Query("dedupe"); raw != "" {
+		var err error
+		if dedupe, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dedupe flag. Use 'true' or 'false'"})
+			return
+		}
+	}
+
+	render := func(posts []Post) []Post {
+		if full {
+			return posts
+		}
+		return previewPosts(posts)
+	}
+
+	cache := loadCache(c)
+	defer cache.RUnlock()
+
+	view := &cache.snapshot
+	if dedupe {
+		view = view.deduped()
+	}
+
+	switch postType {
+	case "popular":
+		popularPosts, maxComments := view.popularPosts()
+
+		// Nobody has commented on anything, so every post would tie for
+		// first place. Report that explicitly instead of returning them all.
+		if maxComments == 0 {
+			if c.Query("fallback") == "latest" {
+				c.JSON(http.StatusOK, socialclient.PostsResponse{Posts: render(view.latestPosts(tuning().latestPostsLimit)), Reason: "no_commented_posts"})
+				return
+			}
+			c.JSON(http.StatusOK, socialclient.PostsResponse{Posts: []Post{}, Reason: "no_commented_posts"})
+			return
+		}
+
+		c.JSON(http.StatusOK, socialclient.PostsResponse{Posts: render(popularPosts)})
+
+	case "latest":
+		c.JSON(http.StatusOK, socialclient.PostsResponse{Posts: render(view.latestPosts(tuning().latestPostsLimit))})
+	}
+}
+
+// getPost returns a single cached post with its comment count. Content is
+// never truncated here.
+func getPost(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+
+	cache := loadCache(c)
+
+	var found *Post
+	for i := range cache.posts {
+		if cache.posts[i].ID == postID {
+			post := cache.posts[i]
+			found = &post
+			break
+		}
+	}
+	count, known := cache.postComments[postID]
+	var missing error
+	if found == nil {
+		missing = cache.missing("Post")
+	}
+	cache.RUnlock()
+
+	if missing != nil {
+		c.JSON(apperr.HTTPStatus(missing), gin.H{"error": missing.Error()})
+		return
+	}
+
+	if !known {
+		var err error
+		if count, err = cache.backfillComments(c.Request.Context(), postID); err != nil {
+			c.JSON(upstreamStatus(err), gin.H{"error": "Failed to fetch comments: " + err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, socialclient.PostResponse{Post: *found, CommentCount: count})
+}
+
+// popularPosts returns the posts tied for the most comments, newest first
+// and capped at maxPopularPosts, along with that comment count. Only posts
+// with a known comment count are considered, which in lazy comments mode
+// excludes older posts nobody has asked about yet.
+func (s *snapshot) popularPosts() ([]Post, int) {
+	var maxComments int
+	var popularPosts []Post
+
+	// Find max comments
+	for _, count := range s.postComments {
+		if count > maxComments {
+			maxComments = count
+		}
+	}
+	if maxComments == 0 {
+		return nil, 0
+	}
+
+	// Find all posts with max comments
+	for _, post := range s.posts {
+		if count, ok := s.postComments[post.ID]; ok && count == maxComments {
+			popularPosts = append(popularPosts, post)
+		}
+	}
+
+	sort.Slice(popularPosts, func(i, j int) bool {
+		return popularPosts[i].ID > popularPosts[j].ID
+	})
+
+	if len(popularPosts) > maxPopularPosts {
+		popularPosts = popularPosts[:maxPopularPosts]
+	}
+
+	return popularPosts, maxComments
+}
+
+// latestPosts returns the limit newest cached posts. The caller must hold
+// the read lock.
+func (s *snapshot) latestPosts(limit int) []Post {
+	posts := make([]Post, len(s.posts))
+	copy(posts, s.posts)
+
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].ID > posts[j].ID
+	})
+
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	return posts
+}
+
+// getHealth reports liveness along with the refresh state of every
+// environment.
+func getHealth(caches map[string]*Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		envs := make(gin.H, len(caches))
+		for name, cache := range caches {
+			h := cache.health()
+			envs[name] = gin.H{
+				"lastUpdated":         h.LastUpdated,
+				"failureStreak":       h.FailureStreak,
+				"effectiveIntervalMs": h.EffectiveInterval.Milliseconds(),
+				"paused":              h.Paused,
+				"pausedAt":            pausedAt(h.PausedAt),
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "environments": envs})
+	}
+}
+
+func getDebugCache(c *gin.Context) {
+	cache := envCache(c)
+	cache.RLock()
+	defer cache.RUnlock()
+
+	refresh := cache.lastRefresh
+	c.JSON(http.StatusOK, gin.H{
+		"environment":      cache.name,
+		"lastUpdated":      cache.lastUpdated,
+		"ageSeconds":       time.Since(cache.lastUpdated).Seconds(),
+		"updateIntervalMs": tuning().refreshInterval.Milliseconds(),
+		"users":            len(cache.users),
+		"posts":            len(cache.posts),
+		"commentedPosts":   len(cache.postComments),
+		"lastRefresh": gin.H{
+			"startedAt":  refresh.StartedAt,
+			"durationMs": refresh.Total.Milliseconds(),
+			"phasesMs": gin.H{
+				"users":    refresh.Users.Milliseconds(),
+				"posts":    refresh.Posts.Milliseconds(),
+				"comments": refresh.Comments.Milliseconds(),
+				"commit":   refresh.Commit.Milliseconds(),
+			},
+			"commentsSkipped": refresh.CommentsSkipped,
+		},
+	})
+}
+
+// Run serves the analytics API until ctx is cancelled, refreshing each
+// environment's cache in the background.
+func Run(ctx context.Context, cfg Config) error {
+	reporter = cfg.HTTP.Reporter
+	caches, defaultEnv, err := loadEnvironments()
+	if err != nil {
+		return fmt.Errorf("failed to load upstream environments: %w", err)
+	}
+
+	r := newRouter(cfg, caches, defaultEnv)
+
+	for _, cache := range caches {
+		cache.loadState()
+	}
+	loadMaintenance(cfg.HTTP.Maintenance)
+
+	// Start background cache updates, one loop per environment
+	for _, cache := range caches {
+		go cache.refreshLoop(ctx)
+	}
+
+	buildinfo.Log("social")
+	ln, err := cfg.Server.Listen(cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", cfg.Listen, err)
+	}
+	return cfg.Server.Serve(ctx, "social", cfg.Server.Server(r), ln)
+}
+
+// refreshLoop refreshes c every effective interval until ctx is cancelled.
+func (c *Cache) refreshLoop(ctx context.Context) {
+	for {
+		c.RLock()
+		paused := c.paused
+		c.RUnlock()
+
+		if paused {
+			log.Printf("[%s] Refresh paused, skipping scheduled refresh", c.name)
+			refreshSkips.WithLabelValues(c.name).Inc()
+		} else {
+			c.updateData(ctx)
+		}
+
+		c.RLock()
+		interval := c.effectiveInterval()
+		c.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-refreshClock.After(interval):
+		}
+	}
+}
+
+// Handler returns the analytics API without the background refreshes Run
+// starts, so each environment's cache is refreshed by the requests that
+// find it due. It serves the API in-process, as the client's tests do.
+func Handler(cfg Config) (http.Handler, error) {
+	reporter = cfg.HTTP.Reporter
+	caches, defaultEnv, err := loadEnvironments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upstream environments: %w", err)
+	}
+	return newRouter(cfg, caches, defaultEnv), nil
+}
+
+// newRouter returns the analytics API over caches, with defaultEnv the
+// environment of requests that don't pick one.
+func newRouter(cfg Config, caches map[string]*Cache, defaultEnv string) *gin.Engine {
+	r := gin.New()
+	r.Use(otelgin.Middleware("social"))
+	r.Use(cfg.HTTP.Chain("social")...)
+	r.GET("/metrics", metricsHandler())
+	r.GET("/healthz", getHealth(caches))
+	r.GET("/version", buildinfo.Handler("social"))
+	r.GET("/", cfg.HTTP.Security.ContentPolicy(middleware.DashboardPolicy),
+		statuspage.Handler("social", cfg.Listen, statusVitals(caches)))
+
+	api := r.Group("/", selectEnvironment(caches, defaultEnv))
+	api.GET("/users", getTopUsers)
+	api.GET("/users/search", searchUsers)
+	api.GET("/users/:id", getUser)
+	api.GET("/posts", getPosts)
+	api.GET("/posts/:id", getPost)
+	api.GET("/stats", getStats)
+	api.GET("/summary", getSummary)
+	api.GET("/dashboard", cfg.HTTP.Security.ContentPolicy(middleware.DashboardPolicy), getDashboard)
+	api.GET("/debug/cache", getDebugCache)
+
+	// The admin API is guarded by the bearer token from ADMIN_TOKEN.
+	admin := api.Group("/admin", middleware.AdminAuth(settings.Get("ADMIN_TOKEN")))
+	admin.DELETE("/cache/users/:id", deleteCachedUser)
+	admin.POST("/refresh", postRefresh)
+	admin.POST("/refresh/pause", postRefreshPause)
+	admin.POST("/refresh/resume", postRefreshResume)
+	admin.GET("/refresh/history", getRefreshHistory)
+	admin.GET("/refresh/:id", getRefreshJob)
+	admin.GET("/maintenance", getMaintenance(cfg.HTTP.Maintenance))
+	admin.POST("/maintenance", postMaintenance(cfg.HTTP.Maintenance))
+	return r
+}
@@ -0,0 +1,344 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeUpstream is a scripted test server: it serves whatever users, posts
+// and comments it holds at the moment, or 503 while failing is set.
+type fakeUpstream struct {
+	mu       sync.Mutex
+	users    map[string]string
+	posts    map[string][]Post
+	comments map[int][]Comment
+	failing  bool
+	delay    time.Duration
+
+	// listings counts the requests for the users listing, one per refresh.
+	listings int
+}
+
+func (u *fakeUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	failing, delay := u.failing, u.delay
+	var body interface{}
+	switch parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/"); {
+	case len(parts) == 1 && parts[0] == "users":
+		u.listings++
+		body = map[string]interface{}{"users": u.users}
+	case len(parts) == 3 && parts[0] == "users" && parts[2] == "posts":
+		body = map[string]interface{}{"posts": u.posts[parts[1]]}
+	case len(parts) == 3 && parts[0] == "posts" && parts[2] == "comments":
+		id, _ := strconv.Atoi(parts[1])
+		body = map[string]interface{}{"comments": u.comments[id]}
+	default:
+		u.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	u.mu.Unlock()
+
+	time.Sleep(delay)
+	if failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// set replaces the upstream's data under its lock.
+func (u *fakeUpstream) set(fn func(u *fakeUpstream)) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	fn(u)
+}
+
+// listed is how many times the users were listed.
+func (u *fakeUpstream) listed() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.listings
+}
+
+// newTestCache returns a cache of u, which starts with two users, one
+// post each and a comment on the first.
+func newTestCache(t *testing.T) (*Cache, *fakeUpstream) {
+	t.Helper()
+	u := &fakeUpstream{
+		users: map[string]string{"1": "Ada", "2": "Brian"},
+		posts: map[string][]Post{
+			"1": {{ID: 10, UserID: 1, Content: "first"}},
+			"2": {{ID: 20, UserID: 2, Content: "second"}},
+		},
+		comments: map[int][]Comment{10: {{ID: 100, PostID: 10, Content: "nice"}}},
+	}
+	srv := httptest.NewServer(u)
+	t.Cleanup(srv.Close)
+	return newCache("test", upstreamEnv{BaseURL: srv.URL}), u
+}
+
+// setTuning applies fn to a copy of the default tunables until the test
+// ends.
+func setTuning(t *testing.T, fn func(*tunables)) {
+	t.Helper()
+	previous := live.Load()
+	tun := defaultTunables()
+	fn(tun)
+	live.Store(tun)
+	t.Cleanup(func() { live.Store(previous) })
+}
+
+// xCache brings c up to date as a request does and returns its X-Cache.
+func xCache(c *Cache) string {
+	waited := c.updateData(context.Background())
+	c.RLock()
+	defer c.RUnlock()
+	return c.status(waited)
+}
+
+func TestXCacheColdFreshStale(t *testing.T) {
+	setTuning(t, func(tun *tunables) { tun.refreshInterval = 100 * time.Millisecond })
+	c, u := newTestCache(t)
+
+	if got := xCache(c); got != cacheMiss {
+		t.Errorf("cold cache: X-Cache = %s, want %s", got, cacheMiss)
+	}
+	if got := xCache(c); got != cacheHit {
+		t.Errorf("fresh cache: X-Cache = %s, want %s", got, cacheHit)
+	}
+
+	u.set(func(u *fakeUpstream) { u.failing = true })
+	time.Sleep(150 * time.Millisecond)
+	if got := xCache(c); got != cacheStale {
+		t.Errorf("failed refresh: X-Cache = %s, want %s", got, cacheStale)
+	}
+}
+
+func TestXCacheHitWhileAnotherRequestChecks(t *testing.T) {
+	c, u := newTestCache(t)
+	xCache(c)
+
+	// Another request holds refreshMu only to see the data is fresh; this
+	// one waits its turn but runs or joins no refresh.
+	c.refreshMu.Lock()
+	status := make(chan string)
+	go func() { status <- xCache(c) }()
+	time.Sleep(20 * time.Millisecond)
+	c.refreshMu.Unlock()
+
+	if got := <-status; got != cacheHit {
+		t.Errorf("X-Cache = %s, want %s", got, cacheHit)
+	}
+	if n := u.listed(); n != 1 {
+		t.Errorf("users listed %d times, want 1", n)
+	}
+}
+
+func TestXCacheMissWhenJoiningRefresh(t *testing.T) {
+	c, u := newTestCache(t)
+	u.set(func(u *fakeUpstream) { u.delay = 50 * time.Millisecond })
+
+	statuses := make(chan string, 5)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(statuses); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- xCache(c)
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	for status := range statuses {
+		if status != cacheMiss {
+			t.Errorf("request waiting on the first refresh: X-Cache = %s, want %s", status, cacheMiss)
+		}
+	}
+	if n := u.listed(); n != 1 {
+		t.Errorf("users listed %d times, want 1", n)
+	}
+}
+
+// fakeClock is a refresh clock that only moves when a test fires the
+// earliest of the waits handed out to its refresh loops.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	loops  int
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	d  time.Duration
+	ch chan time.Time
+}
+
+// useFakeClock makes refresh scheduling follow a fake clock, driving loops
+// refresh loops, until the test ends.
+func useFakeClock(t *testing.T, loops int) *fakeClock {
+	clk := &fakeClock{now: time.Now(), loops: loops}
+	previous := refreshClock
+	refreshClock = clk
+	t.Cleanup(func() { refreshClock = previous })
+	return clk
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := fakeTimer{at: c.now.Add(d), d: d, ch: make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	return timer.ch
+}
+
+// settle waits until every loop is waiting.
+func (c *fakeClock) settle(t *testing.T) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		waiting := len(c.timers)
+		c.mu.Unlock()
+		if waiting >= c.loops {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refresh loops never waited")
+		}
+	}
+}
+
+// elapse waits until every loop is waiting, then moves the clock on to the
+// earliest wait's end and fires it, returning the wait's length.
+func (c *fakeClock) elapse(t *testing.T) time.Duration {
+	t.Helper()
+	c.settle(t)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	next := 0
+	for i, timer := range c.timers {
+		if timer.at.Before(c.timers[next].at) {
+			next = i
+		}
+	}
+	timer := c.timers[next]
+	c.timers = append(c.timers[:next], c.timers[next+1:]...)
+	c.now = timer.at
+	timer.ch <- c.now
+	return timer.d
+}
+
+// since is how long the clock has moved on from start.
+func (c *fakeClock) since(start time.Time) time.Duration {
+	return c.Now().Sub(start)
+}
+
+// runRefreshLoop runs c's refresh loop until the test ends.
+func runRefreshLoop(t *testing.T, c *Cache) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		c.refreshLoop(ctx)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+}
+
+func TestRefreshBackoff(t *testing.T) {
+	setTuning(t, func(tun *tunables) {
+		tun.refreshInterval = time.Second
+		tun.refreshBackoffMax = 8 * time.Second
+	})
+	clk := useFakeClock(t, 1)
+	c, u := newTestCache(t)
+	u.set(func(u *fakeUpstream) { u.failing = true })
+
+	runRefreshLoop(t, c)
+
+	// Every scheduled refresh goes to the upstream, and each failure
+	// doubles the wait before the next up to the ceiling.
+	listed := 0
+	for i, want := range []time.Duration{2, 4, 8, 8} {
+		want *= time.Second
+		if got := clk.elapse(t); got != want {
+			t.Errorf("wait after failure %d = %v, want %v", i+1, got, want)
+		}
+		if n := u.listed(); n <= listed {
+			t.Errorf("failure %d: upstream not asked again", i+1)
+		} else {
+			listed = n
+		}
+	}
+
+	u.set(func(u *fakeUpstream) { u.failing = false })
+	for i := 0; i < 2; i++ {
+		if got := clk.elapse(t); got != time.Second {
+			t.Errorf("wait %d after recovering = %v, want 1s", i+1, got)
+		}
+	}
+	c.RLock()
+	streak := c.failureStreak
+	c.RUnlock()
+	if streak != 0 {
+		t.Errorf("failure streak = %d after recovering, want 0", streak)
+	}
+}
+
+// newLargeUpstream returns an upstream of n users, every hundredth of whom
+// has one post.
+func newLargeUpstream(n int) *fakeUpstream {
+	u := &fakeUpstream{
+		users:    make(map[string]string, n),
+		posts:    make(map[string][]Post, n/100),
+		comments: map[int][]Comment{},
+	}
+	for i := 1; i <= n; i++ {
+		id := strconv.Itoa(i)
+		u.users[id] = "user " + id
+		if i%100 == 0 {
+			u.posts[id] = []Post{{ID: i, UserID: i, Content: "post " + id}}
+		}
+	}
+	return u
+}
+
+func BenchmarkRefresh50kUsers(b *testing.B) {
+	u := newLargeUpstream(50000)
+	srv := httptest.NewServer(u)
+	defer srv.Close()
+	c := newCache("bench", upstreamEnv{BaseURL: srv.URL})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.refresh(context.Background(), true, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	c.RLock()
+	defer c.RUnlock()
+	if len(c.users) != 50000 || len(c.posts) != 500 {
+		b.Fatalf("cached %d users and %d posts, want 50000 and 500", len(c.users), len(c.posts))
+	}
+}
@@ -0,0 +1,236 @@
+// Package socialclient is a Go client for the social media analytics API.
+package socialclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	// BaseURL is the service root, e.g. "http://localhost:8080".
+	BaseURL string
+
+	// Environment selects the upstream environment via X-Upstream-Env.
+	// Empty means the server's default.
+	Environment string
+
+	HTTPClient *http.Client
+
+	// Timeout bounds each attempt. The caller's context bounds the call as
+	// a whole, retries included.
+	Timeout time.Duration
+
+	// Retries is how many times a call is retried after a network error or
+	// a 502, 503 or 504, waiting RetryBackoff, then twice that, and so on.
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+// New returns a client for the service at baseURL with default timeouts and
+// retries.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		HTTPClient:   http.DefaultClient,
+		Timeout:      10 * time.Second,
+		Retries:      2,
+		RetryBackoff: 200 * time.Millisecond,
+	}
+}
+
+// TopUsersOptions mirrors the query parameters of GET /users. The zero value
+// asks for the default ranking.
+type TopUsersOptions struct {
+	// By is "posts" (the default), "comments" or "engagement".
+	By          string
+	IncludeZero bool
+	IncludeTies bool
+
+	// Window restricts the ranking to posts newer than this. Zero means all
+	// time.
+	Window time.Duration
+}
+
+// TopUsers returns the ranking served by GET /users.
+func (c *Client) TopUsers(ctx context.Context, opts TopUsersOptions) (*UsersResponse, error) {
+	q := url.Values{}
+	if opts.By != "" {
+		q.Set("by", opts.By)
+	}
+	if opts.IncludeZero {
+		q.Set("includeZero", "true")
+	}
+	if opts.IncludeTies {
+		q.Set("ties", "include")
+	}
+	if opts.Window > 0 {
+		q.Set("window", opts.Window.String())
+	}
+
+	var resp UsersResponse
+	if err := c.get(ctx, "/users", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// User returns a single user's all-time post ranking entry.
+func (c *Client) User(ctx context.Context, id string) (*UserPostCount, error) {
+	var resp UserResponse
+	if err := c.get(ctx, "/users/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+// SearchQuery mirrors the query parameters of GET /users/search. Zero
+// Limit and Offset leave the server's defaults.
+type SearchQuery struct {
+	Name   string
+	Limit  int
+	Offset int
+}
+
+// SearchUsers returns a page of the users whose names contain query.Name,
+// in ranking order. Pass Offset+len(Users) as the next Offset until it
+// reaches Total.
+func (c *Client) SearchUsers(ctx context.Context, query SearchQuery) (*SearchUsersResponse, error) {
+	q := url.Values{}
+	q.Set("name", query.Name)
+	if query.Limit > 0 {
+		q.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Offset > 0 {
+		q.Set("offset", strconv.Itoa(query.Offset))
+	}
+
+	var resp SearchUsersResponse
+	if err := c.get(ctx, "/users/search", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// PostQuery mirrors the query parameters of GET /posts.
+type PostQuery struct {
+	// Type is "latest" or "popular".
+	Type string
+
+	// FallbackLatest returns the latest posts instead of nothing when no
+	// post has comments. Only meaningful for popular posts.
+	FallbackLatest bool
+
+	// Preview truncates long content.
+	Preview bool
+	Dedupe  bool
+}
+
+// Posts returns the listing served by GET /posts.
+func (c *Client) Posts(ctx context.Context, query PostQuery) (*PostsResponse, error) {
+	q := url.Values{}
+	q.Set("type", query.Type)
+	if query.FallbackLatest {
+		q.Set("fallback", "latest")
+	}
+	if query.Preview {
+		q.Set("full", "false")
+	}
+	if query.Dedupe {
+		q.Set("dedupe", "true")
+	}
+
+	var resp PostsResponse
+	if err := c.get(ctx, "/posts", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Post returns a single post and its comment count.
+func (c *Client) Post(ctx context.Context, id int) (*PostResponse, error) {
+	var resp PostResponse
+	if err := c.get(ctx, "/posts/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Summary returns the combined view served by GET /summary.
+func (c *Client) Summary(ctx context.Context) (*Summary, error) {
+	var resp Summary
+	if err := c.get(ctx, "/summary", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, q url.Values, v interface{}) error {
+	target := c.BaseURL + path
+	if len(q) > 0 {
+		target += "?" + q.Encode()
+	}
+
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.attempt(ctx, target, v)
+		if err == nil || !retry || attempt >= c.Retries || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt makes one request, reporting whether a failure is worth retrying.
+func (c *Client) attempt(ctx context.Context, target string, v interface{}) (bool, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Environment != "" {
+		req.Header.Set("X-Upstream-Env", c.Environment)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var body ErrorResponse
+		if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body) == nil {
+			apiErr.Message = body.Error
+		}
+		return retryable(resp.StatusCode), apiErr
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("socialclient: decoding %s: %w", target, err)
+	}
+	return false, nil
+}
@@ -0,0 +1,54 @@
+package socialclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrBadRequest   = errors.New("socialclient: bad request")
+	ErrUnauthorized = errors.New("socialclient: unauthorized")
+	ErrNotFound     = errors.New("socialclient: not found")
+
+	// ErrUnavailable means the service has no data to serve yet, typically
+	// because its cache is empty and the upstream is failing.
+	ErrUnavailable = errors.New("socialclient: service unavailable")
+)
+
+// APIError is a non-2xx response. It unwraps to one of the sentinel errors
+// above where the status has one, so callers can use errors.Is.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("socialclient: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("socialclient: status %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusServiceUnavailable:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// retryable reports whether a response status is worth another attempt.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
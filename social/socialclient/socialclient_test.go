@@ -0,0 +1,271 @@
+package socialclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+	"github.com/Escanor244/713522IT013/social"
+	"github.com/Escanor244/713522IT013/social/socialclient"
+)
+
+// upstream serves six users: Alice with three posts, Bob with two, Carla
+// with one long post and Dan, Ava and Zed with none. Bob's post 21 has three
+// comments and Alice's post 11 one.
+func upstream(w http.ResponseWriter, r *http.Request) {
+	posts := map[string][]socialclient.Post{
+		"1": {{ID: 11, UserID: 1, Content: "first"}, {ID: 12, UserID: 1, Content: "second"}, {ID: 13, UserID: 1, Content: "third"}},
+		"2": {{ID: 21, UserID: 2, Content: "hello"}, {ID: 22, UserID: 2, Content: "again"}},
+		"3": {{ID: 31, UserID: 3, Content: strings.Repeat("long ", 50)}},
+	}
+	comments := map[string]int{"11": 1, "21": 3}
+
+	var body interface{}
+	switch parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/"); {
+	case len(parts) == 1 && parts[0] == "users":
+		body = map[string]interface{}{"users": map[string]string{
+			"1": "Alice", "2": "Bob", "3": "Carla", "4": "Dan", "5": "Ava", "6": "Zed",
+		}}
+	case len(parts) == 3 && parts[0] == "users" && parts[2] == "posts":
+		body = map[string]interface{}{"posts": posts[parts[1]]}
+	case len(parts) == 3 && parts[0] == "posts" && parts[2] == "comments":
+		list := []map[string]interface{}{}
+		postID, _ := strconv.Atoi(parts[1])
+		for i := 0; i < comments[parts[1]]; i++ {
+			list = append(list, map[string]interface{}{"id": postID*10 + i, "postid": postID, "content": "nice"})
+		}
+		body = map[string]interface{}{"comments": list}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// newService serves the analytics API in-process with two environments:
+// "live" over upstream and "down", whose upstream always fails. It returns
+// the service URL and a count of the requests it has answered.
+func newService(t *testing.T) (string, *int64) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	live := httptest.NewServer(http.HandlerFunc(upstream))
+	t.Cleanup(live.Close)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+
+	envs, _ := json.Marshal(map[string]map[string]string{
+		"live": {"baseURL": live.URL},
+		"down": {"baseURL": down.URL},
+	})
+	t.Setenv("UPSTREAM_ENVS", string(envs))
+	t.Setenv("UPSTREAM_DEFAULT_ENV", "live")
+	t.Setenv("TOP_USERS_LIMIT", "10")
+	cfg, err := social.LoadConfig(config.FromEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := social.Handler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var served int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&served, 1)
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &served
+}
+
+func newClient(url string) *socialclient.Client {
+	c := socialclient.New(url)
+	c.Timeout = 5 * time.Second
+	c.RetryBackoff = time.Millisecond
+	return c
+}
+
+// names returns the names of users in order.
+func names(users []socialclient.UserPostCount) string {
+	var s []string
+	for _, u := range users {
+		s = append(s, u.Name)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestClientAgainstHandlers(t *testing.T) {
+	url, _ := newService(t)
+	c := newClient(url)
+	ctx := context.Background()
+
+	users, err := c.TopUsers(ctx, socialclient.TopUsersOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(users.Users); got != "Alice,Bob,Carla" {
+		t.Errorf("top users = %s, want Alice,Bob,Carla", got)
+	}
+	if users.Users[0].Count != 3 || users.Users[0].Rank != 1 {
+		t.Errorf("first user = %+v, want 3 posts at rank 1", users.Users[0])
+	}
+
+	users, err = c.TopUsers(ctx, socialclient.TopUsersOptions{By: "comments"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users.Users) < 2 || users.Users[0].Name != "Bob" || users.Users[1].Name != "Alice" {
+		t.Errorf("top users by comments = %s, want Bob then Alice", names(users.Users))
+	}
+
+	users, err = c.TopUsers(ctx, socialclient.TopUsersOptions{IncludeZero: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users.Users) != 6 || !strings.HasPrefix(names(users.Users), "Alice,Bob,Carla,") {
+		t.Errorf("top users with zero = %s, want all six, posters first", names(users.Users))
+	}
+
+	user, err := c.User(ctx, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Bob" || user.Count != 2 || user.Comments != 3 {
+		t.Errorf("user 2 = %+v, want Bob with 2 posts and 3 comments", user)
+	}
+
+	popular, err := c.Posts(ctx, socialclient.PostQuery{Type: "popular"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(popular.Posts) != 1 || popular.Posts[0].ID != 21 {
+		t.Errorf("popular posts = %+v, want post 21", popular.Posts)
+	}
+
+	latest, err := c.Posts(ctx, socialclient.PostQuery{Type: "latest", Preview: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest.Posts) == 0 {
+		t.Fatal("no latest posts")
+	}
+	for _, p := range latest.Posts {
+		if truncated := p.ID == 31; p.ContentTruncated != truncated {
+			t.Errorf("post %d previewed with ContentTruncated = %v, want %v", p.ID, p.ContentTruncated, truncated)
+		}
+	}
+
+	post, err := c.Post(ctx, 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Post.UserID != 2 || post.Post.Content != "hello" || post.CommentCount != 3 {
+		t.Errorf("post 21 = %+v, want Bob's hello with 3 comments", post)
+	}
+
+	summary, err := c.Summary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Meta.Environment != "live" || len(summary.Users) == 0 || len(summary.PopularPosts) != 1 {
+		t.Errorf("summary = %+v, want the live environment's users and post 21", summary)
+	}
+}
+
+func TestClientPagesThroughSearch(t *testing.T) {
+	url, _ := newService(t)
+	c := newClient(url)
+
+	var found []socialclient.UserPostCount
+	for offset := 0; ; {
+		page, err := c.SearchUsers(context.Background(), socialclient.SearchQuery{Name: "A", Limit: 3, Offset: offset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 4 || page.Limit != 3 || page.Offset != offset {
+			t.Fatalf("page at %d = total %d, limit %d, offset %d, want 4, 3, %d", offset, page.Total, page.Limit, page.Offset, offset)
+		}
+		found = append(found, page.Users...)
+		offset += len(page.Users)
+		if len(page.Users) == 0 || offset >= page.Total {
+			break
+		}
+	}
+	if got := names(found); got != "Alice,Carla,Ava,Dan" && got != "Alice,Carla,Dan,Ava" {
+		t.Errorf("found %s, want Alice and Carla then Dan and Ava", got)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	url, served := newService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		env    string
+		call   func(c *socialclient.Client) error
+		status int
+		want   error
+	}{
+		{"unknown user", "", func(c *socialclient.Client) error {
+			_, err := c.User(ctx, "99")
+			return err
+		}, http.StatusNotFound, socialclient.ErrNotFound},
+		{"unknown post", "", func(c *socialclient.Client) error {
+			_, err := c.Post(ctx, 99)
+			return err
+		}, http.StatusNotFound, socialclient.ErrNotFound},
+		{"unknown post type", "", func(c *socialclient.Client) error {
+			_, err := c.Posts(ctx, socialclient.PostQuery{Type: "oldest"})
+			return err
+		}, http.StatusBadRequest, socialclient.ErrBadRequest},
+		{"search limit too large", "", func(c *socialclient.Client) error {
+			_, err := c.SearchUsers(ctx, socialclient.SearchQuery{Name: "a", Limit: 1000})
+			return err
+		}, http.StatusBadRequest, socialclient.ErrBadRequest},
+		{"unknown environment", "staging", func(c *socialclient.Client) error {
+			_, err := c.TopUsers(ctx, socialclient.TopUsersOptions{})
+			return err
+		}, http.StatusBadRequest, socialclient.ErrBadRequest},
+		{"upstream down", "down", func(c *socialclient.Client) error {
+			_, err := c.User(ctx, "1")
+			return err
+		}, http.StatusServiceUnavailable, socialclient.ErrUnavailable},
+	}
+	for _, tt := range tests {
+		c := newClient(url)
+		c.Environment = tt.env
+		before := atomic.LoadInt64(served)
+
+		err := tt.call(c)
+		var apiErr *socialclient.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Message == "" {
+			t.Errorf("%s: error %v, want a %d with a message", tt.name, err, tt.status)
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: error %v is not %v", tt.name, err, tt.want)
+		}
+
+		// Only the 503 is worth retrying.
+		attempts := int64(1)
+		if tt.status == http.StatusServiceUnavailable {
+			attempts += int64(c.Retries)
+		}
+		if got := atomic.LoadInt64(served) - before; got != attempts {
+			t.Errorf("%s: %d requests, want %d", tt.name, got, attempts)
+		}
+	}
+}
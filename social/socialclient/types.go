@@ -0,0 +1,100 @@
+package socialclient
+
+import "time"
+
+// The types below are the wire format of the analytics API. The server
+// encodes its responses with them, so a client built on this package cannot
+// drift from what is actually served.
+
+type Post struct {
+	ID      int    `json:"id"`
+	UserID  int    `json:"userid"`
+	Content string `json:"content"`
+
+	// Timestamp is when the post was created, if the upstream sent one.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+
+	// ContentTruncated is set on listings requested with full=false.
+	ContentTruncated bool `json:"contentTruncated,omitempty"`
+
+	// DuplicateOf is the ID of the earliest post with the same normalized
+	// content, or zero if this post is the first.
+	DuplicateOf int `json:"duplicateOfPostId,omitempty"`
+}
+
+type UserPostCount struct {
+	UserID   string
+	Name     string
+	Count    int
+	Comments int
+	Rank     int
+
+	// Engagement is the user's weighted score of posts and comments
+	// received, as configured on the server.
+	Engagement float64
+
+	// PreviousRank is the user's post-count rank before the last refresh
+	// and RankChange how many places they moved up since. Both are null for
+	// new entrants and for rankings other than the all-time post count.
+	PreviousRank *int
+	RankChange   *int
+}
+
+// UsersResponse is the body of GET /users. Meta is only set for windowed
+// rankings.
+type UsersResponse struct {
+	Users []UserPostCount `json:"users"`
+	Meta  *WindowMeta     `json:"meta,omitempty"`
+}
+
+type WindowMeta struct {
+	Window                string `json:"window"`
+	PostsWithoutTimestamp int    `json:"postsWithoutTimestamp"`
+}
+
+// SearchUsersResponse is the body of GET /users/search: the page of the
+// Total matches starting at Offset, at most Limit long.
+type SearchUsersResponse struct {
+	Users  []UserPostCount `json:"users"`
+	Total  int             `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+}
+
+// UserResponse is the body of GET /users/:id.
+type UserResponse struct {
+	User UserPostCount `json:"user"`
+}
+
+// PostsResponse is the body of GET /posts. Reason explains an empty or
+// substituted listing, e.g. "no_commented_posts".
+type PostsResponse struct {
+	Posts  []Post `json:"posts"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PostResponse is the body of GET /posts/:id.
+type PostResponse struct {
+	Post         Post `json:"post"`
+	CommentCount int  `json:"commentCount"`
+}
+
+// Summary is the body of GET /summary.
+type Summary struct {
+	Users        []UserPostCount `json:"users"`
+	LatestPosts  []Post          `json:"latestPosts"`
+	PopularPosts []Post          `json:"popularPosts"`
+	Meta         SummaryMeta     `json:"meta"`
+}
+
+type SummaryMeta struct {
+	Environment string    `json:"environment"`
+	LastUpdated time.Time `json:"lastUpdated"`
+	AgeSeconds  float64   `json:"ageSeconds"`
+	Cache       string    `json:"cache"`
+}
+
+// ErrorResponse is the body of every non-2xx response.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
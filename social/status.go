@@ -0,0 +1,82 @@
+package social
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/statuspage"
+)
+
+// cacheHealth is an environment's refresh state as /healthz and the status
+// page report it.
+type cacheHealth struct {
+	LastUpdated       time.Time
+	FailureStreak     int
+	EffectiveInterval time.Duration
+	Paused            bool
+	PausedAt          time.Time
+	Users             int
+	Posts             int
+	LastRefresh       refreshTiming
+}
+
+func (c *Cache) health() cacheHealth {
+	c.RLock()
+	defer c.RUnlock()
+	return cacheHealth{
+		LastUpdated:       c.lastUpdated,
+		FailureStreak:     c.failureStreak,
+		EffectiveInterval: c.effectiveInterval(),
+		Paused:            c.paused,
+		PausedAt:          c.pausedAt,
+		Users:             len(c.users),
+		Posts:             len(c.posts),
+		LastRefresh:       c.lastRefresh,
+	}
+}
+
+// statusVitals returns one status page section per environment. Only
+// counts and timings are shown: no upstream URLs, tokens or cached content.
+func statusVitals(caches map[string]*Cache) func() []statuspage.Section {
+	names := make([]string, 0, len(caches))
+	for name := range caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return func() []statuspage.Section {
+		sections := make([]statuspage.Section, 0, len(names))
+		for _, name := range names {
+			h := caches[name].health()
+			sections = append(sections, statuspage.Section{
+				Title: "Environment " + name,
+				Rows: []statuspage.Row{
+					{Label: "Cache updated", Value: statuspage.Ago(h.LastUpdated)},
+					{Label: "Users", Value: strconv.Itoa(h.Users)},
+					{Label: "Posts", Value: strconv.Itoa(h.Posts)},
+					{Label: "Last refresh", Value: h.refreshResult()},
+					{Label: "Refresh interval", Value: h.EffectiveInterval.String()},
+				},
+			})
+		}
+		return sections
+	}
+}
+
+func (h cacheHealth) refreshResult() string {
+	switch {
+	case h.Paused:
+		return "paused " + statuspage.Ago(h.PausedAt)
+	case h.FailureStreak > 0:
+		return fmt.Sprintf("failing, %d in a row", h.FailureStreak)
+	case h.LastUpdated.IsZero():
+		return "pending"
+	case h.LastRefresh.Total == 0:
+		// Restored from disk; this process hasn't refreshed yet.
+		return "ok"
+	default:
+		return fmt.Sprintf("ok in %v", h.LastRefresh.Total.Round(time.Millisecond))
+	}
+}
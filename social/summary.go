@@ -0,0 +1,54 @@
+package social
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Escanor244/713522IT013/social/socialclient"
+)
+
+const maxSummaryLimit = 50
+
+// getSummary returns the top users, latest posts and popular posts in one
+// response. All three sections are read under a single hold of the read
+// lock so they always describe the same snapshot.
+func getSummary(c *gin.Context) {
+	userLimit, ok := intQuery(c, "users", tuning().topUsersLimit, 1, maxSummaryLimit)
+	if !ok {
+		return
+	}
+	postLimit, ok := intQuery(c, "posts", tuning().latestPostsLimit, 1, maxSummaryLimit)
+	if !ok {
+		return
+	}
+
+	cache := loadCache(c)
+	defer cache.RUnlock()
+
+	opts := defaultRankOptions()
+	opts.limit = userLimit
+	users := cache.rankUsers(opts)
+	cache.annotateRankChanges(users)
+
+	popular, _ := cache.popularPosts()
+	if popular == nil {
+		popular = []Post{}
+	}
+	if len(popular) > postLimit {
+		popular = popular[:postLimit]
+	}
+
+	c.JSON(http.StatusOK, socialclient.Summary{
+		Users:        users,
+		LatestPosts:  cache.latestPosts(postLimit),
+		PopularPosts: popular,
+		Meta: socialclient.SummaryMeta{
+			Environment: cache.name,
+			LastUpdated: cache.lastUpdated,
+			AgeSeconds:  time.Since(cache.lastUpdated).Seconds(),
+			Cache:       c.Writer.Header().Get("X-Cache"),
+		},
+	})
+}
@@ -0,0 +1,126 @@
+package social
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/upstream"
+)
+
+// Endpoint classes used to label upstream metrics.
+const (
+	endpointUsers    = "users"
+	endpointPosts    = "posts"
+	endpointComments = "comments"
+)
+
+// upstreamClient is shared by every environment. Its retry and size
+// settings come from loadConfig and its TLS settings from
+// configureUpstreamTLS.
+var upstreamClient = &upstream.Client{
+	HTTP:    &http.Client{},
+	Observe: observeUpstream,
+}
+
+// configureUpstreamTLS applies the optional TLS settings to the shared
+// upstream client:
+//
+//	UPSTREAM_CA_FILE               PEM bundle trusted in addition to the system roots
+//	UPSTREAM_CLIENT_CERT/_KEY      PEM client certificate and key for mutual TLS
+//	UPSTREAM_INSECURE_SKIP_VERIFY  disables server certificate verification
+func configureUpstreamTLS() error {
+	caFile := settings.Get("UPSTREAM_CA_FILE")
+	certFile := settings.Get("UPSTREAM_CLIENT_CERT")
+	keyFile := settings.Get("UPSTREAM_CLIENT_KEY")
+	insecure := false
+	if raw := settings.Get("UPSTREAM_INSECURE_SKIP_VERIFY"); raw != "" {
+		var err error
+		if insecure, err = strconv.ParseBool(raw); err != nil {
+			return fmt.Errorf("%s must be a boolean, got %q", settings.Name("UPSTREAM_INSECURE_SKIP_VERIFY"), raw)
+		}
+	}
+
+	if caFile == "" && certFile == "" && keyFile == "" && !insecure {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", settings.Name("UPSTREAM_CA_FILE"), err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s %s contains no PEM certificates", settings.Name("UPSTREAM_CA_FILE"), caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return fmt.Errorf("UPSTREAM_CLIENT_CERT and UPSTREAM_CLIENT_KEY must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load upstream client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if insecure {
+		log.Printf("WARNING: UPSTREAM_INSECURE_SKIP_VERIFY is set, upstream TLS certificates will NOT be verified")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	upstreamClient.HTTP.Transport = transport
+	return nil
+}
+
+// getJSON fetches url from the upstream and decodes the JSON body into v,
+// sending token as a bearer credential when set. Every attempt is bounded by
+// the endpoint class's timeout, timed and counted under that class.
+func getJSON(ctx context.Context, endpoint, url, token string, v interface{}) error {
+	return upstreamClient.GetJSON(ctx, upstream.Request{
+		Endpoint: endpoint,
+		URL:      url,
+		Token:    token,
+		Timeout:  timeoutFor(endpoint),
+	}, v)
+}
+
+// getStream is getJSON for bodies too large to buffer: decode consumes the
+// body as it arrives. The endpoint timeout then limits how long any single
+// read may stall rather than the whole call, since decode may be slow to
+// drain the body on purpose.
+func getStream(ctx context.Context, endpoint, url, token string, decode func(io.Reader) error) error {
+	return upstreamClient.Get(ctx, upstream.Request{
+		Endpoint: endpoint,
+		URL:      url,
+		Token:    token,
+		Timeout:  timeoutFor(endpoint),
+		Stream:   true,
+	}, decode)
+}
+
+func timeoutFor(endpoint string) time.Duration {
+	t := tuning()
+	if d, ok := t.upstreamTimeouts[endpoint]; ok {
+		return d
+	}
+	return t.upstreamTimeout
+}
@@ -0,0 +1,208 @@
+package social
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Escanor244/713522IT013/internal/config"
+)
+
+// testCA issues certificates for the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var serials int64
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serials++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serials),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key signed by ca, for the loopback
+// address when server is set and for client authentication otherwise.
+func (ca *testCA) issue(t *testing.T, server bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serials++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serials),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		tmpl.Subject.CommonName = "upstream"
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUpstreamMutualTLS(t *testing.T) {
+	ca := newTestCA(t, "upstream CA")
+	other := newTestCA(t, "other CA")
+
+	serverCert, serverKey := ca.issue(t, true)
+	pair, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := x509.NewCertPool()
+	clients.AddCert(ca.cert)
+
+	u := &fakeUpstream{users: map[string]string{"1": "Ada"}}
+	srv := httptest.NewUnstartedServer(u)
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clients,
+	}
+	// The rejected handshakes are expected.
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := writeFile(t, dir, "ca.pem", ca.pem)
+	certPEM, keyPEM := ca.issue(t, false)
+	certFile := writeFile(t, dir, "client.pem", certPEM)
+	keyFile := writeFile(t, dir, "client-key.pem", keyPEM)
+	otherPEM, otherKeyPEM := other.issue(t, false)
+	otherCertFile := writeFile(t, dir, "other.pem", otherPEM)
+	otherKeyFile := writeFile(t, dir, "other-key.pem", otherKeyPEM)
+
+	previous, transport := settings, upstreamClient.HTTP.Transport
+	t.Cleanup(func() {
+		settings = previous
+		upstreamClient.HTTP.Transport = transport
+	})
+	settings = config.FromEnv()
+
+	tests := []struct {
+		name     string
+		ca       string
+		cert     string
+		key      string
+		insecure string
+		ok       bool
+	}{
+		{"nothing configured", "", "", "", "", false},
+		{"trusted CA without a client certificate", caFile, "", "", "", false},
+		{"client certificate of an untrusted server", "", certFile, keyFile, "", false},
+		{"client certificate from another CA", caFile, otherCertFile, otherKeyFile, "", false},
+		{"trusted CA and client certificate", caFile, certFile, keyFile, "", true},
+		{"client certificate skipping verification", "", certFile, keyFile, "true", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("UPSTREAM_CA_FILE", tt.ca)
+		t.Setenv("UPSTREAM_CLIENT_CERT", tt.cert)
+		t.Setenv("UPSTREAM_CLIENT_KEY", tt.key)
+		t.Setenv("UPSTREAM_INSECURE_SKIP_VERIFY", tt.insecure)
+		upstreamClient.HTTP.Transport = nil
+		if err := configureUpstreamTLS(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		var body struct{ Users map[string]string }
+		err := getJSON(context.Background(), endpointUsers, srv.URL+"/users", "", &body)
+		if tt.ok && (err != nil || body.Users["1"] != "Ada") {
+			t.Errorf("%s: got %v, %v, want the users", tt.name, body.Users, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%s: fetch succeeded, want a TLS failure", tt.name)
+		}
+	}
+}
+
+func TestUpstreamTLSSettingErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := writeFile(t, dir, "ca.txt", []byte("not a certificate"))
+
+	previous, transport := settings, upstreamClient.HTTP.Transport
+	t.Cleanup(func() {
+		settings = previous
+		upstreamClient.HTTP.Transport = transport
+	})
+	settings = config.FromEnv()
+
+	tests := []struct {
+		name, ca, cert, insecure string
+	}{
+		{"missing CA file", filepath.Join(dir, "missing.pem"), "", ""},
+		{"CA file without certificates", notPEM, "", ""},
+		{"certificate without a key", "", notPEM, ""},
+		{"insecure not a boolean", "", "", "sometimes"},
+	}
+	for _, tt := range tests {
+		t.Setenv("UPSTREAM_CA_FILE", tt.ca)
+		t.Setenv("UPSTREAM_CLIENT_CERT", tt.cert)
+		t.Setenv("UPSTREAM_CLIENT_KEY", "")
+		t.Setenv("UPSTREAM_INSECURE_SKIP_VERIFY", tt.insecure)
+		if err := configureUpstreamTLS(); err == nil {
+			t.Errorf("%s: configured, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package social
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// parseRankingWindow parses the window query parameter. An empty value
+// means all time and yields zero.
+func parseRankingWindow(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+
+	window, err := time.ParseDuration(raw)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("Invalid window. Use a positive duration such as '24h'")
+	}
+	if max := tuning().rankingWindowMax; window > max {
+		return 0, fmt.Errorf("Invalid window. The maximum is %v", max)
+	}
+	return window, nil
+}
+
+// withinWindow returns a view of the snapshot holding only posts created at
+// or after since, with post counts recomputed to match. Posts without a
+// timestamp are left out of the view and counted in the second result.
+func (s *snapshot) withinWindow(since time.Time) (*snapshot, int) {
+	view := &snapshot{
+		users:          s.users,
+		userPostCounts: make(map[string]int, len(s.userPostCounts)),
+		posts:          make([]Post, 0),
+		postComments:   s.postComments,
+	}
+
+	untimed := 0
+	for _, post := range s.posts {
+		if post.Timestamp == nil {
+			untimed++
+			continue
+		}
+		if post.Timestamp.Before(since) {
+			continue
+		}
+		view.posts = append(view.posts, post)
+		view.userPostCounts[strconv.Itoa(post.UserID)]++
+	}
+
+	return view, untimed
+}